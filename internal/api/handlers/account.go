@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"log"
+
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var accountService = service.NewAccountService()
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ForgotPassword always answers 202 so it does not reveal which emails exist.
+func ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil || req.Email == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("email is required")
+	}
+	if err := accountService.RequestPasswordReset(req.Email); err != nil {
+		log.Printf("password reset for %q: %v", req.Email, err)
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+func ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	if err := accountService.ResetPassword(req.Token, req.Password); err != nil {
+		return accountError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RequestEmailVerification mails a verification link to the caller.
+func RequestEmailVerification(c *fiber.Ctx) error {
+	email, _ := claimsFromCtx(c)["sub"].(string)
+	if err := accountService.RequestEmailVerification(email); err != nil {
+		return accountError(c, err)
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+func VerifyEmail(c *fiber.Ctx) error {
+	var req VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	if err := accountService.VerifyEmail(req.Token); err != nil {
+		return accountError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func accountError(c *fiber.Ctx, err error) error {
+	switch err {
+	case service.ErrInvalidAccountToken, service.ErrWeakPassword:
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("request failed")
+}
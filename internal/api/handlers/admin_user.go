@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"agodrift/internal/model"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type SetStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// SearchUsers lists users, filtered by ?q= (name or email), ?role= and
+// ?status=, paginated with ?page= and ?per_page=.
+func SearchUsers(c *fiber.Ctx) error {
+	page, err := userService.Search(model.UserSearch{
+		Query:   c.Query("q"),
+		Role:    c.Query("role"),
+		Status:  c.Query("status"),
+		Page:    c.QueryInt("page", 1),
+		PerPage: c.QueryInt("per_page", 0),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list users")
+	}
+	return c.JSON(page)
+}
+
+func GetUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	u, err := userService.Profile(id)
+	if err != nil {
+		return adminUserError(c, err)
+	}
+	return c.JSON(u)
+}
+
+func SetUserRole(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req SetRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	u, err := userService.SetRole(currentUserID(c), id, req.Role)
+	if err != nil {
+		return adminUserError(c, err)
+	}
+	return c.JSON(u)
+}
+
+// SetUserStatus enables or disables an account; disabling signs it out.
+func SetUserStatus(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req SetStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	u, err := userService.SetStatus(currentUserID(c), currentRole(c), id, req.Status)
+	if err != nil {
+		return adminUserError(c, err)
+	}
+	return c.JSON(u)
+}
+
+func ForceLogoutUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	n, err := userService.ForceLogout(currentUserID(c), currentRole(c), id)
+	if err != nil {
+		return adminUserError(c, err)
+	}
+	return c.JSON(fiber.Map{"revoked": n})
+}
+
+func adminUserError(c *fiber.Ctx, err error) error {
+	switch err {
+	case service.ErrUnknownRole, service.ErrInvalidStatus:
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	case service.ErrSelfAction:
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	case service.ErrOutranked:
+		return c.Status(fiber.StatusForbidden).SendString(err.Error())
+	}
+	return userError(c, err)
+}
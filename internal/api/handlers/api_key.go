@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var apiKeyService = service.GetAPIKeys()
+
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+func ListAPIKeys(c *fiber.Ctx) error {
+	keys, err := apiKeyService.List(currentUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list api keys")
+	}
+	return c.JSON(keys)
+}
+
+// CreateAPIKey returns the new key in full; it cannot be retrieved later.
+func CreateAPIKey(c *fiber.Ctx) error {
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	key, err := apiKeyService.Create(currentUserID(c), currentRole(c), req.Name, req.Permissions, req.ExpiresAt)
+	var ve *service.ValidationError
+	if errors.As(err, &ve) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": ve.Problems})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to create api key")
+	}
+	return c.Status(fiber.StatusCreated).JSON(key)
+}
+
+func RevokeAPIKey(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	err = apiKeyService.Revoke(currentUserID(c), id)
+	if err == repository.ErrAPIKeyNotFound {
+		return c.Status(fiber.StatusNotFound).SendString(err.Error())
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to revoke api key")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
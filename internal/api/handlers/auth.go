@@ -7,13 +7,15 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 
 	"agodrift/internal/config"
-	"agodrift/internal/model"
 	"agodrift/internal/service"
 )
 
 // use the shared AuthService singleton
 var authService = service.GetAuth()
 
+// accessTokenTTL is the lifetime of tokens issued by login
+const accessTokenTTL = 30 * time.Minute
+
 // LoginRequest is the body for login
 type LoginRequest struct {
 	Email    string `json:"email"`
@@ -32,12 +34,27 @@ func Login(c *fiber.Ctx) error {
 	if identifier == "" {
 		identifier = req.Username
 	}
+	if wait, err := loginThrottle.Check(identifier, c.IP()); err != nil {
+		return throttled(c, wait, err)
+	}
 	u, ok := authService.Authenticate(identifier, req.Password)
 	if !ok {
+		loginThrottle.Failure(identifier, c.IP())
 		return c.Status(fiber.StatusUnauthorized).SendString("invalid credentials")
 	}
+	// second step required: hand out a challenge token instead of an access token
+	enabled := mfaService.Enabled(u.ID)
+	if enabled || mfaService.Required(u.Role) {
+		challenge, err := mfaService.Challenge(u)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to create token")
+		}
+		// the failure counter is reset by LoginMFA, which shares it
+		return c.JSON(fiber.Map{"mfa_required": true, "enrollment_required": !enabled, "mfa_token": challenge})
+	}
+	loginThrottle.Success(identifier)
 	// create token (30m)
-	token, err := authService.CreateToken(u, 30*time.Minute)
+	token, err := authService.StartSession(u, accessTokenTTL, sessionClient(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to create token")
 	}
@@ -56,24 +73,23 @@ func Logout(c *fiber.Ctx) error {
 			expFloat, _ := claims["exp"].(float64)
 			exp := int64(expFloat)
 			authService.BlacklistToken(jti, exp)
+			uidFloat, _ := claims["uid"].(float64)
+			_ = authService.Sessions().Revoke(int(uidFloat), jti)
 			return c.SendStatus(fiber.StatusOK)
 		}
 	}
 	return c.SendStatus(fiber.StatusBadRequest)
 }
 
+// Me returns the caller's stored profile rather than the token claims.
 func Me(c *fiber.Ctx) error {
-	user := c.Locals("user")
-	if user == nil {
-		return c.SendStatus(fiber.StatusUnauthorized)
-	}
-	if tok, ok := user.(*jwt.Token); ok {
-		if claims, ok := tok.Claims.(jwt.MapClaims); ok {
-			email, _ := claims["sub"].(string)
-			role, _ := claims["role"].(string)
-			uidFloat, _ := claims["uid"].(float64)
-			return c.JSON(model.User{ID: int(uidFloat), Email: email, Role: role})
-		}
-	}
-	return c.SendStatus(fiber.StatusBadRequest)
+	return GetMyProfile(c)
+}
+
+// JWKS publishes the public keys tokens are signed with so other services can
+// verify them without the signing secret.
+func JWKS(c *fiber.Ctx) error {
+	service.InitDefaultAuth(config.Get("JWT_SECRET", "changeme"))
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(authService.JWKS())
 }
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"time"
 
+	"agodrift/internal/model"
+	"agodrift/internal/refcode"
 	"agodrift/internal/repository"
 	"agodrift/internal/service"
 
@@ -19,6 +23,14 @@ type CreateBookingRequest struct {
 	Adults   int    `json:"adults"`
 	Children int    `json:"children"`
 	Rooms    int    `json:"rooms"`
+	Currency string `json:"currency"` // optional display currency for the total
+	// optional promo codes; non-stackable codes must be used alone
+	PromoCodes []string `json:"promo_codes"`
+	// optional loyalty points to pay with, one cent of USD each
+	RedeemPoints int `json:"redeem_points"`
+	// lead guest (defaults to the account holder), named guests, special
+	// requests and estimated arrival time
+	model.GuestDetails
 }
 
 func CreateBooking(c *fiber.Ctx) error {
@@ -65,37 +77,230 @@ func CreateBooking(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).SendString("check_out must be after check_in")
 	}
 
-	b, err := bookingService.Create(uid, req.HotelID, checkIn, checkOut, req.Adults, req.Children, req.Rooms)
+	b, err := bookingService.Create(model.Booking{
+		UserID:          uid,
+		HotelID:         req.HotelID,
+		CheckIn:         checkIn,
+		CheckOut:        checkOut,
+		Adults:          req.Adults,
+		Children:        req.Children,
+		Rooms:           req.Rooms,
+		DisplayCurrency: req.Currency,
+		PointsRedeemed:  req.RedeemPoints,
+		GuestDetails:    req.GuestDetails,
+	}, req.PromoCodes)
 	if err != nil {
-		if err == repository.ErrNotEnoughRooms {
+		var ve *service.ValidationError
+		if errors.As(err, &ve) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": ve.Problems})
+		}
+		switch err {
+		case repository.ErrNotEnoughRooms:
 			return c.Status(fiber.StatusConflict).SendString("not enough rooms available")
+		case repository.ErrHotelCurrencyChanged:
+			return c.Status(fiber.StatusConflict).SendString("hotel pricing changed, please retry")
+		case repository.ErrPromoUsedUp, repository.ErrNotEnoughPoints:
+			return c.Status(fiber.StatusConflict).SendString(err.Error())
+		case repository.ErrPointsExceedTotal:
+			return c.Status(fiber.StatusConflict).SendString("hotel pricing changed, please retry")
+		case service.ErrHotelNotFound, sql.ErrNoRows:
+			return c.Status(fiber.StatusNotFound).SendString("hotel not found")
+		case service.ErrInvalidCurrency, service.ErrNoExchangeRate:
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 		}
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to create booking")
 	}
 	return c.Status(fiber.StatusCreated).JSON(b)
 }
 
-func ListMyBookings(c *fiber.Ctx) error {
-	user := c.Locals("user")
-	if user == nil {
-		return c.SendStatus(fiber.StatusUnauthorized)
+// ModifyBookingRequest changes only the fields that are present.
+type ModifyBookingRequest struct {
+	CheckIn  *string `json:"check_in"`
+	CheckOut *string `json:"check_out"`
+	Adults   *int    `json:"adults"`
+	Children *int    `json:"children"`
+	Rooms    *int    `json:"rooms"`
+}
+
+// ModifyBooking changes a booking's dates, guests or rooms and returns the
+// repriced booking with the change, whose price_delta_cents is how much the
+// price went up (positive) or down (negative). Paid bookings get 409.
+func ModifyBooking(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
 	}
-	var uid int
-	if tok, ok := user.(*jwt.Token); ok {
-		if claims, ok := tok.Claims.(jwt.MapClaims); ok {
-			uidFloat, ok := claims["uid"].(float64)
-			if ok {
-				uid = int(uidFloat)
-			}
+	var req ModifyBookingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	u := service.BookingUpdate{Adults: req.Adults, Children: req.Children, Rooms: req.Rooms}
+	if req.CheckIn != nil {
+		d, err := time.Parse("2006-01-02", *req.CheckIn)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("invalid check_in")
 		}
+		u.CheckIn = &d
 	}
-	if uid == 0 {
-		return c.SendStatus(fiber.StatusUnauthorized)
+	if req.CheckOut != nil {
+		d, err := time.Parse("2006-01-02", *req.CheckOut)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("invalid check_out")
+		}
+		u.CheckOut = &d
 	}
 
-	list, err := bookingService.ListByUserID(uid)
+	b, change, err := bookingService.Modify(currentUserID(c), currentRole(c), id, u)
+	if err != nil {
+		var ve *service.ValidationError
+		if errors.As(err, &ve) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": ve.Problems})
+		}
+		switch err {
+		case repository.ErrBookingNotFound:
+			return c.Status(fiber.StatusNotFound).SendString("booking not found")
+		case service.ErrForbidden:
+			return c.Status(fiber.StatusForbidden).SendString("forbidden")
+		case repository.ErrBookingCancelled, service.ErrBookingStarted, service.ErrBookingPaid, repository.ErrPointsExceedTotal:
+			return c.Status(fiber.StatusConflict).SendString(err.Error())
+		case repository.ErrNotEnoughRooms:
+			return c.Status(fiber.StatusConflict).SendString("not enough rooms available")
+		case repository.ErrHotelCurrencyChanged:
+			return c.Status(fiber.StatusConflict).SendString("hotel pricing changed, please retry")
+		case service.ErrNoExchangeRate:
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to modify booking")
+	}
+	return c.JSON(fiber.Map{"booking": b, "change": change})
+}
+
+// UpdateBookingGuests replaces the guest details of a booking.
+func UpdateBookingGuests(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req model.GuestDetails
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	b, err := bookingService.UpdateGuestDetails(currentUserID(c), currentRole(c), id, req)
+	if err != nil {
+		var ve *service.ValidationError
+		if errors.As(err, &ve) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": ve.Problems})
+		}
+		switch err {
+		case repository.ErrBookingNotFound:
+			return c.Status(fiber.StatusNotFound).SendString("booking not found")
+		case service.ErrForbidden:
+			return c.Status(fiber.StatusForbidden).SendString("forbidden")
+		case repository.ErrBookingCancelled:
+			return c.Status(fiber.StatusConflict).SendString(err.Error())
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to update guests")
+	}
+	return c.JSON(b)
+}
+
+// ListMyBookings lists the caller's bookings with their hotels, filtered by
+// ?filter=upcoming|past|cancelled and paginated with ?page= and ?per_page=.
+func ListMyBookings(c *fiber.Ctx) error {
+	page, err := bookingService.ListMine(model.BookingSearch{
+		UserID:  currentUserID(c),
+		Filter:  c.Query("filter"),
+		Page:    c.QueryInt("page", 1),
+		PerPage: c.QueryInt("per_page", 0),
+	})
+	if err == service.ErrInvalidBookingFilter {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("failed to list bookings")
 	}
-	return c.JSON(list)
+	return c.JSON(page)
+}
+
+type LookupBookingRequest struct {
+	Reference string `json:"reference"`
+	LastName  string `json:"last_name"`
+}
+
+// LookupBooking lets a guest without an account session find a booking by
+// reference code and last name. Misses are counted for the code and the
+// client IP by their own throttle, so codes can't be guessed without
+// touching login lockouts.
+func LookupBooking(c *fiber.Ctx) error {
+	var req LookupBookingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	if req.Reference == "" || req.LastName == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("reference and last_name required")
+	}
+	subject := refcode.Normalize(req.Reference)
+	if wait, err := lookupThrottle.Check(subject, c.IP()); err != nil {
+		return throttled(c, wait, err)
+	}
+	b, err := bookingService.Lookup(req.Reference, req.LastName)
+	if err == repository.ErrBookingNotFound {
+		lookupThrottle.Failure(subject, c.IP())
+		return c.Status(fiber.StatusNotFound).SendString("booking not found")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to look up booking")
+	}
+	lookupThrottle.Success(subject)
+	return c.JSON(b)
+}
+
+// GetBooking returns one booking with its hotel, price breakdown and status
+// history.
+func GetBooking(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	b, err := bookingService.Get(currentUserID(c), currentRole(c), id)
+	if err != nil {
+		switch err {
+		case repository.ErrBookingNotFound:
+			return c.Status(fiber.StatusNotFound).SendString("booking not found")
+		case service.ErrForbidden:
+			return c.Status(fiber.StatusForbidden).SendString("forbidden")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load booking")
+	}
+	return c.JSON(b)
+}
+
+// CancelBookingResponse is the cancelled booking with what the cancellation
+// cost, so existing clients reading the booking keep working.
+type CancelBookingResponse struct {
+	model.Booking
+	Cancellation model.CancellationCharge `json:"cancellation"`
+}
+
+// CancelBooking cancels one of the caller's bookings and releases its rooms.
+// The response holds the booking and the penalty and refund under its
+// cancellation policy.
+func CancelBooking(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	b, charge, err := bookingService.Cancel(currentUserID(c), currentRole(c), id)
+	if err != nil {
+		switch err {
+		case repository.ErrBookingNotFound:
+			return c.Status(fiber.StatusNotFound).SendString("booking not found")
+		case service.ErrForbidden:
+			return c.Status(fiber.StatusForbidden).SendString("forbidden")
+		case repository.ErrBookingCancelled, repository.ErrBookingStarted:
+			return c.Status(fiber.StatusConflict).SendString(err.Error())
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to cancel booking")
+	}
+	return c.JSON(CancelBookingResponse{Booking: b, Cancellation: charge})
 }
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var cancellationService = service.NewCancellationService()
+
+type HotelPolicyRequest struct {
+	Code string `json:"code"`
+}
+
+// ListCancellationPolicies returns every policy hotels can pick.
+func ListCancellationPolicies(c *fiber.Ctx) error {
+	list, err := cancellationService.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list policies")
+	}
+	return c.JSON(list)
+}
+
+// PutCancellationPolicy creates or replaces a policy. Existing bookings keep
+// the terms they were made under.
+func PutCancellationPolicy(c *fiber.Ctx) error {
+	var p model.CancellationPolicy
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	p.Code = c.Params("code")
+	saved, err := cancellationService.Put(p)
+	if err != nil {
+		return policyError(c, err)
+	}
+	return c.JSON(saved)
+}
+
+// SetHotelCancellationPolicy picks the policy for new bookings at a hotel.
+func SetHotelCancellationPolicy(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req HotelPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	p, err := cancellationService.SetHotelPolicy(currentUserID(c), currentRole(c), id, req.Code)
+	if err != nil {
+		return policyError(c, err)
+	}
+	return c.JSON(p)
+}
+
+func policyError(c *fiber.Ctx, err error) error {
+	var ve *service.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": ve.Problems})
+	case err == repository.ErrPolicyNotFound:
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	case err == service.ErrHotelNotFound, err == sql.ErrNoRows:
+		return c.Status(fiber.StatusNotFound).SendString("not found")
+	case err == service.ErrForbidden:
+		return c.Status(fiber.StatusForbidden).SendString("forbidden")
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("failed to save policy")
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// claimsFromCtx returns the claims of the token validated by the JWT middleware.
+func claimsFromCtx(c *fiber.Ctx) jwt.MapClaims {
+	if tok, ok := c.Locals("user").(*jwt.Token); ok {
+		if claims, ok := tok.Claims.(jwt.MapClaims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// currentUserID returns the uid claim of the caller, or 0 when unauthenticated.
+func currentUserID(c *fiber.Ctx) int {
+	uidFloat, _ := claimsFromCtx(c)["uid"].(float64)
+	return int(uidFloat)
+}
+
+// currentRole returns the role claim of the caller.
+func currentRole(c *fiber.Ctx) string {
+	role, _ := claimsFromCtx(c)["role"].(string)
+	return role
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+
+	"agodrift/internal/model"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var currencyService = service.NewCurrencyService()
+
+func ListExchangeRates(c *fiber.Ctx) error {
+	list, err := currencyService.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list exchange rates")
+	}
+	return c.JSON(list)
+}
+
+// PutExchangeRates creates or updates rates from a JSON array body.
+func PutExchangeRates(c *fiber.Ctx) error {
+	var rates []model.ExchangeRate
+	if err := c.BodyParser(&rates); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	if err := currencyService.SetRates(rates); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	return c.JSON(rates)
+}
+
+// ImportExchangeRates creates or updates rates from an uploaded CSV
+// (base,quote,rate) or JSON file body. Rates not in the file are kept.
+func ImportExchangeRates(c *fiber.Ctx) error {
+	format := c.Query("format")
+	if format == "" {
+		format = service.FormatJSON
+		if strings.Contains(string(c.Request().Header.ContentType()), "csv") {
+			format = service.FormatCSV
+		}
+	}
+	n, err := currencyService.ImportRates(format, bytes.NewReader(c.Body()))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	return c.JSON(fiber.Map{"imported": n})
+}
+
+func DeleteExchangeRate(c *fiber.Ctx) error {
+	if err := currencyService.DeleteRate(c.Params("base"), c.Params("quote")); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to delete exchange rate")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"agodrift/internal/invoice"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var invoiceService = service.NewInvoiceService()
+
+// GetBookingInvoice returns the booking's invoice, issued on first request
+// once the booking is confirmed, as JSON, or with ?format=html or
+// ?format=pdf as a document to download.
+func GetBookingInvoice(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	inv, err := invoiceService.Invoice(currentUserID(c), currentRole(c), id)
+	if err != nil {
+		return invoiceError(c, err, "invoice")
+	}
+	return sendInvoice(c, inv)
+}
+
+// GetBookingCreditNote returns the credit note issued when the booking was
+// cancelled with a refund, in the same formats as the invoice.
+func GetBookingCreditNote(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	inv, err := invoiceService.CreditNote(currentUserID(c), currentRole(c), id)
+	if err != nil {
+		return invoiceError(c, err, "credit note")
+	}
+	return sendInvoice(c, inv)
+}
+
+func sendInvoice(c *fiber.Ctx, inv model.Invoice) error {
+	switch c.Query("format", "json") {
+	case "json":
+		return c.JSON(inv)
+	case "html":
+		body, err := invoice.HTML(inv)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to render invoice")
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		c.Set(fiber.HeaderContentDisposition, `inline; filename="`+inv.Number+`.html"`)
+		return c.Send(body)
+	case "pdf":
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+inv.Number+`.pdf"`)
+		return c.Send(invoice.PDF(inv))
+	}
+	return c.Status(fiber.StatusBadRequest).SendString("format must be json, html or pdf")
+}
+
+func invoiceError(c *fiber.Ctx, err error, what string) error {
+	switch err {
+	case repository.ErrBookingNotFound:
+		return c.Status(fiber.StatusNotFound).SendString("booking not found")
+	case repository.ErrInvoiceNotFound:
+		return c.Status(fiber.StatusNotFound).SendString("no " + what + " for this booking")
+	case service.ErrForbidden:
+		return c.Status(fiber.StatusForbidden).SendString("forbidden")
+	case service.ErrNotInvoiceable:
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("failed to load " + what)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var loginThrottle = service.NewLoginThrottle()
+
+// lookupThrottle counts guest booking lookup misses apart from logins.
+var lookupThrottle = service.NewLookupThrottle()
+
+// UnlockRequest names the account (email) or client IP to unlock.
+type UnlockRequest struct {
+	Email string `json:"email"`
+	IP    string `json:"ip"`
+}
+
+// throttled answers a blocked login with 429 and a Retry-After header.
+func throttled(c *fiber.Ctx, wait time.Duration, err error) error {
+	secs := int(math.Ceil(wait.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(secs))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       err.Error(),
+		"locked":      err == service.ErrLoginLocked,
+		"retry_after": secs,
+	})
+}
+
+// ListLockouts returns recent lockouts, newest first (?limit=, default 50).
+func ListLockouts(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	list, err := loginThrottle.Lockouts(limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list lockouts")
+	}
+	if list == nil {
+		list = []model.LoginLockout{}
+	}
+	return c.JSON(list)
+}
+
+// UnlockLogin clears the failure counter of an account or IP. An IP is
+// unlocked for booking lookups too.
+func UnlockLogin(c *fiber.Ctx) error {
+	var req UnlockRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	scope, subject := model.ThrottleScopeAccount, req.Email
+	if subject == "" {
+		scope, subject = model.ThrottleScopeIP, req.IP
+	}
+	if subject == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("email or ip is required")
+	}
+	if err := loginThrottle.Unlock(scope, subject, currentUserID(c)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to unlock")
+	}
+	if scope == model.ThrottleScopeIP {
+		if err := lookupThrottle.Unlock(model.ThrottleScopeLookupIP, subject, currentUserID(c)); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to unlock")
+		}
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var loyaltyService = service.NewLoyaltyService()
+
+// GetMyLoyalty returns the caller's points balance and tier.
+func GetMyLoyalty(c *fiber.Ctx) error {
+	a, err := loyaltyService.Account(currentUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load loyalty account")
+	}
+	return c.JSON(a)
+}
+
+// ListMyLoyaltyTransactions returns the caller's points ledger, newest
+// first, paginated with ?page= and ?per_page=.
+func ListMyLoyaltyTransactions(c *fiber.Ctx) error {
+	page, err := loyaltyService.History(currentUserID(c), c.QueryInt("page", 1), c.QueryInt("per_page", 0))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list loyalty transactions")
+	}
+	return c.JSON(page)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"agodrift/internal/model"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var mfaService = service.NewMFAService()
+
+// MFALoginRequest is the body for the second login step
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"` // TOTP code or recovery code
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// LoginMFA completes a two-step login with a TOTP or recovery code.
+func LoginMFA(c *fiber.Ctx) error {
+	var req MFALoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	u, jti, exp, err := authService.ParseChallengeToken(req.MFAToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+	}
+	// codes are guessable too, so they share the password's failure counters
+	if wait, err := loginThrottle.Check(u.Email, c.IP()); err != nil {
+		return throttled(c, wait, err)
+	}
+	if err := mfaService.Verify(u.ID, req.Code); err != nil {
+		if err == service.ErrInvalidMFACode {
+			loginThrottle.Failure(u.Email, c.IP())
+		}
+		return mfaError(c, err)
+	}
+	loginThrottle.Success(u.Email)
+	authService.BlacklistToken(jti, exp)
+	token, err := authService.StartSession(u, accessTokenTTL, sessionClient(c))
+	if err == service.ErrAccountDisabled {
+		return c.Status(fiber.StatusForbidden).SendString(err.Error())
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to create token")
+	}
+	return c.JSON(fiber.Map{"token": token})
+}
+
+// LoginMFAEnroll starts the enrollment forced by policy during login.
+func LoginMFAEnroll(c *fiber.Ctx) error {
+	var req MFALoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	u, _, _, err := authService.ParseChallengeToken(req.MFAToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+	}
+	return enroll(c, u)
+}
+
+// LoginMFAActivate finishes forced enrollment and logs the user in.
+func LoginMFAActivate(c *fiber.Ctx) error {
+	var req MFALoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	u, jti, exp, err := authService.ParseChallengeToken(req.MFAToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+	}
+	codes, err := mfaService.Activate(u.ID, req.Code)
+	if err != nil {
+		return mfaError(c, err)
+	}
+	loginThrottle.Success(u.Email)
+	authService.BlacklistToken(jti, exp)
+	token, err := authService.StartSession(u, accessTokenTTL, sessionClient(c))
+	if err == service.ErrAccountDisabled {
+		return c.Status(fiber.StatusForbidden).SendString(err.Error())
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to create token")
+	}
+	return c.JSON(fiber.Map{"token": token, "recovery_codes": codes})
+}
+
+// EnrollMFA starts optional enrollment for a logged-in user.
+func EnrollMFA(c *fiber.Ctx) error {
+	email, _ := claimsFromCtx(c)["sub"].(string)
+	return enroll(c, model.User{ID: currentUserID(c), Email: email, Role: currentRole(c)})
+}
+
+// ActivateMFA verifies the first code and returns the recovery codes.
+func ActivateMFA(c *fiber.Ctx) error {
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	codes, err := mfaService.Activate(currentUserID(c), req.Code)
+	if err != nil {
+		return mfaError(c, err)
+	}
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+func DisableMFA(c *fiber.Ctx) error {
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	if err := mfaService.Disable(model.User{ID: currentUserID(c), Role: currentRole(c)}, req.Code); err != nil {
+		return mfaError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func enroll(c *fiber.Ctx, u model.User) error {
+	secret, uri, err := mfaService.Enroll(u)
+	if err != nil {
+		return mfaError(c, err)
+	}
+	return c.JSON(fiber.Map{"secret": secret, "provisioning_uri": uri})
+}
+
+func mfaError(c *fiber.Ctx, err error) error {
+	switch err {
+	case service.ErrInvalidMFACode:
+		return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+	case service.ErrMFANotEnrolled, service.ErrMFAAlreadyEnabled:
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	case service.ErrMFARequired:
+		return c.Status(fiber.StatusForbidden).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("two-factor authentication failed")
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+
+	"agodrift/internal/config"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var oidcService = service.NewOIDCService()
+
+func ListOIDCProviders(c *fiber.Ctx) error {
+	return c.JSON(oidcService.Providers())
+}
+
+// OIDCLogin redirects to the provider's login page. With ?mode=json the URL
+// is returned instead so single-page apps can navigate themselves.
+func OIDCLogin(c *fiber.Ctx) error {
+	authURL, err := oidcService.AuthURL(c.Params("provider"))
+	if err != nil {
+		if err == service.ErrUnknownProvider {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		return c.Status(fiber.StatusBadGateway).SendString("identity provider unavailable")
+	}
+	if c.Query("mode") == "json" {
+		return c.JSON(fiber.Map{"url": authURL})
+	}
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// OIDCCallback finishes the login and returns our token, or the two-factor
+// challenge as Login does. When OIDC_SUCCESS_REDIRECT is set the browser is
+// sent there with them in the URL fragment instead.
+func OIDCCallback(c *fiber.Ctx) error {
+	if e := c.Query("error"); e != "" {
+		return c.Status(fiber.StatusUnauthorized).SendString("login cancelled: " + e)
+	}
+	res, err := oidcService.Callback(c.Params("provider"), c.Query("state"), c.Query("code"), sessionClient(c))
+	if err != nil {
+		switch err {
+		case service.ErrInvalidState, service.ErrEmailNotVerified:
+			return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+		case service.ErrAccountDisabled:
+			return c.Status(fiber.StatusForbidden).SendString(err.Error())
+		}
+		return c.Status(fiber.StatusUnauthorized).SendString("login failed")
+	}
+	target := config.Get("OIDC_SUCCESS_REDIRECT", "")
+	if res.MFAToken != "" {
+		if target != "" {
+			return c.Redirect(target+"#mfa_token="+url.QueryEscape(res.MFAToken)+"&enrollment_required="+strconv.FormatBool(res.EnrollmentRequired), fiber.StatusFound)
+		}
+		return c.JSON(fiber.Map{"mfa_required": true, "enrollment_required": res.EnrollmentRequired, "mfa_token": res.MFAToken})
+	}
+	if target != "" {
+		return c.Redirect(target+"#token="+url.QueryEscape(res.Token), fiber.StatusFound)
+	}
+	return c.JSON(fiber.Map{"token": res.Token})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type InventoryRequest struct {
+	RoomsTotal     int `json:"rooms_total"`
+	RoomsAvailable int `json:"rooms_available"`
+}
+
+type AssignOwnerRequest struct {
+	UserID int `json:"user_id"`
+}
+
+// ListPartnerHotels returns the hotels linked to the caller.
+func ListPartnerHotels(c *fiber.Ctx) error {
+	list, err := roomService.ListOwned(currentUserID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list hotels")
+	}
+	return c.JSON(list)
+}
+
+func UpdateHotelHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req service.HotelUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	rm, err := roomService.Update(currentUserID(c), currentRole(c), id, req)
+	if err != nil {
+		return hotelError(c, err)
+	}
+	return c.JSON(rm)
+}
+
+func SetInventoryHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req InventoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	rm, err := roomService.SetInventory(currentUserID(c), currentRole(c), id, req.RoomsTotal, req.RoomsAvailable)
+	if err != nil {
+		return hotelError(c, err)
+	}
+	return c.JSON(rm)
+}
+
+// ListPartnerBookings returns bookings at the caller's hotels, optionally
+// narrowed with ?hotel_id=.
+func ListPartnerBookings(c *fiber.Ctx) error {
+	list, err := bookingService.ListForHotels(currentUserID(c), currentRole(c), c.QueryInt("hotel_id"))
+	if err != nil {
+		return hotelError(c, err)
+	}
+	return c.JSON(list)
+}
+
+func AssignHotelOwner(c *fiber.Ctx) error {
+	hotelID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req AssignOwnerRequest
+	if err := c.BodyParser(&req); err != nil || req.UserID == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("user_id required")
+	}
+	if err := roomService.AssignOwner(req.UserID, hotelID); err != nil {
+		return hotelError(c, err)
+	}
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+func RemoveHotelOwner(c *fiber.Ctx) error {
+	hotelID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	userID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid user id")
+	}
+	if err := roomService.RemoveOwner(userID, hotelID); err != nil {
+		return hotelError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func hotelError(c *fiber.Ctx, err error) error {
+	var ve *service.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": ve.Problems})
+	case err == service.ErrHotelNotFound:
+		return c.Status(fiber.StatusNotFound).SendString("not found")
+	case err == service.ErrForbidden:
+		return c.Status(fiber.StatusForbidden).SendString("forbidden")
+	case err == service.ErrInvalidInventory:
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("failed to update hotel")
+}
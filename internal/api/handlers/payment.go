@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"agodrift/internal/model"
+	"agodrift/internal/payment"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var paymentService = service.NewPaymentService()
+
+type PayRequest struct {
+	PaymentToken string `json:"payment_token"` // from the gateway's client-side SDK
+}
+
+type RefundRequest struct {
+	AmountCents int `json:"amount_cents"` // 0 refunds everything not yet refunded
+}
+
+// PayBooking authorizes the booking total. The booking is confirmed when the
+// payment is authorized; a pending payment is settled by a gateway webhook.
+func PayBooking(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req PayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	p, err := paymentService.Pay(currentUserID(c), id, req.PaymentToken)
+	switch err {
+	case nil:
+		if p.Status == model.PaymentPending {
+			return c.Status(fiber.StatusAccepted).JSON(p)
+		}
+		return c.Status(fiber.StatusCreated).JSON(p)
+	case service.ErrPaymentDeclined:
+		return c.Status(fiber.StatusPaymentRequired).JSON(p)
+	}
+	return paymentError(c, err)
+}
+
+func ListBookingPayments(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	list, err := paymentService.ListForBooking(currentUserID(c), currentRole(c), id)
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(list)
+}
+
+func CapturePayment(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	p, err := paymentService.Capture(id)
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(p)
+}
+
+func VoidPayment(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	p, err := paymentService.Void(id)
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(p)
+}
+
+func RefundPayment(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req RefundRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+		}
+	}
+	p, err := paymentService.Refund(id, req.AmountCents)
+	if err != nil {
+		return paymentError(c, err)
+	}
+	return c.JSON(p)
+}
+
+// PaymentWebhook receives asynchronous results from the gateway, signed in
+// the X-Payment-Signature header.
+func PaymentWebhook(c *fiber.Ctx) error {
+	err := paymentService.HandleWebhook(c.Body(), c.Get("X-Payment-Signature"))
+	switch err {
+	case nil:
+		return c.SendStatus(fiber.StatusNoContent)
+	case payment.ErrInvalidSignature:
+		return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+	case repository.ErrPaymentNotFound:
+		return c.Status(fiber.StatusNotFound).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("failed to process webhook")
+}
+
+func paymentError(c *fiber.Ctx, err error) error {
+	switch err {
+	case repository.ErrBookingNotFound, repository.ErrPaymentNotFound:
+		return c.Status(fiber.StatusNotFound).SendString(err.Error())
+	case service.ErrForbidden:
+		return c.Status(fiber.StatusForbidden).SendString("forbidden")
+	case service.ErrPaymentTokenRequired, service.ErrRefundAmount:
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	case service.ErrBookingNotPayable, service.ErrAlreadyPaid, service.ErrPaymentState:
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	case service.ErrGatewayUnavailable:
+		return c.Status(fiber.StatusBadGateway).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("payment request failed")
+}
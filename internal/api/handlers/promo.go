@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var promoService = service.NewPromoService()
+
+// ListPromoCodes returns every promo code, live or not.
+func ListPromoCodes(c *fiber.Ctx) error {
+	list, err := promoService.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list promo codes")
+	}
+	return c.JSON(list)
+}
+
+func CreatePromoCode(c *fiber.Ctx) error {
+	var p model.PromoCode
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	saved, err := promoService.Create(p)
+	if err != nil {
+		return promoError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(saved)
+}
+
+// UpdatePromoCode replaces a promo code. Bookings already made keep their
+// discount; set active to false to withdraw a code.
+func UpdatePromoCode(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var p model.PromoCode
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	saved, err := promoService.Update(id, p)
+	if err != nil {
+		return promoError(c, err)
+	}
+	return c.JSON(saved)
+}
+
+func promoError(c *fiber.Ctx, err error) error {
+	var ve *service.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": ve.Problems})
+	case err == repository.ErrPromoNotFound:
+		return c.Status(fiber.StatusNotFound).SendString("promo code not found")
+	case err == repository.ErrPromoCodeTaken:
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("failed to save promo code")
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"strconv"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RoleAssignmentRequest struct {
+	Role string `json:"role"`
+}
+
+func ListPermissions(c *fiber.Ctx) error {
+	return c.JSON(model.KnownPermissions)
+}
+
+func ListRoles(c *fiber.Ctx) error {
+	list, err := service.GetRBAC().ListRoles()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list roles")
+	}
+	return c.JSON(list)
+}
+
+// PutRole creates or replaces the custom role named in the path; built-in
+// roles are refused with 409.
+func PutRole(c *fiber.Ctx) error {
+	var role model.Role
+	if err := c.BodyParser(&role); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	role.Name = c.Params("name")
+	saved, err := service.GetRBAC().SaveRole(role)
+	if err != nil {
+		return rbacError(c, err)
+	}
+	return c.JSON(saved)
+}
+
+func DeleteRole(c *fiber.Ctx) error {
+	if err := service.GetRBAC().DeleteRole(c.Params("name")); err != nil {
+		return rbacError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func ListUserRoles(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	roles, err := service.GetRBAC().UserRoles(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list roles")
+	}
+	return c.JSON(roles)
+}
+
+func AssignUserRole(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req RoleAssignmentRequest
+	if err := c.BodyParser(&req); err != nil || req.Role == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("role required")
+	}
+	if err := service.GetRBAC().AssignRole(userID, req.Role); err != nil {
+		return rbacError(c, err)
+	}
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+func RevokeUserRole(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	if err := service.GetRBAC().RevokeRole(userID, c.Params("role")); err != nil {
+		return rbacError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func rbacError(c *fiber.Ctx, err error) error {
+	switch err {
+	case repository.ErrRoleNotFound:
+		return c.Status(fiber.StatusNotFound).SendString("role not found")
+	case service.ErrInvalidRole, service.ErrInvalidPermission:
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	case service.ErrBuiltInRole:
+		return c.Status(fiber.StatusConflict).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("failed to update roles")
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"strconv"
+
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var reviewService = service.NewReviewService()
+
+type CreateReviewRequest struct {
+	Rating int    `json:"rating"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type ModerateReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ReviewReplyRequest struct {
+	Body string `json:"body"`
+}
+
+func CreateReview(c *fiber.Ctx) error {
+	uid := currentUserID(c)
+	if uid == 0 {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	hotelID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	if _, found := roomService.Get(hotelID); !found {
+		return c.Status(fiber.StatusNotFound).SendString("not found")
+	}
+	var req CreateReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	rv, err := reviewService.Submit(uid, hotelID, req.Rating, req.Title, req.Body)
+	if err != nil {
+		if err == service.ErrInvalidReview {
+			return c.Status(fiber.StatusBadRequest).SendString("rating must be 1-5 and body is required")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to create review")
+	}
+	return c.Status(fiber.StatusCreated).JSON(rv)
+}
+
+func ListHotelReviews(c *fiber.Ctx) error {
+	hotelID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	list, err := reviewService.ListForHotel(hotelID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list reviews")
+	}
+	return c.JSON(list)
+}
+
+func ReviewQueue(c *fiber.Ctx) error {
+	list, err := reviewService.Queue(c.Query("status"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list reviews")
+	}
+	return c.JSON(list)
+}
+
+func ApproveReview(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	if err := reviewService.Approve(id, currentUserID(c)); err != nil {
+		return reviewError(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func RejectReview(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req ModerateReviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	if err := reviewService.Reject(id, currentUserID(c), req.Reason); err != nil {
+		if err == service.ErrInvalidReview {
+			return c.Status(fiber.StatusBadRequest).SendString("reason required")
+		}
+		return reviewError(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func ReplyToReview(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	var req ReviewReplyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	reply, err := reviewService.Reply(id, currentUserID(c), currentRole(c), req.Body)
+	if err != nil {
+		if err == service.ErrInvalidReview {
+			return c.Status(fiber.StatusBadRequest).SendString("body required")
+		}
+		return reviewError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(reply)
+}
+
+func reviewError(c *fiber.Ctx, err error) error {
+	switch err {
+	case repository.ErrReviewNotFound:
+		return c.Status(fiber.StatusNotFound).SendString("not found")
+	case repository.ErrReplyExists:
+		return c.Status(fiber.StatusConflict).SendString("review already has a reply")
+	case service.ErrForbidden:
+		return c.Status(fiber.StatusForbidden).SendString("forbidden")
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("failed to update review")
+}
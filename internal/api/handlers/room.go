@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"strconv"
+	"strings"
+	"time"
 
 	"agodrift/internal/model"
 	"agodrift/internal/service"
@@ -16,7 +20,11 @@ func Health(c *fiber.Ctx) error {
 }
 
 func ListRoomsHandler(c *fiber.Ctx) error {
-	return c.JSON(roomService.List())
+	rooms := roomService.List()
+	if err := currencyService.ConvertRooms(rooms, c.Query("currency")); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	return c.JSON(rooms)
 }
 
 func AddRoomHandler(c *fiber.Ctx) error {
@@ -38,5 +46,96 @@ func RoomByIDHandler(c *fiber.Ctx) error {
 	if !found {
 		return c.Status(fiber.StatusNotFound).SendString("not found")
 	}
+	if err := currencyService.ConvertRoom(&r, c.Query("currency")); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
 	return c.JSON(r)
 }
+
+// QuoteHandler prices a stay: ?check_in=YYYY-MM-DD&check_out=YYYY-MM-DD&rooms=1&currency=THB,
+// less any comma-separated ?promo=CODE1,CODE2.
+func QuoteHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid id")
+	}
+	checkIn, err := time.Parse("2006-01-02", c.Query("check_in"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid check_in")
+	}
+	checkOut, err := time.Parse("2006-01-02", c.Query("check_out"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid check_out")
+	}
+	if !checkOut.After(checkIn) {
+		return c.Status(fiber.StatusBadRequest).SendString("check_out must be after check_in")
+	}
+	rooms := c.QueryInt("rooms", 1)
+	if rooms <= 0 {
+		rooms = 1
+	}
+	var promos []string
+	if p := c.Query("promo"); p != "" {
+		promos = strings.Split(p, ",")
+	}
+	q, err := bookingService.Quote(id, checkIn, checkOut, rooms, c.Query("currency"), promos)
+	if err != nil {
+		var ve *service.ValidationError
+		if errors.As(err, &ve) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": ve.Problems})
+		}
+		switch err {
+		case service.ErrHotelNotFound:
+			return c.Status(fiber.StatusNotFound).SendString("not found")
+		case service.ErrInvalidCurrency, service.ErrNoExchangeRate:
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to quote")
+	}
+	return c.JSON(q)
+}
+
+// ImportHotelsHandler bulk-imports hotels from a CSV or JSON request body.
+// Pass dry_run=true to only validate.
+func ImportHotelsHandler(c *fiber.Ctx) error {
+	format := c.Query("format")
+	if format == "" {
+		format = service.FormatJSON
+		if strings.Contains(string(c.Request().Header.ContentType()), "csv") {
+			format = service.FormatCSV
+		}
+	}
+	report, err := roomService.Import(format, bytes.NewReader(c.Body()), c.QueryBool("dry_run"), c.QueryInt("batch_size"))
+	if err != nil {
+		if err == service.ErrUnsupportedFormat {
+			return c.Status(fiber.StatusBadRequest).SendString("format must be csv or json")
+		}
+		return c.Status(fiber.StatusBadRequest).SendString("invalid " + format + ": " + err.Error())
+	}
+	switch {
+	case len(report.Errors) > 0:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(report)
+	case report.Failure != "":
+		return c.Status(fiber.StatusInternalServerError).JSON(report)
+	}
+	return c.JSON(report)
+}
+
+// ExportHotelsHandler downloads all hotels as CSV or JSON.
+func ExportHotelsHandler(c *fiber.Ctx) error {
+	format := strings.ToLower(c.Query("format", service.FormatJSON))
+	var buf bytes.Buffer
+	if err := roomService.Export(format, &buf); err != nil {
+		if err == service.ErrUnsupportedFormat {
+			return c.Status(fiber.StatusBadRequest).SendString("format must be csv or json")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to export hotels")
+	}
+	if format == service.FormatCSV {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="hotels.`+format+`"`)
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sessionClient describes the device making the request.
+func sessionClient(c *fiber.Ctx) service.SessionClient {
+	return service.SessionClient{UserAgent: c.Get(fiber.HeaderUserAgent), IP: c.IP()}
+}
+
+func currentSessionID(c *fiber.Ctx) string {
+	jti, _ := claimsFromCtx(c)["jti"].(string)
+	return jti
+}
+
+// ListSessions returns the caller's active logins.
+func ListSessions(c *fiber.Ctx) error {
+	list, err := authService.Sessions().List(currentUserID(c), currentSessionID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to list sessions")
+	}
+	return c.JSON(list)
+}
+
+func RevokeSession(c *fiber.Ctx) error {
+	err := authService.Sessions().Revoke(currentUserID(c), c.Params("id"))
+	if err == service.ErrSessionNotFound {
+		return c.Status(fiber.StatusNotFound).SendString(err.Error())
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to revoke session")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RevokeOtherSessions signs the caller out everywhere but here.
+func RevokeOtherSessions(c *fiber.Ctx) error {
+	n, err := authService.Sessions().RevokeOthers(currentUserID(c), currentSessionID(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to revoke sessions")
+	}
+	return c.JSON(fiber.Map{"revoked": n})
+}
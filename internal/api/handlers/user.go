@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var userService = service.NewUserService()
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ProfileResponse is the caller's profile with their loyalty points.
+type ProfileResponse struct {
+	model.User
+	Loyalty model.LoyaltyAccount `json:"loyalty"`
+}
+
+// GetMyProfile returns the caller's stored profile and loyalty account.
+func GetMyProfile(c *fiber.Ctx) error {
+	u, err := userService.Profile(currentUserID(c))
+	if err != nil {
+		return userError(c, err)
+	}
+	a, err := loyaltyService.Account(u.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("failed to load loyalty account")
+	}
+	return c.JSON(ProfileResponse{User: u, Loyalty: a})
+}
+
+func UpdateMyProfile(c *fiber.Ctx) error {
+	var req service.ProfileUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	u, err := userService.UpdateProfile(currentUserID(c), req)
+	if err != nil {
+		return userError(c, err)
+	}
+	return c.JSON(u)
+}
+
+// ChangeMyPassword keeps the current session and ends all others.
+func ChangeMyPassword(c *fiber.Ctx) error {
+	var req ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid body")
+	}
+	if err := userService.ChangePassword(currentUserID(c), req.CurrentPassword, req.NewPassword, currentSessionID(c)); err != nil {
+		return userError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func userError(c *fiber.Ctx, err error) error {
+	var ve *service.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": ve.Problems})
+	case err == repository.ErrUserNotFound:
+		return c.Status(fiber.StatusNotFound).SendString("user not found")
+	case err == service.ErrWrongPassword:
+		return c.Status(fiber.StatusForbidden).SendString(err.Error())
+	case err == service.ErrWeakPassword:
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString("request failed")
+}
@@ -4,6 +4,7 @@ import (
 	"agodrift/internal/api/handlers"
 	"agodrift/internal/config"
 	"agodrift/internal/middleware"
+	"agodrift/internal/model"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,22 +17,131 @@ func NewApp() *fiber.App {
 	// health check
 	app.Get("/api/v1/health", handlers.Health)
 
+	// public verification keys
+	app.Get("/.well-known/jwks.json", handlers.JWKS)
+
 	// auth routes
 	app.Post("/api/v1/auth/login", handlers.Login)
-	// protected routes
+	app.Post("/api/v1/auth/login/2fa", handlers.LoginMFA)
+	app.Post("/api/v1/auth/login/2fa/enroll", handlers.LoginMFAEnroll)
+	app.Post("/api/v1/auth/login/2fa/activate", handlers.LoginMFAActivate)
+	app.Post("/api/v1/auth/password/forgot", handlers.ForgotPassword)
+	app.Post("/api/v1/auth/password/reset", handlers.ResetPassword)
+	app.Post("/api/v1/auth/email/verify", handlers.VerifyEmail)
+	app.Get("/api/v1/auth/oidc/providers", handlers.ListOIDCProviders)
+	app.Get("/api/v1/auth/oidc/:provider/login", handlers.OIDCLogin)
+	app.Get("/api/v1/auth/oidc/:provider/callback", handlers.OIDCCallback)
+	// protected routes; API keys are only accepted where JWTConfig lists
+	// the scopes a key needs
 	app.Post("/api/v1/auth/logout", middleware.JWTConfig(secret), handlers.Logout)
 	app.Get("/api/v1/auth/me", middleware.JWTConfig(secret), handlers.Me)
+	app.Get("/api/v1/auth/sessions", middleware.JWTConfig(secret), handlers.ListSessions)
+	app.Post("/api/v1/auth/sessions/revoke-others", middleware.JWTConfig(secret), handlers.RevokeOtherSessions)
+	app.Delete("/api/v1/auth/sessions/:id", middleware.JWTConfig(secret), handlers.RevokeSession)
+	app.Post("/api/v1/auth/email/verify/request", middleware.JWTConfig(secret), handlers.RequestEmailVerification)
+	app.Post("/api/v1/auth/2fa/enroll", middleware.JWTConfig(secret), handlers.EnrollMFA)
+	app.Post("/api/v1/auth/2fa/activate", middleware.JWTConfig(secret), handlers.ActivateMFA)
+	app.Post("/api/v1/auth/2fa/disable", middleware.JWTConfig(secret), handlers.DisableMFA)
+
+	// profile routes
+	app.Get("/api/v1/users/me", middleware.JWTConfig(secret), handlers.GetMyProfile)
+	app.Patch("/api/v1/users/me", middleware.JWTConfig(secret), handlers.UpdateMyProfile)
+	app.Get("/api/v1/users/me/loyalty", middleware.JWTConfig(secret), handlers.GetMyLoyalty)
+	app.Get("/api/v1/users/me/loyalty/transactions", middleware.JWTConfig(secret), handlers.ListMyLoyaltyTransactions)
+	app.Post("/api/v1/users/me/password", middleware.JWTConfig(secret), handlers.ChangeMyPassword)
+	app.Get("/api/v1/users/me/api-keys", middleware.JWTConfig(secret), handlers.ListAPIKeys)
+	app.Post("/api/v1/users/me/api-keys", middleware.JWTConfig(secret), handlers.CreateAPIKey)
+	app.Delete("/api/v1/users/me/api-keys/:id", middleware.JWTConfig(secret), handlers.RevokeAPIKey)
 
 	// room routes
 	app.Get("/api/v1/listrooms", handlers.ListRoomsHandler)
 	app.Get("/api/v1/listrooms/:id", handlers.RoomByIDHandler)
+	app.Get("/api/v1/listrooms/:id/quote", handlers.QuoteHandler)
+
+	// require hotels:write permission to create room
+	app.Post("/api/v1/AddRoom", middleware.JWTConfig(secret, model.PermHotelsWrite), middleware.RequirePermission(model.PermHotelsWrite), handlers.AddRoomHandler)
+
+	// bulk hotel import/export
+	app.Post("/api/v1/admin/hotels/import", middleware.JWTConfig(secret, model.PermHotelsWrite), middleware.RequirePermission(model.PermHotelsWrite), handlers.ImportHotelsHandler)
+	app.Get("/api/v1/admin/hotels/export", middleware.JWTConfig(secret, model.PermHotelsExport), middleware.RequirePermission(model.PermHotelsExport), handlers.ExportHotelsHandler)
+
+	// partner routes: ownership of each hotel is checked in the service layer
+	app.Get("/api/v1/partner/hotels", middleware.JWTConfig(secret, model.PermHotelsWriteOwn), handlers.ListPartnerHotels)
+	app.Patch("/api/v1/partner/hotels/:id", middleware.JWTConfig(secret, model.PermHotelsWriteOwn), middleware.RequirePermission(model.PermHotelsWriteOwn), handlers.UpdateHotelHandler)
+	app.Put("/api/v1/partner/hotels/:id/inventory", middleware.JWTConfig(secret, model.PermHotelsWriteOwn), middleware.RequirePermission(model.PermHotelsWriteOwn), handlers.SetInventoryHandler)
+	app.Put("/api/v1/partner/hotels/:id/cancellation-policy", middleware.JWTConfig(secret, model.PermHotelsWriteOwn), middleware.RequirePermission(model.PermHotelsWriteOwn), handlers.SetHotelCancellationPolicy)
+	app.Get("/api/v1/partner/bookings", middleware.JWTConfig(secret, model.PermBookingsReadHotel), middleware.RequirePermission(model.PermBookingsReadHotel), handlers.ListPartnerBookings)
+
+	// cancellation policies
+	app.Get("/api/v1/cancellation-policies", handlers.ListCancellationPolicies)
+	app.Put("/api/v1/admin/cancellation-policies/:code", middleware.JWTConfig(secret, model.PermHotelsWrite), middleware.RequirePermission(model.PermHotelsWrite), handlers.PutCancellationPolicy)
+
+	// promo codes
+	app.Get("/api/v1/admin/promo-codes", middleware.JWTConfig(secret, model.PermPromosManage), middleware.RequirePermission(model.PermPromosManage), handlers.ListPromoCodes)
+	app.Post("/api/v1/admin/promo-codes", middleware.JWTConfig(secret, model.PermPromosManage), middleware.RequirePermission(model.PermPromosManage), handlers.CreatePromoCode)
+	app.Put("/api/v1/admin/promo-codes/:id", middleware.JWTConfig(secret, model.PermPromosManage), middleware.RequirePermission(model.PermPromosManage), handlers.UpdatePromoCode)
 
-	// require admin role to create room
-	app.Post("/api/v1/AddRoom", middleware.JWTConfig(secret), middleware.RequireRole("admin"), handlers.AddRoomHandler)
+	// hotel ownership links
+	app.Post("/api/v1/admin/hotels/:id/owners", middleware.JWTConfig(secret, model.PermHotelsOwners), middleware.RequirePermission(model.PermHotelsOwners), handlers.AssignHotelOwner)
+	app.Delete("/api/v1/admin/hotels/:id/owners/:userId", middleware.JWTConfig(secret, model.PermHotelsOwners), middleware.RequirePermission(model.PermHotelsOwners), handlers.RemoveHotelOwner)
+
+	// exchange rates
+	app.Get("/api/v1/admin/exchange-rates", middleware.JWTConfig(secret, model.PermRatesWrite), middleware.RequirePermission(model.PermRatesWrite), handlers.ListExchangeRates)
+	app.Put("/api/v1/admin/exchange-rates", middleware.JWTConfig(secret, model.PermRatesWrite), middleware.RequirePermission(model.PermRatesWrite), handlers.PutExchangeRates)
+	app.Post("/api/v1/admin/exchange-rates/import", middleware.JWTConfig(secret, model.PermRatesWrite), middleware.RequirePermission(model.PermRatesWrite), handlers.ImportExchangeRates)
+	app.Delete("/api/v1/admin/exchange-rates/:base/:quote", middleware.JWTConfig(secret, model.PermRatesWrite), middleware.RequirePermission(model.PermRatesWrite), handlers.DeleteExchangeRate)
+
+	// roles and permissions
+	app.Get("/api/v1/admin/rbac/permissions", middleware.JWTConfig(secret, model.PermRBACManage), middleware.RequirePermission(model.PermRBACManage), handlers.ListPermissions)
+	app.Get("/api/v1/admin/rbac/roles", middleware.JWTConfig(secret, model.PermRBACManage), middleware.RequirePermission(model.PermRBACManage), handlers.ListRoles)
+	app.Put("/api/v1/admin/rbac/roles/:name", middleware.JWTConfig(secret, model.PermRBACManage), middleware.RequirePermission(model.PermRBACManage), handlers.PutRole)
+	app.Delete("/api/v1/admin/rbac/roles/:name", middleware.JWTConfig(secret, model.PermRBACManage), middleware.RequirePermission(model.PermRBACManage), handlers.DeleteRole)
+	app.Get("/api/v1/admin/users/:id/roles", middleware.JWTConfig(secret, model.PermRBACManage), middleware.RequirePermission(model.PermRBACManage), handlers.ListUserRoles)
+	app.Post("/api/v1/admin/users/:id/roles", middleware.JWTConfig(secret, model.PermRBACManage), middleware.RequirePermission(model.PermRBACManage), handlers.AssignUserRole)
+	app.Delete("/api/v1/admin/users/:id/roles/:role", middleware.JWTConfig(secret, model.PermRBACManage), middleware.RequirePermission(model.PermRBACManage), handlers.RevokeUserRole)
+
+	// user management
+	app.Get("/api/v1/admin/users", middleware.JWTConfig(secret, model.PermUsersManage), middleware.RequirePermission(model.PermUsersManage), handlers.SearchUsers)
+	app.Get("/api/v1/admin/users/:id", middleware.JWTConfig(secret, model.PermUsersManage), middleware.RequirePermission(model.PermUsersManage), handlers.GetUser)
+	app.Put("/api/v1/admin/users/:id/role", middleware.JWTConfig(secret, model.PermRBACManage), middleware.RequirePermission(model.PermRBACManage), handlers.SetUserRole)
+	app.Put("/api/v1/admin/users/:id/status", middleware.JWTConfig(secret, model.PermUsersManage), middleware.RequirePermission(model.PermUsersManage), handlers.SetUserStatus)
+	app.Post("/api/v1/admin/users/:id/logout", middleware.JWTConfig(secret, model.PermUsersManage), middleware.RequirePermission(model.PermUsersManage), handlers.ForceLogoutUser)
+
+	// login lockouts
+	app.Get("/api/v1/admin/login-lockouts", middleware.JWTConfig(secret, model.PermUsersManage), middleware.RequirePermission(model.PermUsersManage), handlers.ListLockouts)
+	app.Post("/api/v1/admin/login-lockouts/unlock", middleware.JWTConfig(secret, model.PermUsersManage), middleware.RequirePermission(model.PermUsersManage), handlers.UnlockLogin)
 
 	// booking routes
-	app.Post("/api/v1/bookings", middleware.JWTConfig(secret), handlers.CreateBooking)
+	// public lookup by reference code and last name
+	app.Post("/api/v1/bookings/lookup", handlers.LookupBooking)
+	// retries with the same Idempotency-Key replay the first response
+	app.Post("/api/v1/bookings", middleware.JWTConfig(secret), middleware.Idempotency(), handlers.CreateBooking)
 	app.Get("/api/v1/bookings/me", middleware.JWTConfig(secret), handlers.ListMyBookings)
+	app.Get("/api/v1/bookings/:id", middleware.JWTConfig(secret), handlers.GetBooking)
+	app.Patch("/api/v1/bookings/:id", middleware.JWTConfig(secret), handlers.ModifyBooking)
+	app.Put("/api/v1/bookings/:id/guests", middleware.JWTConfig(secret), handlers.UpdateBookingGuests)
+	app.Post("/api/v1/bookings/:id/cancel", middleware.JWTConfig(secret), handlers.CancelBooking)
+	app.Get("/api/v1/bookings/:id/invoice", middleware.JWTConfig(secret), handlers.GetBookingInvoice)
+	app.Get("/api/v1/bookings/:id/credit-note", middleware.JWTConfig(secret), handlers.GetBookingCreditNote)
+
+	// payment routes
+	app.Post("/api/v1/bookings/:id/payments", middleware.JWTConfig(secret), middleware.Idempotency(), handlers.PayBooking)
+	app.Get("/api/v1/bookings/:id/payments", middleware.JWTConfig(secret), handlers.ListBookingPayments)
+	app.Post("/api/v1/admin/payments/:id/capture", middleware.JWTConfig(secret, model.PermPaymentsManage), middleware.RequirePermission(model.PermPaymentsManage), handlers.CapturePayment)
+	app.Post("/api/v1/admin/payments/:id/void", middleware.JWTConfig(secret, model.PermPaymentsManage), middleware.RequirePermission(model.PermPaymentsManage), handlers.VoidPayment)
+	app.Post("/api/v1/admin/payments/:id/refund", middleware.JWTConfig(secret, model.PermPaymentsManage), middleware.RequirePermission(model.PermPaymentsManage), handlers.RefundPayment)
+	// gateway callbacks, authenticated by signature
+	app.Post("/api/v1/payments/webhook", handlers.PaymentWebhook)
+
+	// review routes
+	app.Get("/api/v1/listrooms/:id/reviews", handlers.ListHotelReviews)
+	app.Post("/api/v1/listrooms/:id/reviews", middleware.JWTConfig(secret), handlers.CreateReview)
+	app.Post("/api/v1/reviews/:id/reply", middleware.JWTConfig(secret, model.PermReviewsReply), middleware.RequirePermission(model.PermReviewsReply), handlers.ReplyToReview)
+
+	// review moderation
+	app.Get("/api/v1/admin/reviews", middleware.JWTConfig(secret, model.PermReviewsModerate), middleware.RequirePermission(model.PermReviewsModerate), handlers.ReviewQueue)
+	app.Post("/api/v1/admin/reviews/:id/approve", middleware.JWTConfig(secret, model.PermReviewsModerate), middleware.RequirePermission(model.PermReviewsModerate), handlers.ApproveReview)
+	app.Post("/api/v1/admin/reviews/:id/reject", middleware.JWTConfig(secret, model.PermReviewsModerate), middleware.RequirePermission(model.PermReviewsModerate), handlers.RejectReview)
 
 	return app
 }
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agodrift/internal/service"
+)
+
+// ImportHotels implements `agodrift import-hotels`.
+func ImportHotels(args []string) int {
+	fs := flag.NewFlagSet("import-hotels", flag.ContinueOnError)
+	file := fs.String("file", "", "CSV or JSON file to import (required)")
+	format := fs.String("format", "", "csv or json (defaults to the file extension)")
+	dryRun := fs.Bool("dry-run", false, "validate only, do not write")
+	batch := fs.Int("batch-size", service.DefaultImportBatchSize, "rows per transaction")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" {
+		fs.Usage()
+		return 2
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+
+	report, err := service.NewRoomService().Import(*format, f, *dryRun, *batch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import failed:", err)
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+	if len(report.Errors) > 0 || report.Failure != "" {
+		return 1
+	}
+	return 0
+}
+
+// ExportHotels implements `agodrift export-hotels`.
+func ExportHotels(args []string) int {
+	fs := flag.NewFlagSet("export-hotels", flag.ContinueOnError)
+	format := fs.String("format", service.FormatJSON, "csv or json")
+	out := fs.String("out", "", "output file (defaults to stdout)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := service.NewRoomService().Export(*format, w); err != nil {
+		fmt.Fprintln(os.Stderr, "export failed:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"agodrift/internal/service"
+)
+
+// AwardPoints implements `agodrift award-points`, meant to run daily from
+// cron. Stays are credited once, so missed or repeated runs are safe.
+func AwardPoints(args []string) int {
+	fs := flag.NewFlagSet("award-points", flag.ContinueOnError)
+	date := fs.String("date", "", "credit stays checked out on or before this date, YYYY-MM-DD (default today)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	day := time.Now()
+	if *date != "" {
+		d, err := time.Parse("2006-01-02", *date)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid -date:", err)
+			return 2
+		}
+		day = d
+	}
+
+	n, err := service.NewLoyaltyService().AwardCompleted(day)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "award points failed:", err)
+		return 1
+	}
+	fmt.Printf("credited loyalty points for %d stays checked out by %s\n", n, day.Format("2006-01-02"))
+	return 0
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agodrift/internal/service"
+)
+
+// ImportRates implements `agodrift import-rates`.
+func ImportRates(args []string) int {
+	fs := flag.NewFlagSet("import-rates", flag.ContinueOnError)
+	file := fs.String("file", "", "CSV (base,quote,rate) or JSON file to import (required)")
+	format := fs.String("format", "", "csv or json (defaults to the file extension)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" {
+		fs.Usage()
+		return 2
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+
+	n, err := service.NewCurrencyService().ImportRates(*format, f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import failed:", err)
+		return 1
+	}
+	fmt.Printf("imported %d exchange rates\n", n)
+	return 0
+}
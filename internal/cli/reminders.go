@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"agodrift/internal/mail"
+	"agodrift/internal/service"
+)
+
+// SendReminders implements `agodrift send-reminders`, meant to run daily
+// from cron. Each booking is reminded at most once.
+func SendReminders(args []string) int {
+	fs := flag.NewFlagSet("send-reminders", flag.ContinueOnError)
+	date := fs.String("date", "", "check-in date to remind about, YYYY-MM-DD (default tomorrow)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	day := time.Now().AddDate(0, 0, 1)
+	if *date != "" {
+		d, err := time.Parse("2006-01-02", *date)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid -date:", err)
+			return 2
+		}
+		day = d
+	}
+
+	n, err := service.NewBookingService().SendStayReminders(day)
+	// wait for queued messages before exiting
+	mail.DefaultQueue().Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "send reminders failed:", err)
+		return 1
+	}
+	fmt.Printf("queued %d stay reminders for %s\n", n, day.Format("2006-01-02"))
+	return 0
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -27,6 +28,15 @@ func Get(key, def string) string {
 	return v
 }
 
+// GetInt reads an integer env var, falling back to def when unset or invalid.
+func GetInt(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
 // GetDB returns a database connection.
 func GetDB() *sql.DB {
 	dbOnce.Do(func() {
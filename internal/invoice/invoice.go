@@ -0,0 +1,135 @@
+// Package invoice renders invoices and credit notes as HTML and PDF.
+package invoice
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"strconv"
+
+	"agodrift/internal/model"
+	"agodrift/internal/money"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var htmlTemplate = template.Must(template.ParseFS(templateFS, "templates/invoice.html.tmpl"))
+
+// view is an invoice with every amount and date formatted for people.
+type view struct {
+	model.Invoice
+	Title  string
+	Issued string
+	Stay   string
+	Lines  []lineView
+	Total  string
+	Points string
+}
+
+type lineView struct {
+	Description string
+	Quantity    string
+	Unit        string
+	Amount      string
+	Included    bool
+}
+
+func newView(inv model.Invoice) view {
+	v := view{
+		Invoice: inv,
+		Title:   inv.Title(),
+		Issued:  inv.IssuedAt.Format("2 Jan 2006"),
+		Stay:    fmt.Sprintf("%s to %s", inv.CheckIn.Format("2 Jan 2006"), inv.CheckOut.Format("2 Jan 2006")),
+		Total:   money.Format(inv.TotalCents, inv.Currency),
+	}
+	for _, l := range inv.Lines {
+		lv := lineView{Description: l.Description, Amount: money.Format(l.AmountCents, inv.Currency), Included: l.Included}
+		if l.Quantity > 0 {
+			lv.Quantity = strconv.Itoa(l.Quantity)
+		}
+		if l.UnitCents != 0 {
+			lv.Unit = money.Format(l.UnitCents, inv.Currency)
+		}
+		if l.Included {
+			lv.Amount = "(" + lv.Amount + ")"
+		}
+		v.Lines = append(v.Lines, lv)
+	}
+	if inv.PointsCents != 0 {
+		v.Points = money.Format(inv.PointsCents, inv.Currency)
+	}
+	return v
+}
+
+// HTML renders the document as a standalone page.
+func HTML(inv model.Invoice) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, newView(inv)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PDF renders the document as a one-page A4 PDF.
+func PDF(inv model.Invoice) []byte {
+	v := newView(inv)
+	p := &page{}
+	y := 790.0
+	p.text(fontBold, 18, left, y, v.Issuer)
+	if v.IssuerTaxID != "" {
+		y -= 16
+		p.text(fontRegular, 10, left, y, "Tax ID: "+v.IssuerTaxID)
+	}
+	y -= 36
+	p.text(fontBold, 16, left, y, v.Title+" "+v.Number)
+
+	y -= 28
+	details := [][2]string{{"Issued", v.Issued}}
+	if v.InvoiceNumber != "" {
+		details = append(details, [2]string{"Corrects invoice", v.InvoiceNumber})
+	}
+	details = append(details,
+		[2]string{"Booking", v.Reference},
+		[2]string{"Bill to", v.BillTo.Name},
+	)
+	if v.BillTo.Email != "" {
+		details = append(details, [2]string{"", v.BillTo.Email})
+	}
+	details = append(details,
+		[2]string{"Hotel", v.Hotel + ", " + v.HotelLocation},
+		[2]string{"Stay", v.Stay},
+	)
+	for _, d := range details {
+		p.text(fontBold, 10, left, y, d[0])
+		p.text(fontRegular, 10, left+110, y, d[1])
+		y -= 15
+	}
+
+	y -= 20
+	p.text(fontBold, 10, left, y, "Description")
+	p.textRight(fontMonoBold, 10, colQty, y, "Qty")
+	p.textRight(fontMonoBold, 10, colUnit, y, "Unit price")
+	p.textRight(fontMonoBold, 10, right, y, "Amount")
+	y -= 6
+	p.line(left, y, right, y)
+	for _, l := range v.Lines {
+		y -= 16
+		p.text(fontRegular, 10, left, y, l.Description)
+		p.textRight(fontMono, 10, colQty, y, l.Quantity)
+		p.textRight(fontMono, 10, colUnit, y, l.Unit)
+		p.textRight(fontMono, 10, right, y, l.Amount)
+	}
+	y -= 8
+	p.line(left, y, right, y)
+	y -= 18
+	p.textRight(fontMonoBold, 11, colUnit, y, "Total")
+	p.textRight(fontMonoBold, 11, right, y, v.Total)
+	if v.Points != "" {
+		y -= 16
+		p.textRight(fontMono, 10, colUnit, y, "Paid with loyalty points")
+		p.textRight(fontMono, 10, right, y, v.Points)
+	}
+	return p.pdf()
+}
@@ -0,0 +1,141 @@
+// Package mail delivers outbound email through a pluggable Mailer.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"agodrift/internal/config"
+)
+
+// Message is a single email. HTML is optional; Text is always sent.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends messages.
+type Mailer interface {
+	Send(m Message) error
+}
+
+// FromEnv picks the mailer from MAIL_DRIVER: "stdout" (default) prints
+// messages, "file" writes one .eml file per message to MAIL_DIR and "smtp"
+// relays through SMTP_HOST:SMTP_PORT as SMTP_USER/SMTP_PASS.
+func FromEnv() Mailer {
+	from := config.Get("MAIL_FROM", "AgoDrift <no-reply@agodrift.dev>")
+	switch config.Get("MAIL_DRIVER", "stdout") {
+	case "smtp":
+		return NewSMTPMailer(config.Get("SMTP_HOST", "localhost"), config.Get("SMTP_PORT", "587"), config.Get("SMTP_USER", ""), config.Get("SMTP_PASS", ""), from)
+	case "file":
+		return NewFileMailer(config.Get("MAIL_DIR", "mail"), from)
+	default:
+		return NewWriterMailer(os.Stdout, from)
+	}
+}
+
+// writerMailer writes each message in RFC 5322 form to w.
+type writerMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewWriterMailer(w io.Writer, from string) *writerMailer {
+	return &writerMailer{w: w, from: from}
+}
+
+func (m *writerMailer) Send(msg Message) error {
+	raw, err := Render(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = fmt.Fprintf(m.w, "%s\n.\n", raw)
+	return err
+}
+
+// fileMailer drops messages into a directory, e.g. for local development
+// where an .eml file can be opened in any mail client.
+type fileMailer struct {
+	dir  string
+	from string
+	mu   sync.Mutex
+	seq  int
+}
+
+func NewFileMailer(dir string, from string) *fileMailer {
+	return &fileMailer{dir: dir, from: from}
+}
+
+func (m *fileMailer) Send(msg Message) error {
+	now := time.Now()
+	raw, err := Render(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%04d.eml", now.Format("20060102T150405.000"), m.seq)
+	m.mu.Unlock()
+	return os.WriteFile(filepath.Join(m.dir, name), raw, 0o644)
+}
+
+// Render builds the raw message, multipart/alternative when HTML is set.
+func Render(from string, msg Message, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+	header("From", from)
+	header("To", sanitizeHeader(msg.To))
+	header("Subject", sanitizeHeader(msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		buf.WriteString("\r\n")
+		buf.WriteString(msg.Text)
+		return buf.Bytes(), nil
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	header("Content-Type", "multipart/alternative; boundary="+w.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ ctype, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {part.ctype}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(pw, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// sanitizeHeader stops user-controlled values from injecting headers.
+func sanitizeHeader(v string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(v)
+}
@@ -0,0 +1,90 @@
+package mail
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"agodrift/internal/config"
+)
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+var (
+	defaultQueue     *Queue
+	defaultQueueOnce sync.Once
+)
+
+// DefaultQueue returns the process-wide queue over the MAIL_DRIVER mailer,
+// starting it on first use. Failed deliveries are retried up to
+// MAIL_MAX_ATTEMPTS times.
+func DefaultQueue() *Queue {
+	defaultQueueOnce.Do(func() {
+		defaultQueue = NewQueue(FromEnv(), 2, 1000, config.GetInt("MAIL_MAX_ATTEMPTS", 5), 2*time.Second)
+	})
+	return defaultQueue
+}
+
+// Queue sends messages in the background through another Mailer, retrying
+// failures with exponential backoff. Send never waits for delivery.
+type Queue struct {
+	next        Mailer
+	jobs        chan Message
+	maxAttempts int
+	baseDelay   time.Duration
+	wg          sync.WaitGroup
+	closeOnce   sync.Once
+}
+
+// NewQueue starts workers goroutines delivering through next.
+func NewQueue(next Mailer, workers, size, maxAttempts int, baseDelay time.Duration) *Queue {
+	q := &Queue{
+		next:        next,
+		jobs:        make(chan Message, size),
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Send enqueues msg, or returns ErrQueueFull rather than block the caller.
+func (q *Queue) Send(msg Message) error {
+	select {
+	case q.jobs <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting messages and waits for queued ones to be delivered
+// or given up on.
+func (q *Queue) Close() {
+	q.closeOnce.Do(func() { close(q.jobs) })
+	q.wg.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for msg := range q.jobs {
+		delay := q.baseDelay
+		for attempt := 1; ; attempt++ {
+			err := q.next.Send(msg)
+			if err == nil {
+				break
+			}
+			if attempt >= q.maxAttempts {
+				log.Printf("mail: giving up on %q to %s after %d attempts: %v", msg.Subject, msg.To, attempt, err)
+				break
+			}
+			log.Printf("mail: attempt %d for %q to %s failed, retrying in %s: %v", attempt, msg.Subject, msg.To, delay, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// smtpMailer relays through an SMTP server, upgrading to TLS with STARTTLS
+// when the server offers it.
+type smtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer authenticates with PLAIN when user is set.
+func NewSMTPMailer(host, port, user, pass, from string) *smtpMailer {
+	m := &smtpMailer{addr: net.JoinHostPort(host, port), from: from}
+	if user != "" {
+		m.auth = smtp.PlainAuth("", user, pass, host)
+	}
+	return m
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	raw, err := Render(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	sender, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+	rcpt, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, sender.Address, []string{rcpt.Address}, raw)
+}
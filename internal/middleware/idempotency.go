@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+
+	"agodrift/internal/service"
+)
+
+// Idempotency makes a route safe to retry with an Idempotency-Key header.
+// It must run after JWTConfig: keys are scoped to the caller. Responses
+// below 500 are stored and replayed; server errors release the key.
+func Idempotency() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" {
+			return c.Next()
+		}
+		var uid int
+		if tok, ok := c.Locals("user").(*jwt.Token); ok {
+			if claims, ok := tok.Claims.(jwt.MapClaims); ok {
+				uidFloat, _ := claims["uid"].(float64)
+				uid = int(uidFloat)
+			}
+		}
+		if uid == 0 {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+
+		svc := service.GetIdempotency()
+		rec, err := svc.Begin(uid, key, service.Fingerprint(c.Method(), c.Path(), c.Body()))
+		switch err {
+		case nil:
+		case service.ErrInvalidIdempotencyKey:
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		case service.ErrIdempotencyMismatch:
+			return c.Status(fiber.StatusUnprocessableEntity).SendString(err.Error())
+		case service.ErrIdempotencyInProgress:
+			return c.Status(fiber.StatusConflict).SendString(err.Error())
+		default:
+			return c.Status(fiber.StatusInternalServerError).SendString("failed to check Idempotency-Key")
+		}
+		if rec != nil {
+			c.Set("Idempotent-Replayed", "true")
+			if rec.ContentType != "" {
+				c.Set(fiber.HeaderContentType, rec.ContentType)
+			}
+			return c.Status(rec.Status).Send(rec.Body)
+		}
+
+		if err := c.Next(); err != nil {
+			if aerr := svc.Abort(uid, key); aerr != nil {
+				log.Printf("idempotency: release %q: %v", key, aerr)
+			}
+			return err
+		}
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			err = svc.Abort(uid, key)
+		} else {
+			err = svc.Finish(uid, key, status, string(c.Response().Header.ContentType()), c.Response().Body())
+		}
+		if err != nil {
+			log.Printf("idempotency: store %q: %v", key, err)
+		}
+		return nil
+	}
+}
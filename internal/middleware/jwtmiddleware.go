@@ -1,51 +1,84 @@
 package middleware
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	fiberjwt "github.com/gofiber/jwt/v2"
 	"github.com/golang-jwt/jwt/v4"
 
 	"agodrift/internal/service"
 )
 
-// JWTConfig returns a Fiber middleware that validates JWT and checks blacklist
-func JWTConfig(secret string) fiber.Handler {
+// JWTConfig returns a Fiber middleware that validates JWT and checks blacklist.
+// Tokens are verified against the AuthService key set, so both HS256 tokens
+// and RS256/EdDSA tokens selected by their kid header are accepted. Requests
+// without an Authorization header may send an X-API-Key instead, but only on
+// routes that list keyScopes; the key must grant every one of them.
+func JWTConfig(secret string, keyScopes ...string) fiber.Handler {
 	// ensure singleton auth using provided secret
 	service.InitDefaultAuth(secret)
-	cfg := fiberjwt.New(fiberjwt.Config{
-		SigningKey: []byte(secret),
-		ContextKey: "user",
-		SuccessHandler: func(c *fiber.Ctx) error {
-			// check blacklist
-			if u := c.Locals("user"); u != nil {
-				if tok, ok := u.(*jwt.Token); ok {
-					if claims, ok := tok.Claims.(jwt.MapClaims); ok {
-						jti, _ := claims["jti"].(string)
-						if service.GetAuth().IsBlacklisted(jti) {
-							return c.Status(fiber.StatusUnauthorized).SendString("token revoked")
-						}
-					}
-				}
-			}
-			return c.Next()
-		},
-	})
 	return func(c *fiber.Ctx) error {
 		// Allow Authorization: Bearer <token>
-		// fiberjwt will parse and validate token for us
-		if err := cfg(c); err != nil {
-			// fiberjwt returns an error which should already be a response
-			// Map some errors to 401
-			// If it's an unauthorized error, forward a 401
-			if strings.Contains(err.Error(), "Missing or malformed JWT") || strings.Contains(err.Error(), "token is expired") {
+		auth := c.Get(fiber.HeaderAuthorization)
+		if key := c.Get("X-API-Key"); auth == "" && key != "" {
+			return apiKeyAuth(c, key, keyScopes)
+		}
+		if len(auth) <= 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+			return c.Status(fiber.StatusUnauthorized).SendString("Missing or malformed JWT")
+		}
+		tok, err := service.GetAuth().ParseToken(auth[7:])
+		if err != nil || !tok.Valid {
+			return c.Status(fiber.StatusUnauthorized).SendString("Invalid or expired JWT")
+		}
+		c.Locals("user", tok)
+		// check blacklist
+		if claims, ok := tok.Claims.(jwt.MapClaims); ok {
+			// purpose-bound tokens (e.g. mfa challenges) are not access tokens
+			if _, ok := claims["purpose"]; ok {
+				return c.Status(fiber.StatusUnauthorized).SendString("Invalid or expired JWT")
+			}
+			if service.GetAuth().IsRevoked(claims) {
+				return c.Status(fiber.StatusUnauthorized).SendString("token revoked")
+			}
+			u, err := service.GetAuth().CurrentUser(claims)
+			if err != nil {
 				return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
 			}
-			return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+			// authorize with the stored role, not the one at login
+			claims["role"] = u.Role
 		}
-		return nil
+		return c.Next()
+	}
+}
+
+// apiKeyAuth stores claims equivalent to an access token of the key's user,
+// with the role narrowed to the key's permissions. Keys are refused on routes
+// without scopes, which covers account security and everything a guest does.
+func apiKeyAuth(c *fiber.Ctx, raw string, scopes []string) error {
+	key, u, err := service.GetAPIKeys().Authenticate(raw)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).SendString(err.Error())
+	}
+	if len(scopes) == 0 {
+		return c.Status(fiber.StatusForbidden).SendString("api keys cannot use this endpoint")
+	}
+	role := service.APIKeyRole(u.Role, key.ID)
+	for _, scope := range scopes {
+		if !service.GetRBAC().Has(u.ID, role, scope) {
+			return c.Status(fiber.StatusForbidden).SendString("forbidden")
+		}
+	}
+	// numbers are float64 as in a parsed token
+	claims := jwt.MapClaims{
+		"sub":        u.Email,
+		"uid":        float64(u.ID),
+		"role":       role,
+		"jti":        "apikey:" + strconv.Itoa(key.ID),
+		"api_key_id": float64(key.ID),
 	}
+	c.Locals("user", &jwt.Token{Claims: claims, Valid: true})
+	return c.Next()
 }
 
 // RequireRole returns middleware that ensures the token has the given role
@@ -66,3 +99,24 @@ func RequireRole(role string) fiber.Handler {
 		return c.Next()
 	}
 }
+
+// RequirePermission returns middleware that ensures the caller holds perm
+// through any of their assigned roles
+func RequirePermission(perm string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user")
+		if user == nil {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		if tok, ok := user.(*jwt.Token); ok {
+			if claims, ok := tok.Claims.(jwt.MapClaims); ok {
+				uidFloat, _ := claims["uid"].(float64)
+				r, _ := claims["role"].(string)
+				if service.GetRBAC().Has(int(uidFloat), r, perm) {
+					return c.Next()
+				}
+			}
+		}
+		return c.Status(fiber.StatusForbidden).SendString("forbidden")
+	}
+}
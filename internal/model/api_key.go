@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// APIKey lets an integration act as its user with a subset of the user's
+// permissions. Only a hash of the secret is stored; Prefix identifies the
+// key in listings and logs.
+type APIKey struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"` // e.g. "agd_k7q2m9xp"
+	Hash        string     `json:"-"`      // sha256 of the full key
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Usable reports whether the key is neither revoked nor expired at now.
+func (k APIKey) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || k.ExpiresAt.After(now))
+}
@@ -2,16 +2,197 @@ package model
 
 import "time"
 
+// Booking statuses.
+const (
+	BookingPending   = "pending"
+	BookingConfirmed = "confirmed"
+	BookingCancelled = "cancelled"
+)
+
 type Booking struct {
-	ID             int       `json:"id"`
-	UserID         int       `json:"user_id"`
-	HotelID        int       `json:"hotel_id"`
-	CheckIn        time.Time `json:"check_in"`
-	CheckOut       time.Time `json:"check_out"`
-	Adults         int       `json:"adults"`
-	Children       int       `json:"children"`
-	Rooms          int       `json:"rooms"`
-	TotalPriceCents int      `json:"total_price_cents"`
-	Status         string    `json:"status"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID                int       `json:"id"`
+	Reference         string    `json:"reference"` // confirmation code, e.g. AGD-7K3QX7
+	UserID            int       `json:"user_id"`
+	HotelID           int       `json:"hotel_id"`
+	CheckIn           time.Time `json:"check_in"`
+	CheckOut          time.Time `json:"check_out"`
+	Adults            int       `json:"adults"`
+	Children          int       `json:"children"`
+	Rooms             int       `json:"rooms"`
+	TotalPriceCents   int       `json:"total_price_cents"`
+	Currency          string    `json:"currency"` // currency the hotel charges in
+	DisplayCurrency   string    `json:"display_currency,omitempty"`
+	DisplayTotalCents *int      `json:"display_total_cents,omitempty"`
+	ExchangeRate      string    `json:"exchange_rate,omitempty"` // rate used for the display total, kept for audit
+	Status            string    `json:"status"`
+	CreatedAt         time.Time `json:"created_at"`
+	// policy the booking was made under; nil for bookings made before
+	// policies existed, which cancel free of charge
+	CancellationPolicy *CancellationPolicy `json:"cancellation_policy,omitempty"`
+	// promo codes taken off the stay's price; TotalPriceCents is after them
+	DiscountCents int            `json:"discount_cents"`
+	Promos        []AppliedPromo `json:"promos"`
+	// loyalty points spent on the booking and what they covered of the
+	// total, in the booking currency
+	PointsRedeemed   int `json:"points_redeemed"`
+	PointsValueCents int `json:"points_value_cents"`
+	GuestDetails
+}
+
+// AmountDueCents is what is left to pay by card after loyalty points.
+func (b Booking) AmountDueCents() int {
+	return max(b.TotalPriceCents-b.PointsValueCents, 0)
+}
+
+// PointsReturned is how many redeemed points go back to the guest when the
+// booking is cancelled with penaltyCents owed. The penalty is taken from the
+// card payment first and only the rest from the points.
+func (b Booking) PointsReturned(penaltyCents int) int {
+	if b.PointsRedeemed == 0 || b.PointsValueCents == 0 {
+		return b.PointsRedeemed
+	}
+	kept := min(max(penaltyCents-b.AmountDueCents(), 0), b.PointsValueCents)
+	return b.PointsRedeemed - (b.PointsRedeemed*kept+b.PointsValueCents-1)/b.PointsValueCents
+}
+
+// GuestContact is how the hotel reaches the lead guest.
+type GuestContact struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// BookingGuest is one named guest. Age is set for children only.
+type BookingGuest struct {
+	Name  string `json:"name"`
+	Child bool   `json:"child"`
+	Age   *int   `json:"age,omitempty"`
+}
+
+// GuestDetails is what the front desk needs besides the guest counts.
+type GuestDetails struct {
+	LeadGuest       *GuestContact  `json:"lead_guest,omitempty"`
+	Guests          []BookingGuest `json:"guests"`
+	SpecialRequests string         `json:"special_requests"`
+	ArrivalTime     string         `json:"arrival_time,omitempty"` // estimated, "15:30"
+}
+
+// Nights returns the number of nights between check-in and check-out, at least 1.
+func Nights(checkIn time.Time, checkOut time.Time) int {
+	nights := int(checkOut.Sub(checkIn).Hours() / 24)
+	if nights < 1 {
+		nights = 1
+	}
+	return nights
+}
+
+// Quote is the price of a prospective stay.
+type Quote struct {
+	HotelID           int       `json:"hotel_id"`
+	CheckIn           time.Time `json:"check_in"`
+	CheckOut          time.Time `json:"check_out"`
+	Nights            int       `json:"nights"`
+	Rooms             int       `json:"rooms"`
+	Currency          string    `json:"currency"`
+	NightlyPriceCents int       `json:"nightly_price_cents"`
+	SubtotalCents     int       `json:"subtotal_cents"`
+	DiscountCents     int       `json:"discount_cents"`
+	TotalPriceCents   int       `json:"total_price_cents"`
+	ExchangeRate      string    `json:"exchange_rate,omitempty"`
+	Available         bool      `json:"available"`
+	// codes in the currency of the quote; DiscountCents is their total
+	Promos []AppliedPromo `json:"promos"`
+	// terms the stay would be booked under
+	CancellationPolicy    CancellationPolicy `json:"cancellation_policy"`
+	FreeCancellationUntil *time.Time         `json:"free_cancellation_until,omitempty"`
+}
+
+// BookingTerms are the parts of a booking a guest can change after booking.
+type BookingTerms struct {
+	CheckIn         time.Time `json:"check_in"`
+	CheckOut        time.Time `json:"check_out"`
+	Adults          int       `json:"adults"`
+	Children        int       `json:"children"`
+	Rooms           int       `json:"rooms"`
+	TotalPriceCents int       `json:"total_price_cents"`
+}
+
+// Terms returns the booking's current changeable terms.
+func (b Booking) Terms() BookingTerms {
+	return BookingTerms{CheckIn: b.CheckIn, CheckOut: b.CheckOut, Adults: b.Adults, Children: b.Children, Rooms: b.Rooms, TotalPriceCents: b.TotalPriceCents}
+}
+
+// Equal reports whether both terms describe the same stay and price.
+func (t BookingTerms) Equal(o BookingTerms) bool {
+	return t.CheckIn.Equal(o.CheckIn) && t.CheckOut.Equal(o.CheckOut) && t.Adults == o.Adults &&
+		t.Children == o.Children && t.Rooms == o.Rooms && t.TotalPriceCents == o.TotalPriceCents
+}
+
+// BookingChange records one modification of a booking for audit.
+type BookingChange struct {
+	ID              int          `json:"id"`
+	BookingID       int          `json:"booking_id"`
+	ChangedBy       int          `json:"changed_by"`
+	Old             BookingTerms `json:"old"`
+	New             BookingTerms `json:"new"`
+	PriceDeltaCents int          `json:"price_delta_cents"` // positive when the guest owes more
+	Currency        string       `json:"currency"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+// Filters for a user's booking list.
+const (
+	BookingsUpcoming  = "upcoming"  // not cancelled, stay not over yet
+	BookingsPast      = "past"      // not cancelled, checked out
+	BookingsCancelled = "cancelled" // cancelled, whenever the stay was
+)
+
+// BookingSearch selects one page of a user's bookings. Filter is empty for
+// all bookings; upcoming and past are relative to Today.
+type BookingSearch struct {
+	UserID  int
+	Filter  string
+	Today   time.Time
+	Page    int
+	PerPage int
+}
+
+// BookingStatusChange is one entry of a booking's status history.
+type BookingStatusChange struct {
+	Status    string    `json:"status"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// HotelSummary is the part of a hotel shown with its bookings.
+type HotelSummary struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Location    string  `json:"location"`
+	Destination string  `json:"destination"`
+	Rating      float64 `json:"rating"`
+}
+
+// PriceBreakdown explains a booking's total.
+type PriceBreakdown struct {
+	NightlyPriceCents int    `json:"nightly_price_cents"`
+	Nights            int    `json:"nights"`
+	Rooms             int    `json:"rooms"`
+	SubtotalCents     int    `json:"subtotal_cents"`
+	DiscountCents     int    `json:"discount_cents"`
+	TotalPriceCents   int    `json:"total_price_cents"`
+	Currency          string `json:"currency"`
+	DisplayCurrency   string `json:"display_currency,omitempty"`
+	DisplayTotalCents *int   `json:"display_total_cents,omitempty"`
+	ExchangeRate      string `json:"exchange_rate,omitempty"`
+}
+
+// BookingDetail is a booking with what the app shows alongside it.
+type BookingDetail struct {
+	Booking
+	Hotel         *HotelSummary         `json:"hotel"` // nil if the hotel was removed
+	Nights        int                   `json:"nights"`
+	Price         PriceBreakdown        `json:"price"`
+	StatusHistory []BookingStatusChange `json:"status_history"`
+	// what cancelling now would cost; nil once cancelled
+	Cancellation *CancellationCharge `json:"cancellation,omitempty"`
 }
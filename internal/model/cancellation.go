@@ -0,0 +1,98 @@
+package model
+
+import "time"
+
+// DefaultCancellationPolicy applies to hotels that have not picked a policy.
+const DefaultCancellationPolicy = "flexible"
+
+// CancellationRule charges a penalty for cancelling less than HoursBefore
+// hours before check-in: PenaltyNights nights of the stay or PenaltyPercent
+// of the total, whichever is more.
+type CancellationRule struct {
+	HoursBefore    int `json:"hours_before"`
+	PenaltyNights  int `json:"penalty_nights,omitempty"`
+	PenaltyPercent int `json:"penalty_percent,omitempty"`
+}
+
+// CancellationPolicy decides what a guest pays when cancelling. Bookings
+// keep a copy of the policy they were made under, so later edits only
+// affect new bookings.
+type CancellationPolicy struct {
+	Code          string             `json:"code"`
+	Name          string             `json:"name"`
+	Description   string             `json:"description"`
+	NonRefundable bool               `json:"non_refundable"` // the full total is charged whenever cancelled
+	Rules         []CancellationRule `json:"rules"`
+}
+
+// DefaultCancellationPolicies are created by the seed.
+var DefaultCancellationPolicies = []CancellationPolicy{
+	{Code: "flexible", Name: "Flexible", Description: "Free cancellation until 48 hours before check-in, then the first night is charged.",
+		Rules: []CancellationRule{{HoursBefore: 48, PenaltyNights: 1}}},
+	{Code: "moderate", Name: "Moderate", Description: "Free cancellation until 7 days before check-in, then half the total is charged; the full total within 24 hours.",
+		Rules: []CancellationRule{{HoursBefore: 168, PenaltyPercent: 50}, {HoursBefore: 24, PenaltyPercent: 100}}},
+	{Code: "non_refundable", Name: "Non-refundable", Description: "The full total is charged whenever the booking is cancelled.",
+		NonRefundable: true, Rules: []CancellationRule{}},
+}
+
+// CancellationCharge is what cancelling a booking at a given time costs.
+type CancellationCharge struct {
+	Policy       string     `json:"policy"`
+	PenaltyCents int        `json:"penalty_cents"`
+	RefundCents  int        `json:"refund_cents"`
+	Currency     string     `json:"currency"`
+	FreeUntil    *time.Time `json:"free_until,omitempty"` // nil for non-refundable bookings
+	// loyalty points redeemed on the booking that are given back
+	PointsReturned int `json:"points_returned,omitempty"`
+}
+
+// FreeUntil returns the last moment a stay starting on checkIn can be
+// cancelled free of charge, or nil if it never can. Deadlines count back
+// from the start of the check-in date.
+func (p CancellationPolicy) FreeUntil(checkIn time.Time) *time.Time {
+	if p.NonRefundable {
+		return nil
+	}
+	hours := 0
+	for _, r := range p.Rules {
+		hours = max(hours, r.HoursBefore)
+	}
+	t := checkIn.Add(-time.Duration(hours) * time.Hour)
+	return &t
+}
+
+// Charge returns the penalty and refund for cancelling b at the given time.
+// When several rules apply the largest penalty wins; it never exceeds the
+// booking total.
+func (p CancellationPolicy) Charge(b Booking, at time.Time) CancellationCharge {
+	c := CancellationCharge{Policy: p.Code, Currency: b.Currency, FreeUntil: p.FreeUntil(b.CheckIn)}
+	total := b.TotalPriceCents
+	if p.NonRefundable {
+		c.PenaltyCents = total
+		return c
+	}
+	nights := Nights(b.CheckIn, b.CheckOut)
+	left := b.CheckIn.Sub(at)
+	for _, r := range p.Rules {
+		if left >= time.Duration(r.HoursBefore)*time.Hour {
+			continue
+		}
+		penalty := total * min(r.PenaltyNights, nights) / nights
+		penalty = max(penalty, total*r.PenaltyPercent/100)
+		c.PenaltyCents = max(c.PenaltyCents, penalty)
+	}
+	c.PenaltyCents = min(c.PenaltyCents, total)
+	c.RefundCents = total - c.PenaltyCents
+	return c
+}
+
+// CancellationCharge returns what cancelling b at the given time costs under
+// the policy it was booked with, and the loyalty points it gives back.
+func (b Booking) CancellationCharge(at time.Time) CancellationCharge {
+	c := CancellationCharge{RefundCents: b.TotalPriceCents, Currency: b.Currency, FreeUntil: &b.CheckIn}
+	if b.CancellationPolicy != nil {
+		c = b.CancellationPolicy.Charge(b, at)
+	}
+	c.PointsReturned = b.PointsReturned(c.PenaltyCents)
+	return c
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// ExchangeRate says how many units of Quote one unit of Base buys.
+type ExchangeRate struct {
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Rate      string    `json:"rate"` // decimal string to avoid float rounding
+	UpdatedAt time.Time `json:"updated_at"`
+}
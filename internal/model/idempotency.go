@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// IdempotencyRecord remembers a request made with an Idempotency-Key so a
+// retry gets the original response instead of repeating the side effects.
+// Status is 0 while the first request is still running.
+type IdempotencyRecord struct {
+	UserID      int
+	Key         string
+	Fingerprint string // sha256 of method, path and body
+	Status      int
+	Body        []byte
+	ContentType string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Completed reports whether the response has been stored.
+func (r IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Invoice document kinds. Each booking has at most one of each.
+const (
+	KindInvoice    = "invoice"
+	KindCreditNote = "credit_note" // refund on cancellation, against the invoice
+)
+
+// Invoice line kinds. Bookings have no fees, so there is no fee line.
+const (
+	LineRoom     = "room"
+	LineDiscount = "discount"
+	LineRefund   = "refund"
+	LineTax      = "tax" // included in the other lines, not added to them
+)
+
+// InvoiceNumber formats the sequence number of a document, e.g. INV-000042
+// or CN-000007.
+func InvoiceNumber(kind string, seq int) string {
+	prefix := "INV"
+	if kind == KindCreditNote {
+		prefix = "CN"
+	}
+	return fmt.Sprintf("%s-%06d", prefix, seq)
+}
+
+// InvoiceLine is one line of an invoice. Included lines (taxes) are already
+// part of the other amounts and do not add to the total.
+type InvoiceLine struct {
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	UnitCents   int    `json:"unit_cents,omitempty"` // only when Quantity × UnitCents is AmountCents
+	AmountCents int    `json:"amount_cents"`         // negative for discounts and refunds
+	Included    bool   `json:"included,omitempty"`
+}
+
+// Invoice is an issued invoice or credit note. It is a snapshot: later
+// changes to the booking, hotel or guest don't alter it.
+type Invoice struct {
+	ID            int           `json:"id"`
+	Number        string        `json:"number"`
+	Kind          string        `json:"kind"`
+	InvoiceNumber string        `json:"invoice_number,omitempty"` // the invoice a credit note corrects
+	BookingID     int           `json:"booking_id"`
+	UserID        int           `json:"user_id"`
+	Reference     string        `json:"reference"` // booking confirmation code
+	IssuedAt      time.Time     `json:"issued_at"`
+	Issuer        string        `json:"issuer"`
+	IssuerTaxID   string        `json:"issuer_tax_id,omitempty"`
+	BillTo        GuestContact  `json:"bill_to"`
+	Hotel         string        `json:"hotel"`
+	HotelLocation string        `json:"hotel_location"`
+	CheckIn       time.Time     `json:"check_in"`
+	CheckOut      time.Time     `json:"check_out"`
+	Currency      string        `json:"currency"`
+	Lines         []InvoiceLine `json:"lines"`
+	TaxCents      int           `json:"tax_cents"`   // included in the total
+	TotalCents    int           `json:"total_cents"` // negative for credit notes
+	// part of the total paid with loyalty points rather than money
+	PointsCents int `json:"points_cents,omitempty"`
+}
+
+// Title is the document name shown to people.
+func (inv Invoice) Title() string {
+	if inv.Kind == KindCreditNote {
+		return "Credit note"
+	}
+	return "Invoice"
+}
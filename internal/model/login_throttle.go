@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Login throttle scopes: failures are counted per account and per client IP.
+// Guest booking lookups have their own pair, so they never lock out logins.
+const (
+	ThrottleScopeAccount  = "account"
+	ThrottleScopeIP       = "ip"
+	ThrottleScopeBooking  = "booking" // booking reference
+	ThrottleScopeLookupIP = "lookup_ip"
+)
+
+// LoginAttempts is the failure counter for one account or IP.
+type LoginAttempts struct {
+	Scope       string    `json:"scope"`
+	Subject     string    `json:"subject"` // lower-cased email or client IP
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// LoginLockout is the audit record written each time a lockout starts.
+type LoginLockout struct {
+	ID          int        `json:"id"`
+	Scope       string     `json:"scope"`
+	Subject     string     `json:"subject"`
+	Failures    int        `json:"failures"`
+	IP          string     `json:"ip"` // client that triggered the lockout
+	LockedAt    time.Time  `json:"locked_at"`
+	LockedUntil time.Time  `json:"locked_until"`
+	UnlockedBy  *int       `json:"unlocked_by,omitempty"` // admin who lifted it early
+	UnlockedAt  *time.Time `json:"unlocked_at,omitempty"`
+}
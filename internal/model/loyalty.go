@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+// Loyalty ledger entry kinds. Entries are never changed or removed; a
+// balance is the sum of its entries.
+const (
+	LoyaltyEarn    = "earn"    // stay completed
+	LoyaltyReverse = "reverse" // earned points taken back when the booking is cancelled
+	LoyaltyRedeem  = "redeem"  // points spent on a booking
+	LoyaltyReturn  = "return"  // redeemed points given back when the booking is cancelled
+)
+
+// LoyaltyCurrency is the currency points are earned on and worth: one point
+// per whole unit spent, before tier bonuses, and one cent per point.
+const LoyaltyCurrency = "USD"
+
+// LoyaltyEntry is one line of a member's points ledger.
+type LoyaltyEntry struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	BookingID   *int      `json:"booking_id,omitempty"`
+	Kind        string    `json:"kind"`
+	Points      int       `json:"points"` // negative for reversals and redemptions
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LoyaltyTier is a membership level reached by points earned over the last
+// year. BonusPercent is added to the points earned on each stay.
+type LoyaltyTier struct {
+	Name         string `json:"name"`
+	MinPoints    int    `json:"min_points"`
+	BonusPercent int    `json:"bonus_percent"`
+}
+
+// LoyaltyTiers are ordered from the lowest.
+var LoyaltyTiers = []LoyaltyTier{
+	{Name: "member", MinPoints: 0, BonusPercent: 0},
+	{Name: "silver", MinPoints: 2000, BonusPercent: 25},
+	{Name: "gold", MinPoints: 5000, BonusPercent: 50},
+}
+
+// TierFor returns the tier reached with the given qualifying points and the
+// next one up, which is nil at the top.
+func TierFor(qualifying int) (LoyaltyTier, *LoyaltyTier) {
+	i := 0
+	for i+1 < len(LoyaltyTiers) && qualifying >= LoyaltyTiers[i+1].MinPoints {
+		i++
+	}
+	if i+1 < len(LoyaltyTiers) {
+		next := LoyaltyTiers[i+1]
+		return LoyaltyTiers[i], &next
+	}
+	return LoyaltyTiers[i], nil
+}
+
+// EarnPoints returns the points for spending spentCents of LoyaltyCurrency
+// at the given tier.
+func EarnPoints(spentCents int, tier LoyaltyTier) int {
+	return spentCents / 100 * (100 + tier.BonusPercent) / 100
+}
+
+// LoyaltyAccount summarizes a member's points.
+type LoyaltyAccount struct {
+	Balance          int          `json:"balance"`
+	ValueCents       int          `json:"value_cents"` // what the balance is worth in LoyaltyCurrency
+	Currency         string       `json:"currency"`
+	Tier             LoyaltyTier  `json:"tier"`
+	QualifyingPoints int          `json:"qualifying_points"` // earned over the last year
+	NextTier         *LoyaltyTier `json:"next_tier,omitempty"`
+	PointsToNextTier int          `json:"points_to_next_tier,omitempty"`
+}
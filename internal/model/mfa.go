@@ -0,0 +1,10 @@
+package model
+
+// MFA is a user's TOTP enrollment. Secret is set on enrollment and only
+// takes effect once Enabled after the first code is verified.
+type MFA struct {
+	UserID   int
+	Secret   string
+	Enabled  bool
+	LastStep int64 // last accepted TOTP step, to reject replayed codes
+}
@@ -0,0 +1,14 @@
+package model
+
+// Page is one page of a paginated listing.
+type Page[T any] struct {
+	Items   []T `json:"items"`
+	Total   int `json:"total"` // matches across all pages
+	Page    int `json:"page"`  // 1-based
+	PerPage int `json:"per_page"`
+}
+
+// Offset returns the number of items before page.
+func Offset(page, perPage int) int {
+	return (page - 1) * perPage
+}
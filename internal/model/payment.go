@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Payment statuses.
+const (
+	PaymentPending    = "pending"    // waiting for the gateway's result
+	PaymentAuthorized = "authorized" // card hold placed; booking confirmed
+	PaymentCaptured   = "captured"
+	PaymentVoided     = "voided"
+	PaymentRefunded   = "refunded" // fully refunded
+	PaymentFailed     = "failed"
+)
+
+// Payment is one attempt to pay for a booking through a gateway.
+type Payment struct {
+	ID            int       `json:"id"`
+	BookingID     int       `json:"booking_id"`
+	UserID        int       `json:"user_id"`
+	Gateway       string    `json:"gateway"`
+	GatewayRef    string    `json:"gateway_ref,omitempty"`
+	AmountCents   int       `json:"amount_cents"`
+	Currency      string    `json:"currency"`
+	CapturedCents int       `json:"captured_cents"`
+	RefundedCents int       `json:"refunded_cents"`
+	Status        string    `json:"status"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
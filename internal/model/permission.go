@@ -0,0 +1,64 @@
+package model
+
+// Permissions are "resource:action" strings, optionally narrowed with a
+// scope suffix such as ":own" or ":any". A granted permission also covers
+// every permission it prefixes ("hotels:write" covers "hotels:write:own"),
+// ":any" covers ":own", "resource:*" covers the whole resource and "*"
+// covers everything.
+const (
+	PermAll = "*"
+
+	PermHotelsWrite    = "hotels:write"     // create, import and edit any hotel
+	PermHotelsWriteOwn = "hotels:write:own" // edit linked hotels and their inventory
+	PermHotelsExport   = "hotels:export"
+	PermHotelsOwners   = "hotels:owners" // link partners and managers to hotels
+
+	PermBookingsReadAny   = "bookings:read:any"
+	PermBookingsReadHotel = "bookings:read:hotel" // bookings at linked hotels
+	PermBookingsCancelAny = "bookings:cancel:any" // cancel other users' bookings
+	PermBookingsModifyAny = "bookings:modify:any" // change other users' bookings
+
+	PermReviewsReply    = "reviews:reply"
+	PermReviewsModerate = "reviews:moderate"
+
+	PermRatesWrite = "rates:write"
+
+	PermPaymentsManage = "payments:manage" // capture, void and refund payments
+
+	PermPromosManage = "promos:manage" // create and edit promo codes
+
+	PermRBACManage = "rbac:manage"
+
+	PermUsersManage = "users:manage" // unlock, disable and sign out accounts
+)
+
+// KnownPermissions lists the permissions the API checks, for the admin UI.
+var KnownPermissions = []string{
+	PermHotelsWrite, PermHotelsWriteOwn, PermHotelsExport, PermHotelsOwners,
+	PermBookingsReadAny, PermBookingsReadHotel, PermBookingsCancelAny, PermBookingsModifyAny,
+	PermReviewsReply, PermReviewsModerate,
+	PermRatesWrite,
+	PermPaymentsManage,
+	PermPromosManage,
+	PermRBACManage,
+	PermUsersManage,
+}
+
+// Role is a named set of permissions that can be assigned to users.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	BuiltIn     bool     `json:"built_in"`
+}
+
+// DefaultRoles are created with the schema and cannot be deleted.
+var DefaultRoles = []Role{
+	{Name: RoleAdmin, Description: "Full access", Permissions: []string{PermAll}, BuiltIn: true},
+	{Name: RoleUser, Description: "Traveler", Permissions: []string{}, BuiltIn: true},
+	{Name: RoleManager, Description: "Hotel staff", Permissions: []string{PermReviewsReply}, BuiltIn: true},
+	{Name: RolePartner, Description: "Hotel owner", Permissions: []string{PermHotelsWriteOwn, PermBookingsReadHotel, PermReviewsReply}, BuiltIn: true},
+	{Name: "support", Description: "Customer support", Permissions: []string{PermBookingsReadAny, PermBookingsCancelAny, PermBookingsModifyAny, PermReviewsModerate, PermUsersManage}, BuiltIn: true},
+	{Name: "finance", Description: "Finance team", Permissions: []string{PermBookingsReadAny, PermRatesWrite, PermPaymentsManage}, BuiltIn: true},
+	{Name: "marketing", Description: "Marketing team", Permissions: []string{PermPromosManage}, BuiltIn: true},
+}
@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+// Promo code kinds.
+const (
+	PromoPercent = "percent" // PercentOff percent of the stay
+	PromoFixed   = "fixed"   // AmountOffCents in Currency
+)
+
+// PromoCode is a marketing discount guests enter when booking. Zero limits
+// mean no limit; empty hotel and destination lists mean every hotel.
+type PromoCode struct {
+	ID             int        `json:"id"`
+	Code           string     `json:"code"` // upper case, e.g. SUMMER25
+	Description    string     `json:"description"`
+	Kind           string     `json:"kind"`
+	PercentOff     int        `json:"percent_off,omitempty"`
+	AmountOffCents int        `json:"amount_off_cents,omitempty"`
+	Currency       string     `json:"currency,omitempty"` // of the fixed amount and minimum spend
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	MaxUses        int        `json:"max_uses"`          // bookings across all guests
+	MaxUsesPerUser int        `json:"max_uses_per_user"` // bookings per guest
+	MinNights      int        `json:"min_nights"`
+	MinSpendCents  int        `json:"min_spend_cents"` // before discounts
+	HotelIDs       []int      `json:"hotel_ids"`
+	Destinations   []string   `json:"destinations"`
+	// Stackable codes may be combined with other stackable codes; others
+	// must be used alone.
+	Stackable bool `json:"stackable"`
+	// ExcludeSaleRates keeps the code off hotels already showing a
+	// strike-through original price.
+	ExcludeSaleRates bool      `json:"exclude_sale_rates"`
+	Active           bool      `json:"active"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Live reports whether the code can be used at the given time.
+func (p PromoCode) Live(now time.Time) bool {
+	return p.Active && (p.StartsAt == nil || !now.Before(*p.StartsAt)) && (p.EndsAt == nil || now.Before(*p.EndsAt))
+}
+
+// AppliedPromo is a promo code as applied to one booking. The discount
+// terms are copied in the hotel's currency so the booking can be repriced
+// when it changes.
+type AppliedPromo struct {
+	PromoID        int    `json:"promo_id"`
+	Code           string `json:"code"`
+	Kind           string `json:"kind"`
+	PercentOff     int    `json:"percent_off,omitempty"`
+	AmountOffCents int    `json:"amount_off_cents,omitempty"`
+	DiscountCents  int    `json:"discount_cents"`
+}
+
+// ApplyPromos works out each code's discount on subtotalCents and returns
+// the total discount. Every code is taken off the undiscounted subtotal and
+// together they never exceed it.
+func ApplyPromos(subtotalCents int, promos []AppliedPromo) int {
+	left := subtotalCents
+	for i := range promos {
+		d := promos[i].AmountOffCents
+		if promos[i].Kind == PromoPercent {
+			d = subtotalCents * promos[i].PercentOff / 100
+		}
+		d = min(d, left)
+		promos[i].DiscountCents = d
+		left -= d
+	}
+	return subtotalCents - left
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Review is a guest review of a hotel. New reviews go through moderation
+// before they are shown publicly.
+type Review struct {
+	ID               int          `json:"id"`
+	HotelID          int          `json:"hotel_id"`
+	UserID           int          `json:"user_id"`
+	Rating           int          `json:"rating"` // 1-5
+	Title            string       `json:"title"`
+	Body             string       `json:"body"`
+	Status           string       `json:"status"`                      // pending / approved / rejected
+	Flags            string       `json:"flags,omitempty"`             // comma-separated filter flags
+	ModerationReason string       `json:"moderation_reason,omitempty"` // set when rejected
+	ModeratedBy      *int         `json:"moderated_by,omitempty"`
+	Reply            *ReviewReply `json:"reply,omitempty"`
+	CreatedAt        time.Time    `json:"created_at"`
+}
+
+// ReviewReply is the single public response a hotel manager can post on a review.
+type ReviewReply struct {
+	ReviewID  int       `json:"review_id"`
+	UserID    int       `json:"user_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
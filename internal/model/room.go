@@ -3,6 +3,7 @@ package model
 // Room represents a hotel room
 type Room struct {
 	ID                 int     `json:"id"`
+	ExternalRef        string  `json:"external_ref,omitempty"` // partner's own identifier, used for bulk upserts
 	Name               string  `json:"name"`
 	Description        string  `json:"description"`
 	Location           string  `json:"location"`
@@ -11,6 +12,7 @@ type Room struct {
 	Reviews            int     `json:"reviews"`
 	PriceCents         int     `json:"price_cents"`
 	OriginalPriceCents *int    `json:"original_price_cents,omitempty"`
+	Currency           string  `json:"currency"` // ISO 4217 code the prices are in
 	Amenities          string  `json:"amenities"`
 	Featured           bool    `json:"featured"`
 	MaxAdults          int     `json:"max_adults"`
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Session is one issued access token, identified by its jti, so users can
+// see where they are logged in and revoke individual logins.
+type Session struct {
+	ID         string     `json:"id"` // token jti
+	UserID     int        `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current"` // the session making the request
+}
+
+// Active reports whether the session can still be used at now.
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+// OIDCLogin is a login started with an identity provider, kept until the
+// provider redirects back with its state.
+type OIDCLogin struct {
+	State     string
+	Provider  string
+	Nonce     string
+	Verifier  string // PKCE code verifier
+	ExpiresAt time.Time
+}
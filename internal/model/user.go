@@ -1,9 +1,39 @@
 package model
 
+// User roles.
+const (
+	RoleAdmin   = "admin"
+	RoleUser    = "user"
+	RoleManager = "manager" // hotel staff: replies to reviews for linked hotels
+	RolePartner = "partner" // hotel owner: manages linked hotels, inventory and bookings
+)
+
+// User account statuses. Disabled users cannot log in and their tokens are
+// refused.
+const (
+	UserActive   = "active"
+	UserDisabled = "disabled"
+)
+
 type User struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"-"`    // plaintext for demo; in real app store hashed password
-	Role     string `json:"role"` // "admin" or "user"
+	Role     string `json:"role"` // "admin", "user", "manager" or "partner"
+
+	EmailVerified     bool   `json:"email_verified"`
+	Phone             string `json:"phone"`
+	PreferredCurrency string `json:"preferred_currency"` // ISO 4217, empty for the hotel's own
+	Language          string `json:"language"`           // e.g. "en" or "th-TH"
+	Status            string `json:"status"`             // "active" or "disabled"
+}
+
+// UserSearch filters the admin user listing. Query matches name or email.
+type UserSearch struct {
+	Query   string
+	Role    string
+	Status  string
+	Page    int
+	PerPage int
 }
@@ -0,0 +1,136 @@
+// Package money holds currency helpers shared by services and repositories.
+// Amounts are always integers in the currency's minor unit (e.g. cents).
+package money
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used for hotels and bookings created without one.
+const DefaultCurrency = "USD"
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// zeroDecimal lists ISO 4217 currencies without a minor unit. Everything
+// else is treated as having two decimals.
+var zeroDecimal = map[string]bool{"JPY": true, "KRW": true, "VND": true, "IDR": true, "CLP": true, "ISK": true}
+
+// Normalize upper-cases and trims a currency code.
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Valid reports whether code looks like an ISO 4217 alphabetic code.
+func Valid(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// MinorUnits returns the number of decimals used by the currency.
+func MinorUnits(code string) int {
+	if zeroDecimal[Normalize(code)] {
+		return 0
+	}
+	return 2
+}
+
+// Format renders an amount for people, e.g. Format(189050, "USD") is
+// "USD 1,890.50".
+func Format(amount int, code string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	units := MinorUnits(code)
+	div := 1
+	for i := 0; i < units; i++ {
+		div *= 10
+	}
+	whole := strconv.Itoa(amount / div)
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	if units > 0 {
+		b.WriteByte('.')
+		frac := strconv.Itoa(amount % div)
+		b.WriteString(strings.Repeat("0", units-len(frac)) + frac)
+	}
+	return Normalize(code) + " " + sign + b.String()
+}
+
+// ParseRate parses a positive decimal exchange rate such as "35.8125".
+func ParseRate(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() <= 0 {
+		return nil, ErrInvalidRate
+	}
+	return r, nil
+}
+
+// FormatRate renders a rate with up to 10 decimals and no trailing zeros.
+func FormatRate(r *big.Rat) string {
+	s := r.FloatString(10)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Convert converts an amount in from's minor unit to to's minor unit using
+// rate (units of to per unit of from). The exact result is rounded half away
+// from zero so conversions never drift by more than half a minor unit.
+func Convert(amount int, from string, to string, rate *big.Rat) int {
+	v := new(big.Rat).Mul(big.NewRat(int64(amount), 1), rate)
+	shift := MinorUnits(to) - MinorUnits(from)
+	if shift != 0 {
+		p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil)
+		f := new(big.Rat).SetInt(p)
+		if shift > 0 {
+			v.Mul(v, f)
+		} else {
+			v.Quo(v, f)
+		}
+	}
+	return int(roundHalfAwayFromZero(v).Int64())
+}
+
+// IncludedPercent returns the part of amount that is a percentage added on
+// top of a base, as with tax included in a price: amount × percent / (100 +
+// percent), rounded half away from zero like Convert.
+func IncludedPercent(amount int, percent *big.Rat) int {
+	share := new(big.Rat).Quo(percent, new(big.Rat).Add(percent, big.NewRat(100, 1)))
+	return int(roundHalfAwayFromZero(share.Mul(share, big.NewRat(int64(amount), 1))).Int64())
+}
+
+func roundHalfAwayFromZero(v *big.Rat) *big.Int {
+	num := new(big.Int).Abs(v.Num())
+	den := v.Denom()
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(r, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if v.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
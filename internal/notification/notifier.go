@@ -0,0 +1,133 @@
+// Package notification emails guests about their bookings.
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	texttemplate "text/template"
+
+	"agodrift/internal/config"
+	"agodrift/internal/mail"
+	"agodrift/internal/model"
+	"agodrift/internal/money"
+	"agodrift/internal/repository"
+)
+
+// Booking events that send an email. Each has a template file defining
+// "<event>.subject", "<event>.text" and "<event>.html".
+const (
+	BookingCreated   = "booking_created"
+	BookingConfirmed = "booking_confirmed"
+	BookingModified  = "booking_modified"
+	BookingCancelled = "booking_cancelled"
+	StayReminder     = "stay_reminder"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.tmpl"))
+)
+
+// Notifier renders booking emails and hands them to a mailer. Lookups happen
+// on the caller's goroutine; delivery is up to the mailer, normally a
+// mail.Queue so requests never wait on SMTP.
+type Notifier struct {
+	users   repository.UserRepository
+	rooms   repository.RoomRepository
+	mailer  mail.Mailer
+	baseURL string
+}
+
+// NewNotifier sends through the shared background mail queue.
+func NewNotifier() *Notifier {
+	db := config.GetDB()
+	return NewNotifierWith(repository.NewMySQLUserRepo(db), repository.NewMySQLRoomRepo(db), mail.DefaultQueue(), config.Get("APP_BASE_URL", "http://localhost:3000"))
+}
+
+func NewNotifierWith(users repository.UserRepository, rooms repository.RoomRepository, mailer mail.Mailer, baseURL string) *Notifier {
+	return &Notifier{users: users, rooms: rooms, mailer: mailer, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Notify emails the booking's guest about event. Failures are logged, never
+// returned: a booking must not fail because of its email.
+func (n *Notifier) Notify(event string, b model.Booking) {
+	if n == nil {
+		return
+	}
+	msg, err := n.Render(event, b)
+	if err != nil {
+		log.Printf("notification: %s for booking %d: %v", event, b.ID, err)
+		return
+	}
+	if err := n.mailer.Send(msg); err != nil {
+		log.Printf("notification: %s for booking %d: %v", event, b.ID, err)
+	}
+}
+
+// Render builds the email for event without sending it.
+func (n *Notifier) Render(event string, b model.Booking) (mail.Message, error) {
+	u, ok := n.users.GetByID(b.UserID)
+	if !ok {
+		return mail.Message{}, fmt.Errorf("user %d not found", b.UserID)
+	}
+	hotel, ok := n.rooms.Get(b.HotelID)
+	if !ok {
+		return mail.Message{}, fmt.Errorf("hotel %d not found", b.HotelID)
+	}
+	data := bookingData{
+		Guest:     u.Name,
+		Booking:   b,
+		Hotel:     hotel,
+		Nights:    model.Nights(b.CheckIn, b.CheckOut),
+		CheckIn:   b.CheckIn.Format("Mon, 2 Jan 2006"),
+		CheckOut:  b.CheckOut.Format("Mon, 2 Jan 2006"),
+		Total:     money.Format(b.TotalPriceCents, b.Currency),
+		ManageURL: fmt.Sprintf("%s/bookings/%d", n.baseURL, b.ID),
+		Reference: b.Reference,
+	}
+	if data.Reference == "" {
+		data.Reference = fmt.Sprintf("#%d", b.ID)
+	}
+	if b.DisplayTotalCents != nil {
+		data.DisplayTotal = money.Format(*b.DisplayTotalCents, b.DisplayCurrency)
+	}
+	if data.Guest == "" {
+		data.Guest = "there"
+	}
+	var subject, text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, event+".subject", data); err != nil {
+		return mail.Message{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, event+".text", data); err != nil {
+		return mail.Message{}, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, event+".html", data); err != nil {
+		return mail.Message{}, err
+	}
+	return mail.Message{
+		To:      u.Email,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+type bookingData struct {
+	Guest        string
+	Reference    string // confirmation code, or #id for older bookings
+	Booking      model.Booking
+	Hotel        model.Room
+	Nights       int
+	CheckIn      string
+	CheckOut     string
+	Total        string
+	DisplayTotal string // total in the guest's currency, if they asked for one
+	ManageURL    string
+}
@@ -0,0 +1,106 @@
+package payment
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Test tokens understood by the fake gateway. Any other token is approved.
+const (
+	TokenDeclined          = "tok_declined"
+	TokenInsufficientFunds = "tok_insufficient_funds"
+	TokenPending           = "tok_pending" // settled later by a webhook event
+)
+
+type fakeTransaction struct {
+	authorized int
+	captured   int
+	refunded   int
+	voided     bool
+}
+
+// FakeGateway approves or declines by card token and keeps transactions in
+// memory. Results depend only on the request, so local runs and tests are
+// repeatable.
+type FakeGateway struct {
+	secret string
+	mu     sync.Mutex
+	txns   map[string]*fakeTransaction
+}
+
+func NewFakeGateway(webhookSecret string) *FakeGateway {
+	return &FakeGateway{secret: webhookSecret, txns: make(map[string]*fakeTransaction)}
+}
+
+func (g *FakeGateway) Name() string { return "fake" }
+
+func (g *FakeGateway) Authorize(req AuthorizeRequest) (Result, error) {
+	ref := "fake_" + req.Reference
+	switch req.Token {
+	case TokenDeclined:
+		return Result{Ref: ref, Status: StatusFailed, Reason: "card_declined"}, nil
+	case TokenInsufficientFunds:
+		return Result{Ref: ref, Status: StatusFailed, Reason: "insufficient_funds"}, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.txns[ref]; !ok {
+		g.txns[ref] = &fakeTransaction{authorized: req.AmountCents}
+	}
+	if req.Token == TokenPending {
+		return Result{Ref: ref, Status: StatusPending}, nil
+	}
+	return Result{Ref: ref, Status: StatusSucceeded}, nil
+}
+
+func (g *FakeGateway) Capture(ref string, amountCents int) (Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	t, ok := g.txns[ref]
+	if !ok || t.voided {
+		return Result{}, ErrUnknownTransaction
+	}
+	if t.captured+amountCents > t.authorized {
+		return Result{}, ErrInvalidAmount
+	}
+	t.captured += amountCents
+	return Result{Ref: ref, Status: StatusSucceeded}, nil
+}
+
+func (g *FakeGateway) Void(ref string) (Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	t, ok := g.txns[ref]
+	if !ok || t.captured > 0 {
+		return Result{}, ErrUnknownTransaction
+	}
+	t.voided = true
+	return Result{Ref: ref, Status: StatusSucceeded}, nil
+}
+
+func (g *FakeGateway) Refund(ref string, amountCents int) (Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	t, ok := g.txns[ref]
+	if !ok {
+		return Result{}, ErrUnknownTransaction
+	}
+	if t.refunded+amountCents > t.captured {
+		return Result{}, ErrInvalidAmount
+	}
+	t.refunded += amountCents
+	return Result{Ref: ref, Status: StatusSucceeded}, nil
+}
+
+// ParseWebhook accepts an Event as JSON signed with Sign.
+func (g *FakeGateway) ParseWebhook(body []byte, signature string) (Event, error) {
+	if !VerifySignature(g.secret, body, strings.TrimSpace(signature)) {
+		return Event{}, ErrInvalidSignature
+	}
+	var e Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		return Event{}, err
+	}
+	return e, nil
+}
@@ -0,0 +1,145 @@
+// Package payment talks to card payment gateways through a common Gateway
+// interface. Amounts are in the currency's minor unit, as everywhere else.
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"agodrift/internal/config"
+)
+
+var (
+	ErrUnknownTransaction = errors.New("unknown gateway transaction")
+	ErrInvalidAmount      = errors.New("amount exceeds what the transaction allows")
+	ErrInvalidSignature   = errors.New("invalid webhook signature")
+)
+
+// Result statuses. A pending result is settled later by a webhook event.
+const (
+	StatusSucceeded = "succeeded"
+	StatusPending   = "pending"
+	StatusFailed    = "failed"
+)
+
+// Result is the gateway's answer to an operation.
+type Result struct {
+	Ref    string // gateway transaction id
+	Status string
+	Reason string // decline or failure reason
+}
+
+// AuthorizeRequest places a hold on the guest's card. Reference is ours and
+// unique per payment, so gateways can deduplicate retries.
+type AuthorizeRequest struct {
+	Reference   string
+	AmountCents int
+	Currency    string
+	Token       string // card token from the gateway's client-side SDK
+}
+
+// Webhook event types.
+const (
+	EventAuthorizationSucceeded = "authorization.succeeded"
+	EventAuthorizationFailed    = "authorization.failed"
+	EventCaptureSucceeded       = "capture.succeeded"
+)
+
+// Event is an asynchronous result delivered to the webhook endpoint.
+type Event struct {
+	Type        string `json:"type"`
+	Ref         string `json:"gateway_ref"`
+	AmountCents int    `json:"amount_cents,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Gateway is a card payment provider.
+type Gateway interface {
+	Name() string
+	Authorize(req AuthorizeRequest) (Result, error)
+	// Capture collects up to the authorized amount.
+	Capture(ref string, amountCents int) (Result, error)
+	// Void releases an authorization that was not captured.
+	Void(ref string) (Result, error)
+	// Refund returns up to the captured amount.
+	Refund(ref string, amountCents int) (Result, error)
+	// ParseWebhook verifies and decodes a webhook request body.
+	ParseWebhook(body []byte, signature string) (Event, error)
+}
+
+var (
+	envGateway     Gateway
+	envErr         error
+	envGatewayOnce sync.Once
+)
+
+// FromEnv returns the process's gateway, picked by PAYMENT_GATEWAY. Only
+// "fake" ships with the app, and since it approves any other card token it
+// has to be chosen explicitly. Webhooks are signed with
+// PAYMENT_WEBHOOK_SECRET, which has no default.
+func FromEnv() (Gateway, error) {
+	envGatewayOnce.Do(func() {
+		envGateway, envErr = fromEnv()
+	})
+	return envGateway, envErr
+}
+
+func fromEnv() (Gateway, error) {
+	name := config.Get("PAYMENT_GATEWAY", "")
+	secret := config.Get("PAYMENT_WEBHOOK_SECRET", "")
+	switch {
+	case name == "":
+		return nil, errors.New("payment: PAYMENT_GATEWAY is not set")
+	case name != "fake":
+		return nil, fmt.Errorf("payment: unknown PAYMENT_GATEWAY %q", name)
+	case secret == "":
+		return nil, errors.New("payment: PAYMENT_WEBHOOK_SECRET is not set")
+	}
+	return NewFakeGateway(secret), nil
+}
+
+// Unavailable returns a gateway that fails every operation with err. It
+// stands in when payments are not configured, for commands that never
+// take payments.
+func Unavailable(err error) Gateway {
+	return unavailableGateway{err: err}
+}
+
+type unavailableGateway struct {
+	err error
+}
+
+func (g unavailableGateway) Name() string { return "unavailable" }
+
+func (g unavailableGateway) Authorize(AuthorizeRequest) (Result, error) { return Result{}, g.err }
+
+func (g unavailableGateway) Capture(string, int) (Result, error) { return Result{}, g.err }
+
+func (g unavailableGateway) Void(string) (Result, error) { return Result{}, g.err }
+
+func (g unavailableGateway) Refund(string, int) (Result, error) { return Result{}, g.err }
+
+func (g unavailableGateway) ParseWebhook([]byte, string) (Event, error) { return Event{}, g.err }
+
+// Sign returns the hex HMAC-SHA256 of body, as sent in the webhook
+// signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a signature made by Sign in constant time.
+func VerifySignature(secret string, body []byte, signature string) bool {
+	want, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
@@ -0,0 +1,75 @@
+// Package refcode generates and checks booking reference codes such as
+// AGD-7K3QX9: five random characters and a check character from the
+// Crockford base32 alphabet, which has no I, L, O or U to misread over the
+// phone. The check character (Luhn mod 32) catches any single mistyped
+// character and most swapped pairs.
+package refcode
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+const (
+	Prefix   = "AGD-"
+	alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// payloadLength random characters are followed by one check character
+	payloadLength = 5
+)
+
+var base = big.NewInt(int64(len(alphabet)))
+
+// New returns a random, valid code.
+func New() (string, error) {
+	payload := make([]byte, payloadLength)
+	for i := range payload {
+		n, err := rand.Int(rand.Reader, base)
+		if err != nil {
+			return "", err
+		}
+		payload[i] = alphabet[n.Int64()]
+	}
+	return Prefix + string(payload) + string(checkChar(string(payload))), nil
+}
+
+// Normalize returns code in canonical form: upper case, with the prefix and
+// without spaces. The prefix is optional on input and look-alike characters
+// are mapped as in Crockford base32 (O to 0, I and L to 1).
+func Normalize(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.NewReplacer(" ", "", "-", "", "O", "0", "I", "1", "L", "1").Replace(code)
+	code = strings.TrimPrefix(code, strings.TrimSuffix(Prefix, "-"))
+	return Prefix + code
+}
+
+// Valid reports whether a normalized code is well formed and its check
+// character matches.
+func Valid(code string) bool {
+	body, ok := strings.CutPrefix(code, Prefix)
+	if !ok || len(body) != payloadLength+1 {
+		return false
+	}
+	for i := 0; i < len(body); i++ {
+		if strings.IndexByte(alphabet, body[i]) < 0 {
+			return false
+		}
+	}
+	return checkChar(body[:payloadLength]) == body[payloadLength]
+}
+
+// checkChar computes the Luhn mod N check character of payload.
+func checkChar(payload string) byte {
+	n := len(alphabet)
+	factor, sum := 2, 0
+	for i := len(payload) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(alphabet, payload[i])
+		if factor == 2 {
+			factor = 1
+		} else {
+			factor = 2
+		}
+		sum += addend/n + addend%n
+	}
+	return alphabet[(n-sum%n)%n]
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyRepository stores hashed API keys.
+type APIKeyRepository interface {
+	Create(k model.APIKey) (model.APIKey, error)
+	Get(id int) (model.APIKey, bool)
+	GetByPrefix(prefix string) (model.APIKey, bool)
+	ListByUser(userID int) ([]model.APIKey, error)
+	Revoke(userID int, id int, at time.Time) error
+	Touch(id int, at time.Time) error
+}
+
+type inMemoryAPIKeyRepo struct {
+	mu   sync.RWMutex
+	keys map[int]model.APIKey
+	next int
+}
+
+func NewInMemoryAPIKeyRepo() *inMemoryAPIKeyRepo {
+	return &inMemoryAPIKeyRepo{keys: make(map[int]model.APIKey), next: 1}
+}
+
+func (r *inMemoryAPIKeyRepo) Create(k model.APIKey) (model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k.ID = r.next
+	r.next++
+	r.keys[k.ID] = k
+	return k, nil
+}
+
+func (r *inMemoryAPIKeyRepo) Get(id int) (model.APIKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	k, ok := r.keys[id]
+	return k, ok
+}
+
+func (r *inMemoryAPIKeyRepo) GetByPrefix(prefix string) (model.APIKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.Prefix == prefix {
+			return k, true
+		}
+	}
+	return model.APIKey{}, false
+}
+
+func (r *inMemoryAPIKeyRepo) ListByUser(userID int) ([]model.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.APIKey, 0)
+	for _, k := range r.keys {
+		if k.UserID == userID {
+			out = append(out, k)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+func (r *inMemoryAPIKeyRepo) Revoke(userID int, id int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[id]
+	if !ok || k.UserID != userID || k.RevokedAt != nil {
+		return ErrAPIKeyNotFound
+	}
+	k.RevokedAt = &at
+	r.keys[id] = k
+	return nil
+}
+
+func (r *inMemoryAPIKeyRepo) Touch(id int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if k, ok := r.keys[id]; ok {
+		k.LastUsedAt = &at
+		r.keys[id] = k
+	}
+	return nil
+}
+
+type mysqlAPIKeyRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLAPIKeyRepo(db *sql.DB) *mysqlAPIKeyRepo {
+	return &mysqlAPIKeyRepo{db: db}
+}
+
+const apiKeySelect = "SELECT id, user_id, name, prefix, key_hash, permissions, expires_at, last_used_at, created_at, revoked_at FROM api_keys"
+
+func (r *mysqlAPIKeyRepo) Create(k model.APIKey) (model.APIKey, error) {
+	res, err := r.db.Exec("INSERT INTO api_keys (user_id, name, prefix, key_hash, permissions, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		k.UserID, k.Name, k.Prefix, k.Hash, strings.Join(k.Permissions, ","), k.ExpiresAt, k.CreatedAt)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+	id, _ := res.LastInsertId()
+	k.ID = int(id)
+	return k, nil
+}
+
+func (r *mysqlAPIKeyRepo) Get(id int) (model.APIKey, bool) {
+	k, err := scanAPIKey(r.db.QueryRow(apiKeySelect+" WHERE id = ?", id))
+	return k, err == nil
+}
+
+func (r *mysqlAPIKeyRepo) GetByPrefix(prefix string) (model.APIKey, bool) {
+	k, err := scanAPIKey(r.db.QueryRow(apiKeySelect+" WHERE prefix = ?", prefix))
+	return k, err == nil
+}
+
+func (r *mysqlAPIKeyRepo) ListByUser(userID int) ([]model.APIKey, error) {
+	rows, err := r.db.Query(apiKeySelect+" WHERE user_id = ? ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]model.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (r *mysqlAPIKeyRepo) Revoke(userID int, id int, at time.Time) error {
+	res, err := r.db.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL", at, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *mysqlAPIKeyRepo) Touch(id int, at time.Time) error {
+	_, err := r.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", at, id)
+	return err
+}
+
+func scanAPIKey(row rowScanner) (model.APIKey, error) {
+	var k model.APIKey
+	var perms string
+	var expires, lastUsed, revoked sql.NullTime
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Hash, &perms, &expires, &lastUsed, &k.CreatedAt, &revoked); err != nil {
+		return k, err
+	}
+	k.Permissions = strings.Split(perms, ",")
+	k.ExpiresAt = nullTimePtr(expires)
+	k.LastUsedAt = nullTimePtr(lastUsed)
+	k.RevokedAt = nullTimePtr(revoked)
+	return k, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
@@ -3,17 +3,58 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	"agodrift/internal/model"
+	"agodrift/internal/money"
+	"agodrift/internal/refcode"
+
+	"github.com/go-sql-driver/mysql"
 )
 
-var ErrNotEnoughRooms = errors.New("not enough rooms available")
+var (
+	ErrNotEnoughRooms       = errors.New("not enough rooms available")
+	ErrHotelCurrencyChanged = errors.New("hotel currency changed")
+	ErrBookingNotFound      = errors.New("booking not found")
+	ErrBookingCancelled     = errors.New("booking is already cancelled")
+	ErrBookingStarted       = errors.New("bookings can only be changed or cancelled before check-in")
+	ErrPointsExceedTotal    = errors.New("loyalty points are worth more than the booking total")
+	// ErrConfirmedAmountDue: only pending bookings can be paid, so a
+	// confirmed one must not be repriced above what has been paid for it
+	ErrConfirmedAmountDue = errors.New("confirmed booking would be left with an amount due")
+)
 
 type BookingRepository interface {
-	Create(userID int, hotelID int, checkIn time.Time, checkOut time.Time, adults int, children int, rooms int) (model.Booking, error)
+	Create(b model.Booking) (model.Booking, error)
 	ListByUserID(userID int) ([]model.Booking, error)
+	// Search returns one page of a user's bookings and the total matches.
+	Search(f model.BookingSearch) ([]model.Booking, int, error)
+	// StatusHistory returns the status changes of each booking, oldest first.
+	StatusHistory(ids []int) (map[int][]model.BookingStatusChange, error)
+	ListByHotelIDs(hotelIDs []int) ([]model.Booking, error)
+	GetByID(id int) (model.Booking, error)
+	GetByReference(code string) (model.Booking, error)
+	SetGuestDetails(id int, d model.GuestDetails) error
+	// Modify changes the booking's dates, guests or rooms and records the change.
+	Modify(id int, changedBy int, terms model.BookingTerms, exchangeRate string) (model.Booking, model.BookingChange, error)
+	// Transition moves the booking from one status to another and records it
+	// in the history; false if the booking was not in status from.
+	Transition(id int, from string, to string) (bool, error)
+	// Cancel marks the booking cancelled and returns its rooms to inventory,
+	// or returns ErrBookingStarted if its stay starts on or before today.
+	// Loyalty points it earned are taken back and returnPoints of those
+	// redeemed on it given back.
+	Cancel(id int, today time.Time, returnPoints int) (model.Booking, error)
+	// ListCheckingIn returns active bookings whose stay starts on day.
+	ListCheckingIn(day time.Time) ([]model.Booking, error)
+	// MarkReminderSent records the stay reminder; false if already sent.
+	MarkReminderSent(id int) (bool, error)
+	// ListCompleted returns confirmed bookings checked out on or before day
+	// that have not earned loyalty points yet.
+	ListCompleted(day time.Time) ([]model.Booking, error)
 }
 
 type mysqlBookingRepo struct {
@@ -24,7 +65,15 @@ func NewMySQLBookingRepo(db *sql.DB) *mysqlBookingRepo {
 	return &mysqlBookingRepo{db: db}
 }
 
-func (r *mysqlBookingRepo) Create(userID int, hotelID int, checkIn time.Time, checkOut time.Time, adults int, children int, rooms int) (model.Booking, error) {
+// Create prices and stores a booking and decrements hotel inventory. Promo
+// codes in b.Promos are taken off the price and count as used unless that
+// would exceed their limits (ErrPromoUsedUp). b.PointsRedeemed loyalty
+// points worth b.PointsValueCents are spent (ErrNotEnoughPoints), and a
+// booking they pay for in full is confirmed right away. When
+// b.DisplayCurrency and b.ExchangeRate are set, the display total is derived
+// from the locked hotel price with that rate; b.Currency must then match the
+// hotel's currency the rate was quoted from.
+func (r *mysqlBookingRepo) Create(b model.Booking) (model.Booking, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -38,29 +87,72 @@ func (r *mysqlBookingRepo) Create(userID int, hotelID int, checkIn time.Time, ch
 
 	// Lock hotel row to prevent overselling rooms
 	var priceCents int
+	var currency string
 	var roomsAvailable int
-	err = tx.QueryRowContext(ctx, "SELECT price_cents, rooms_available FROM hotels WHERE id = ? FOR UPDATE", hotelID).Scan(&priceCents, &roomsAvailable)
+	err = tx.QueryRowContext(ctx, "SELECT price_cents, currency, rooms_available FROM hotels WHERE id = ? FOR UPDATE", b.HotelID).Scan(&priceCents, &currency, &roomsAvailable)
 	if err != nil {
 		return model.Booking{}, err
 	}
-	if roomsAvailable < rooms {
+	if roomsAvailable < b.Rooms {
 		return model.Booking{}, ErrNotEnoughRooms
 	}
+	if b.Currency != "" && b.Currency != currency {
+		return model.Booking{}, ErrHotelCurrencyChanged
+	}
+	b.Currency = currency
 
-	// nights = difference in days
-	nights := int(checkOut.Sub(checkIn).Hours() / 24)
-	if nights < 1 {
-		nights = 1
+	displayTotal, err := priceStay(&b, priceCents)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if b.PointsValueCents > b.TotalPriceCents {
+		return model.Booking{}, ErrPointsExceedTotal
+	}
+	b.Status = model.BookingPending
+	if b.PointsRedeemed > 0 && b.AmountDueCents() == 0 {
+		b.Status = model.BookingConfirmed
 	}
-	total := priceCents * nights * rooms
 
-	res, err := tx.ExecContext(ctx, "INSERT INTO bookings (user_id, hotel_id, check_in, check_out, adults, children, rooms, total_price_cents, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", userID, hotelID, checkIn, checkOut, adults, children, rooms, total, "pending")
+	lead := leadGuest(b.LeadGuest)
+	var policy []byte // NULL without a policy
+	if b.CancellationPolicy != nil {
+		if policy, err = json.Marshal(b.CancellationPolicy); err != nil {
+			return model.Booking{}, err
+		}
+	}
+	var res sql.Result
+	// references are random, so retry the rare collision with a new one
+	for attempt := 0; ; attempt++ {
+		if b.Reference, err = refcode.New(); err != nil {
+			return model.Booking{}, err
+		}
+		res, err = tx.ExecContext(ctx, "INSERT INTO bookings (reference, user_id, hotel_id, check_in, check_out, adults, children, rooms, total_price_cents, discount_cents, points_redeemed, points_value_cents, currency, display_currency, display_total_cents, exchange_rate, status, lead_guest_name, lead_guest_email, lead_guest_phone, special_requests, arrival_time, cancellation_policy) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			b.Reference, b.UserID, b.HotelID, b.CheckIn, b.CheckOut, b.Adults, b.Children, b.Rooms, b.TotalPriceCents, b.DiscountCents, b.PointsRedeemed, b.PointsValueCents, b.Currency,
+			sql.NullString{String: b.DisplayCurrency, Valid: displayTotal.Valid}, displayTotal, sql.NullString{String: b.ExchangeRate, Valid: displayTotal.Valid}, b.Status,
+			lead.Name, lead.Email, lead.Phone, b.SpecialRequests, b.ArrivalTime, policy)
+		var me *mysql.MySQLError
+		if err == nil || attempt == 4 || !errors.As(err, &me) || me.Number != 1062 {
+			break
+		}
+	}
 	if err != nil {
 		return model.Booking{}, err
 	}
 	id64, _ := res.LastInsertId()
+	if err := insertGuests(ctx, tx, int(id64), b.Guests); err != nil {
+		return model.Booking{}, err
+	}
+	if err := claimPromos(ctx, tx, int(id64), b.UserID, b.Promos); err != nil {
+		return model.Booking{}, err
+	}
+	if err := redeemPoints(ctx, tx, int(id64), b.UserID, b.PointsRedeemed); err != nil {
+		return model.Booking{}, err
+	}
+	if err := recordStatus(ctx, tx, int(id64), b.Status); err != nil {
+		return model.Booking{}, err
+	}
 
-	result, err := tx.ExecContext(ctx, "UPDATE hotels SET rooms_available = rooms_available - ? WHERE id = ? AND rooms_available >= ?", rooms, hotelID, rooms)
+	result, err := tx.ExecContext(ctx, "UPDATE hotels SET rooms_available = rooms_available - ? WHERE id = ? AND rooms_available >= ?", b.Rooms, b.HotelID, b.Rooms)
 	if err != nil {
 		return model.Booking{}, err
 	}
@@ -73,22 +165,508 @@ func (r *mysqlBookingRepo) Create(userID int, hotelID int, checkIn time.Time, ch
 		return model.Booking{}, err
 	}
 
-	return model.Booking{
-		ID:              int(id64),
-		UserID:          userID,
-		HotelID:         hotelID,
-		CheckIn:         checkIn,
-		CheckOut:        checkOut,
-		Adults:          adults,
-		Children:        children,
-		Rooms:           rooms,
-		TotalPriceCents: total,
-		Status:          "pending",
-	}, nil
+	b.ID = int(id64)
+	if !displayTotal.Valid {
+		b.DisplayCurrency = ""
+		b.ExchangeRate = ""
+	}
+	return b, nil
+}
+
+// priceStay sets the booking total from the nightly price less its promo
+// codes and, when a display currency and rate are set, the converted display
+// total it also returns.
+func priceStay(b *model.Booking, nightlyCents int) (sql.NullInt64, error) {
+	subtotal := nightlyCents * model.Nights(b.CheckIn, b.CheckOut) * b.Rooms
+	b.DiscountCents = model.ApplyPromos(subtotal, b.Promos)
+	b.TotalPriceCents = subtotal - b.DiscountCents
+	b.DisplayTotalCents = nil
+	if b.DisplayCurrency == "" || b.ExchangeRate == "" {
+		return sql.NullInt64{}, nil
+	}
+	rate, err := money.ParseRate(b.ExchangeRate)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	v := money.Convert(b.TotalPriceCents, b.Currency, b.DisplayCurrency, rate)
+	b.DisplayTotalCents = &v
+	return sql.NullInt64{Int64: int64(v), Valid: true}, nil
+}
+
+// Modify reprices the booking with new terms at the hotel's current price and
+// moves only the difference in rooms in or out of inventory, recording the
+// change. exchangeRate is a fresh rate for the booking's display currency.
+func (r *mysqlBookingRepo) Modify(id int, changedBy int, terms model.BookingTerms, exchangeRate string) (model.Booking, model.BookingChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var b model.Booking
+	var displayCurrency sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT id, user_id, hotel_id, check_in, check_out, adults, children, rooms, total_price_cents, points_redeemed, points_value_cents, currency, display_currency, status FROM bookings WHERE id = ? FOR UPDATE", id).
+		Scan(&b.ID, &b.UserID, &b.HotelID, &b.CheckIn, &b.CheckOut, &b.Adults, &b.Children, &b.Rooms, &b.TotalPriceCents, &b.PointsRedeemed, &b.PointsValueCents, &b.Currency, &displayCurrency, &b.Status)
+	if err == sql.ErrNoRows {
+		return model.Booking{}, model.BookingChange{}, ErrBookingNotFound
+	}
+	if err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	if b.Status == model.BookingCancelled {
+		return model.Booking{}, model.BookingChange{}, ErrBookingCancelled
+	}
+	old := b.Terms()
+
+	// promo codes stay applied; percentages follow the new price
+	if b.Promos, err = bookingPromos(ctx, tx, id); err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+
+	// Lock hotel row, as in Create
+	var priceCents int
+	var currency string
+	if err := tx.QueryRowContext(ctx, "SELECT price_cents, currency FROM hotels WHERE id = ? FOR UPDATE", b.HotelID).Scan(&priceCents, &currency); err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	if currency != b.Currency {
+		return model.Booking{}, model.BookingChange{}, ErrHotelCurrencyChanged
+	}
+
+	// inventory is a room count, so only a change in rooms moves it
+	if delta := terms.Rooms - b.Rooms; delta > 0 {
+		res, err := tx.ExecContext(ctx, "UPDATE hotels SET rooms_available = rooms_available - ? WHERE id = ? AND rooms_available >= ?", delta, b.HotelID, delta)
+		if err != nil {
+			return model.Booking{}, model.BookingChange{}, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return model.Booking{}, model.BookingChange{}, ErrNotEnoughRooms
+		}
+	} else if delta < 0 {
+		if _, err := tx.ExecContext(ctx, "UPDATE hotels SET rooms_available = LEAST(rooms_total, rooms_available + ?) WHERE id = ?", -delta, b.HotelID); err != nil {
+			return model.Booking{}, model.BookingChange{}, err
+		}
+	}
+
+	b.CheckIn, b.CheckOut = terms.CheckIn, terms.CheckOut
+	b.Adults, b.Children, b.Rooms = terms.Adults, terms.Children, terms.Rooms
+	b.DisplayCurrency = displayCurrency.String
+	b.ExchangeRate = exchangeRate
+	displayTotal, err := priceStay(&b, priceCents)
+	if err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	// redeemed points stay spent, so the stay can't shrink below them
+	if b.PointsValueCents > b.TotalPriceCents {
+		return model.Booking{}, model.BookingChange{}, ErrPointsExceedTotal
+	}
+	// a booking confirmed on points alone has nothing left to pay with
+	if b.Status == model.BookingConfirmed && b.AmountDueCents() > 0 {
+		return model.Booking{}, model.BookingChange{}, ErrConfirmedAmountDue
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE bookings SET check_in = ?, check_out = ?, adults = ?, children = ?, rooms = ?, total_price_cents = ?, discount_cents = ?, display_total_cents = ?, exchange_rate = ? WHERE id = ?",
+		b.CheckIn, b.CheckOut, b.Adults, b.Children, b.Rooms, b.TotalPriceCents, b.DiscountCents, displayTotal, sql.NullString{String: b.ExchangeRate, Valid: displayTotal.Valid}, id)
+	if err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	for _, p := range b.Promos {
+		if _, err := tx.ExecContext(ctx, "UPDATE booking_promos SET discount_cents = ? WHERE booking_id = ? AND promo_id = ?", p.DiscountCents, id, p.PromoID); err != nil {
+			return model.Booking{}, model.BookingChange{}, err
+		}
+	}
+
+	change := model.BookingChange{
+		BookingID: id, ChangedBy: changedBy, Old: old, New: b.Terms(),
+		PriceDeltaCents: b.TotalPriceCents - old.TotalPriceCents, Currency: b.Currency, CreatedAt: time.Now(),
+	}
+	oldJSON, _ := json.Marshal(change.Old)
+	newJSON, _ := json.Marshal(change.New)
+	res, err := tx.ExecContext(ctx, "INSERT INTO booking_changes (booking_id, changed_by, old_terms, new_terms, price_delta_cents, currency, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id, changedBy, oldJSON, newJSON, change.PriceDeltaCents, change.Currency, change.CreatedAt)
+	if err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	changeID, _ := res.LastInsertId()
+	change.ID = int(changeID)
+
+	if err := tx.Commit(); err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	b, err = r.GetByID(id)
+	return b, change, err
+}
+
+const bookingSelect = "SELECT id, reference, user_id, hotel_id, check_in, check_out, adults, children, rooms, total_price_cents, discount_cents, points_redeemed, points_value_cents, currency, display_currency, display_total_cents, exchange_rate, status, created_at, lead_guest_name, lead_guest_email, lead_guest_phone, special_requests, arrival_time, cancellation_policy FROM bookings"
+
+// SetGuestDetails replaces the booking's lead guest, named guests, special
+// requests and arrival time.
+func (r *mysqlBookingRepo) SetGuestDetails(id int, d model.GuestDetails) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	lead := leadGuest(d.LeadGuest)
+	res, err := tx.ExecContext(ctx, "UPDATE bookings SET lead_guest_name = ?, lead_guest_email = ?, lead_guest_phone = ?, special_requests = ?, arrival_time = ? WHERE id = ?",
+		lead.Name, lead.Email, lead.Phone, d.SpecialRequests, d.ArrivalTime, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// MySQL reports 0 for unchanged rows too
+		var exists int
+		if err := tx.QueryRowContext(ctx, "SELECT 1 FROM bookings WHERE id = ?", id).Scan(&exists); err == sql.ErrNoRows {
+			return ErrBookingNotFound
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM booking_guests WHERE booking_id = ?", id); err != nil {
+		return err
+	}
+	if err := insertGuests(ctx, tx, id, d.Guests); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func recordStatus(ctx context.Context, tx *sql.Tx, bookingID int, status string) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO booking_status_history (booking_id, status, changed_at) VALUES (?, ?, ?)", bookingID, status, time.Now())
+	return err
+}
+
+func (r *mysqlBookingRepo) StatusHistory(ids []int) (map[int][]model.BookingStatusChange, error) {
+	out := make(map[int][]model.BookingStatusChange, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	rows, err := r.db.Query("SELECT booking_id, status, changed_at FROM booking_status_history WHERE booking_id IN ("+placeholders+") ORDER BY booking_id, changed_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var c model.BookingStatusChange
+		if err := rows.Scan(&id, &c.Status, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		out[id] = append(out[id], c)
+	}
+	return out, rows.Err()
+}
+
+func leadGuest(g *model.GuestContact) model.GuestContact {
+	if g == nil {
+		return model.GuestContact{}
+	}
+	return *g
+}
+
+func insertGuests(ctx context.Context, tx *sql.Tx, bookingID int, guests []model.BookingGuest) error {
+	for i, g := range guests {
+		age := sql.NullInt64{}
+		if g.Age != nil {
+			age = sql.NullInt64{Int64: int64(*g.Age), Valid: true}
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO booking_guests (booking_id, position, name, is_child, age) VALUES (?, ?, ?, ?, ?)", bookingID, i, g.Name, g.Child, age); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimPromos records the booking's promo codes. Each code's row is locked
+// while its uses are counted, so concurrent bookings can't overrun a limit.
+func claimPromos(ctx context.Context, tx *sql.Tx, bookingID int, userID int, promos []model.AppliedPromo) error {
+	for i, p := range promos {
+		var maxUses, maxPerUser int
+		if err := tx.QueryRowContext(ctx, "SELECT max_uses, max_uses_per_user FROM promo_codes WHERE id = ? FOR UPDATE", p.PromoID).Scan(&maxUses, &maxPerUser); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrPromoNotFound
+			}
+			return err
+		}
+		var total, byUser int
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM(b.user_id = ?), 0) FROM booking_promos bp JOIN bookings b ON b.id = bp.booking_id WHERE bp.promo_id = ? AND b.status <> ?",
+			userID, p.PromoID, model.BookingCancelled).Scan(&total, &byUser)
+		if err != nil {
+			return err
+		}
+		if (maxUses > 0 && total >= maxUses) || (maxPerUser > 0 && byUser >= maxPerUser) {
+			return ErrPromoUsedUp
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO booking_promos (booking_id, promo_id, position, code, kind, percent_off, amount_off_cents, discount_cents) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			bookingID, p.PromoID, i, p.Code, p.Kind, p.PercentOff, p.AmountOffCents, p.DiscountCents)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bookingPromos(ctx context.Context, tx *sql.Tx, bookingID int) ([]model.AppliedPromo, error) {
+	rows, err := tx.QueryContext(ctx, bookingPromoSelect+" WHERE booking_id = ? ORDER BY position", bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]model.AppliedPromo, 0)
+	for rows.Next() {
+		_, p, err := scanAppliedPromo(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+const bookingPromoSelect = "SELECT booking_id, promo_id, code, kind, percent_off, amount_off_cents, discount_cents FROM booking_promos"
+
+func scanAppliedPromo(row rowScanner) (int, model.AppliedPromo, error) {
+	var bookingID int
+	var p model.AppliedPromo
+	err := row.Scan(&bookingID, &p.PromoID, &p.Code, &p.Kind, &p.PercentOff, &p.AmountOffCents, &p.DiscountCents)
+	return bookingID, p, err
+}
+
+// attachPromos loads the promo codes of the listed bookings in one query.
+func (r *mysqlBookingRepo) attachPromos(list []model.Booking) error {
+	if len(list) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(list))
+	args := make([]any, len(list))
+	for i := range list {
+		list[i].Promos = []model.AppliedPromo{}
+		index[list[i].ID] = i
+		args[i] = list[i].ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(list)), ", ")
+	rows, err := r.db.Query(bookingPromoSelect+" WHERE booking_id IN ("+placeholders+") ORDER BY booking_id, position", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		bookingID, p, err := scanAppliedPromo(rows)
+		if err != nil {
+			return err
+		}
+		i := index[bookingID]
+		list[i].Promos = append(list[i].Promos, p)
+	}
+	return rows.Err()
+}
+
+// attachGuests loads the named guests of the listed bookings in one query.
+func (r *mysqlBookingRepo) attachGuests(list []model.Booking) error {
+	if len(list) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(list))
+	args := make([]any, len(list))
+	for i := range list {
+		list[i].Guests = []model.BookingGuest{}
+		index[list[i].ID] = i
+		args[i] = list[i].ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(list)), ", ")
+	rows, err := r.db.Query("SELECT booking_id, name, is_child, age FROM booking_guests WHERE booking_id IN ("+placeholders+") ORDER BY booking_id, position", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bookingID int
+		var g model.BookingGuest
+		var age sql.NullInt64
+		if err := rows.Scan(&bookingID, &g.Name, &g.Child, &age); err != nil {
+			return err
+		}
+		if age.Valid {
+			v := int(age.Int64)
+			g.Age = &v
+		}
+		i := index[bookingID]
+		list[i].Guests = append(list[i].Guests, g)
+	}
+	return rows.Err()
 }
 
 func (r *mysqlBookingRepo) ListByUserID(userID int) ([]model.Booking, error) {
-	rows, err := r.db.Query("SELECT id, user_id, hotel_id, check_in, check_out, adults, children, rooms, total_price_cents, status, created_at FROM bookings WHERE user_id = ? ORDER BY created_at DESC", userID)
+	return r.list(bookingSelect+" WHERE user_id = ? ORDER BY created_at DESC", userID)
+}
+
+func (r *mysqlBookingRepo) Search(f model.BookingSearch) ([]model.Booking, int, error) {
+	cond := "user_id = ?"
+	args := []any{f.UserID}
+	order := "created_at DESC, id DESC"
+	today := f.Today.Format("2006-01-02")
+	switch f.Filter {
+	case model.BookingsUpcoming:
+		cond += " AND status <> ? AND check_out >= ?"
+		args = append(args, model.BookingCancelled, today)
+		order = "check_in, id"
+	case model.BookingsPast:
+		cond += " AND status <> ? AND check_out < ?"
+		args = append(args, model.BookingCancelled, today)
+		order = "check_in DESC, id DESC"
+	case model.BookingsCancelled:
+		cond += " AND status = ?"
+		args = append(args, model.BookingCancelled)
+	}
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM bookings WHERE "+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	list, err := r.list(bookingSelect+" WHERE "+cond+" ORDER BY "+order+" LIMIT ? OFFSET ?", append(args, f.PerPage, model.Offset(f.Page, f.PerPage))...)
+	return list, total, err
+}
+
+// ListByHotelIDs returns the bookings made at any of the given hotels.
+func (r *mysqlBookingRepo) ListByHotelIDs(hotelIDs []int) ([]model.Booking, error) {
+	if len(hotelIDs) == 0 {
+		return []model.Booking{}, nil
+	}
+	args := make([]any, len(hotelIDs))
+	for i, id := range hotelIDs {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(hotelIDs)), ", ")
+	return r.list(bookingSelect+" WHERE hotel_id IN ("+placeholders+") ORDER BY check_in DESC", args...)
+}
+
+func (r *mysqlBookingRepo) GetByID(id int) (model.Booking, error) {
+	list, err := r.list(bookingSelect+" WHERE id = ?", id)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if len(list) == 0 {
+		return model.Booking{}, ErrBookingNotFound
+	}
+	return list[0], nil
+}
+
+func (r *mysqlBookingRepo) GetByReference(code string) (model.Booking, error) {
+	list, err := r.list(bookingSelect+" WHERE reference = ?", code)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if len(list) == 0 {
+		return model.Booking{}, ErrBookingNotFound
+	}
+	return list[0], nil
+}
+
+func (r *mysqlBookingRepo) Transition(id int, from string, to string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE bookings SET status = ? WHERE id = ? AND status = ?", to, id, from)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := recordStatus(ctx, tx, id, to); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (r *mysqlBookingRepo) Cancel(id int, today time.Time, returnPoints int) (model.Booking, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var userID, hotelID, rooms int
+	var checkIn time.Time
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT user_id, hotel_id, check_in, rooms, status FROM bookings WHERE id = ? FOR UPDATE", id).Scan(&userID, &hotelID, &checkIn, &rooms, &status)
+	if err == sql.ErrNoRows {
+		return model.Booking{}, ErrBookingNotFound
+	}
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if status == model.BookingCancelled {
+		return model.Booking{}, ErrBookingCancelled
+	}
+	// the dates may have been changed since the caller read them
+	if !checkIn.After(today) {
+		return model.Booking{}, ErrBookingStarted
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE bookings SET status = ? WHERE id = ?", model.BookingCancelled, id); err != nil {
+		return model.Booking{}, err
+	}
+	if err := recordStatus(ctx, tx, id, model.BookingCancelled); err != nil {
+		return model.Booking{}, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE hotels SET rooms_available = LEAST(rooms_total, rooms_available + ?) WHERE id = ?", rooms, hotelID); err != nil {
+		return model.Booking{}, err
+	}
+	if err := unwindLoyalty(ctx, tx, id, userID, returnPoints); err != nil {
+		return model.Booking{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Booking{}, err
+	}
+	return r.GetByID(id)
+}
+
+func (r *mysqlBookingRepo) ListCheckingIn(day time.Time) ([]model.Booking, error) {
+	return r.list(bookingSelect+" WHERE check_in = ? AND status <> ? ORDER BY id", day.Format("2006-01-02"), model.BookingCancelled)
+}
+
+func (r *mysqlBookingRepo) ListCompleted(day time.Time) ([]model.Booking, error) {
+	return r.list(bookingSelect+" WHERE status = ? AND check_out <= ? AND NOT EXISTS (SELECT 1 FROM loyalty_ledger l WHERE l.booking_id = bookings.id AND l.kind = ?) ORDER BY id",
+		model.BookingConfirmed, day.Format("2006-01-02"), model.LoyaltyEarn)
+}
+
+func (r *mysqlBookingRepo) MarkReminderSent(id int) (bool, error) {
+	res, err := r.db.Exec("UPDATE bookings SET reminder_sent_at = CURRENT_TIMESTAMP WHERE id = ? AND reminder_sent_at IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+func (r *mysqlBookingRepo) list(query string, args ...any) ([]model.Booking, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -97,10 +675,39 @@ func (r *mysqlBookingRepo) ListByUserID(userID int) ([]model.Booking, error) {
 	out := make([]model.Booking, 0)
 	for rows.Next() {
 		var b model.Booking
-		if err := rows.Scan(&b.ID, &b.UserID, &b.HotelID, &b.CheckIn, &b.CheckOut, &b.Adults, &b.Children, &b.Rooms, &b.TotalPriceCents, &b.Status, &b.CreatedAt); err != nil {
+		var displayCurrency, exchangeRate sql.NullString
+		var displayTotal sql.NullInt64
+		var lead model.GuestContact
+		var reference sql.NullString
+		var policy []byte
+		if err := rows.Scan(&b.ID, &reference, &b.UserID, &b.HotelID, &b.CheckIn, &b.CheckOut, &b.Adults, &b.Children, &b.Rooms, &b.TotalPriceCents, &b.DiscountCents, &b.PointsRedeemed, &b.PointsValueCents, &b.Currency, &displayCurrency, &displayTotal, &exchangeRate, &b.Status, &b.CreatedAt,
+			&lead.Name, &lead.Email, &lead.Phone, &b.SpecialRequests, &b.ArrivalTime, &policy); err != nil {
 			continue
 		}
+		if policy != nil {
+			var p model.CancellationPolicy
+			if err := json.Unmarshal(policy, &p); err == nil {
+				b.CancellationPolicy = &p
+			}
+		}
+		if lead.Name != "" {
+			b.LeadGuest = &lead
+		}
+		b.Reference = reference.String
+		b.DisplayCurrency = displayCurrency.String
+		b.ExchangeRate = exchangeRate.String
+		if displayTotal.Valid {
+			v := int(displayTotal.Int64)
+			b.DisplayTotalCents = &v
+		}
 		out = append(out, b)
 	}
+	rows.Close()
+	if err := r.attachGuests(out); err != nil {
+		return nil, err
+	}
+	if err := r.attachPromos(out); err != nil {
+		return nil, err
+	}
 	return out, nil
 }
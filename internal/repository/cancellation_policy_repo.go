@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	"agodrift/internal/model"
+)
+
+var ErrPolicyNotFound = errors.New("cancellation policy not found")
+
+// CancellationPolicyRepository stores cancellation policies and which one
+// each hotel uses.
+type CancellationPolicyRepository interface {
+	List() ([]model.CancellationPolicy, error)
+	Get(code string) (model.CancellationPolicy, error)
+	// Put creates or replaces a policy.
+	Put(p model.CancellationPolicy) error
+	// ForHotel returns the hotel's policy, or the default one when the hotel
+	// has not picked any.
+	ForHotel(hotelID int) (model.CancellationPolicy, error)
+	SetForHotel(hotelID int, code string) error
+}
+
+type inMemoryCancellationPolicyRepo struct {
+	mu       sync.RWMutex
+	policies map[string]model.CancellationPolicy
+	hotels   map[int]string
+}
+
+// NewInMemoryCancellationPolicyRepo starts with the default policies.
+func NewInMemoryCancellationPolicyRepo() *inMemoryCancellationPolicyRepo {
+	r := &inMemoryCancellationPolicyRepo{policies: make(map[string]model.CancellationPolicy), hotels: make(map[int]string)}
+	for _, p := range model.DefaultCancellationPolicies {
+		r.policies[p.Code] = p
+	}
+	return r
+}
+
+func (r *inMemoryCancellationPolicyRepo) List() ([]model.CancellationPolicy, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.CancellationPolicy, 0, len(r.policies))
+	for _, p := range r.policies {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out, nil
+}
+
+func (r *inMemoryCancellationPolicyRepo) Get(code string) (model.CancellationPolicy, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.policies[code]
+	if !ok {
+		return model.CancellationPolicy{}, ErrPolicyNotFound
+	}
+	return p, nil
+}
+
+func (r *inMemoryCancellationPolicyRepo) Put(p model.CancellationPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies[p.Code] = p
+	return nil
+}
+
+func (r *inMemoryCancellationPolicyRepo) ForHotel(hotelID int) (model.CancellationPolicy, error) {
+	r.mu.RLock()
+	code, ok := r.hotels[hotelID]
+	r.mu.RUnlock()
+	if !ok {
+		code = model.DefaultCancellationPolicy
+	}
+	return r.Get(code)
+}
+
+func (r *inMemoryCancellationPolicyRepo) SetForHotel(hotelID int, code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.policies[code]; !ok {
+		return ErrPolicyNotFound
+	}
+	r.hotels[hotelID] = code
+	return nil
+}
+
+type mysqlCancellationPolicyRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLCancellationPolicyRepo(db *sql.DB) *mysqlCancellationPolicyRepo {
+	return &mysqlCancellationPolicyRepo{db: db}
+}
+
+const policySelect = "SELECT code, name, description, non_refundable, rules FROM cancellation_policies"
+
+func (r *mysqlCancellationPolicyRepo) List() ([]model.CancellationPolicy, error) {
+	rows, err := r.db.Query(policySelect + " ORDER BY code")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]model.CancellationPolicy, 0)
+	for rows.Next() {
+		p, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *mysqlCancellationPolicyRepo) Get(code string) (model.CancellationPolicy, error) {
+	p, err := scanPolicy(r.db.QueryRow(policySelect+" WHERE code = ?", code))
+	if err == sql.ErrNoRows {
+		return model.CancellationPolicy{}, ErrPolicyNotFound
+	}
+	return p, err
+}
+
+func (r *mysqlCancellationPolicyRepo) Put(p model.CancellationPolicy) error {
+	rules, err := json.Marshal(p.Rules)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec("INSERT INTO cancellation_policies (code, name, description, non_refundable, rules) VALUES (?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE name = VALUES(name), description = VALUES(description), non_refundable = VALUES(non_refundable), rules = VALUES(rules)",
+		p.Code, p.Name, p.Description, p.NonRefundable, rules)
+	return err
+}
+
+func (r *mysqlCancellationPolicyRepo) ForHotel(hotelID int) (model.CancellationPolicy, error) {
+	var code sql.NullString
+	if err := r.db.QueryRow("SELECT cancellation_policy FROM hotels WHERE id = ?", hotelID).Scan(&code); err != nil {
+		return model.CancellationPolicy{}, err
+	}
+	if !code.Valid {
+		code.String = model.DefaultCancellationPolicy
+	}
+	return r.Get(code.String)
+}
+
+func (r *mysqlCancellationPolicyRepo) SetForHotel(hotelID int, code string) error {
+	if _, err := r.Get(code); err != nil {
+		return err
+	}
+	res, err := r.db.Exec("UPDATE hotels SET cancellation_policy = ? WHERE id = ?", code, hotelID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists int
+		if err := r.db.QueryRow("SELECT COUNT(*) FROM hotels WHERE id = ?", hotelID).Scan(&exists); err != nil || exists == 0 {
+			return sql.ErrNoRows
+		}
+	}
+	return nil
+}
+
+func scanPolicy(row rowScanner) (model.CancellationPolicy, error) {
+	var p model.CancellationPolicy
+	var rules []byte
+	if err := row.Scan(&p.Code, &p.Name, &p.Description, &p.NonRefundable, &rules); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(rules, &p.Rules); err != nil {
+		return p, err
+	}
+	return p, nil
+}
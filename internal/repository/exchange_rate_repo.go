@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+)
+
+// ExchangeRateRepository stores the admin-managed exchange rate table.
+type ExchangeRateRepository interface {
+	List() ([]model.ExchangeRate, error)
+	Get(base string, quote string) (model.ExchangeRate, bool)
+	Upsert(rates []model.ExchangeRate) error
+	Delete(base string, quote string) error
+}
+
+type inMemoryExchangeRateRepo struct {
+	mu    sync.RWMutex
+	rates map[string]model.ExchangeRate // keyed by base+quote
+}
+
+func NewInMemoryExchangeRateRepo() *inMemoryExchangeRateRepo {
+	return &inMemoryExchangeRateRepo{rates: make(map[string]model.ExchangeRate)}
+}
+
+func (r *inMemoryExchangeRateRepo) List() ([]model.ExchangeRate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.ExchangeRate, 0, len(r.rates))
+	for _, er := range r.rates {
+		out = append(out, er)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Base+out[i].Quote < out[j].Base+out[j].Quote })
+	return out, nil
+}
+
+func (r *inMemoryExchangeRateRepo) Get(base string, quote string) (model.ExchangeRate, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	er, ok := r.rates[base+quote]
+	return er, ok
+}
+
+func (r *inMemoryExchangeRateRepo) Upsert(rates []model.ExchangeRate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, er := range rates {
+		er.UpdatedAt = time.Now()
+		r.rates[er.Base+er.Quote] = er
+	}
+	return nil
+}
+
+func (r *inMemoryExchangeRateRepo) Delete(base string, quote string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.rates, base+quote)
+	return nil
+}
+
+type mysqlExchangeRateRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLExchangeRateRepo(db *sql.DB) *mysqlExchangeRateRepo {
+	return &mysqlExchangeRateRepo{db: db}
+}
+
+func (r *mysqlExchangeRateRepo) List() ([]model.ExchangeRate, error) {
+	rows, err := r.db.Query("SELECT base, quote, rate, updated_at FROM exchange_rates ORDER BY base, quote")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.ExchangeRate, 0)
+	for rows.Next() {
+		var er model.ExchangeRate
+		if err := rows.Scan(&er.Base, &er.Quote, &er.Rate, &er.UpdatedAt); err != nil {
+			continue
+		}
+		out = append(out, er)
+	}
+	return out, nil
+}
+
+func (r *mysqlExchangeRateRepo) Get(base string, quote string) (model.ExchangeRate, bool) {
+	var er model.ExchangeRate
+	err := r.db.QueryRow("SELECT base, quote, rate, updated_at FROM exchange_rates WHERE base = ? AND quote = ?", base, quote).Scan(&er.Base, &er.Quote, &er.Rate, &er.UpdatedAt)
+	if err != nil {
+		return er, false
+	}
+	return er, true
+}
+
+// Upsert writes all rates in one transaction so a file import never leaves
+// the table half updated.
+func (r *mysqlExchangeRateRepo) Upsert(rates []model.ExchangeRate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	for _, er := range rates {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO exchange_rates (base, quote, rate) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE rate = VALUES(rate), updated_at = CURRENT_TIMESTAMP", er.Base, er.Quote, er.Rate); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *mysqlExchangeRateRepo) Delete(base string, quote string) error {
+	_, err := r.db.Exec("DELETE FROM exchange_rates WHERE base = ? AND quote = ?", base, quote)
+	return err
+}
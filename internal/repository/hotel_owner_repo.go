@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+)
+
+// HotelOwnerRepository links partner and manager accounts to the hotels they
+// are allowed to manage.
+type HotelOwnerRepository interface {
+	IsOwner(userID int, hotelID int) bool
+	HotelIDs(userID int) ([]int, error)
+	Assign(userID int, hotelID int) error
+	Remove(userID int, hotelID int) error
+}
+
+type inMemoryHotelOwnerRepo struct {
+	mu    sync.RWMutex
+	links map[int]map[int]bool // userID -> hotelID set
+}
+
+func NewInMemoryHotelOwnerRepo() *inMemoryHotelOwnerRepo {
+	return &inMemoryHotelOwnerRepo{links: make(map[int]map[int]bool)}
+}
+
+func (r *inMemoryHotelOwnerRepo) IsOwner(userID int, hotelID int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.links[userID][hotelID]
+}
+
+func (r *inMemoryHotelOwnerRepo) HotelIDs(userID int) ([]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]int, 0, len(r.links[userID]))
+	for id := range r.links[userID] {
+		out = append(out, id)
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+func (r *inMemoryHotelOwnerRepo) Assign(userID int, hotelID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.links[userID] == nil {
+		r.links[userID] = make(map[int]bool)
+	}
+	r.links[userID][hotelID] = true
+	return nil
+}
+
+func (r *inMemoryHotelOwnerRepo) Remove(userID int, hotelID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.links[userID], hotelID)
+	return nil
+}
+
+type mysqlHotelOwnerRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLHotelOwnerRepo(db *sql.DB) *mysqlHotelOwnerRepo {
+	return &mysqlHotelOwnerRepo{db: db}
+}
+
+func (r *mysqlHotelOwnerRepo) IsOwner(userID int, hotelID int) bool {
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM hotel_owners WHERE user_id = ? AND hotel_id = ?", userID, hotelID).Scan(&n)
+	return err == nil && n > 0
+}
+
+func (r *mysqlHotelOwnerRepo) HotelIDs(userID int) ([]int, error) {
+	rows, err := r.db.Query("SELECT hotel_id FROM hotel_owners WHERE user_id = ? ORDER BY hotel_id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			continue
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+func (r *mysqlHotelOwnerRepo) Assign(userID int, hotelID int) error {
+	_, err := r.db.Exec("INSERT IGNORE INTO hotel_owners (user_id, hotel_id) VALUES (?, ?)", userID, hotelID)
+	return err
+}
+
+func (r *mysqlHotelOwnerRepo) Remove(userID int, hotelID int) error {
+	_, err := r.db.Exec("DELETE FROM hotel_owners WHERE user_id = ? AND hotel_id = ?", userID, hotelID)
+	return err
+}
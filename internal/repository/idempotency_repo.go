@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+)
+
+// IdempotencyRepository stores idempotency keys per user.
+type IdempotencyRepository interface {
+	// Reserve stores rec unless the user already has an unexpired record
+	// with the same key, which is returned instead with false. A record
+	// still in progress that was created before staleBefore is replaced.
+	Reserve(rec model.IdempotencyRecord, staleBefore time.Time) (model.IdempotencyRecord, bool, error)
+	Complete(userID int, key string, status int, contentType string, body []byte) error
+	// Release forgets a key whose request failed so it can be retried.
+	Release(userID int, key string) error
+	// DeleteExpired removes records expired at now and returns how many.
+	DeleteExpired(now time.Time) (int, error)
+}
+
+type idempotencyID struct {
+	userID int
+	key    string
+}
+
+type inMemoryIdempotencyRepo struct {
+	mu      sync.Mutex
+	records map[idempotencyID]model.IdempotencyRecord
+}
+
+func NewInMemoryIdempotencyRepo() *inMemoryIdempotencyRepo {
+	return &inMemoryIdempotencyRepo{records: make(map[idempotencyID]model.IdempotencyRecord)}
+}
+
+func (r *inMemoryIdempotencyRepo) Reserve(rec model.IdempotencyRecord, staleBefore time.Time) (model.IdempotencyRecord, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := idempotencyID{rec.UserID, rec.Key}
+	cur, ok := r.records[id]
+	stale := !cur.Completed() && !cur.CreatedAt.After(staleBefore)
+	if ok && cur.ExpiresAt.After(rec.CreatedAt) && !stale {
+		return cur, false, nil
+	}
+	r.records[id] = rec
+	return rec, true, nil
+}
+
+func (r *inMemoryIdempotencyRepo) Complete(userID int, key string, status int, contentType string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := idempotencyID{userID, key}
+	if rec, ok := r.records[id]; ok {
+		rec.Status = status
+		rec.ContentType = contentType
+		rec.Body = append([]byte(nil), body...)
+		r.records[id] = rec
+	}
+	return nil
+}
+
+func (r *inMemoryIdempotencyRepo) Release(userID int, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.records, idempotencyID{userID, key})
+	return nil
+}
+
+func (r *inMemoryIdempotencyRepo) DeleteExpired(now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for id, rec := range r.records {
+		if !rec.ExpiresAt.After(now) {
+			delete(r.records, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type mysqlIdempotencyRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLIdempotencyRepo(db *sql.DB) *mysqlIdempotencyRepo {
+	return &mysqlIdempotencyRepo{db: db}
+}
+
+func (r *mysqlIdempotencyRepo) Reserve(rec model.IdempotencyRecord, staleBefore time.Time) (model.IdempotencyRecord, bool, error) {
+	// neither an expired record nor one whose request's lease ran out blocks
+	// reuse of its key; the insert below still lets only one retry win
+	if _, err := r.db.Exec("DELETE FROM idempotency_keys WHERE user_id = ? AND idem_key = ? AND (expires_at <= ? OR (status_code = 0 AND created_at <= ?))",
+		rec.UserID, rec.Key, rec.CreatedAt, staleBefore); err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	// the primary key makes concurrent retries race for a single row
+	res, err := r.db.Exec("INSERT IGNORE INTO idempotency_keys (user_id, idem_key, fingerprint, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		rec.UserID, rec.Key, rec.Fingerprint, rec.CreatedAt, rec.ExpiresAt)
+	if err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return rec, true, nil
+	}
+	var cur model.IdempotencyRecord
+	err = r.db.QueryRow("SELECT user_id, idem_key, fingerprint, status_code, content_type, response, created_at, expires_at FROM idempotency_keys WHERE user_id = ? AND idem_key = ?", rec.UserID, rec.Key).
+		Scan(&cur.UserID, &cur.Key, &cur.Fingerprint, &cur.Status, &cur.ContentType, &cur.Body, &cur.CreatedAt, &cur.ExpiresAt)
+	if err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	return cur, false, nil
+}
+
+func (r *mysqlIdempotencyRepo) Complete(userID int, key string, status int, contentType string, body []byte) error {
+	_, err := r.db.Exec("UPDATE idempotency_keys SET status_code = ?, content_type = ?, response = ? WHERE user_id = ? AND idem_key = ?", status, contentType, body, userID, key)
+	return err
+}
+
+func (r *mysqlIdempotencyRepo) Release(userID int, key string) error {
+	_, err := r.db.Exec("DELETE FROM idempotency_keys WHERE user_id = ? AND idem_key = ?", userID, key)
+	return err
+}
+
+func (r *mysqlIdempotencyRepo) DeleteExpired(now time.Time) (int, error) {
+	res, err := r.db.Exec("DELETE FROM idempotency_keys WHERE expires_at <= ?", now)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
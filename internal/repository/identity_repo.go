@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+	"sync"
+
+	"agodrift/internal/model"
+)
+
+// IdentityRepository links external identity provider accounts to users.
+type IdentityRepository interface {
+	FindUser(provider string, subject string) (model.User, bool)
+	Link(userID int, provider string, subject string, email string) error
+}
+
+type inMemoryIdentityRepo struct {
+	mu    sync.RWMutex
+	users UserRepository
+	links map[string]string // provider+"|"+subject -> user email
+}
+
+// NewInMemoryIdentityRepo resolves linked users through the given user repo.
+func NewInMemoryIdentityRepo(users UserRepository) *inMemoryIdentityRepo {
+	return &inMemoryIdentityRepo{users: users, links: make(map[string]string)}
+}
+
+func (r *inMemoryIdentityRepo) FindUser(provider string, subject string) (model.User, bool) {
+	r.mu.RLock()
+	email, ok := r.links[provider+"|"+subject]
+	r.mu.RUnlock()
+	if !ok {
+		return model.User{}, false
+	}
+	return r.users.GetByEmail(email)
+}
+
+func (r *inMemoryIdentityRepo) Link(userID int, provider string, subject string, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.links[provider+"|"+subject] = email
+	return nil
+}
+
+type mysqlIdentityRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLIdentityRepo(db *sql.DB) *mysqlIdentityRepo {
+	return &mysqlIdentityRepo{db: db}
+}
+
+func (r *mysqlIdentityRepo) FindUser(provider string, subject string) (model.User, bool) {
+	u, err := scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = (SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?)", provider, subject))
+	return u, err == nil
+}
+
+func (r *mysqlIdentityRepo) Link(userID int, provider string, subject string, email string) error {
+	_, err := r.db.Exec("INSERT INTO user_identities (user_id, provider, subject, email) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE email = VALUES(email)", userID, provider, subject, email)
+	return err
+}
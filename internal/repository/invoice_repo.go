@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	ErrInvoiceExists   = errors.New("booking already has this document")
+)
+
+// InvoiceRepository stores invoices and credit notes. Numbers are
+// sequential per kind without gaps: a number is only used once its document
+// is stored.
+type InvoiceRepository interface {
+	// Create numbers and stores a document, or returns ErrInvoiceExists if
+	// the booking already has one of that kind.
+	Create(inv model.Invoice) (model.Invoice, error)
+	Get(bookingID int, kind string) (model.Invoice, error)
+}
+
+type inMemoryInvoiceRepo struct {
+	mu       sync.Mutex
+	invoices []model.Invoice
+	last     map[string]int
+}
+
+func NewInMemoryInvoiceRepo() *inMemoryInvoiceRepo {
+	return &inMemoryInvoiceRepo{last: make(map[string]int)}
+}
+
+func (r *inMemoryInvoiceRepo) Create(inv model.Invoice) (model.Invoice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.invoices {
+		if other.BookingID == inv.BookingID && other.Kind == inv.Kind {
+			return model.Invoice{}, ErrInvoiceExists
+		}
+	}
+	r.last[inv.Kind]++
+	inv.Number = model.InvoiceNumber(inv.Kind, r.last[inv.Kind])
+	inv.ID = len(r.invoices) + 1
+	r.invoices = append(r.invoices, inv)
+	return inv, nil
+}
+
+func (r *inMemoryInvoiceRepo) Get(bookingID int, kind string) (model.Invoice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, inv := range r.invoices {
+		if inv.BookingID == bookingID && inv.Kind == kind {
+			return inv, nil
+		}
+	}
+	return model.Invoice{}, ErrInvoiceNotFound
+}
+
+type mysqlInvoiceRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLInvoiceRepo(db *sql.DB) *mysqlInvoiceRepo {
+	return &mysqlInvoiceRepo{db: db}
+}
+
+func (r *mysqlInvoiceRepo) Create(inv model.Invoice) (model.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// the counter row stays locked until commit, so numbers are handed out
+	// in order and a failed insert gives its number back
+	res, err := tx.ExecContext(ctx, "UPDATE invoice_sequences SET last_number = LAST_INSERT_ID(last_number + 1) WHERE kind = ?", inv.Kind)
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	seq, err := res.LastInsertId()
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	inv.Number = model.InvoiceNumber(inv.Kind, int(seq))
+	doc, err := json.Marshal(inv)
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	res, err = tx.ExecContext(ctx, "INSERT INTO invoices (number, kind, booking_id, user_id, issued_at, currency, total_cents, document) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		inv.Number, inv.Kind, inv.BookingID, inv.UserID, inv.IssuedAt, inv.Currency, inv.TotalCents, doc)
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == 1062 {
+		return model.Invoice{}, ErrInvoiceExists
+	}
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Invoice{}, err
+	}
+	id, _ := res.LastInsertId()
+	inv.ID = int(id)
+	return inv, nil
+}
+
+func (r *mysqlInvoiceRepo) Get(bookingID int, kind string) (model.Invoice, error) {
+	var id int
+	var doc []byte
+	err := r.db.QueryRow("SELECT id, document FROM invoices WHERE booking_id = ? AND kind = ?", bookingID, kind).Scan(&id, &doc)
+	if err == sql.ErrNoRows {
+		return model.Invoice{}, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	var inv model.Invoice
+	if err := json.Unmarshal(doc, &inv); err != nil {
+		return model.Invoice{}, err
+	}
+	inv.ID = id
+	return inv, nil
+}
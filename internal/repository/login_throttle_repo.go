@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+)
+
+// LoginThrottleRepository stores login failure counters and the lockout
+// audit trail.
+type LoginThrottleRepository interface {
+	Get(scope, subject string) (model.LoginAttempts, bool)
+	// AddFailure atomically counts a failure at now and returns the updated
+	// counter. A counter whose lockout has run out or whose last failure is
+	// before since starts over.
+	AddFailure(scope, subject string, now, since time.Time) (model.LoginAttempts, error)
+	// Lock locks the subject until the given time unless it is already
+	// locked at now, and reports whether it did.
+	Lock(scope, subject string, until, now time.Time) (bool, error)
+	Reset(scope, subject string) error
+	RecordLockout(l model.LoginLockout) error
+	// MarkUnlocked stamps the subject's open lockouts as lifted by adminID.
+	MarkUnlocked(scope, subject string, adminID int, at time.Time) error
+	ListLockouts(limit int) ([]model.LoginLockout, error)
+}
+
+type inMemoryLoginThrottleRepo struct {
+	mu       sync.Mutex
+	attempts map[string]model.LoginAttempts // keyed by scope + ":" + subject
+	lockouts []model.LoginLockout
+}
+
+func NewInMemoryLoginThrottleRepo() *inMemoryLoginThrottleRepo {
+	return &inMemoryLoginThrottleRepo{attempts: make(map[string]model.LoginAttempts)}
+}
+
+func (r *inMemoryLoginThrottleRepo) Get(scope, subject string) (model.LoginAttempts, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.attempts[scope+":"+subject]
+	return a, ok
+}
+
+func (r *inMemoryLoginThrottleRepo) AddFailure(scope, subject string, now, since time.Time) (model.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.attempts[scope+":"+subject]
+	expiredLock := !a.LockedUntil.IsZero() && !a.LockedUntil.After(now)
+	if !ok || expiredLock || a.LastFailure.Before(since) {
+		a = model.LoginAttempts{Scope: scope, Subject: subject}
+	}
+	a.Failures++
+	a.LastFailure = now
+	r.attempts[scope+":"+subject] = a
+	return a, nil
+}
+
+func (r *inMemoryLoginThrottleRepo) Lock(scope, subject string, until, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.attempts[scope+":"+subject]
+	if !ok || a.LockedUntil.After(now) {
+		return false, nil
+	}
+	a.LockedUntil = until
+	r.attempts[scope+":"+subject] = a
+	return true, nil
+}
+
+func (r *inMemoryLoginThrottleRepo) Reset(scope, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, scope+":"+subject)
+	return nil
+}
+
+func (r *inMemoryLoginThrottleRepo) RecordLockout(l model.LoginLockout) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l.ID = len(r.lockouts) + 1
+	r.lockouts = append(r.lockouts, l)
+	return nil
+}
+
+func (r *inMemoryLoginThrottleRepo) MarkUnlocked(scope, subject string, adminID int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, l := range r.lockouts {
+		if l.Scope == scope && l.Subject == subject && l.UnlockedAt == nil && l.LockedUntil.After(at) {
+			by, t := adminID, at
+			r.lockouts[i].UnlockedBy = &by
+			r.lockouts[i].UnlockedAt = &t
+		}
+	}
+	return nil
+}
+
+func (r *inMemoryLoginThrottleRepo) ListLockouts(limit int) ([]model.LoginLockout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]model.LoginLockout, len(r.lockouts))
+	copy(out, r.lockouts)
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+type mysqlLoginThrottleRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLLoginThrottleRepo(db *sql.DB) *mysqlLoginThrottleRepo {
+	return &mysqlLoginThrottleRepo{db: db}
+}
+
+func (r *mysqlLoginThrottleRepo) Get(scope, subject string) (model.LoginAttempts, bool) {
+	a := model.LoginAttempts{Scope: scope, Subject: subject}
+	var locked sql.NullTime
+	err := r.db.QueryRow("SELECT failures, last_failure, locked_until FROM login_attempts WHERE scope = ? AND subject = ?", scope, subject).
+		Scan(&a.Failures, &a.LastFailure, &locked)
+	if err != nil {
+		return a, false
+	}
+	if locked.Valid {
+		a.LockedUntil = locked.Time
+	}
+	return a, true
+}
+
+// AddFailure increments the counter in one statement so concurrent failures
+// are all counted. MySQL applies the assignments left to right, so the
+// start-over test reads locked_until and last_failure before they change.
+func (r *mysqlLoginThrottleRepo) AddFailure(scope, subject string, now, since time.Time) (model.LoginAttempts, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return model.LoginAttempts{}, err
+	}
+	defer tx.Rollback()
+	const startOver = "(locked_until IS NOT NULL AND locked_until <= ?) OR last_failure < ?"
+	if _, err := tx.Exec(`INSERT INTO login_attempts (scope, subject, failures, last_failure) VALUES (?, ?, 1, ?)
+		ON DUPLICATE KEY UPDATE failures = IF(`+startOver+`, 1, failures + 1),
+		locked_until = IF(`+startOver+`, NULL, locked_until), last_failure = VALUES(last_failure)`,
+		scope, subject, now, now, since, now, since); err != nil {
+		return model.LoginAttempts{}, err
+	}
+	a := model.LoginAttempts{Scope: scope, Subject: subject}
+	var locked sql.NullTime
+	if err := tx.QueryRow("SELECT failures, last_failure, locked_until FROM login_attempts WHERE scope = ? AND subject = ?", scope, subject).
+		Scan(&a.Failures, &a.LastFailure, &locked); err != nil {
+		return model.LoginAttempts{}, err
+	}
+	if locked.Valid {
+		a.LockedUntil = locked.Time
+	}
+	return a, tx.Commit()
+}
+
+func (r *mysqlLoginThrottleRepo) Lock(scope, subject string, until, now time.Time) (bool, error) {
+	res, err := r.db.Exec("UPDATE login_attempts SET locked_until = ? WHERE scope = ? AND subject = ? AND (locked_until IS NULL OR locked_until <= ?)",
+		until, scope, subject, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *mysqlLoginThrottleRepo) Reset(scope, subject string) error {
+	_, err := r.db.Exec("DELETE FROM login_attempts WHERE scope = ? AND subject = ?", scope, subject)
+	return err
+}
+
+func (r *mysqlLoginThrottleRepo) RecordLockout(l model.LoginLockout) error {
+	_, err := r.db.Exec("INSERT INTO login_lockouts (scope, subject, failures, ip, locked_at, locked_until) VALUES (?, ?, ?, ?, ?, ?)",
+		l.Scope, l.Subject, l.Failures, l.IP, l.LockedAt, l.LockedUntil)
+	return err
+}
+
+func (r *mysqlLoginThrottleRepo) MarkUnlocked(scope, subject string, adminID int, at time.Time) error {
+	_, err := r.db.Exec("UPDATE login_lockouts SET unlocked_by = ?, unlocked_at = ? WHERE scope = ? AND subject = ? AND unlocked_at IS NULL AND locked_until > ?",
+		adminID, at, scope, subject, at)
+	return err
+}
+
+func (r *mysqlLoginThrottleRepo) ListLockouts(limit int) ([]model.LoginLockout, error) {
+	rows, err := r.db.Query("SELECT id, scope, subject, failures, ip, locked_at, locked_until, unlocked_by, unlocked_at FROM login_lockouts ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.LoginLockout
+	for rows.Next() {
+		var l model.LoginLockout
+		var by sql.NullInt64
+		var at sql.NullTime
+		if err := rows.Scan(&l.ID, &l.Scope, &l.Subject, &l.Failures, &l.IP, &l.LockedAt, &l.LockedUntil, &by, &at); err != nil {
+			return nil, err
+		}
+		if by.Valid {
+			id := int(by.Int64)
+			l.UnlockedBy = &id
+		}
+		if at.Valid {
+			t := at.Time
+			l.UnlockedAt = &t
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
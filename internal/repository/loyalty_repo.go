@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrNotEnoughPoints = errors.New("not enough loyalty points")
+	// ErrLoyaltyEntryExists means the booking already has an entry of that
+	// kind; each booking earns, reverses, redeems and returns at most once.
+	ErrLoyaltyEntryExists = errors.New("loyalty entry already recorded")
+)
+
+// LoyaltyRepository is an append-only points ledger. Redemptions and their
+// return on cancellation are written by the booking repository, in the
+// same transaction as the booking.
+type LoyaltyRepository interface {
+	Append(e model.LoyaltyEntry) (model.LoyaltyEntry, error)
+	Balance(userID int) (int, error)
+	// Qualifying sums the points earned since the given time, less those
+	// reversed.
+	Qualifying(userID int, since time.Time) (int, error)
+	// History returns one page of the user's entries, newest first, and
+	// the total number of entries.
+	History(userID int, page int, perPage int) ([]model.LoyaltyEntry, int, error)
+}
+
+type inMemoryLoyaltyRepo struct {
+	mu      sync.RWMutex
+	entries []model.LoyaltyEntry
+}
+
+func NewInMemoryLoyaltyRepo() *inMemoryLoyaltyRepo {
+	return &inMemoryLoyaltyRepo{}
+}
+
+func (r *inMemoryLoyaltyRepo) Append(e model.LoyaltyEntry) (model.LoyaltyEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e.BookingID != nil {
+		for _, other := range r.entries {
+			if other.BookingID != nil && *other.BookingID == *e.BookingID && other.Kind == e.Kind {
+				return model.LoyaltyEntry{}, ErrLoyaltyEntryExists
+			}
+		}
+	}
+	e.ID = len(r.entries) + 1
+	r.entries = append(r.entries, e)
+	return e, nil
+}
+
+func (r *inMemoryLoyaltyRepo) Balance(userID int) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	balance := 0
+	for _, e := range r.entries {
+		if e.UserID == userID {
+			balance += e.Points
+		}
+	}
+	return balance, nil
+}
+
+func (r *inMemoryLoyaltyRepo) Qualifying(userID int, since time.Time) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sum := 0
+	for _, e := range r.entries {
+		if e.UserID == userID && (e.Kind == model.LoyaltyEarn || e.Kind == model.LoyaltyReverse) && !e.CreatedAt.Before(since) {
+			sum += e.Points
+		}
+	}
+	return sum, nil
+}
+
+func (r *inMemoryLoyaltyRepo) History(userID int, page int, perPage int) ([]model.LoyaltyEntry, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mine := make([]model.LoyaltyEntry, 0)
+	for _, e := range r.entries {
+		if e.UserID == userID {
+			mine = append(mine, e)
+		}
+	}
+	sort.Slice(mine, func(i, j int) bool { return mine[i].ID > mine[j].ID })
+	start := min(model.Offset(page, perPage), len(mine))
+	end := min(start+perPage, len(mine))
+	return mine[start:end], len(mine), nil
+}
+
+type mysqlLoyaltyRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLLoyaltyRepo(db *sql.DB) *mysqlLoyaltyRepo {
+	return &mysqlLoyaltyRepo{db: db}
+}
+
+func (r *mysqlLoyaltyRepo) Append(e model.LoyaltyEntry) (model.LoyaltyEntry, error) {
+	res, err := r.db.Exec("INSERT INTO loyalty_ledger (user_id, booking_id, kind, points, description, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		e.UserID, e.BookingID, e.Kind, e.Points, e.Description, e.CreatedAt)
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == 1062 {
+		return model.LoyaltyEntry{}, ErrLoyaltyEntryExists
+	}
+	if err != nil {
+		return model.LoyaltyEntry{}, err
+	}
+	id, _ := res.LastInsertId()
+	e.ID = int(id)
+	return e, nil
+}
+
+func (r *mysqlLoyaltyRepo) Balance(userID int) (int, error) {
+	var balance int
+	err := r.db.QueryRow("SELECT COALESCE(SUM(points), 0) FROM loyalty_ledger WHERE user_id = ?", userID).Scan(&balance)
+	return balance, err
+}
+
+func (r *mysqlLoyaltyRepo) Qualifying(userID int, since time.Time) (int, error) {
+	var sum int
+	err := r.db.QueryRow("SELECT COALESCE(SUM(points), 0) FROM loyalty_ledger WHERE user_id = ? AND kind IN (?, ?) AND created_at >= ?",
+		userID, model.LoyaltyEarn, model.LoyaltyReverse, since).Scan(&sum)
+	return sum, err
+}
+
+func (r *mysqlLoyaltyRepo) History(userID int, page int, perPage int) ([]model.LoyaltyEntry, int, error) {
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM loyalty_ledger WHERE user_id = ?", userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.Query("SELECT id, user_id, booking_id, kind, points, description, created_at FROM loyalty_ledger WHERE user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		userID, perPage, model.Offset(page, perPage))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	out := make([]model.LoyaltyEntry, 0)
+	for rows.Next() {
+		var e model.LoyaltyEntry
+		var bookingID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.UserID, &bookingID, &e.Kind, &e.Points, &e.Description, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if bookingID.Valid {
+			id := int(bookingID.Int64)
+			e.BookingID = &id
+		}
+		out = append(out, e)
+	}
+	return out, total, rows.Err()
+}
+
+// redeemPoints spends points on a booking. The user's row is locked while
+// the balance is summed, so concurrent bookings can't overspend it.
+func redeemPoints(ctx context.Context, tx *sql.Tx, bookingID int, userID int, points int) error {
+	if points == 0 {
+		return nil
+	}
+	var id int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&id); err != nil {
+		return err
+	}
+	var balance int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(points), 0) FROM loyalty_ledger WHERE user_id = ?", userID).Scan(&balance); err != nil {
+		return err
+	}
+	if balance < points {
+		return ErrNotEnoughPoints
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO loyalty_ledger (user_id, booking_id, kind, points, description, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, bookingID, model.LoyaltyRedeem, -points, "Redeemed on booking", time.Now())
+	return err
+}
+
+// unwindLoyalty takes back the points a cancelled booking earned and gives
+// back returnPoints of those redeemed on it.
+func unwindLoyalty(ctx context.Context, tx *sql.Tx, bookingID int, userID int, returnPoints int) error {
+	now := time.Now()
+	_, err := tx.ExecContext(ctx, "INSERT INTO loyalty_ledger (user_id, booking_id, kind, points, description, created_at) "+
+		"SELECT user_id, booking_id, ?, -points, ?, ? FROM loyalty_ledger WHERE booking_id = ? AND kind = ? AND points <> 0",
+		model.LoyaltyReverse, "Booking cancelled", now, bookingID, model.LoyaltyEarn)
+	if err != nil {
+		return err
+	}
+	if returnPoints == 0 {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO loyalty_ledger (user_id, booking_id, kind, points, description, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, bookingID, model.LoyaltyReturn, returnPoints, "Booking cancelled", now)
+	return err
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+)
+
+// MFARepository stores TOTP secrets and hashed recovery codes.
+type MFARepository interface {
+	Get(userID int) (model.MFA, bool)
+	SaveSecret(userID int, secret string) error
+	Enable(userID int, recoveryHashes []string) error
+	Disable(userID int) error
+	// AdvanceStep records step as used if it is newer than the last one.
+	AdvanceStep(userID int, step int64) bool
+	// UseRecoveryCode consumes an unused recovery code.
+	UseRecoveryCode(userID int, hash string) bool
+}
+
+type inMemoryMFARepo struct {
+	mu       sync.Mutex
+	mfa      map[int]model.MFA
+	recovery map[int]map[string]bool // hash -> used
+}
+
+func NewInMemoryMFARepo() *inMemoryMFARepo {
+	return &inMemoryMFARepo{mfa: make(map[int]model.MFA), recovery: make(map[int]map[string]bool)}
+}
+
+func (r *inMemoryMFARepo) Get(userID int) (model.MFA, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.mfa[userID]
+	return m, ok
+}
+
+func (r *inMemoryMFARepo) SaveSecret(userID int, secret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mfa[userID] = model.MFA{UserID: userID, Secret: secret}
+	return nil
+}
+
+func (r *inMemoryMFARepo) Enable(userID int, recoveryHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.mfa[userID]
+	m.Enabled = true
+	r.mfa[userID] = m
+	r.recovery[userID] = make(map[string]bool, len(recoveryHashes))
+	for _, h := range recoveryHashes {
+		r.recovery[userID][h] = false
+	}
+	return nil
+}
+
+func (r *inMemoryMFARepo) Disable(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.mfa, userID)
+	delete(r.recovery, userID)
+	return nil
+}
+
+func (r *inMemoryMFARepo) AdvanceStep(userID int, step int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.mfa[userID]
+	if !ok || step <= m.LastStep {
+		return false
+	}
+	m.LastStep = step
+	r.mfa[userID] = m
+	return true
+}
+
+func (r *inMemoryMFARepo) UseRecoveryCode(userID int, hash string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used, ok := r.recovery[userID][hash]
+	if !ok || used {
+		return false
+	}
+	r.recovery[userID][hash] = true
+	return true
+}
+
+type mysqlMFARepo struct {
+	db *sql.DB
+}
+
+func NewMySQLMFARepo(db *sql.DB) *mysqlMFARepo {
+	return &mysqlMFARepo{db: db}
+}
+
+func (r *mysqlMFARepo) Get(userID int) (model.MFA, bool) {
+	m := model.MFA{UserID: userID}
+	var enabled int
+	err := r.db.QueryRow("SELECT secret, enabled, last_step FROM user_mfa WHERE user_id = ?", userID).Scan(&m.Secret, &enabled, &m.LastStep)
+	if err != nil {
+		return m, false
+	}
+	m.Enabled = enabled == 1
+	return m, true
+}
+
+func (r *mysqlMFARepo) SaveSecret(userID int, secret string) error {
+	_, err := r.db.Exec("INSERT INTO user_mfa (user_id, secret, enabled, last_step) VALUES (?, ?, 0, 0) ON DUPLICATE KEY UPDATE secret = VALUES(secret), enabled = 0, last_step = 0", userID, secret)
+	return err
+}
+
+// Enable turns on MFA and replaces the user's recovery codes atomically.
+func (r *mysqlMFARepo) Enable(userID int, recoveryHashes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := tx.ExecContext(ctx, "UPDATE user_mfa SET enabled = 1 WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, h := range recoveryHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *mysqlMFARepo) Disable(userID int) error {
+	if _, err := r.db.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	_, err := r.db.Exec("DELETE FROM user_mfa WHERE user_id = ?", userID)
+	return err
+}
+
+func (r *mysqlMFARepo) AdvanceStep(userID int, step int64) bool {
+	res, err := r.db.Exec("UPDATE user_mfa SET last_step = ? WHERE user_id = ? AND last_step < ?", step, userID, step)
+	if err != nil {
+		return false
+	}
+	n, _ := res.RowsAffected()
+	return n == 1
+}
+
+func (r *mysqlMFARepo) UseRecoveryCode(userID int, hash string) bool {
+	res, err := r.db.Exec("UPDATE mfa_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash)
+	if err != nil {
+		return false
+	}
+	n, _ := res.RowsAffected()
+	return n == 1
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+)
+
+// OIDCLoginRepository keeps provider logins between the redirect to the
+// provider and its callback, which may reach another instance.
+type OIDCLoginRepository interface {
+	// Create stores a login and drops expired ones.
+	Create(l model.OIDCLogin) error
+	// Take removes and returns the login for state; false if unknown,
+	// already taken or expired.
+	Take(state string, now time.Time) (model.OIDCLogin, bool)
+}
+
+type inMemoryOIDCLoginRepo struct {
+	mu     sync.Mutex
+	logins map[string]model.OIDCLogin
+}
+
+func NewInMemoryOIDCLoginRepo() *inMemoryOIDCLoginRepo {
+	return &inMemoryOIDCLoginRepo{logins: make(map[string]model.OIDCLogin)}
+}
+
+func (r *inMemoryOIDCLoginRepo) Create(l model.OIDCLogin) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for k, other := range r.logins {
+		if now.After(other.ExpiresAt) {
+			delete(r.logins, k)
+		}
+	}
+	r.logins[l.State] = l
+	return nil
+}
+
+func (r *inMemoryOIDCLoginRepo) Take(state string, now time.Time) (model.OIDCLogin, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.logins[state]
+	delete(r.logins, state)
+	if !ok || now.After(l.ExpiresAt) {
+		return model.OIDCLogin{}, false
+	}
+	return l, true
+}
+
+type mysqlOIDCLoginRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLOIDCLoginRepo(db *sql.DB) *mysqlOIDCLoginRepo {
+	return &mysqlOIDCLoginRepo{db: db}
+}
+
+func (r *mysqlOIDCLoginRepo) Create(l model.OIDCLogin) error {
+	if _, err := r.db.Exec("DELETE FROM oidc_logins WHERE expires_at < ?", time.Now()); err != nil {
+		return err
+	}
+	_, err := r.db.Exec("INSERT INTO oidc_logins (state, provider, nonce, verifier, expires_at) VALUES (?, ?, ?, ?, ?)",
+		l.State, l.Provider, l.Nonce, l.Verifier, l.ExpiresAt)
+	return err
+}
+
+func (r *mysqlOIDCLoginRepo) Take(state string, now time.Time) (model.OIDCLogin, bool) {
+	var l model.OIDCLogin
+	err := r.db.QueryRow("SELECT state, provider, nonce, verifier, expires_at FROM oidc_logins WHERE state = ?", state).
+		Scan(&l.State, &l.Provider, &l.Nonce, &l.Verifier, &l.ExpiresAt)
+	if err != nil {
+		return model.OIDCLogin{}, false
+	}
+	// only the caller whose delete wins may use the login
+	res, err := r.db.Exec("DELETE FROM oidc_logins WHERE state = ?", state)
+	if err != nil {
+		return model.OIDCLogin{}, false
+	}
+	if n, _ := res.RowsAffected(); n != 1 || now.After(l.ExpiresAt) {
+		return model.OIDCLogin{}, false
+	}
+	return l, true
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// OneTimeTokenRepository remembers issued single-use tokens (password reset,
+// email verification) by jti so each can be consumed exactly once.
+type OneTimeTokenRepository interface {
+	Create(jti string, userID int, purpose string, expiresAt time.Time) error
+	// Consume marks the token used; false if unknown, used or expired.
+	Consume(jti string, purpose string, now time.Time) bool
+	// Invalidate retires every outstanding token of the user for purpose.
+	Invalidate(userID int, purpose string, now time.Time) error
+}
+
+type oneTimeToken struct {
+	userID    int
+	purpose   string
+	expiresAt time.Time
+	used      bool
+}
+
+type inMemoryOneTimeTokenRepo struct {
+	mu     sync.Mutex
+	tokens map[string]oneTimeToken
+}
+
+func NewInMemoryOneTimeTokenRepo() *inMemoryOneTimeTokenRepo {
+	return &inMemoryOneTimeTokenRepo{tokens: make(map[string]oneTimeToken)}
+}
+
+func (r *inMemoryOneTimeTokenRepo) Create(jti string, userID int, purpose string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[jti] = oneTimeToken{userID: userID, purpose: purpose, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *inMemoryOneTimeTokenRepo) Consume(jti string, purpose string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[jti]
+	if !ok || t.used || t.purpose != purpose || !t.expiresAt.After(now) {
+		return false
+	}
+	t.used = true
+	r.tokens[jti] = t
+	return true
+}
+
+func (r *inMemoryOneTimeTokenRepo) Invalidate(userID int, purpose string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for jti, t := range r.tokens {
+		if t.userID == userID && t.purpose == purpose {
+			t.used = true
+			r.tokens[jti] = t
+		}
+	}
+	return nil
+}
+
+type mysqlOneTimeTokenRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLOneTimeTokenRepo(db *sql.DB) *mysqlOneTimeTokenRepo {
+	return &mysqlOneTimeTokenRepo{db: db}
+}
+
+func (r *mysqlOneTimeTokenRepo) Create(jti string, userID int, purpose string, expiresAt time.Time) error {
+	_, err := r.db.Exec("INSERT INTO one_time_tokens (jti, user_id, purpose, expires_at) VALUES (?, ?, ?, ?)", jti, userID, purpose, expiresAt)
+	return err
+}
+
+func (r *mysqlOneTimeTokenRepo) Consume(jti string, purpose string, now time.Time) bool {
+	res, err := r.db.Exec("UPDATE one_time_tokens SET used_at = ? WHERE jti = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", now, jti, purpose, now)
+	if err != nil {
+		return false
+	}
+	n, _ := res.RowsAffected()
+	return n == 1
+}
+
+func (r *mysqlOneTimeTokenRepo) Invalidate(userID int, purpose string, now time.Time) error {
+	_, err := r.db.Exec("UPDATE one_time_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL", now, userID, purpose)
+	return err
+}
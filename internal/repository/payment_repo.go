@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+
+	"agodrift/internal/model"
+)
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// PaymentRepository stores payments.
+type PaymentRepository interface {
+	Create(p model.Payment) (model.Payment, error)
+	Get(id int) (model.Payment, error)
+	GetByGatewayRef(gateway string, ref string) (model.Payment, error)
+	// ListByBooking returns the booking's payments, oldest first.
+	ListByBooking(bookingID int) ([]model.Payment, error)
+	// Update saves the gateway reference, amounts, status and failure reason.
+	Update(p model.Payment) error
+}
+
+type inMemoryPaymentRepo struct {
+	mu       sync.RWMutex
+	payments map[int]model.Payment
+	next     int
+}
+
+func NewInMemoryPaymentRepo() *inMemoryPaymentRepo {
+	return &inMemoryPaymentRepo{payments: make(map[int]model.Payment), next: 1}
+}
+
+func (r *inMemoryPaymentRepo) Create(p model.Payment) (model.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p.ID = r.next
+	r.next++
+	r.payments[p.ID] = p
+	return p, nil
+}
+
+func (r *inMemoryPaymentRepo) Get(id int) (model.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.payments[id]
+	if !ok {
+		return model.Payment{}, ErrPaymentNotFound
+	}
+	return p, nil
+}
+
+func (r *inMemoryPaymentRepo) GetByGatewayRef(gateway string, ref string) (model.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.payments {
+		if p.Gateway == gateway && p.GatewayRef == ref {
+			return p, nil
+		}
+	}
+	return model.Payment{}, ErrPaymentNotFound
+}
+
+func (r *inMemoryPaymentRepo) ListByBooking(bookingID int) ([]model.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.Payment, 0)
+	for _, p := range r.payments {
+		if p.BookingID == bookingID {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (r *inMemoryPaymentRepo) Update(p model.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.payments[p.ID]; !ok {
+		return ErrPaymentNotFound
+	}
+	r.payments[p.ID] = p
+	return nil
+}
+
+type mysqlPaymentRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLPaymentRepo(db *sql.DB) *mysqlPaymentRepo {
+	return &mysqlPaymentRepo{db: db}
+}
+
+const paymentSelect = "SELECT id, booking_id, user_id, gateway, gateway_ref, amount_cents, currency, captured_cents, refunded_cents, status, failure_reason, created_at, updated_at FROM payments"
+
+func scanPayment(row rowScanner) (model.Payment, error) {
+	var p model.Payment
+	err := row.Scan(&p.ID, &p.BookingID, &p.UserID, &p.Gateway, &p.GatewayRef, &p.AmountCents, &p.Currency, &p.CapturedCents, &p.RefundedCents, &p.Status, &p.FailureReason, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return p, ErrPaymentNotFound
+	}
+	return p, err
+}
+
+func (r *mysqlPaymentRepo) Create(p model.Payment) (model.Payment, error) {
+	res, err := r.db.Exec("INSERT INTO payments (booking_id, user_id, gateway, gateway_ref, amount_cents, currency, captured_cents, refunded_cents, status, failure_reason, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		p.BookingID, p.UserID, p.Gateway, p.GatewayRef, p.AmountCents, p.Currency, p.CapturedCents, p.RefundedCents, p.Status, p.FailureReason, p.CreatedAt, p.UpdatedAt)
+	if err != nil {
+		return model.Payment{}, err
+	}
+	id, _ := res.LastInsertId()
+	p.ID = int(id)
+	return p, nil
+}
+
+func (r *mysqlPaymentRepo) Get(id int) (model.Payment, error) {
+	return scanPayment(r.db.QueryRow(paymentSelect+" WHERE id = ?", id))
+}
+
+func (r *mysqlPaymentRepo) GetByGatewayRef(gateway string, ref string) (model.Payment, error) {
+	return scanPayment(r.db.QueryRow(paymentSelect+" WHERE gateway = ? AND gateway_ref = ?", gateway, ref))
+}
+
+func (r *mysqlPaymentRepo) ListByBooking(bookingID int) ([]model.Payment, error) {
+	rows, err := r.db.Query(paymentSelect+" WHERE booking_id = ? ORDER BY id", bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]model.Payment, 0)
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *mysqlPaymentRepo) Update(p model.Payment) error {
+	_, err := r.db.Exec("UPDATE payments SET gateway_ref = ?, captured_cents = ?, refunded_cents = ?, status = ?, failure_reason = ?, updated_at = ? WHERE id = ?",
+		p.GatewayRef, p.CapturedCents, p.RefundedCents, p.Status, p.FailureReason, p.UpdatedAt, p.ID)
+	return err
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	"agodrift/internal/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrPromoNotFound  = errors.New("promo code not found")
+	ErrPromoCodeTaken = errors.New("promo code already exists")
+	ErrPromoUsedUp    = errors.New("promo code has reached its usage limit")
+)
+
+// PromoRepository stores promo codes. Uses are the booking_promos rows of
+// bookings that are not cancelled, so cancelling gives a use back.
+type PromoRepository interface {
+	List() ([]model.PromoCode, error)
+	Get(id int) (model.PromoCode, error)
+	GetByCode(code string) (model.PromoCode, error)
+	Create(p model.PromoCode) (model.PromoCode, error)
+	Update(p model.PromoCode) error
+	// Uses counts the bookings using the code, in total and by the user.
+	Uses(promoID int, userID int) (total int, byUser int, err error)
+}
+
+type inMemoryPromoRepo struct {
+	mu     sync.RWMutex
+	promos map[int]model.PromoCode
+	uses   map[int][]int // promo id -> user id per use
+	next   int
+}
+
+func NewInMemoryPromoRepo() *inMemoryPromoRepo {
+	return &inMemoryPromoRepo{promos: make(map[int]model.PromoCode), uses: make(map[int][]int), next: 1}
+}
+
+func (r *inMemoryPromoRepo) List() ([]model.PromoCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.PromoCode, 0, len(r.promos))
+	for _, p := range r.promos {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (r *inMemoryPromoRepo) Get(id int) (model.PromoCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.promos[id]
+	if !ok {
+		return model.PromoCode{}, ErrPromoNotFound
+	}
+	return p, nil
+}
+
+func (r *inMemoryPromoRepo) GetByCode(code string) (model.PromoCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.promos {
+		if p.Code == code {
+			return p, nil
+		}
+	}
+	return model.PromoCode{}, ErrPromoNotFound
+}
+
+func (r *inMemoryPromoRepo) Create(p model.PromoCode) (model.PromoCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.promos {
+		if other.Code == p.Code {
+			return model.PromoCode{}, ErrPromoCodeTaken
+		}
+	}
+	p.ID = r.next
+	r.next++
+	r.promos[p.ID] = p
+	return p, nil
+}
+
+func (r *inMemoryPromoRepo) Update(p model.PromoCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.promos[p.ID]; !ok {
+		return ErrPromoNotFound
+	}
+	for _, other := range r.promos {
+		if other.Code == p.Code && other.ID != p.ID {
+			return ErrPromoCodeTaken
+		}
+	}
+	r.promos[p.ID] = p
+	return nil
+}
+
+func (r *inMemoryPromoRepo) Uses(promoID int, userID int) (int, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	byUser := 0
+	for _, u := range r.uses[promoID] {
+		if u == userID {
+			byUser++
+		}
+	}
+	return len(r.uses[promoID]), byUser, nil
+}
+
+// RecordUse stands in for a booking made with the code.
+func (r *inMemoryPromoRepo) RecordUse(promoID int, userID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uses[promoID] = append(r.uses[promoID], userID)
+}
+
+type mysqlPromoRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLPromoRepo(db *sql.DB) *mysqlPromoRepo {
+	return &mysqlPromoRepo{db: db}
+}
+
+const promoColumns = "code, description, kind, percent_off, amount_off_cents, currency, starts_at, ends_at, max_uses, max_uses_per_user, min_nights, min_spend_cents, hotel_ids, destinations, stackable, exclude_sale_rates, active"
+
+func (r *mysqlPromoRepo) List() ([]model.PromoCode, error) {
+	rows, err := r.db.Query("SELECT id, " + promoColumns + ", created_at FROM promo_codes ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]model.PromoCode, 0)
+	for rows.Next() {
+		p, err := scanPromo(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *mysqlPromoRepo) Get(id int) (model.PromoCode, error) {
+	p, err := scanPromo(r.db.QueryRow("SELECT id, "+promoColumns+", created_at FROM promo_codes WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return model.PromoCode{}, ErrPromoNotFound
+	}
+	return p, err
+}
+
+func (r *mysqlPromoRepo) GetByCode(code string) (model.PromoCode, error) {
+	p, err := scanPromo(r.db.QueryRow("SELECT id, "+promoColumns+", created_at FROM promo_codes WHERE code = ?", code))
+	if err == sql.ErrNoRows {
+		return model.PromoCode{}, ErrPromoNotFound
+	}
+	return p, err
+}
+
+func (r *mysqlPromoRepo) Create(p model.PromoCode) (model.PromoCode, error) {
+	args, err := promoArgs(p)
+	if err != nil {
+		return model.PromoCode{}, err
+	}
+	res, err := r.db.Exec("INSERT INTO promo_codes ("+promoColumns+", created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", append(args, p.CreatedAt)...)
+	if err != nil {
+		return model.PromoCode{}, promoWriteError(err)
+	}
+	id, _ := res.LastInsertId()
+	p.ID = int(id)
+	return p, nil
+}
+
+func (r *mysqlPromoRepo) Update(p model.PromoCode) error {
+	args, err := promoArgs(p)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.Exec("UPDATE promo_codes SET code = ?, description = ?, kind = ?, percent_off = ?, amount_off_cents = ?, currency = ?, starts_at = ?, ends_at = ?, "+
+		"max_uses = ?, max_uses_per_user = ?, min_nights = ?, min_spend_cents = ?, hotel_ids = ?, destinations = ?, stackable = ?, exclude_sale_rates = ?, active = ? WHERE id = ?",
+		append(args, p.ID)...)
+	if err != nil {
+		return promoWriteError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// unchanged rows report 0 too
+		if _, err := r.Get(p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *mysqlPromoRepo) Uses(promoID int, userID int) (int, int, error) {
+	var total, byUser int
+	err := r.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(b.user_id = ?), 0) FROM booking_promos bp JOIN bookings b ON b.id = bp.booking_id WHERE bp.promo_id = ? AND b.status <> ?",
+		userID, promoID, model.BookingCancelled).Scan(&total, &byUser)
+	return total, byUser, err
+}
+
+func promoArgs(p model.PromoCode) ([]any, error) {
+	hotels, err := json.Marshal(p.HotelIDs)
+	if err != nil {
+		return nil, err
+	}
+	destinations, err := json.Marshal(p.Destinations)
+	if err != nil {
+		return nil, err
+	}
+	return []any{p.Code, p.Description, p.Kind, p.PercentOff, p.AmountOffCents, p.Currency, p.StartsAt, p.EndsAt,
+		p.MaxUses, p.MaxUsesPerUser, p.MinNights, p.MinSpendCents, hotels, destinations, p.Stackable, p.ExcludeSaleRates, p.Active}, nil
+}
+
+func promoWriteError(err error) error {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == 1062 {
+		return ErrPromoCodeTaken
+	}
+	return err
+}
+
+func scanPromo(row rowScanner) (model.PromoCode, error) {
+	var p model.PromoCode
+	var starts, ends sql.NullTime
+	var hotels, destinations []byte
+	if err := row.Scan(&p.ID, &p.Code, &p.Description, &p.Kind, &p.PercentOff, &p.AmountOffCents, &p.Currency, &starts, &ends,
+		&p.MaxUses, &p.MaxUsesPerUser, &p.MinNights, &p.MinSpendCents, &hotels, &destinations, &p.Stackable, &p.ExcludeSaleRates, &p.Active, &p.CreatedAt); err != nil {
+		return p, err
+	}
+	p.StartsAt = nullTimePtr(starts)
+	p.EndsAt = nullTimePtr(ends)
+	if err := json.Unmarshal(hotels, &p.HotelIDs); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(destinations, &p.Destinations); err != nil {
+		return p, err
+	}
+	return p, nil
+}
@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+)
+
+var ErrRoleNotFound = errors.New("role not found")
+
+// RBACRepository stores roles, their permissions and user role assignments.
+type RBACRepository interface {
+	ListRoles() ([]model.Role, error)
+	GetRole(name string) (model.Role, bool)
+	SaveRole(role model.Role) error
+	DeleteRole(name string) error
+	UserRoles(userID int) ([]string, error)
+	AssignRole(userID int, role string) error
+	RevokeRole(userID int, role string) error
+}
+
+type inMemoryRBACRepo struct {
+	mu        sync.RWMutex
+	roles     map[string]model.Role
+	userRoles map[int]map[string]bool
+}
+
+// NewInMemoryRBACRepo returns a repo seeded with model.DefaultRoles.
+func NewInMemoryRBACRepo() *inMemoryRBACRepo {
+	r := &inMemoryRBACRepo{
+		roles:     make(map[string]model.Role),
+		userRoles: make(map[int]map[string]bool),
+	}
+	for _, role := range model.DefaultRoles {
+		r.roles[role.Name] = role
+	}
+	return r
+}
+
+func (r *inMemoryRBACRepo) ListRoles() ([]model.Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		out = append(out, role)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (r *inMemoryRBACRepo) GetRole(name string) (model.Role, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	role, ok := r.roles[name]
+	return role, ok
+}
+
+func (r *inMemoryRBACRepo) SaveRole(role model.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.roles[role.Name]; ok {
+		role.BuiltIn = existing.BuiltIn
+	}
+	r.roles[role.Name] = role
+	return nil
+}
+
+func (r *inMemoryRBACRepo) DeleteRole(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.roles[name]; !ok {
+		return ErrRoleNotFound
+	}
+	delete(r.roles, name)
+	for _, roles := range r.userRoles {
+		delete(roles, name)
+	}
+	return nil
+}
+
+func (r *inMemoryRBACRepo) UserRoles(userID int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.userRoles[userID]))
+	for name := range r.userRoles[userID] {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (r *inMemoryRBACRepo) AssignRole(userID int, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.roles[role]; !ok {
+		return ErrRoleNotFound
+	}
+	if r.userRoles[userID] == nil {
+		r.userRoles[userID] = make(map[string]bool)
+	}
+	r.userRoles[userID][role] = true
+	return nil
+}
+
+func (r *inMemoryRBACRepo) RevokeRole(userID int, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.userRoles[userID], role)
+	return nil
+}
+
+type mysqlRBACRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLRBACRepo(db *sql.DB) *mysqlRBACRepo {
+	return &mysqlRBACRepo{db: db}
+}
+
+func (r *mysqlRBACRepo) ListRoles() ([]model.Role, error) {
+	rows, err := r.db.Query("SELECT r.name, r.description, r.built_in, rp.permission FROM roles r LEFT JOIN role_permissions rp ON rp.role = r.name ORDER BY r.name, rp.permission")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.Role, 0)
+	for rows.Next() {
+		var name, description string
+		var builtIn int
+		var perm sql.NullString
+		if err := rows.Scan(&name, &description, &builtIn, &perm); err != nil {
+			continue
+		}
+		if len(out) == 0 || out[len(out)-1].Name != name {
+			out = append(out, model.Role{Name: name, Description: description, BuiltIn: builtIn == 1, Permissions: []string{}})
+		}
+		if perm.Valid {
+			last := &out[len(out)-1]
+			last.Permissions = append(last.Permissions, perm.String)
+		}
+	}
+	return out, nil
+}
+
+func (r *mysqlRBACRepo) GetRole(name string) (model.Role, bool) {
+	role := model.Role{Name: name, Permissions: []string{}}
+	var builtIn int
+	if err := r.db.QueryRow("SELECT description, built_in FROM roles WHERE name = ?", name).Scan(&role.Description, &builtIn); err != nil {
+		return role, false
+	}
+	role.BuiltIn = builtIn == 1
+	rows, err := r.db.Query("SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission", name)
+	if err != nil {
+		return role, false
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var perm string
+		if err := rows.Scan(&perm); err == nil {
+			role.Permissions = append(role.Permissions, perm)
+		}
+	}
+	return role, true
+}
+
+// SaveRole creates or updates a role and replaces its permission set.
+func (r *mysqlRBACRepo) SaveRole(role model.Role) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := tx.ExecContext(ctx, "INSERT INTO roles (name, description) VALUES (?, ?) ON DUPLICATE KEY UPDATE description = VALUES(description)", role.Name, role.Description); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role = ?", role.Name); err != nil {
+		return err
+	}
+	for _, perm := range role.Permissions {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO role_permissions (role, permission) VALUES (?, ?)", role.Name, perm); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *mysqlRBACRepo) DeleteRole(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	for _, q := range []string{"DELETE FROM user_roles WHERE role = ?", "DELETE FROM role_permissions WHERE role = ?"} {
+		if _, err := tx.ExecContext(ctx, q, name); err != nil {
+			return err
+		}
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM roles WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRoleNotFound
+	}
+	return tx.Commit()
+}
+
+func (r *mysqlRBACRepo) UserRoles(userID int) ([]string, error) {
+	rows, err := r.db.Query("SELECT role FROM user_roles WHERE user_id = ? ORDER BY role", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]string, 0)
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err == nil {
+			out = append(out, role)
+		}
+	}
+	return out, nil
+}
+
+func (r *mysqlRBACRepo) AssignRole(userID int, role string) error {
+	if _, ok := r.GetRole(role); !ok {
+		return ErrRoleNotFound
+	}
+	_, err := r.db.Exec("INSERT IGNORE INTO user_roles (user_id, role) VALUES (?, ?)", userID, role)
+	return err
+}
+
+func (r *mysqlRBACRepo) RevokeRole(userID int, role string) error {
+	_, err := r.db.Exec("DELETE FROM user_roles WHERE user_id = ? AND role = ?", userID, role)
+	return err
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"agodrift/internal/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrReviewNotFound = errors.New("review not found")
+	ErrReplyExists    = errors.New("review already has a reply")
+)
+
+// ReviewRepository stores hotel reviews, their moderation state and hotel replies.
+type ReviewRepository interface {
+	Create(rv model.Review) (model.Review, error)
+	Get(id int) (model.Review, bool)
+	ListByHotel(hotelID int, status string) ([]model.Review, error)
+	ListByStatus(status string) ([]model.Review, error)
+	SetStatus(id int, status string, reason string, moderatorID int) error
+	CreateReply(reply model.ReviewReply) (model.ReviewReply, error)
+}
+
+type mysqlReviewRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLReviewRepo(db *sql.DB) *mysqlReviewRepo {
+	return &mysqlReviewRepo{db: db}
+}
+
+const reviewSelect = "SELECT r.id, r.hotel_id, r.user_id, r.rating, r.title, r.body, r.status, r.flags, r.moderation_reason, r.moderated_by, r.created_at, rr.user_id, rr.body, rr.created_at FROM reviews r LEFT JOIN review_replies rr ON rr.review_id = r.id"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReview(s rowScanner) (model.Review, error) {
+	var rv model.Review
+	var moderatedBy, replyUser sql.NullInt64
+	var replyBody sql.NullString
+	var replyAt sql.NullTime
+	if err := s.Scan(&rv.ID, &rv.HotelID, &rv.UserID, &rv.Rating, &rv.Title, &rv.Body, &rv.Status, &rv.Flags, &rv.ModerationReason, &moderatedBy, &rv.CreatedAt, &replyUser, &replyBody, &replyAt); err != nil {
+		return rv, err
+	}
+	if moderatedBy.Valid {
+		v := int(moderatedBy.Int64)
+		rv.ModeratedBy = &v
+	}
+	if replyUser.Valid {
+		rv.Reply = &model.ReviewReply{ReviewID: rv.ID, UserID: int(replyUser.Int64), Body: replyBody.String, CreatedAt: replyAt.Time}
+	}
+	return rv, nil
+}
+
+func (r *mysqlReviewRepo) Create(rv model.Review) (model.Review, error) {
+	res, err := r.db.Exec("INSERT INTO reviews (hotel_id, user_id, rating, title, body, status, flags) VALUES (?, ?, ?, ?, ?, ?, ?)", rv.HotelID, rv.UserID, rv.Rating, rv.Title, rv.Body, rv.Status, rv.Flags)
+	if err != nil {
+		return rv, err
+	}
+	id, _ := res.LastInsertId()
+	rv.ID = int(id)
+	rv.CreatedAt = time.Now()
+	if rv.Status == model.ReviewApproved {
+		if err := r.applyToHotelRating(context.Background(), r.db, rv.HotelID, rv.Rating); err != nil {
+			return rv, err
+		}
+	}
+	return rv, nil
+}
+
+func (r *mysqlReviewRepo) Get(id int) (model.Review, bool) {
+	rv, err := scanReview(r.db.QueryRow(reviewSelect+" WHERE r.id = ?", id))
+	if err != nil {
+		return rv, false
+	}
+	return rv, true
+}
+
+func (r *mysqlReviewRepo) list(query string, args ...any) ([]model.Review, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]model.Review, 0)
+	for rows.Next() {
+		rv, err := scanReview(rows)
+		if err != nil {
+			continue
+		}
+		out = append(out, rv)
+	}
+	return out, nil
+}
+
+func (r *mysqlReviewRepo) ListByHotel(hotelID int, status string) ([]model.Review, error) {
+	return r.list(reviewSelect+" WHERE r.hotel_id = ? AND r.status = ? ORDER BY r.created_at DESC", hotelID, status)
+}
+
+func (r *mysqlReviewRepo) ListByStatus(status string) ([]model.Review, error) {
+	return r.list(reviewSelect+" WHERE r.status = ? ORDER BY r.created_at ASC", status)
+}
+
+// SetStatus records a moderation decision. Approving a review that was not
+// already approved folds its rating into the hotel's aggregate rating, and
+// moving an approved review to another status takes it back out.
+func (r *mysqlReviewRepo) SetStatus(id int, status string, reason string, moderatorID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var hotelID, rating int
+	var current string
+	err = tx.QueryRowContext(ctx, "SELECT hotel_id, rating, status FROM reviews WHERE id = ? FOR UPDATE", id).Scan(&hotelID, &rating, &current)
+	if err == sql.ErrNoRows {
+		return ErrReviewNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE reviews SET status = ?, moderation_reason = ?, moderated_by = ? WHERE id = ?", status, reason, moderatorID, id); err != nil {
+		return err
+	}
+	switch {
+	case status == model.ReviewApproved && current != model.ReviewApproved:
+		err = r.applyToHotelRating(ctx, tx, hotelID, rating)
+	case status != model.ReviewApproved && current == model.ReviewApproved:
+		err = r.removeFromHotelRating(ctx, tx, hotelID, rating)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (r *mysqlReviewRepo) applyToHotelRating(ctx context.Context, ex execer, hotelID int, rating int) error {
+	_, err := ex.ExecContext(ctx, "UPDATE hotels SET rating = ROUND((rating * reviews + ?) / (reviews + 1), 1), reviews = reviews + 1 WHERE id = ?", rating, hotelID)
+	return err
+}
+
+// removeFromHotelRating undoes applyToHotelRating. MySQL assigns left to
+// right, so the new rating is worked out from the old review count.
+func (r *mysqlReviewRepo) removeFromHotelRating(ctx context.Context, ex execer, hotelID int, rating int) error {
+	_, err := ex.ExecContext(ctx, "UPDATE hotels SET rating = IF(reviews <= 1, 0, ROUND((rating * reviews - ?) / (reviews - 1), 1)), reviews = GREATEST(reviews - 1, 0) WHERE id = ?", rating, hotelID)
+	return err
+}
+
+func (r *mysqlReviewRepo) CreateReply(reply model.ReviewReply) (model.ReviewReply, error) {
+	_, err := r.db.Exec("INSERT INTO review_replies (review_id, user_id, body) VALUES (?, ?, ?)", reply.ReviewID, reply.UserID, reply.Body)
+	if err != nil {
+		var me *mysql.MySQLError
+		if errors.As(err, &me) && me.Number == 1062 {
+			return reply, ErrReplyExists
+		}
+		return reply, err
+	}
+	reply.CreatedAt = time.Now()
+	return reply, nil
+}
@@ -1,10 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"agodrift/internal/model"
+	"agodrift/internal/money"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -12,7 +17,14 @@ import (
 type RoomRepository interface {
 	List() []model.Room
 	Get(id int) (model.Room, bool)
+	// GetMany returns the hotels with the given ids, by id; unknown ids are
+	// left out.
+	GetMany(ids []int) (map[int]model.Room, error)
 	Create(r model.Room) model.Room
+	UpsertBatch(rooms []model.Room) (created int, updated int, err error)
+	// Update saves the partner-editable fields of a hotel (see HotelUpdate).
+	Update(r model.Room) error
+	UpdateInventory(id int, roomsTotal int, roomsAvailable int) error
 }
 
 type inMemoryRoomRepo struct {
@@ -26,7 +38,7 @@ func NewInMemoryRoomRepo() *inMemoryRoomRepo {
 		rooms: make(map[int]model.Room),
 		next:  1,
 	}
-	r.Create(model.Room{Name: "Demo Hotel", Description: "Demo", Location: "Demo", Destination: "Demo", Rating: 4.5, Reviews: 10, PriceCents: 15000, Amenities: "Wi-Fi", Featured: true, MaxAdults: 2, MaxChildren: 1, RoomsTotal: 10, RoomsAvailable: 5, Status: "active"})
+	r.Create(model.Room{Name: "Demo Hotel", Description: "Demo", Location: "Demo", Destination: "Demo", Rating: 4.5, Reviews: 10, PriceCents: 15000, Currency: "USD", Amenities: "Wi-Fi", Featured: true, MaxAdults: 2, MaxChildren: 1, RoomsTotal: 10, RoomsAvailable: 5, Status: "active"})
 	return r
 }
 
@@ -37,6 +49,7 @@ func (r *inMemoryRoomRepo) List() []model.Room {
 	for _, rm := range r.rooms {
 		out = append(out, rm)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
 	return out
 }
 
@@ -47,6 +60,18 @@ func (r *inMemoryRoomRepo) Get(id int) (model.Room, bool) {
 	return rm, ok
 }
 
+func (r *inMemoryRoomRepo) GetMany(ids []int) (map[int]model.Room, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[int]model.Room, len(ids))
+	for _, id := range ids {
+		if rm, ok := r.rooms[id]; ok {
+			out[id] = rm
+		}
+	}
+	return out, nil
+}
+
 func (r *inMemoryRoomRepo) Create(rm model.Room) model.Room {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -56,6 +81,69 @@ func (r *inMemoryRoomRepo) Create(rm model.Room) model.Room {
 	return rm
 }
 
+func (r *inMemoryRoomRepo) UpsertBatch(rooms []model.Room) (int, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byRef := make(map[string]int)
+	for id, rm := range r.rooms {
+		if rm.ExternalRef != "" {
+			byRef[rm.ExternalRef] = id
+		}
+	}
+	created, updated := 0, 0
+	for _, rm := range rooms {
+		applyRoomDefaults(&rm)
+		if id, ok := byRef[rm.ExternalRef]; ok && rm.ExternalRef != "" {
+			rm.ID = id
+			r.rooms[id] = rm
+			updated++
+			continue
+		}
+		if cur, ok := r.rooms[rm.ID]; ok && rm.ExternalRef == "" {
+			rm.ExternalRef = cur.ExternalRef
+			r.rooms[rm.ID] = rm
+			updated++
+			continue
+		}
+		rm.ID = r.next
+		r.next++
+		r.rooms[rm.ID] = rm
+		if rm.ExternalRef != "" {
+			byRef[rm.ExternalRef] = rm.ID
+		}
+		created++
+	}
+	return created, updated, nil
+}
+
+func (r *inMemoryRoomRepo) Update(rm model.Room) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.rooms[rm.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	applyRoomDefaults(&rm)
+	cur.Name, cur.Description, cur.Location, cur.Destination = rm.Name, rm.Description, rm.Location, rm.Destination
+	cur.PriceCents, cur.OriginalPriceCents, cur.Currency = rm.PriceCents, rm.OriginalPriceCents, rm.Currency
+	cur.Amenities, cur.MaxAdults, cur.MaxChildren, cur.Status = rm.Amenities, rm.MaxAdults, rm.MaxChildren, rm.Status
+	r.rooms[rm.ID] = cur
+	return nil
+}
+
+func (r *inMemoryRoomRepo) UpdateInventory(id int, roomsTotal int, roomsAvailable int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rm, ok := r.rooms[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	rm.RoomsTotal = roomsTotal
+	rm.RoomsAvailable = roomsAvailable
+	r.rooms[id] = rm
+	return nil
+}
+
 type mysqlRoomRepo struct {
 	db *sql.DB
 }
@@ -64,54 +152,16 @@ func NewMySQLRoomRepo(db *sql.DB) *mysqlRoomRepo {
 	return &mysqlRoomRepo{db: db}
 }
 
-func (r *mysqlRoomRepo) List() []model.Room {
-	rows, err := r.db.Query("SELECT id, name, description, location, destination, rating, reviews, price_cents, original_price_cents, amenities, featured, max_adults, max_children, rooms_total, rooms_available, status FROM hotels")
-	if err != nil {
-		return nil
-	}
-	defer rows.Close()
-
-	var rooms []model.Room
-	for rows.Next() {
-		var rm model.Room
-		var original sql.NullInt64
-		var featuredInt int
-		if err := rows.Scan(
-			&rm.ID,
-			&rm.Name,
-			&rm.Description,
-			&rm.Location,
-			&rm.Destination,
-			&rm.Rating,
-			&rm.Reviews,
-			&rm.PriceCents,
-			&original,
-			&rm.Amenities,
-			&featuredInt,
-			&rm.MaxAdults,
-			&rm.MaxChildren,
-			&rm.RoomsTotal,
-			&rm.RoomsAvailable,
-			&rm.Status,
-		); err != nil {
-			continue
-		}
-		rm.Featured = featuredInt == 1
-		if original.Valid {
-			v := int(original.Int64)
-			rm.OriginalPriceCents = &v
-		}
-		rooms = append(rooms, rm)
-	}
-	return rooms
-}
+const roomSelect = "SELECT id, external_ref, name, description, location, destination, rating, reviews, price_cents, original_price_cents, currency, amenities, featured, max_adults, max_children, rooms_total, rooms_available, status FROM hotels"
 
-func (r *mysqlRoomRepo) Get(id int) (model.Room, bool) {
+func scanRoom(s rowScanner) (model.Room, error) {
 	var rm model.Room
 	var original sql.NullInt64
+	var externalRef sql.NullString
 	var featuredInt int
-	err := r.db.QueryRow("SELECT id, name, description, location, destination, rating, reviews, price_cents, original_price_cents, amenities, featured, max_adults, max_children, rooms_total, rooms_available, status FROM hotels WHERE id = ?", id).Scan(
+	if err := s.Scan(
 		&rm.ID,
+		&externalRef,
 		&rm.Name,
 		&rm.Description,
 		&rm.Location,
@@ -120,6 +170,7 @@ func (r *mysqlRoomRepo) Get(id int) (model.Room, bool) {
 		&rm.Reviews,
 		&rm.PriceCents,
 		&original,
+		&rm.Currency,
 		&rm.Amenities,
 		&featuredInt,
 		&rm.MaxAdults,
@@ -127,30 +178,181 @@ func (r *mysqlRoomRepo) Get(id int) (model.Room, bool) {
 		&rm.RoomsTotal,
 		&rm.RoomsAvailable,
 		&rm.Status,
-	)
-	if err != nil {
-		return rm, false
+	); err != nil {
+		return rm, err
 	}
 	rm.Featured = featuredInt == 1
+	rm.ExternalRef = externalRef.String
 	if original.Valid {
 		v := int(original.Int64)
 		rm.OriginalPriceCents = &v
 	}
+	return rm, nil
+}
+
+func (r *mysqlRoomRepo) List() []model.Room {
+	rows, err := r.db.Query(roomSelect)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var rooms []model.Room
+	for rows.Next() {
+		rm, err := scanRoom(rows)
+		if err != nil {
+			continue
+		}
+		rooms = append(rooms, rm)
+	}
+	return rooms
+}
+
+func (r *mysqlRoomRepo) Get(id int) (model.Room, bool) {
+	rm, err := scanRoom(r.db.QueryRow(roomSelect+" WHERE id = ?", id))
+	if err != nil {
+		return rm, false
+	}
 	return rm, true
 }
 
+func (r *mysqlRoomRepo) GetMany(ids []int) (map[int]model.Room, error) {
+	out := make(map[int]model.Room, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	rows, err := r.db.Query(roomSelect+" WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rm, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[rm.ID] = rm
+	}
+	return out, rows.Err()
+}
+
 func (r *mysqlRoomRepo) Create(rm model.Room) model.Room {
+	applyRoomDefaults(&rm)
+	result, err := r.db.Exec(
+		"INSERT INTO hotels (external_ref, "+hotelColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		roomArgs(rm)...,
+	)
+	if err != nil {
+		return rm
+	}
+	id, _ := result.LastInsertId()
+	rm.ID = int(id)
+	return rm
+}
+
+// UpsertBatch writes the rooms in a single transaction, updating existing
+// hotels that share an external reference, or the id for rooms without one,
+// and inserting the rest. Either every row is written or none are.
+func (r *mysqlRoomRepo) UpsertBatch(rooms []model.Room) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO hotels (external_ref, "+hotelColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE name = VALUES(name), description = VALUES(description), location = VALUES(location), destination = VALUES(destination), "+
+		"rating = VALUES(rating), reviews = VALUES(reviews), price_cents = VALUES(price_cents), original_price_cents = VALUES(original_price_cents), currency = VALUES(currency), "+
+		"amenities = VALUES(amenities), featured = VALUES(featured), max_adults = VALUES(max_adults), max_children = VALUES(max_children), "+
+		"rooms_total = VALUES(rooms_total), rooms_available = VALUES(rooms_available), status = VALUES(status)")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmt.Close()
+	byID, err := tx.PrepareContext(ctx, "UPDATE hotels SET "+strings.ReplaceAll(hotelColumns, ",", " = ?,")+" = ? WHERE id = ?")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer byID.Close()
+
+	created, updated := 0, 0
+	for _, rm := range rooms {
+		applyRoomDefaults(&rm)
+		if rm.ExternalRef == "" && rm.ID != 0 {
+			if _, err := byID.ExecContext(ctx, append(roomArgs(rm)[1:], rm.ID)...); err != nil {
+				return 0, 0, err
+			}
+			updated++
+			continue
+		}
+		res, err := stmt.ExecContext(ctx, roomArgs(rm)...)
+		if err != nil {
+			return 0, 0, err
+		}
+		// MySQL reports 1 affected row for an insert, 2 for an update and 0
+		// when an existing row was left unchanged.
+		affected, _ := res.RowsAffected()
+		if affected == 1 {
+			created++
+		} else {
+			updated++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return created, updated, nil
+}
+
+// Update writes the partner-editable columns of an existing hotel. Room
+// counts, rating and reviews are left alone: bookings and reviews change
+// them with their own atomic updates, which a stale copy would undo.
+func (r *mysqlRoomRepo) Update(rm model.Room) error {
+	applyRoomDefaults(&rm)
 	original := sql.NullInt64{}
 	if rm.OriginalPriceCents != nil {
 		original = sql.NullInt64{Int64: int64(*rm.OriginalPriceCents), Valid: true}
 	}
-	featured := 0
-	if rm.Featured {
-		featured = 1
+	res, err := r.db.Exec("UPDATE hotels SET name = ?, description = ?, location = ?, destination = ?, price_cents = ?, original_price_cents = ?, currency = ?, "+
+		"amenities = ?, max_adults = ?, max_children = ?, status = ? WHERE id = ?",
+		rm.Name, rm.Description, rm.Location, rm.Destination, rm.PriceCents, original, rm.Currency,
+		rm.Amenities, rm.MaxAdults, rm.MaxChildren, rm.Status, rm.ID)
+	if err != nil {
+		return err
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// MySQL reports 0 for unchanged rows too, so confirm the hotel exists
+		if _, ok := r.Get(rm.ID); !ok {
+			return sql.ErrNoRows
+		}
+	}
+	return nil
+}
+
+func (r *mysqlRoomRepo) UpdateInventory(id int, roomsTotal int, roomsAvailable int) error {
+	_, err := r.db.Exec("UPDATE hotels SET rooms_total = ?, rooms_available = ? WHERE id = ?", roomsTotal, roomsAvailable, id)
+	return err
+}
+
+const hotelColumns = "name, description, location, destination, rating, reviews, price_cents, original_price_cents, currency, amenities, featured, max_adults, max_children, rooms_total, rooms_available, status"
+
+func applyRoomDefaults(rm *model.Room) {
 	if rm.Status == "" {
 		rm.Status = "active"
 	}
+	if rm.Currency == "" {
+		rm.Currency = money.DefaultCurrency
+	}
 	if rm.RoomsTotal == 0 {
 		rm.RoomsTotal = 1
 	}
@@ -160,8 +362,20 @@ func (r *mysqlRoomRepo) Create(rm model.Room) model.Room {
 	if rm.MaxAdults == 0 {
 		rm.MaxAdults = 1
 	}
-	result, err := r.db.Exec(
-		"INSERT INTO hotels (name, description, location, destination, rating, reviews, price_cents, original_price_cents, amenities, featured, max_adults, max_children, rooms_total, rooms_available, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+}
+
+func roomArgs(rm model.Room) []any {
+	original := sql.NullInt64{}
+	if rm.OriginalPriceCents != nil {
+		original = sql.NullInt64{Int64: int64(*rm.OriginalPriceCents), Valid: true}
+	}
+	externalRef := sql.NullString{String: rm.ExternalRef, Valid: rm.ExternalRef != ""}
+	featured := 0
+	if rm.Featured {
+		featured = 1
+	}
+	return []any{
+		externalRef,
 		rm.Name,
 		rm.Description,
 		rm.Location,
@@ -170,6 +384,7 @@ func (r *mysqlRoomRepo) Create(rm model.Room) model.Room {
 		rm.Reviews,
 		rm.PriceCents,
 		original,
+		rm.Currency,
 		rm.Amenities,
 		featured,
 		rm.MaxAdults,
@@ -177,11 +392,5 @@ func (r *mysqlRoomRepo) Create(rm model.Room) model.Room {
 		rm.RoomsTotal,
 		rm.RoomsAvailable,
 		rm.Status,
-	)
-	if err != nil {
-		return rm
 	}
-	id, _ := result.LastInsertId()
-	rm.ID = int(id)
-	return rm
 }
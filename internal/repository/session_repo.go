@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"agodrift/internal/model"
+)
+
+// SessionRepository stores issued access tokens by jti.
+type SessionRepository interface {
+	Create(s model.Session) error
+	Get(id string) (model.Session, bool)
+	// ListActive returns the user's unrevoked, unexpired sessions, newest first.
+	ListActive(userID int, now time.Time) ([]model.Session, error)
+	Touch(id string, at time.Time) error
+	// Revoke revokes one of the user's sessions; false if it was not active.
+	Revoke(userID int, id string, at time.Time) (bool, error)
+	// RevokeAll revokes every active session of the user except keep and
+	// returns how many were revoked.
+	RevokeAll(userID int, keep string, at time.Time) (int, error)
+}
+
+type inMemorySessionRepo struct {
+	mu       sync.RWMutex
+	sessions map[string]model.Session
+}
+
+func NewInMemorySessionRepo() *inMemorySessionRepo {
+	return &inMemorySessionRepo{sessions: make(map[string]model.Session)}
+}
+
+func (r *inMemorySessionRepo) Create(s model.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[s.ID] = s
+	return nil
+}
+
+func (r *inMemorySessionRepo) Get(id string) (model.Session, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.sessions[id]
+	return s, ok
+}
+
+func (r *inMemorySessionRepo) ListActive(userID int, now time.Time) ([]model.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]model.Session, 0)
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.Active(now) {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *inMemorySessionRepo) Touch(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sessions[id]; ok {
+		s.LastSeenAt = at
+		r.sessions[id] = s
+	}
+	return nil
+}
+
+func (r *inMemorySessionRepo) Revoke(userID int, id string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	if !ok || s.UserID != userID || s.RevokedAt != nil {
+		return false, nil
+	}
+	s.RevokedAt = &at
+	r.sessions[id] = s
+	return true, nil
+}
+
+func (r *inMemorySessionRepo) RevokeAll(userID int, keep string, at time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for id, s := range r.sessions {
+		if s.UserID == userID && id != keep && s.Active(at) {
+			s.RevokedAt = &at
+			r.sessions[id] = s
+			n++
+		}
+	}
+	return n, nil
+}
+
+type mysqlSessionRepo struct {
+	db *sql.DB
+}
+
+func NewMySQLSessionRepo(db *sql.DB) *mysqlSessionRepo {
+	return &mysqlSessionRepo{db: db}
+}
+
+const sessionSelect = "SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at FROM user_sessions"
+
+func (r *mysqlSessionRepo) Create(s model.Session) error {
+	_, err := r.db.Exec("INSERT INTO user_sessions (id, user_id, user_agent, ip, created_at, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		s.ID, s.UserID, s.UserAgent, s.IP, s.CreatedAt, s.LastSeenAt, s.ExpiresAt)
+	return err
+}
+
+func (r *mysqlSessionRepo) Get(id string) (model.Session, bool) {
+	s, err := scanSession(r.db.QueryRow(sessionSelect+" WHERE id = ?", id))
+	return s, err == nil
+}
+
+func (r *mysqlSessionRepo) ListActive(userID int, now time.Time) ([]model.Session, error) {
+	rows, err := r.db.Query(sessionSelect+" WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY created_at DESC", userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]model.Session, 0)
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *mysqlSessionRepo) Touch(id string, at time.Time) error {
+	_, err := r.db.Exec("UPDATE user_sessions SET last_seen_at = ? WHERE id = ?", at, id)
+	return err
+}
+
+func (r *mysqlSessionRepo) Revoke(userID int, id string, at time.Time) (bool, error) {
+	res, err := r.db.Exec("UPDATE user_sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL", at, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+func (r *mysqlSessionRepo) RevokeAll(userID int, keep string, at time.Time) (int, error) {
+	res, err := r.db.Exec("UPDATE user_sessions SET revoked_at = ? WHERE user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?", at, userID, keep, at)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func scanSession(row rowScanner) (model.Session, error) {
+	var s model.Session
+	var revoked sql.NullTime
+	if err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &revoked); err != nil {
+		return s, err
+	}
+	if revoked.Valid {
+		t := revoked.Time
+		s.RevokedAt = &t
+	}
+	return s, nil
+}
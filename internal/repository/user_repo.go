@@ -2,6 +2,9 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"sort"
+	"strings"
 	"sync"
 
 	"agodrift/internal/model"
@@ -9,10 +12,21 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 )
 
+var ErrUserNotFound = errors.New("user not found")
+
 // UserRepository defines methods for user storage.
 type UserRepository interface {
 	GetByEmail(email string) (model.User, bool)
+	GetByID(id int) (model.User, bool)
 	Create(u model.User) model.User
+	// Update saves the profile fields: name, phone, preferred currency and language.
+	Update(u model.User) error
+	UpdatePassword(id int, password string) error
+	SetEmailVerified(id int) error
+	SetRole(id int, role string) error
+	SetStatus(id int, status string) error
+	// Search returns one page of users ordered by id, and the total matches.
+	Search(f model.UserSearch) ([]model.User, int, error)
 }
 
 type inMemoryUserRepo struct {
@@ -40,15 +54,88 @@ func (r *inMemoryUserRepo) GetByEmail(email string) (model.User, bool) {
 	return u, ok
 }
 
+func (r *inMemoryUserRepo) GetByID(id int) (model.User, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, u := range r.users {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return model.User{}, false
+}
+
 func (r *inMemoryUserRepo) Create(u model.User) model.User {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if u.Status == "" {
+		u.Status = model.UserActive
+	}
 	u.ID = r.next
 	r.next++
 	r.users[u.Email] = u
 	return u
 }
 
+func (r *inMemoryUserRepo) Update(u model.User) error {
+	return r.update(u.ID, func(cur *model.User) {
+		cur.Name = u.Name
+		cur.Phone = u.Phone
+		cur.PreferredCurrency = u.PreferredCurrency
+		cur.Language = u.Language
+	})
+}
+
+func (r *inMemoryUserRepo) UpdatePassword(id int, password string) error {
+	return r.update(id, func(u *model.User) { u.Password = password })
+}
+
+func (r *inMemoryUserRepo) SetEmailVerified(id int) error {
+	return r.update(id, func(u *model.User) { u.EmailVerified = true })
+}
+
+func (r *inMemoryUserRepo) SetRole(id int, role string) error {
+	return r.update(id, func(u *model.User) { u.Role = role })
+}
+
+func (r *inMemoryUserRepo) SetStatus(id int, status string) error {
+	return r.update(id, func(u *model.User) { u.Status = status })
+}
+
+func (r *inMemoryUserRepo) Search(f model.UserSearch) ([]model.User, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	q := strings.ToLower(f.Query)
+	var matches []model.User
+	for _, u := range r.users {
+		if q != "" && !strings.Contains(strings.ToLower(u.Name), q) && !strings.Contains(strings.ToLower(u.Email), q) {
+			continue
+		}
+		if (f.Role != "" && u.Role != f.Role) || (f.Status != "" && u.Status != f.Status) {
+			continue
+		}
+		matches = append(matches, u)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+	total := len(matches)
+	start := min(model.Offset(f.Page, f.PerPage), total)
+	end := min(start+f.PerPage, total)
+	return matches[start:end], total, nil
+}
+
+func (r *inMemoryUserRepo) update(id int, fn func(u *model.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for email, u := range r.users {
+		if u.ID == id {
+			fn(&u)
+			r.users[email] = u
+			return nil
+		}
+	}
+	return ErrUserNotFound
+}
+
 type mysqlUserRepo struct {
 	db *sql.DB
 }
@@ -57,17 +144,28 @@ func NewMySQLUserRepo(db *sql.DB) *mysqlUserRepo {
 	return &mysqlUserRepo{db: db}
 }
 
-func (r *mysqlUserRepo) GetByEmail(email string) (model.User, bool) {
+// userColumns is selected by every query returning users; prefix with a
+// table alias where needed.
+const userColumns = "id, name, email, password, role, email_verified, phone, preferred_currency, language, status"
+
+func scanUser(row rowScanner) (model.User, error) {
 	var u model.User
-	err := r.db.QueryRow("SELECT id, name, email, password, role FROM users WHERE email = ?", email).Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.Role)
-	if err != nil {
-		return u, false
-	}
-	return u, true
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.Role, &u.EmailVerified, &u.Phone, &u.PreferredCurrency, &u.Language, &u.Status)
+	return u, err
+}
+
+func (r *mysqlUserRepo) GetByEmail(email string) (model.User, bool) {
+	u, err := scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE email = ?", email))
+	return u, err == nil
+}
+
+func (r *mysqlUserRepo) GetByID(id int) (model.User, bool) {
+	u, err := scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+	return u, err == nil
 }
 
 func (r *mysqlUserRepo) Create(u model.User) model.User {
-	result, err := r.db.Exec("INSERT INTO users (name, email, password, role) VALUES (?, ?, ?, ?)", u.Name, u.Email, u.Password, u.Role)
+	result, err := r.db.Exec("INSERT INTO users (name, email, password, role, email_verified) VALUES (?, ?, ?, ?, ?)", u.Name, u.Email, u.Password, u.Role, u.EmailVerified)
 	if err != nil {
 		return u
 	}
@@ -75,3 +173,66 @@ func (r *mysqlUserRepo) Create(u model.User) model.User {
 	u.ID = int(id)
 	return u
 }
+
+func (r *mysqlUserRepo) Update(u model.User) error {
+	_, err := r.db.Exec("UPDATE users SET name = ?, phone = ?, preferred_currency = ?, language = ? WHERE id = ?", u.Name, u.Phone, u.PreferredCurrency, u.Language, u.ID)
+	return err
+}
+
+func (r *mysqlUserRepo) UpdatePassword(id int, password string) error {
+	_, err := r.db.Exec("UPDATE users SET password = ? WHERE id = ?", password, id)
+	return err
+}
+
+func (r *mysqlUserRepo) SetEmailVerified(id int) error {
+	_, err := r.db.Exec("UPDATE users SET email_verified = 1 WHERE id = ?", id)
+	return err
+}
+
+func (r *mysqlUserRepo) SetRole(id int, role string) error {
+	_, err := r.db.Exec("UPDATE users SET role = ? WHERE id = ?", role, id)
+	return err
+}
+
+func (r *mysqlUserRepo) SetStatus(id int, status string) error {
+	_, err := r.db.Exec("UPDATE users SET status = ? WHERE id = ?", status, id)
+	return err
+}
+
+func (r *mysqlUserRepo) Search(f model.UserSearch) ([]model.User, int, error) {
+	where := []string{"1 = 1"}
+	var args []any
+	if f.Query != "" {
+		like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.Query) + "%"
+		where = append(where, "(name LIKE ? OR email LIKE ?)")
+		args = append(args, like, like)
+	}
+	if f.Role != "" {
+		where = append(where, "role = ?")
+		args = append(args, f.Role)
+	}
+	if f.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, f.Status)
+	}
+	cond := strings.Join(where, " AND ")
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM users WHERE "+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.Query("SELECT "+userColumns+" FROM users WHERE "+cond+" ORDER BY id LIMIT ? OFFSET ?", append(args, f.PerPage, model.Offset(f.Page, f.PerPage))...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	out := make([]model.User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, u)
+	}
+	return out, total, rows.Err()
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/mail"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+var (
+	ErrInvalidAccountToken = errors.New("invalid or expired token")
+	ErrWeakPassword        = errors.New("password must be at least 8 characters")
+)
+
+const (
+	passwordResetTTL = time.Hour
+	verifyEmailTTL   = 48 * time.Hour
+	minPasswordLen   = 8
+)
+
+// AccountService handles password reset and email verification. Both use
+// signed purpose tokens whose jti is stored so each link works once.
+type AccountService struct {
+	users   repository.UserRepository
+	tokens  repository.OneTimeTokenRepository
+	auth    *AuthService
+	mailer  mail.Mailer
+	baseURL string // frontend that hosts the reset and verify pages
+	now     func() time.Time
+}
+
+// NewAccountService builds links against APP_BASE_URL and sends mail through
+// the background mail queue.
+func NewAccountService() *AccountService {
+	db := config.GetDB()
+	return NewAccountServiceWith(repository.NewMySQLUserRepo(db), repository.NewMySQLOneTimeTokenRepo(db), GetAuth(), mail.DefaultQueue(), config.Get("APP_BASE_URL", "http://localhost:3000"), time.Now)
+}
+
+func NewAccountServiceWith(users repository.UserRepository, tokens repository.OneTimeTokenRepository, auth *AuthService, mailer mail.Mailer, baseURL string, now func() time.Time) *AccountService {
+	return &AccountService{users: users, tokens: tokens, auth: auth, mailer: mailer, baseURL: strings.TrimRight(baseURL, "/"), now: now}
+}
+
+// RequestPasswordReset mails a reset link. Unknown emails are ignored so
+// the endpoint cannot be used to discover accounts.
+func (s *AccountService) RequestPasswordReset(email string) error {
+	u, ok := s.users.GetByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if !ok {
+		return nil
+	}
+	token, err := s.issue(u, PurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	link := s.baseURL + "/reset-password?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mail.Message{
+		To:      u.Email,
+		Subject: "Reset your AgoDrift password",
+		Text: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for this account. Open the link below within %d minutes to choose a new one:\n\n%s\n\nIf it wasn't you, you can ignore this email.\n",
+			displayName(u), int(passwordResetTTL.Minutes()), link),
+	})
+}
+
+// ResetPassword sets a new password and signs the user out everywhere.
+func (s *AccountService) ResetPassword(token, password string) error {
+	if len(password) < minPasswordLen {
+		return ErrWeakPassword
+	}
+	u, err := s.consume(token, PurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	if err := s.users.UpdatePassword(u.ID, password); err != nil {
+		return err
+	}
+	// older reset links for the account die with the password they were for
+	if err := s.tokens.Invalidate(u.ID, PurposePasswordReset, s.now()); err != nil {
+		log.Printf("account: invalidate reset tokens: %v", err)
+	}
+	s.auth.RevokeUserTokens(u.ID)
+	return nil
+}
+
+// RequestEmailVerification mails a verification link for the user's address.
+func (s *AccountService) RequestEmailVerification(email string) error {
+	u, ok := s.users.GetByEmail(email)
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	if u.EmailVerified {
+		return nil
+	}
+	token, err := s.issue(u, PurposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	link := s.baseURL + "/verify-email?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mail.Message{
+		To:      u.Email,
+		Subject: "Confirm your email address",
+		Text:    fmt.Sprintf("Hi %s,\n\nPlease confirm this is your email address:\n\n%s\n", displayName(u), link),
+	})
+}
+
+// VerifyEmail marks the address the token was sent to as verified.
+func (s *AccountService) VerifyEmail(token string) error {
+	u, err := s.consume(token, PurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+	// the token is bound to the address it was mailed to
+	current, ok := s.users.GetByEmail(u.Email)
+	if !ok || current.ID != u.ID {
+		return ErrInvalidAccountToken
+	}
+	return s.users.SetEmailVerified(u.ID)
+}
+
+func (s *AccountService) issue(u model.User, purpose string, ttl time.Duration) (string, error) {
+	token, jti, err := s.auth.CreatePurposeToken(u, purpose, ttl)
+	if err != nil {
+		return "", err
+	}
+	if err := s.tokens.Create(jti, u.ID, purpose, s.now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *AccountService) consume(token, purpose string) (model.User, error) {
+	u, jti, _, ok := s.auth.ParsePurposeToken(token, purpose)
+	if !ok || !s.tokens.Consume(jti, purpose, s.now()) {
+		return model.User{}, ErrInvalidAccountToken
+	}
+	return u, nil
+}
+
+func displayName(u model.User) string {
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.Email
+}
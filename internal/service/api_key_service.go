@@ -0,0 +1,177 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+var ErrInvalidAPIKey = errors.New("invalid or expired api key")
+
+// API keys look like agd_<8 hex prefix>_<64 hex secret>. The prefix is
+// stored in clear so keys can be told apart; only a hash of the whole key is
+// kept.
+const (
+	apiKeyScheme    = "agd_"
+	apiKeyPrefixLen = len(apiKeyScheme) + 8
+	// apiKeyTouchInterval limits last-used writes to one per key per minute.
+	apiKeyTouchInterval = time.Minute
+)
+
+// NewAPIKey is the result of creating a key. Key is only ever shown here.
+type NewAPIKey struct {
+	model.APIKey
+	Key string `json:"key"`
+}
+
+// APIKeyService issues and checks personal API keys. A key carries a subset
+// of its user's permissions; RBACService.Has enforces the subset through the
+// role returned by APIKeyRole.
+type APIKeyService struct {
+	repo  repository.APIKeyRepository
+	users repository.UserRepository
+	perms *RBACService
+	now   func() time.Time
+}
+
+// DefaultAPIKeys is a shared singleton used by handlers and middleware
+var DefaultAPIKeys *APIKeyService
+
+var apiKeysOnce sync.Once
+
+// GetAPIKeys returns the shared APIKeyService, creating it on first use.
+func GetAPIKeys() *APIKeyService {
+	apiKeysOnce.Do(func() {
+		if DefaultAPIKeys == nil {
+			db := config.GetDB()
+			DefaultAPIKeys = NewAPIKeyServiceWith(repository.NewMySQLAPIKeyRepo(db), repository.NewMySQLUserRepo(db), GetRBAC(), time.Now)
+		}
+	})
+	return DefaultAPIKeys
+}
+
+// NewAPIKeyServiceWith also installs the key scope lookup on perms.
+func NewAPIKeyServiceWith(repo repository.APIKeyRepository, users repository.UserRepository, perms *RBACService, now func() time.Time) *APIKeyService {
+	s := &APIKeyService{repo: repo, users: users, perms: perms, now: now}
+	perms.SetKeyScopes(s.scope)
+	return s
+}
+
+// Create issues a key limited to permissions, each of which the user must
+// hold. A nil expiresAt means the key never expires.
+func (s *APIKeyService) Create(userID int, role string, name string, permissions []string, expiresAt *time.Time) (NewAPIKey, error) {
+	var problems []string
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		problems = append(problems, "name must be 1 to 100 characters")
+	}
+	if len(permissions) == 0 {
+		problems = append(problems, "at least one permission is required")
+	}
+	seen := make(map[string]bool)
+	perms := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch {
+		case !permissionPattern.MatchString(p):
+			problems = append(problems, fmt.Sprintf("invalid permission %q", p))
+		case !s.perms.Has(userID, role, p):
+			problems = append(problems, fmt.Sprintf("you do not hold permission %q", p))
+		case !seen[p]:
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+	now := s.now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		problems = append(problems, "expires_at must be in the future")
+	}
+	if len(problems) > 0 {
+		return NewAPIKey{}, &ValidationError{Problems: problems}
+	}
+
+	prefix, err := randomHex(4)
+	if err != nil {
+		return NewAPIKey{}, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return NewAPIKey{}, err
+	}
+	raw := apiKeyScheme + prefix + "_" + secret
+	k, err := s.repo.Create(model.APIKey{
+		UserID: userID, Name: name, Prefix: raw[:apiKeyPrefixLen], Hash: hashAPIKey(raw),
+		Permissions: perms, ExpiresAt: expiresAt, CreatedAt: now,
+	})
+	if err != nil {
+		return NewAPIKey{}, err
+	}
+	return NewAPIKey{APIKey: k, Key: raw}, nil
+}
+
+// List returns the user's keys, newest first, including revoked ones.
+func (s *APIKeyService) List(userID int) ([]model.APIKey, error) {
+	return s.repo.ListByUser(userID)
+}
+
+func (s *APIKeyService) Revoke(userID int, id int) error {
+	return s.repo.Revoke(userID, id, s.now())
+}
+
+// Authenticate resolves a raw key to its key record and active user.
+func (s *APIKeyService) Authenticate(raw string) (model.APIKey, model.User, error) {
+	if len(raw) <= apiKeyPrefixLen || !strings.HasPrefix(raw, apiKeyScheme) {
+		return model.APIKey{}, model.User{}, ErrInvalidAPIKey
+	}
+	k, ok := s.repo.GetByPrefix(raw[:apiKeyPrefixLen])
+	if !ok || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashAPIKey(raw))) != 1 {
+		return model.APIKey{}, model.User{}, ErrInvalidAPIKey
+	}
+	now := s.now()
+	if !k.Usable(now) {
+		return model.APIKey{}, model.User{}, ErrInvalidAPIKey
+	}
+	u, ok := s.users.GetByID(k.UserID)
+	if !ok || u.Status == model.UserDisabled {
+		return model.APIKey{}, model.User{}, ErrInvalidAPIKey
+	}
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.repo.Touch(k.ID, now); err != nil {
+			log.Printf("api key: touch %d: %v", k.ID, err)
+		}
+	}
+	return k, u, nil
+}
+
+// scope returns the permissions of a usable key.
+func (s *APIKeyService) scope(keyID int) ([]string, bool) {
+	k, ok := s.repo.Get(keyID)
+	if !ok || !k.Usable(s.now()) {
+		return nil, false
+	}
+	return k.Permissions, true
+}
+
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"errors"
+	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,8 +17,9 @@ import (
 
 // AuthService handles authentication and token generation.
 type AuthService struct {
-	users  repository.UserRepository
-	secret []byte
+	users    repository.UserRepository
+	keys     *KeySet
+	sessions *SessionService
 	// simple in-memory blacklist of token jtis -> expiry
 	blacklist map[string]int64
 	mu        sync.Mutex
@@ -31,10 +35,37 @@ func InitDefaultAuth(secret string) {
 	}
 }
 
+// NewAuthService creates the service. Signing keys are read from:
+//
+//	JWT_SIGNING_KEY_FILE  PEM private key (RSA or Ed25519) used to sign new tokens
+//	JWT_VERIFY_KEY_FILES  comma-separated PEM keys whose tokens are still accepted
+//	JWT_ACCEPT_HS256      "true" to keep accepting HS256 tokens signed with secret
+//
+// Without JWT_SIGNING_KEY_FILE tokens are signed with HS256 and secret.
 func NewAuthService(secret string) *AuthService {
+	keys, err := LoadKeySet(
+		config.Get("JWT_SIGNING_KEY_FILE", ""),
+		strings.Split(config.Get("JWT_VERIFY_KEY_FILES", ""), ","),
+		[]byte(secret),
+		config.Get("JWT_ACCEPT_HS256", "false") == "true",
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db := config.GetDB()
+	return NewAuthServiceWith(repository.NewMySQLUserRepo(db), keys, NewSessionService(repository.NewMySQLSessionRepo(db), time.Now))
+}
+
+// NewAuthServiceWithKeys keeps sessions in memory.
+func NewAuthServiceWithKeys(users repository.UserRepository, keys *KeySet) *AuthService {
+	return NewAuthServiceWith(users, keys, NewSessionService(repository.NewInMemorySessionRepo(), time.Now))
+}
+
+func NewAuthServiceWith(users repository.UserRepository, keys *KeySet, sessions *SessionService) *AuthService {
 	return &AuthService{
-		users:     repository.NewMySQLUserRepo(config.GetDB()),
-		secret:    []byte(secret),
+		users:     users,
+		keys:      keys,
+		sessions:  sessions,
 		blacklist: make(map[string]int64),
 	}
 }
@@ -53,25 +84,141 @@ func (s *AuthService) Authenticate(email, password string) (model.User, bool) {
 		return model.User{}, false
 	}
 	// plain password check for demo - replace with hashed compare in prod
-	if u.Password != password {
+	if u.Password != password || u.Status == model.UserDisabled {
 		return model.User{}, false
 	}
 	return u, true
 }
 
-// CreateToken generates a JWT with role claim and jti.
+var ErrAccountDisabled = errors.New("account is disabled")
+
+// CurrentUser loads the stored user a token was issued to. It fails for
+// unknown and disabled users, so the JWT middleware refuses their tokens and
+// picks up role changes without a new login.
+func (s *AuthService) CurrentUser(claims jwt.MapClaims) (model.User, error) {
+	uidFloat, _ := claims["uid"].(float64)
+	u, ok := s.users.GetByID(int(uidFloat))
+	if !ok {
+		return model.User{}, repository.ErrUserNotFound
+	}
+	if u.Status == model.UserDisabled {
+		return model.User{}, ErrAccountDisabled
+	}
+	return u, nil
+}
+
+// CreateToken generates a JWT with role claim and jti, recording a session
+// without client details.
 func (s *AuthService) CreateToken(u model.User, ttl time.Duration) (string, error) {
+	return s.StartSession(u, ttl, SessionClient{})
+}
+
+// StartSession issues an access token and records it as a session of the
+// client so the user can later list and revoke it.
+func (s *AuthService) StartSession(u model.User, ttl time.Duration, client SessionClient) (string, error) {
+	// the caller may hold a stale copy, e.g. from an mfa challenge token
+	if cur, ok := s.users.GetByID(u.ID); ok {
+		if cur.Status == model.UserDisabled {
+			return "", ErrAccountDisabled
+		}
+		u = cur
+	}
 	jti := uuid.NewString()
+	exp := time.Now().Add(ttl)
 	claims := jwt.MapClaims{
 		"sub":  u.Email,
 		"uid":  u.ID,
 		"role": u.Role,
 		"jti":  jti,
-		"exp":  time.Now().Add(ttl).Unix(),
+		"exp":  exp.Unix(),
 		"iat":  time.Now().Unix(),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secret)
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", err
+	}
+	if err := s.sessions.Start(u, jti, exp, client); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Sessions exposes the session store for listing and revocation.
+func (s *AuthService) Sessions() *SessionService {
+	return s.sessions
+}
+
+// Purpose-bound tokens prove a single step (the password step of a two-step
+// login, ownership of a mailbox) and are never access tokens: the JWT
+// middleware refuses any token with a purpose claim.
+const (
+	PurposeMFA           = "mfa"
+	PurposePasswordReset = "password_reset"
+	PurposeVerifyEmail   = "verify_email"
+)
+
+var ErrInvalidChallenge = errors.New("invalid or expired mfa token")
+
+// CreateChallengeToken issues a token for the second login step.
+func (s *AuthService) CreateChallengeToken(u model.User, ttl time.Duration) (string, error) {
+	tok, _, err := s.CreatePurposeToken(u, PurposeMFA, ttl)
+	return tok, err
+}
+
+// ParseChallengeToken verifies a challenge token and returns the user it was
+// issued for. The token's jti is returned so it can be revoked once used.
+func (s *AuthService) ParseChallengeToken(raw string) (model.User, string, int64, error) {
+	u, jti, exp, ok := s.ParsePurposeToken(raw, PurposeMFA)
+	if !ok || s.IsBlacklisted(jti) {
+		return model.User{}, "", 0, ErrInvalidChallenge
+	}
+	return u, jti, exp, nil
+}
+
+// CreatePurposeToken signs a short-lived token usable only for purpose and
+// returns it with its jti.
+func (s *AuthService) CreatePurposeToken(u model.User, purpose string, ttl time.Duration) (string, string, error) {
+	jti := uuid.NewString()
+	claims := jwt.MapClaims{
+		"sub":     u.Email,
+		"uid":     u.ID,
+		"role":    u.Role,
+		"jti":     jti,
+		"purpose": purpose,
+		"exp":     time.Now().Add(ttl).Unix(),
+		"iat":     time.Now().Unix(),
+	}
+	tok, err := s.keys.Sign(claims)
+	return tok, jti, err
+}
+
+// ParsePurposeToken verifies the signature, expiry and purpose of a token.
+// Single use is up to the caller.
+func (s *AuthService) ParsePurposeToken(raw string, purpose string) (model.User, string, int64, bool) {
+	tok, err := s.keys.Parse(raw)
+	if err != nil || !tok.Valid {
+		return model.User{}, "", 0, false
+	}
+	claims, ok := tok.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return model.User{}, "", 0, false
+	}
+	jti, _ := claims["jti"].(string)
+	email, _ := claims["sub"].(string)
+	role, _ := claims["role"].(string)
+	uidFloat, _ := claims["uid"].(float64)
+	expFloat, _ := claims["exp"].(float64)
+	return model.User{ID: int(uidFloat), Email: email, Role: role}, jti, int64(expFloat), true
+}
+
+// ParseToken verifies a token's signature and expiry against the key set.
+func (s *AuthService) ParseToken(raw string) (*jwt.Token, error) {
+	return s.keys.Parse(raw)
+}
+
+// JWKS returns the public keys tokens can be verified with.
+func (s *AuthService) JWKS() map[string][]JWK {
+	return s.keys.JWKS()
 }
 
 // BlacklistToken marks a token's jti as revoked until expiry
@@ -96,3 +243,18 @@ func (s *AuthService) IsBlacklisted(jti string) bool {
 	}
 	return true
 }
+
+// RevokeUserTokens revokes every session of the user, e.g. after a
+// password reset.
+func (s *AuthService) RevokeUserTokens(userID int) {
+	if _, err := s.sessions.RevokeAll(userID); err != nil {
+		log.Printf("auth: revoke sessions of user %d: %v", userID, err)
+	}
+}
+
+// IsRevoked reports whether an access token was revoked, either by jti or
+// because its session is no longer active.
+func (s *AuthService) IsRevoked(claims jwt.MapClaims) bool {
+	jti, _ := claims["jti"].(string)
+	return s.IsBlacklisted(jti) || !s.sessions.Check(jti)
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/refcode"
+	"agodrift/internal/repository"
+)
+
+var ErrInvalidBookingFilter = errors.New(`filter must be "upcoming", "past" or "cancelled"`)
+
+const (
+	defaultBookingsPerPage = 20
+	maxBookingsPerPage     = 100
+)
+
+// Get returns a booking with its hotel, price breakdown, status history and
+// what cancelling it now would cost.
+// Guests see their own bookings, hotel staff those at their linked hotels.
+func (s *BookingService) Get(userID int, role string, id int) (model.BookingDetail, error) {
+	b, err := s.repo.GetByID(id)
+	if err != nil {
+		return model.BookingDetail{}, err
+	}
+	if b.UserID != userID && !s.access.can(userID, role, b.HotelID, model.PermBookingsReadAny, model.PermBookingsReadHotel) {
+		return model.BookingDetail{}, ErrForbidden
+	}
+	list, err := s.details([]model.Booking{b})
+	if err != nil {
+		return model.BookingDetail{}, err
+	}
+	return list[0], nil
+}
+
+// ListMine returns one page of the caller's bookings, 20 per page by
+// default, optionally only upcoming, past or cancelled ones.
+func (s *BookingService) ListMine(f model.BookingSearch) (model.Page[model.BookingDetail], error) {
+	switch f.Filter {
+	case "", model.BookingsUpcoming, model.BookingsPast, model.BookingsCancelled:
+	default:
+		return model.Page[model.BookingDetail]{}, ErrInvalidBookingFilter
+	}
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.PerPage < 1 {
+		f.PerPage = defaultBookingsPerPage
+	}
+	f.PerPage = min(f.PerPage, maxBookingsPerPage)
+	if f.Today.IsZero() {
+		f.Today = time.Now()
+	}
+	list, total, err := s.repo.Search(f)
+	if err != nil {
+		return model.Page[model.BookingDetail]{}, err
+	}
+	items, err := s.details(list)
+	if err != nil {
+		return model.Page[model.BookingDetail]{}, err
+	}
+	return model.Page[model.BookingDetail]{Items: items, Total: total, Page: f.Page, PerPage: f.PerPage}, nil
+}
+
+// details loads the page's hotels and status histories in one query each.
+func (s *BookingService) details(list []model.Booking) ([]model.BookingDetail, error) {
+	ids := make([]int, len(list))
+	hotelIDs := make([]int, 0, len(list))
+	seen := make(map[int]bool)
+	for i, b := range list {
+		ids[i] = b.ID
+		if !seen[b.HotelID] {
+			seen[b.HotelID] = true
+			hotelIDs = append(hotelIDs, b.HotelID)
+		}
+	}
+	history, err := s.repo.StatusHistory(ids)
+	if err != nil {
+		return nil, err
+	}
+	rooms, err := s.rooms.GetMany(hotelIDs)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	out := make([]model.BookingDetail, len(list))
+	for i, b := range list {
+		var hotel *model.HotelSummary
+		if rm, ok := rooms[b.HotelID]; ok {
+			hotel = &model.HotelSummary{ID: rm.ID, Name: rm.Name, Location: rm.Location, Destination: rm.Destination, Rating: rm.Rating}
+		}
+		out[i] = NewBookingDetail(b, hotel, history[b.ID])
+		if b.Status != model.BookingCancelled {
+			c := b.CancellationCharge(now)
+			out[i].Cancellation = &c
+		}
+	}
+	return out, nil
+}
+
+// NewBookingDetail assembles the detail view of b. Bookings made before status
+// history was kept get a single entry for their current status.
+func NewBookingDetail(b model.Booking, hotel *model.HotelSummary, history []model.BookingStatusChange) model.BookingDetail {
+	nights := model.Nights(b.CheckIn, b.CheckOut)
+	if len(history) == 0 {
+		history = []model.BookingStatusChange{{Status: b.Status, ChangedAt: b.CreatedAt}}
+	}
+	price := model.PriceBreakdown{
+		Nights:            nights,
+		Rooms:             b.Rooms,
+		SubtotalCents:     b.TotalPriceCents + b.DiscountCents,
+		DiscountCents:     b.DiscountCents,
+		TotalPriceCents:   b.TotalPriceCents,
+		Currency:          b.Currency,
+		DisplayCurrency:   b.DisplayCurrency,
+		DisplayTotalCents: b.DisplayTotalCents,
+		ExchangeRate:      b.ExchangeRate,
+	}
+	if b.Rooms > 0 {
+		price.NightlyPriceCents = price.SubtotalCents / (nights * b.Rooms)
+	}
+	return model.BookingDetail{Booking: b, Hotel: hotel, Nights: nights, Price: price, StatusHistory: history}
+}
+
+// Lookup finds a booking by its reference code for a guest who is not
+// logged in. The last name must match the lead guest or the account holder;
+// every failure is reported as ErrBookingNotFound so codes can't be probed.
+func (s *BookingService) Lookup(code string, lastName string) (model.BookingDetail, error) {
+	code = refcode.Normalize(code)
+	if !refcode.Valid(code) {
+		return model.BookingDetail{}, repository.ErrBookingNotFound
+	}
+	b, err := s.repo.GetByReference(code)
+	if err != nil {
+		return model.BookingDetail{}, err
+	}
+	names := []string{}
+	if b.LeadGuest != nil {
+		names = append(names, b.LeadGuest.Name)
+	}
+	if u, ok := s.users.GetByID(b.UserID); ok {
+		names = append(names, u.Name)
+	}
+	for _, name := range names {
+		if MatchesLastName(name, lastName) {
+			list, err := s.details([]model.Booking{b})
+			if err != nil {
+				return model.BookingDetail{}, err
+			}
+			return list[0], nil
+		}
+	}
+	return model.BookingDetail{}, repository.ErrBookingNotFound
+}
+
+// MatchesLastName reports whether lastName, ignoring case and spacing, is
+// the end of fullName: "Berg" and "van der Berg" both match "Anna van der Berg".
+func MatchesLastName(fullName string, lastName string) bool {
+	full := strings.ToLower(strings.Join(strings.Fields(fullName), " "))
+	last := strings.ToLower(strings.Join(strings.Fields(lastName), " "))
+	if last == "" || full == "" {
+		return false
+	}
+	return full == last || strings.HasSuffix(full, " "+last)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+const (
+	maxGuestNameLength      = 100
+	maxSpecialRequestLength = 1000
+	maxChildAge             = 17
+)
+
+var arrivalTimePattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// CheckGuestDetails trims d and checks it against the booking's guest
+// counts: named guests are optional but there may not be more of them than
+// were booked, and every named child needs an age.
+func CheckGuestDetails(d model.GuestDetails, adults, children int) (model.GuestDetails, error) {
+	var problems []string
+	if d.LeadGuest != nil {
+		lead := model.GuestContact{
+			Name:  strings.TrimSpace(d.LeadGuest.Name),
+			Email: strings.TrimSpace(d.LeadGuest.Email),
+			Phone: strings.TrimSpace(d.LeadGuest.Phone),
+		}
+		if lead.Name == "" || utf8.RuneCountInString(lead.Name) > maxGuestNameLength {
+			problems = append(problems, "lead_guest.name must be 1 to 100 characters")
+		}
+		if lead.Email != "" {
+			if a, err := mail.ParseAddress(lead.Email); err != nil || a.Address != lead.Email || len(lead.Email) > 255 {
+				problems = append(problems, "lead_guest.email is not a valid email address")
+			}
+		}
+		if lead.Phone != "" && !profilePhonePattern.MatchString(lead.Phone) {
+			problems = append(problems, "lead_guest.phone is not a valid phone number")
+		}
+		d.LeadGuest = &lead
+	}
+
+	guests := make([]model.BookingGuest, 0, len(d.Guests))
+	namedAdults, namedChildren := 0, 0
+	for i, g := range d.Guests {
+		g.Name = strings.TrimSpace(g.Name)
+		if g.Name == "" || utf8.RuneCountInString(g.Name) > maxGuestNameLength {
+			problems = append(problems, fmt.Sprintf("guests[%d].name must be 1 to 100 characters", i))
+		}
+		if g.Child {
+			namedChildren++
+			if g.Age == nil || *g.Age < 0 || *g.Age > maxChildAge {
+				problems = append(problems, fmt.Sprintf("guests[%d].age must be between 0 and %d", i, maxChildAge))
+			}
+		} else {
+			namedAdults++
+			g.Age = nil
+		}
+		guests = append(guests, g)
+	}
+	d.Guests = guests
+	if namedAdults > adults {
+		problems = append(problems, fmt.Sprintf("guests lists %d adults but %d were booked", namedAdults, adults))
+	}
+	if namedChildren > children {
+		problems = append(problems, fmt.Sprintf("guests lists %d children but %d were booked", namedChildren, children))
+	}
+
+	d.SpecialRequests = strings.TrimSpace(d.SpecialRequests)
+	if utf8.RuneCountInString(d.SpecialRequests) > maxSpecialRequestLength {
+		problems = append(problems, "special_requests must be at most 1000 characters")
+	}
+	d.ArrivalTime = strings.TrimSpace(d.ArrivalTime)
+	if d.ArrivalTime != "" && !arrivalTimePattern.MatchString(d.ArrivalTime) {
+		problems = append(problems, "arrival_time must be HH:MM")
+	}
+	if len(problems) > 0 {
+		return model.GuestDetails{}, &ValidationError{Problems: problems}
+	}
+	return d, nil
+}
+
+// UpdateGuestDetails replaces the guest details of a booking made by the
+// caller, or anyone's booking when the caller may modify any.
+func (s *BookingService) UpdateGuestDetails(userID int, role string, id int, d model.GuestDetails) (model.Booking, error) {
+	b, err := s.repo.GetByID(id)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if b.UserID != userID && !s.access.perms.Has(userID, role, model.PermBookingsModifyAny) {
+		return model.Booking{}, ErrForbidden
+	}
+	if b.Status == model.BookingCancelled {
+		return model.Booking{}, repository.ErrBookingCancelled
+	}
+	d, err = CheckGuestDetails(d, b.Adults, b.Children)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if d.LeadGuest == nil {
+		d.LeadGuest = s.accountContact(b.UserID)
+	}
+	if err := s.repo.SetGuestDetails(id, d); err != nil {
+		return model.Booking{}, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// accountContact is the lead guest used when none is given: the account
+// holder.
+func (s *BookingService) accountContact(userID int) *model.GuestContact {
+	u, ok := s.users.GetByID(userID)
+	if !ok {
+		return nil
+	}
+	return &model.GuestContact{Name: u.Name, Email: u.Email, Phone: u.Phone}
+}
@@ -1,25 +1,389 @@
 package service
 
 import (
+	"errors"
+	"log"
 	"time"
 
 	"agodrift/internal/config"
 	"agodrift/internal/model"
+	"agodrift/internal/money"
+	"agodrift/internal/notification"
 	"agodrift/internal/repository"
 )
 
+var (
+	ErrHotelNotFound  = errors.New("hotel not found")
+	ErrBookingStarted = repository.ErrBookingStarted
+	ErrBookingPaid    = errors.New("paid bookings cannot be changed; cancel and book again")
+)
+
+// BookingUpdate is a partial change to a booking; nil fields are kept.
+type BookingUpdate struct {
+	CheckIn  *time.Time
+	CheckOut *time.Time
+	Adults   *int
+	Children *int
+	Rooms    *int
+}
+
+// Apply returns the booking's terms with the update applied, or the reasons
+// it is invalid, including named guests the new counts no longer cover.
+// today is the current date at midnight.
+func (u BookingUpdate) Apply(b model.Booking, today time.Time) (model.BookingTerms, error) {
+	t := b.Terms()
+	if u.CheckIn != nil {
+		t.CheckIn = *u.CheckIn
+	}
+	if u.CheckOut != nil {
+		t.CheckOut = *u.CheckOut
+	}
+	if u.Adults != nil {
+		t.Adults = *u.Adults
+	}
+	if u.Children != nil {
+		t.Children = *u.Children
+	}
+	if u.Rooms != nil {
+		t.Rooms = *u.Rooms
+	}
+	var problems []string
+	if t.Equal(b.Terms()) {
+		problems = append(problems, "nothing to change")
+	}
+	if t.CheckIn.Before(today) {
+		problems = append(problems, "check_in must not be in the past")
+	}
+	if !t.CheckOut.After(t.CheckIn) {
+		problems = append(problems, "check_out must be after check_in")
+	}
+	if t.Adults < 1 {
+		problems = append(problems, "adults must be at least 1")
+	}
+	if t.Children < 0 {
+		problems = append(problems, "children must not be negative")
+	}
+	if t.Rooms < 1 {
+		problems = append(problems, "rooms must be at least 1")
+	}
+	// named guests must still fit; they are changed with the guest details
+	if _, err := CheckGuestDetails(model.GuestDetails{Guests: b.Guests}, t.Adults, t.Children); err != nil {
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			return model.BookingTerms{}, err
+		}
+		problems = append(problems, ve.Problems...)
+	}
+	if len(problems) > 0 {
+		return model.BookingTerms{}, &ValidationError{Problems: problems}
+	}
+	return t, nil
+}
+
 type BookingService struct {
-	repo repository.BookingRepository
+	repo     repository.BookingRepository
+	rooms    repository.RoomRepository
+	users    repository.UserRepository
+	owners   repository.HotelOwnerRepository
+	access   hotelAccess
+	currency *CurrencyService
+	policies repository.CancellationPolicyRepository
+	promos   *PromoService
+	loyalty  *LoyaltyService
+	payments *PaymentService
+	invoices *InvoiceService
+	notifier *notification.Notifier
 }
 
 func NewBookingService() *BookingService {
-	return &BookingService{repo: repository.NewMySQLBookingRepo(config.GetDB())}
+	db := config.GetDB()
+	return NewBookingServiceWith(repository.NewMySQLBookingRepo(db), repository.NewMySQLRoomRepo(db), repository.NewMySQLUserRepo(db),
+		repository.NewMySQLHotelOwnerRepo(db), GetRBAC(), NewCurrencyService(), repository.NewMySQLCancellationPolicyRepo(db),
+		NewPromoService(), NewLoyaltyService(), NewPaymentService(), NewInvoiceService(), notification.NewNotifier())
+}
+
+func NewBookingServiceWith(repo repository.BookingRepository, rooms repository.RoomRepository, users repository.UserRepository,
+	owners repository.HotelOwnerRepository, perms *RBACService, currency *CurrencyService, policies repository.CancellationPolicyRepository,
+	promos *PromoService, loyalty *LoyaltyService, payments *PaymentService, invoices *InvoiceService, notifier *notification.Notifier) *BookingService {
+	return &BookingService{
+		repo:     repo,
+		rooms:    rooms,
+		users:    users,
+		owners:   owners,
+		access:   hotelAccess{owners: owners, perms: perms},
+		currency: currency,
+		policies: policies,
+		promos:   promos,
+		loyalty:  loyalty,
+		payments: payments,
+		invoices: invoices,
+		notifier: notifier,
+	}
+}
+
+// Create books a stay under the hotel's current cancellation policy, less
+// any promo codes, with b.PointsRedeemed loyalty points paying for part or
+// all of it. b.DisplayCurrency optionally asks for the total to be
+// converted as well; the rate used is stored on the booking. Without a lead
+// guest the account holder is used.
+func (s *BookingService) Create(b model.Booking, promoCodes []string) (model.Booking, error) {
+	d, err := CheckGuestDetails(b.GuestDetails, b.Adults, b.Children)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if d.LeadGuest == nil {
+		d.LeadGuest = s.accountContact(b.UserID)
+	}
+	b.GuestDetails = d
+	policy, err := s.policies.ForHotel(b.HotelID)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	b.CancellationPolicy = &policy
+	if len(promoCodes) > 0 || b.PointsRedeemed != 0 {
+		hotel, ok := s.rooms.Get(b.HotelID)
+		if !ok {
+			return model.Booking{}, ErrHotelNotFound
+		}
+		stay := Stay{UserID: b.UserID, Hotel: hotel, CheckIn: b.CheckIn, CheckOut: b.CheckOut, Rooms: b.Rooms}
+		if b.Promos, err = s.promos.Apply(promoCodes, stay); err != nil {
+			return model.Booking{}, err
+		}
+		if b.PointsValueCents, err = s.loyalty.Redemption(b.UserID, b.PointsRedeemed, hotel.Currency); err != nil {
+			return model.Booking{}, err
+		}
+		subtotal := hotel.PriceCents * model.Nights(b.CheckIn, b.CheckOut) * b.Rooms
+		if b.PointsValueCents > subtotal-model.ApplyPromos(subtotal, b.Promos) {
+			return model.Booking{}, &ValidationError{Problems: []string{"redeem_points are worth more than the booking total"}}
+		}
+		// fixed promo amounts and the points' value are in the hotel's
+		// currency, which must not change before the booking is stored
+		b.Currency = hotel.Currency
+	}
+	b.DisplayCurrency = money.Normalize(b.DisplayCurrency)
+	if b.DisplayCurrency != "" {
+		hotel, ok := s.rooms.Get(b.HotelID)
+		if !ok {
+			return model.Booking{}, ErrHotelNotFound
+		}
+		if hotel.Currency != b.DisplayCurrency {
+			rate, err := s.currency.Rate(hotel.Currency, b.DisplayCurrency)
+			if err != nil {
+				return model.Booking{}, err
+			}
+			b.Currency = hotel.Currency
+			b.ExchangeRate = money.FormatRate(rate)
+		}
+	}
+	b, err = s.repo.Create(b)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	s.notifier.Notify(notification.BookingCreated, b)
+	return b, nil
+}
+
+// Modify changes a booking made by the caller, or anyone's booking when the
+// caller may modify any, before check-in. The stay is repriced like a new
+// booking and the returned change holds the price delta; its promo codes
+// must still qualify. Bookings with a payment are not changed, since the
+// payment would no longer match, nor are bookings confirmed on loyalty points
+// repriced above what the points cover.
+func (s *BookingService) Modify(userID int, role string, id int, u BookingUpdate) (model.Booking, model.BookingChange, error) {
+	b, err := s.repo.GetByID(id)
+	if err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	if b.UserID != userID && !s.access.perms.Has(userID, role, model.PermBookingsModifyAny) {
+		return model.Booking{}, model.BookingChange{}, ErrForbidden
+	}
+	if b.Status == model.BookingCancelled {
+		return model.Booking{}, model.BookingChange{}, repository.ErrBookingCancelled
+	}
+	paid, err := s.payments.Paid(id)
+	if err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	if paid {
+		return model.Booking{}, model.BookingChange{}, ErrBookingPaid
+	}
+	today := stayToday(b)
+	if !b.CheckIn.After(today) {
+		return model.Booking{}, model.BookingChange{}, ErrBookingStarted
+	}
+	terms, err := u.Apply(b, today)
+	if err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	// promo codes stay applied, so the new stay must still qualify for them
+	if len(b.Promos) > 0 {
+		h, ok := s.rooms.Get(b.HotelID)
+		if !ok {
+			return model.Booking{}, model.BookingChange{}, ErrHotelNotFound
+		}
+		stay := Stay{UserID: b.UserID, Hotel: h, CheckIn: terms.CheckIn, CheckOut: terms.CheckOut, Rooms: terms.Rooms}
+		if err := s.promos.Requalify(b.Promos, stay); err != nil {
+			return model.Booking{}, model.BookingChange{}, err
+		}
+	}
+	// the display total is re-quoted at today's rate, as for a new booking
+	var rate string
+	if b.DisplayCurrency != "" {
+		r, err := s.currency.Rate(b.Currency, b.DisplayCurrency)
+		if err != nil {
+			return model.Booking{}, model.BookingChange{}, err
+		}
+		rate = money.FormatRate(r)
+	}
+	b, change, err := s.repo.Modify(id, userID, terms, rate)
+	if err == repository.ErrConfirmedAmountDue {
+		return model.Booking{}, model.BookingChange{}, ErrBookingPaid
+	}
+	if err != nil {
+		return model.Booking{}, model.BookingChange{}, err
+	}
+	s.notifier.Notify(notification.BookingModified, b)
+	return b, change, nil
+}
+
+// Cancel cancels a booking made by the caller, or anyone's booking when the
+// caller may cancel any, before check-in and releases its rooms. The penalty
+// under the booking's cancellation policy is kept from its payments and the
+// rest released or refunded. Loyalty points the stay earned are taken back
+// and those redeemed on it returned, less any part of the penalty they
+// cover. A confirmed booking is invoiced, and credited for what is refunded.
+func (s *BookingService) Cancel(userID int, role string, id int) (model.Booking, model.CancellationCharge, error) {
+	b, err := s.repo.GetByID(id)
+	if err != nil {
+		return model.Booking{}, model.CancellationCharge{}, err
+	}
+	if b.UserID != userID && !s.access.perms.Has(userID, role, model.PermBookingsCancelAny) {
+		return model.Booking{}, model.CancellationCharge{}, ErrForbidden
+	}
+	today := stayToday(b)
+	if !b.CheckIn.After(today) {
+		return model.Booking{}, model.CancellationCharge{}, ErrBookingStarted
+	}
+	charge := b.CancellationCharge(time.Now())
+	before := b
+	b, err = s.repo.Cancel(id, today, charge.PointsReturned)
+	if err != nil {
+		return model.Booking{}, model.CancellationCharge{}, err
+	}
+	// the booking stays cancelled; finance settles failures by hand through
+	// the admin payment routes
+	if _, err := s.payments.SettleCancellation(b.ID, charge.PenaltyCents); err != nil {
+		log.Printf("booking: settle payments of cancelled booking %d: %v", b.ID, err)
+	}
+	// confirmed bookings are invoiced, so the guest can expense a penalty,
+	// and credited for the rest
+	if before.Status == model.BookingConfirmed {
+		if _, err := s.invoices.IssueCreditNote(before, charge.RefundCents); err != nil {
+			log.Printf("booking: invoice and credit note for cancelled booking %d: %v", b.ID, err)
+		}
+	}
+	s.notifier.Notify(notification.BookingCancelled, b)
+	return b, charge, nil
+}
+
+// stayToday returns today's date at midnight in the zone b's stay dates are
+// read in, which is the database connection's.
+func stayToday(b model.Booking) time.Time {
+	y, m, d := time.Now().In(b.CheckIn.Location()).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, b.CheckIn.Location())
+}
+
+// SendStayReminders emails guests whose stay starts on day, once per
+// booking, and returns how many reminders were queued.
+func (s *BookingService) SendStayReminders(day time.Time) (int, error) {
+	list, err := s.repo.ListCheckingIn(day)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, b := range list {
+		first, err := s.repo.MarkReminderSent(b.ID)
+		if err != nil {
+			return sent, err
+		}
+		if first {
+			s.notifier.Notify(notification.StayReminder, b)
+			sent++
+		}
+	}
+	return sent, nil
 }
 
-func (s *BookingService) Create(userID int, hotelID int, checkIn time.Time, checkOut time.Time, adults int, children int, rooms int) (model.Booking, error) {
-	return s.repo.Create(userID, hotelID, checkIn, checkOut, adults, children, rooms)
+// ListForHotels returns bookings at the hotels linked to the caller. When
+// hotelID is non-zero only that hotel is listed, provided the caller is
+// linked to it or may read any booking.
+func (s *BookingService) ListForHotels(userID int, role string, hotelID int) ([]model.Booking, error) {
+	if hotelID != 0 {
+		if !s.access.can(userID, role, hotelID, model.PermBookingsReadAny, model.PermBookingsReadHotel) {
+			return nil, ErrForbidden
+		}
+		return s.repo.ListByHotelIDs([]int{hotelID})
+	}
+	if !s.access.perms.Has(userID, role, model.PermBookingsReadHotel) {
+		return nil, ErrForbidden
+	}
+	ids, err := s.owners.HotelIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListByHotelIDs(ids)
 }
 
-func (s *BookingService) ListByUserID(userID int) ([]model.Booking, error) {
-	return s.repo.ListByUserID(userID)
+// Quote prices a prospective stay less any promo codes, optionally in
+// another currency, and shows the cancellation policy it would be booked
+// under.
+func (s *BookingService) Quote(hotelID int, checkIn time.Time, checkOut time.Time, rooms int, currency string, promoCodes []string) (model.Quote, error) {
+	hotel, ok := s.rooms.Get(hotelID)
+	if !ok {
+		return model.Quote{}, ErrHotelNotFound
+	}
+	q := model.Quote{
+		HotelID:           hotelID,
+		CheckIn:           checkIn,
+		CheckOut:          checkOut,
+		Nights:            model.Nights(checkIn, checkOut),
+		Rooms:             rooms,
+		Currency:          hotel.Currency,
+		NightlyPriceCents: hotel.PriceCents,
+		Available:         hotel.RoomsAvailable >= rooms,
+	}
+	policy, err := s.policies.ForHotel(hotelID)
+	if err != nil {
+		return model.Quote{}, err
+	}
+	q.CancellationPolicy = policy
+	q.FreeCancellationUntil = policy.FreeUntil(checkIn)
+	q.SubtotalCents = q.NightlyPriceCents * q.Nights * rooms
+	q.Promos, err = s.promos.Apply(promoCodes, Stay{Hotel: hotel, CheckIn: checkIn, CheckOut: checkOut, Rooms: rooms})
+	if err != nil {
+		return model.Quote{}, err
+	}
+	q.DiscountCents = model.ApplyPromos(q.SubtotalCents, q.Promos)
+	q.TotalPriceCents = q.SubtotalCents - q.DiscountCents
+	currency = money.Normalize(currency)
+	if currency != "" && currency != hotel.Currency {
+		rate, err := s.currency.Rate(hotel.Currency, currency)
+		if err != nil {
+			return model.Quote{}, err
+		}
+		q.NightlyPriceCents = money.Convert(q.NightlyPriceCents, hotel.Currency, currency, rate)
+		// convert the subtotal directly rather than multiplying the rounded
+		// nightly price, and take off the converted discounts so they add up
+		q.SubtotalCents = money.Convert(q.SubtotalCents, hotel.Currency, currency, rate)
+		q.DiscountCents = 0
+		for i := range q.Promos {
+			q.Promos[i].AmountOffCents = money.Convert(q.Promos[i].AmountOffCents, hotel.Currency, currency, rate)
+			q.Promos[i].DiscountCents = money.Convert(q.Promos[i].DiscountCents, hotel.Currency, currency, rate)
+			q.DiscountCents += q.Promos[i].DiscountCents
+		}
+		q.TotalPriceCents = q.SubtotalCents - q.DiscountCents
+		q.Currency = currency
+		q.ExchangeRate = money.FormatRate(rate)
+	}
+	return q, nil
 }
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+var policyCodePattern = regexp.MustCompile(`^[a-z0-9_]{2,32}$`)
+
+// CancellationService manages cancellation policies and which one each
+// hotel uses. What a cancellation costs is worked out by the policy itself.
+type CancellationService struct {
+	policies repository.CancellationPolicyRepository
+	access   hotelAccess
+}
+
+func NewCancellationService() *CancellationService {
+	db := config.GetDB()
+	return NewCancellationServiceWith(repository.NewMySQLCancellationPolicyRepo(db), repository.NewMySQLHotelOwnerRepo(db), GetRBAC())
+}
+
+func NewCancellationServiceWith(policies repository.CancellationPolicyRepository, owners repository.HotelOwnerRepository, perms *RBACService) *CancellationService {
+	return &CancellationService{policies: policies, access: hotelAccess{owners: owners, perms: perms}}
+}
+
+func (s *CancellationService) List() ([]model.CancellationPolicy, error) {
+	return s.policies.List()
+}
+
+// Put creates or replaces a policy. Existing bookings keep the copy they
+// were made with.
+func (s *CancellationService) Put(p model.CancellationPolicy) (model.CancellationPolicy, error) {
+	p, problems := CheckCancellationPolicy(p)
+	if len(problems) > 0 {
+		return model.CancellationPolicy{}, &ValidationError{Problems: problems}
+	}
+	if err := s.policies.Put(p); err != nil {
+		return model.CancellationPolicy{}, err
+	}
+	return p, nil
+}
+
+// CheckCancellationPolicy normalizes a policy, ordering its rules from the
+// earliest deadline, and returns the reasons it is invalid.
+func CheckCancellationPolicy(p model.CancellationPolicy) (model.CancellationPolicy, []string) {
+	var problems []string
+	p.Code = strings.ToLower(strings.TrimSpace(p.Code))
+	p.Name = strings.TrimSpace(p.Name)
+	p.Description = strings.TrimSpace(p.Description)
+	if !policyCodePattern.MatchString(p.Code) {
+		problems = append(problems, "code must be 2-32 lowercase letters, digits or underscores")
+	}
+	if p.Name == "" || len(p.Name) > 100 {
+		problems = append(problems, "name must be 1-100 characters")
+	}
+	if len(p.Description) > 500 {
+		problems = append(problems, "description must be at most 500 characters")
+	}
+	if p.Rules == nil {
+		p.Rules = []model.CancellationRule{}
+	}
+	if p.NonRefundable && len(p.Rules) > 0 {
+		problems = append(problems, "non-refundable policies take no rules")
+	}
+	for i, r := range p.Rules {
+		if r.HoursBefore < 0 {
+			problems = append(problems, fmt.Sprintf("rules[%d]: hours_before must not be negative", i))
+		}
+		if r.PenaltyNights < 0 || r.PenaltyPercent < 0 || r.PenaltyPercent > 100 {
+			problems = append(problems, fmt.Sprintf("rules[%d]: penalty_nights must not be negative and penalty_percent must be 0-100", i))
+		}
+		if r.PenaltyNights == 0 && r.PenaltyPercent == 0 {
+			problems = append(problems, fmt.Sprintf("rules[%d]: set penalty_nights or penalty_percent", i))
+		}
+	}
+	sort.SliceStable(p.Rules, func(i, j int) bool { return p.Rules[i].HoursBefore > p.Rules[j].HoursBefore })
+	return p, problems
+}
+
+// SetHotelPolicy picks the policy new bookings at a hotel the caller may
+// manage are made under.
+func (s *CancellationService) SetHotelPolicy(userID int, role string, hotelID int, code string) (model.CancellationPolicy, error) {
+	if !s.access.can(userID, role, hotelID, model.PermHotelsWrite, model.PermHotelsWriteOwn) {
+		return model.CancellationPolicy{}, ErrForbidden
+	}
+	code = strings.ToLower(strings.TrimSpace(code))
+	switch err := s.policies.SetForHotel(hotelID, code); err {
+	case nil:
+	case sql.ErrNoRows:
+		return model.CancellationPolicy{}, ErrHotelNotFound
+	default:
+		return model.CancellationPolicy{}, err
+	}
+	return s.policies.Get(code)
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/money"
+	"agodrift/internal/repository"
+)
+
+var (
+	ErrInvalidCurrency = errors.New("invalid currency")
+	ErrNoExchangeRate  = errors.New("no exchange rate for currency pair")
+)
+
+// CurrencyService manages exchange rates and converts prices for display.
+type CurrencyService struct {
+	repo repository.ExchangeRateRepository
+	base string // pivot currency for cross rates
+}
+
+func NewCurrencyService() *CurrencyService {
+	return &CurrencyService{
+		repo: repository.NewMySQLExchangeRateRepo(config.GetDB()),
+		base: money.Normalize(config.Get("BASE_CURRENCY", money.DefaultCurrency)),
+	}
+}
+
+func NewCurrencyServiceWithRepo(repo repository.ExchangeRateRepository, base string) *CurrencyService {
+	return &CurrencyService{repo: repo, base: money.Normalize(base)}
+}
+
+// Rate returns how many units of to one unit of from buys. It uses a direct
+// rate when present, then the inverse, then a cross rate through the base
+// currency.
+func (s *CurrencyService) Rate(from string, to string) (*big.Rat, error) {
+	return s.rate(s.repo.Get, from, to)
+}
+
+// rateLookup finds the stored rate for a currency pair.
+type rateLookup func(base string, quote string) (model.ExchangeRate, bool)
+
+func (s *CurrencyService) rate(get rateLookup, from string, to string) (*big.Rat, error) {
+	from, to = money.Normalize(from), money.Normalize(to)
+	if !money.Valid(from) || !money.Valid(to) {
+		return nil, ErrInvalidCurrency
+	}
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	if r, ok := pair(get, from, to); ok {
+		return r, nil
+	}
+	if from != s.base && to != s.base {
+		a, okA := pair(get, from, s.base)
+		b, okB := pair(get, s.base, to)
+		if okA && okB {
+			return new(big.Rat).Mul(a, b), nil
+		}
+	}
+	return nil, ErrNoExchangeRate
+}
+
+func pair(get rateLookup, from string, to string) (*big.Rat, bool) {
+	if er, ok := get(from, to); ok {
+		if r, err := money.ParseRate(er.Rate); err == nil {
+			return r, true
+		}
+	}
+	if er, ok := get(to, from); ok {
+		if r, err := money.ParseRate(er.Rate); err == nil {
+			return new(big.Rat).Inv(r), true
+		}
+	}
+	return nil, false
+}
+
+// Convert converts an amount in minor units and returns the rate used.
+func (s *CurrencyService) Convert(amount int, from string, to string) (int, *big.Rat, error) {
+	rate, err := s.Rate(from, to)
+	if err != nil {
+		return 0, nil, err
+	}
+	return money.Convert(amount, money.Normalize(from), money.Normalize(to), rate), rate, nil
+}
+
+// ConvertRoom rewrites a hotel's prices into the requested currency.
+func (s *CurrencyService) ConvertRoom(rm *model.Room, to string) error {
+	return s.convertRoom(s.repo.Get, rm, to)
+}
+
+// ConvertRooms rewrites the prices of a list of hotels, loading the rates
+// once rather than per hotel.
+func (s *CurrencyService) ConvertRooms(rooms []model.Room, to string) error {
+	if money.Normalize(to) == "" {
+		return nil
+	}
+	list, err := s.repo.List()
+	if err != nil {
+		return err
+	}
+	rates := make(map[string]model.ExchangeRate, len(list))
+	for _, er := range list {
+		rates[er.Base+er.Quote] = er
+	}
+	get := func(base string, quote string) (model.ExchangeRate, bool) {
+		er, ok := rates[base+quote]
+		return er, ok
+	}
+	for i := range rooms {
+		if err := s.convertRoom(get, &rooms[i], to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *CurrencyService) convertRoom(get rateLookup, rm *model.Room, to string) error {
+	to = money.Normalize(to)
+	if to == "" || to == rm.Currency {
+		return nil
+	}
+	rate, err := s.rate(get, rm.Currency, to)
+	if err != nil {
+		return err
+	}
+	rm.PriceCents = money.Convert(rm.PriceCents, rm.Currency, to, rate)
+	if rm.OriginalPriceCents != nil {
+		v := money.Convert(*rm.OriginalPriceCents, rm.Currency, to, rate)
+		rm.OriginalPriceCents = &v
+	}
+	rm.Currency = to
+	return nil
+}
+
+func (s *CurrencyService) List() ([]model.ExchangeRate, error) {
+	return s.repo.List()
+}
+
+// SetRates validates and stores rates.
+func (s *CurrencyService) SetRates(rates []model.ExchangeRate) error {
+	for i := range rates {
+		rates[i].Base = money.Normalize(rates[i].Base)
+		rates[i].Quote = money.Normalize(rates[i].Quote)
+		if !money.Valid(rates[i].Base) || !money.Valid(rates[i].Quote) || rates[i].Base == rates[i].Quote {
+			return ErrInvalidCurrency
+		}
+		r, err := money.ParseRate(rates[i].Rate)
+		if err != nil {
+			return err
+		}
+		rates[i].Rate = money.FormatRate(r)
+	}
+	return s.repo.Upsert(rates)
+}
+
+func (s *CurrencyService) DeleteRate(base string, quote string) error {
+	return s.repo.Delete(money.Normalize(base), money.Normalize(quote))
+}
+
+// ImportRates reads a rate file (CSV with base,quote,rate columns or a JSON
+// array of rates) and stores it atomically.
+func (s *CurrencyService) ImportRates(format string, r io.Reader) (int, error) {
+	var rates []model.ExchangeRate
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		if err := json.NewDecoder(r).Decode(&rates); err != nil {
+			return 0, err
+		}
+	case FormatCSV:
+		records, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return 0, err
+		}
+		for i, rec := range records {
+			if len(rec) < 3 {
+				return 0, errors.New("each row needs base,quote,rate")
+			}
+			if i == 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "base") {
+				continue
+			}
+			rates = append(rates, model.ExchangeRate{Base: rec[0], Quote: rec[1], Rate: rec[2]})
+		}
+	default:
+		return 0, ErrUnsupportedFormat
+	}
+	if err := s.SetRates(rates); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"agodrift/internal/repository"
+)
+
+// hotelAccess answers whether a caller may act on a hotel: either they hold
+// the unscoped permission, or they hold the linked-hotel permission and are
+// linked to the hotel as a partner or manager.
+type hotelAccess struct {
+	owners repository.HotelOwnerRepository
+	perms  *RBACService
+}
+
+func (a hotelAccess) can(userID int, role string, hotelID int, anyPerm string, linkedPerm string) bool {
+	if a.perms.Has(userID, role, anyPerm) {
+		return true
+	}
+	return a.perms.Has(userID, role, linkedPerm) && a.owners.IsOwner(userID, hotelID)
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"agodrift/internal/model"
+	"agodrift/internal/money"
+)
+
+// Supported bulk transfer formats.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// DefaultImportBatchSize is the number of rows written per transaction.
+const DefaultImportBatchSize = 100
+
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// HotelCSVHeader is the column layout used for CSV import and export. The id
+// lets an exported hotel without an external_ref be matched on re-import.
+var HotelCSVHeader = []string{
+	"id", "external_ref", "name", "description", "location", "destination", "rating", "reviews",
+	"price_cents", "original_price_cents", "currency", "amenities", "featured", "max_adults", "max_children",
+	"rooms_total", "rooms_available", "status",
+}
+
+// RowError describes why a single input row was rejected. Rows are numbered
+// from 1 and exclude the CSV header.
+type RowError struct {
+	Row         int      `json:"row"`
+	ExternalRef string   `json:"external_ref,omitempty"`
+	Errors      []string `json:"errors"`
+}
+
+// ImportReport summarises a bulk import.
+type ImportReport struct {
+	DryRun   bool       `json:"dry_run"`
+	Total    int        `json:"total"`
+	Valid    int        `json:"valid"`
+	Created  int        `json:"created"`
+	Updated  int        `json:"updated"`
+	Batches  int        `json:"batches"`
+	Errors   []RowError `json:"errors"`
+	Failure  string     `json:"failure,omitempty"`
+	Imported bool       `json:"imported"`
+}
+
+// ParseHotels decodes hotels from CSV or JSON. Rows that cannot be decoded
+// are reported as row errors rather than aborting the whole parse.
+func ParseHotels(format string, r io.Reader) ([]model.Room, []RowError, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return parseHotelsCSV(r)
+	case FormatJSON:
+		var rooms []model.Room
+		if err := json.NewDecoder(r).Decode(&rooms); err != nil {
+			return nil, nil, err
+		}
+		return rooms, nil, nil
+	}
+	return nil, nil, ErrUnsupportedFormat
+}
+
+func parseHotelsCSV(r io.Reader) ([]model.Room, []RowError, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"name", "location", "destination", "price_cents"} {
+		if _, ok := cols[required]; !ok {
+			return nil, nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	rooms := make([]model.Room, 0)
+	rowErrs := make([]RowError, 0)
+	row := 0
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+		if err != nil {
+			// keep a placeholder so row numbers stay aligned with the input
+			rowErrs = append(rowErrs, RowError{Row: row, Errors: []string{err.Error()}})
+			rooms = append(rooms, model.Room{})
+			continue
+		}
+		get := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		var errs []string
+		atoi := func(name string) int {
+			v := get(name)
+			if v == "" {
+				return 0
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, name+" must be an integer")
+			}
+			return n
+		}
+		rm := model.Room{
+			ID:             atoi("id"),
+			ExternalRef:    get("external_ref"),
+			Name:           get("name"),
+			Description:    get("description"),
+			Location:       get("location"),
+			Destination:    get("destination"),
+			Reviews:        atoi("reviews"),
+			PriceCents:     atoi("price_cents"),
+			Currency:       money.Normalize(get("currency")),
+			Amenities:      get("amenities"),
+			MaxAdults:      atoi("max_adults"),
+			MaxChildren:    atoi("max_children"),
+			RoomsTotal:     atoi("rooms_total"),
+			RoomsAvailable: atoi("rooms_available"),
+			Status:         get("status"),
+		}
+		if v := get("rating"); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, "rating must be a number")
+			}
+			rm.Rating = f
+		}
+		if get("original_price_cents") != "" {
+			v := atoi("original_price_cents")
+			rm.OriginalPriceCents = &v
+		}
+		if v := get("featured"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, "featured must be true/false or 1/0")
+			}
+			rm.Featured = b
+		}
+		if len(errs) > 0 {
+			rowErrs = append(rowErrs, RowError{Row: row, ExternalRef: rm.ExternalRef, Errors: errs})
+		}
+		rooms = append(rooms, rm)
+	}
+	return rooms, rowErrs, nil
+}
+
+// ValidateHotel returns the problems with a hotel record, empty when valid.
+func ValidateHotel(rm model.Room) []string {
+	var errs []string
+	if strings.TrimSpace(rm.Name) == "" {
+		errs = append(errs, "name is required")
+	}
+	if strings.TrimSpace(rm.Location) == "" {
+		errs = append(errs, "location is required")
+	}
+	if strings.TrimSpace(rm.Destination) == "" {
+		errs = append(errs, "destination is required")
+	}
+	if rm.PriceCents <= 0 {
+		errs = append(errs, "price_cents must be positive")
+	}
+	if rm.OriginalPriceCents != nil && *rm.OriginalPriceCents < rm.PriceCents {
+		errs = append(errs, "original_price_cents must not be below price_cents")
+	}
+	if rm.Currency != "" && !money.Valid(rm.Currency) {
+		errs = append(errs, "currency must be a 3-letter ISO 4217 code")
+	}
+	if rm.Rating < 0 || rm.Rating > 5 {
+		errs = append(errs, "rating must be between 0 and 5")
+	}
+	if rm.Reviews < 0 || rm.MaxAdults < 0 || rm.MaxChildren < 0 || rm.RoomsTotal < 0 || rm.RoomsAvailable < 0 {
+		errs = append(errs, "counts must not be negative")
+	}
+	if rm.RoomsTotal > 0 && rm.RoomsAvailable > rm.RoomsTotal {
+		errs = append(errs, "rooms_available must not exceed rooms_total")
+	}
+	switch rm.Status {
+	case "", "active", "inactive", "maintenance":
+	default:
+		errs = append(errs, "status must be active, inactive or maintenance")
+	}
+	return errs
+}
+
+// WriteHotels encodes hotels as CSV or JSON.
+func WriteHotels(format string, w io.Writer, rooms []model.Room) error {
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rooms)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(HotelCSVHeader); err != nil {
+			return err
+		}
+		for _, rm := range rooms {
+			original := ""
+			if rm.OriginalPriceCents != nil {
+				original = strconv.Itoa(*rm.OriginalPriceCents)
+			}
+			rec := []string{
+				strconv.Itoa(rm.ID), rm.ExternalRef, rm.Name, rm.Description, rm.Location, rm.Destination,
+				strconv.FormatFloat(rm.Rating, 'f', 1, 64), strconv.Itoa(rm.Reviews),
+				strconv.Itoa(rm.PriceCents), original, rm.Currency, rm.Amenities, strconv.FormatBool(rm.Featured),
+				strconv.Itoa(rm.MaxAdults), strconv.Itoa(rm.MaxChildren),
+				strconv.Itoa(rm.RoomsTotal), strconv.Itoa(rm.RoomsAvailable), rm.Status,
+			}
+			if err := cw.Write(rec); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return ErrUnsupportedFormat
+}
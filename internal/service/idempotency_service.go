@@ -0,0 +1,119 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("Idempotency-Key must be 1 to 255 characters")
+	ErrIdempotencyMismatch   = errors.New("Idempotency-Key was already used with a different request")
+	ErrIdempotencyInProgress = errors.New("a request with this Idempotency-Key is still being processed")
+)
+
+// idempotencyPurgeInterval spaces out deletion of expired keys.
+const idempotencyPurgeInterval = time.Hour
+
+// idempotencyLease is how long a request holds its key before a retry may
+// take over, e.g. after the instance running it crashed. It is well above
+// the longest a booking request can run.
+const idempotencyLease = 5 * time.Minute
+
+// IdempotencyService lets clients retry unsafe requests: the first request
+// with a key runs and its response is stored, later ones with the same key
+// and body get that response back until the key expires.
+type IdempotencyService struct {
+	repo   repository.IdempotencyRepository
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+var (
+	defaultIdempotency *IdempotencyService
+	idempotencyOnce    sync.Once
+)
+
+// GetIdempotency returns the shared IdempotencyService. Keys expire after
+// IDEMPOTENCY_KEY_TTL_HOURS (default 24).
+func GetIdempotency() *IdempotencyService {
+	idempotencyOnce.Do(func() {
+		window := time.Duration(config.GetInt("IDEMPOTENCY_KEY_TTL_HOURS", 24)) * time.Hour
+		defaultIdempotency = NewIdempotencyServiceWith(repository.NewMySQLIdempotencyRepo(config.GetDB()), window, time.Now)
+	})
+	return defaultIdempotency
+}
+
+func NewIdempotencyServiceWith(repo repository.IdempotencyRepository, window time.Duration, now func() time.Time) *IdempotencyService {
+	return &IdempotencyService{repo: repo, window: window, now: now}
+}
+
+// Fingerprint identifies a request for comparison with later retries.
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin claims key for the user. It returns the stored response when the
+// request already completed, and nil when the caller should run it and then
+// call Finish or Abort. A key still in progress once its lease has run out
+// is taken over, so a crash doesn't block retries until the key expires.
+func (s *IdempotencyService) Begin(userID int, key string, fingerprint string) (*model.IdempotencyRecord, error) {
+	if key == "" || len(key) > 255 {
+		return nil, ErrInvalidIdempotencyKey
+	}
+	now := s.now()
+	s.purge(now)
+	rec, created, err := s.repo.Reserve(model.IdempotencyRecord{
+		UserID: userID, Key: key, Fingerprint: fingerprint,
+		CreatedAt: now, ExpiresAt: now.Add(s.window),
+	}, now.Add(-idempotencyLease))
+	switch {
+	case err != nil:
+		return nil, err
+	case created:
+		return nil, nil
+	case rec.Fingerprint != fingerprint:
+		return nil, ErrIdempotencyMismatch
+	case !rec.Completed():
+		return nil, ErrIdempotencyInProgress
+	}
+	return &rec, nil
+}
+
+// Finish stores the response to replay for the key.
+func (s *IdempotencyService) Finish(userID int, key string, status int, contentType string, body []byte) error {
+	return s.repo.Complete(userID, key, status, contentType, body)
+}
+
+// Abort releases the key after a failure that should not be replayed.
+func (s *IdempotencyService) Abort(userID int, key string) error {
+	return s.repo.Release(userID, key)
+}
+
+func (s *IdempotencyService) purge(now time.Time) {
+	s.mu.Lock()
+	due := now.Sub(s.lastPurge) >= idempotencyPurgeInterval
+	if due {
+		s.lastPurge = now
+	}
+	s.mu.Unlock()
+	if !due {
+		return
+	}
+	if _, err := s.repo.DeleteExpired(now); err != nil {
+		log.Printf("idempotency: purge expired keys: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/money"
+	"agodrift/internal/repository"
+)
+
+var ErrNotInvoiceable = errors.New("bookings are invoiced once confirmed")
+
+// InvoiceIssuer is who invoices are issued by. TaxPercent is the rate of
+// the tax included in prices, e.g. 7 or 8.1; nil for none.
+type InvoiceIssuer struct {
+	Name       string
+	TaxID      string
+	TaxPercent *big.Rat
+}
+
+// InvoiceIssuerFromEnv reads INVOICE_ISSUER, INVOICE_TAX_ID and
+// INVOICE_TAX_PERCENT.
+func InvoiceIssuerFromEnv() (InvoiceIssuer, error) {
+	issuer := InvoiceIssuer{
+		Name:  config.Get("INVOICE_ISSUER", "AgoDrift"),
+		TaxID: config.Get("INVOICE_TAX_ID", ""),
+	}
+	if p := strings.TrimSpace(config.Get("INVOICE_TAX_PERCENT", "")); p != "" && p != "0" {
+		percent, err := money.ParseRate(p)
+		if err != nil {
+			return InvoiceIssuer{}, fmt.Errorf("invoice: invalid INVOICE_TAX_PERCENT %q", p)
+		}
+		issuer.TaxPercent = percent
+	}
+	return issuer, nil
+}
+
+// InvoiceService issues invoices for confirmed bookings and credit notes for
+// refunds on cancellation. Documents are snapshots numbered in sequence; a
+// booking changed after it was invoiced keeps its original invoice, and its
+// credit note makes up the difference.
+type InvoiceService struct {
+	repo     repository.InvoiceRepository
+	bookings repository.BookingRepository
+	rooms    repository.RoomRepository
+	users    repository.UserRepository
+	access   hotelAccess
+	issuer   InvoiceIssuer
+	// issuerErr is a bad issuer setting; no documents are issued with it
+	issuerErr error
+	now       func() time.Time
+}
+
+// NewInvoiceService reads the issuer from the environment. main refuses to
+// start the server with an invalid setting; other commands still run but
+// cannot issue documents.
+func NewInvoiceService() *InvoiceService {
+	db := config.GetDB()
+	issuer, err := InvoiceIssuerFromEnv()
+	s := NewInvoiceServiceWith(repository.NewMySQLInvoiceRepo(db), repository.NewMySQLBookingRepo(db), repository.NewMySQLRoomRepo(db),
+		repository.NewMySQLUserRepo(db), repository.NewMySQLHotelOwnerRepo(db), GetRBAC(), issuer, time.Now)
+	s.issuerErr = err
+	return s
+}
+
+func NewInvoiceServiceWith(repo repository.InvoiceRepository, bookings repository.BookingRepository, rooms repository.RoomRepository, users repository.UserRepository,
+	owners repository.HotelOwnerRepository, perms *RBACService, issuer InvoiceIssuer, now func() time.Time) *InvoiceService {
+	return &InvoiceService{repo: repo, bookings: bookings, rooms: rooms, users: users, access: hotelAccess{owners: owners, perms: perms}, issuer: issuer, now: now}
+}
+
+// Invoice returns the booking's invoice, issuing it on first request. The
+// guest who booked, and staff who may read the booking, can see it.
+func (s *InvoiceService) Invoice(userID int, role string, bookingID int) (model.Invoice, error) {
+	b, err := s.readable(userID, role, bookingID)
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	inv, err := s.repo.Get(b.ID, model.KindInvoice)
+	if err != repository.ErrInvoiceNotFound {
+		return inv, err
+	}
+	if b.Status != model.BookingConfirmed {
+		return model.Invoice{}, ErrNotInvoiceable
+	}
+	return s.Issue(b)
+}
+
+// CreditNote returns the credit note of a cancelled booking, if it was
+// refunded anything.
+func (s *InvoiceService) CreditNote(userID int, role string, bookingID int) (model.Invoice, error) {
+	b, err := s.readable(userID, role, bookingID)
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	return s.repo.Get(b.ID, model.KindCreditNote)
+}
+
+func (s *InvoiceService) readable(userID int, role string, bookingID int) (model.Booking, error) {
+	b, err := s.bookings.GetByID(bookingID)
+	if err != nil {
+		return model.Booking{}, err
+	}
+	if b.UserID != userID && !s.access.can(userID, role, b.HotelID, model.PermBookingsReadAny, model.PermBookingsReadHotel) {
+		return model.Booking{}, ErrForbidden
+	}
+	return b, nil
+}
+
+// Issue invoices a booking, or returns its invoice if it already has one.
+// Bookings are priced as room nights less discounts and carry no booking or
+// service fees, so there is no fee line.
+func (s *InvoiceService) Issue(b model.Booking) (model.Invoice, error) {
+	inv, err := s.document(b, model.KindInvoice)
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	nights := model.Nights(b.CheckIn, b.CheckOut)
+	subtotal := b.TotalPriceCents + b.DiscountCents
+	room := model.InvoiceLine{
+		Kind:        model.LineRoom,
+		Description: fmt.Sprintf("Room nights (%d night%s x %d room%s)", nights, plural(nights), b.Rooms, plural(b.Rooms)),
+		Quantity:    nights * b.Rooms, AmountCents: subtotal,
+	}
+	if room.Quantity > 0 && subtotal%room.Quantity == 0 {
+		room.UnitCents = subtotal / room.Quantity
+	}
+	inv.Lines = append(inv.Lines, room)
+	discounted := 0
+	for _, p := range b.Promos {
+		inv.Lines = append(inv.Lines, model.InvoiceLine{Kind: model.LineDiscount, Description: "Promo code " + p.Code, AmountCents: -p.DiscountCents})
+		discounted += p.DiscountCents
+	}
+	if rest := b.DiscountCents - discounted; rest != 0 {
+		inv.Lines = append(inv.Lines, model.InvoiceLine{Kind: model.LineDiscount, Description: "Discount", AmountCents: -rest})
+	}
+	inv.TotalCents = b.TotalPriceCents
+	inv.PointsCents = b.PointsValueCents
+	s.addTax(&inv)
+
+	inv, err = s.repo.Create(inv)
+	if err == repository.ErrInvoiceExists {
+		return s.repo.Get(b.ID, model.KindInvoice)
+	}
+	return inv, err
+}
+
+// IssueCreditNote documents the cancellation of a confirmed booking that
+// was refunded refundCents: the booking is invoiced if it was not yet, and
+// the invoice is credited down to what the guest still pays, the
+// cancellation penalty. b is the booking as it was before cancelling. The
+// invoice may predate a change to the booking's price, so the credit is
+// reconciled against the invoiced total rather than taken from the refund.
+// A booking gets at most one credit note; a zero Invoice is returned when
+// nothing is credited.
+func (s *InvoiceService) IssueCreditNote(b model.Booking, refundCents int) (model.Invoice, error) {
+	invoice, err := s.Issue(b)
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	credit := invoice.TotalCents - (b.TotalPriceCents - refundCents)
+	if credit > invoice.TotalCents {
+		credit = invoice.TotalCents
+	}
+	if credit <= 0 {
+		return model.Invoice{}, nil
+	}
+	cn, err := s.document(b, model.KindCreditNote)
+	if err != nil {
+		return model.Invoice{}, err
+	}
+	cn.InvoiceNumber = invoice.Number
+	cn.Lines = append(cn.Lines, model.InvoiceLine{
+		Kind: model.LineRefund, Description: "Refund on cancellation of booking " + b.Reference, AmountCents: -credit,
+	})
+	cn.TotalCents = -credit
+	s.addTax(&cn)
+
+	cn, err = s.repo.Create(cn)
+	if err == repository.ErrInvoiceExists {
+		return s.repo.Get(b.ID, model.KindCreditNote)
+	}
+	return cn, err
+}
+
+// document fills in what every document of the booking shows. Invoices are
+// addressed to the lead guest, or the account holder without one.
+func (s *InvoiceService) document(b model.Booking, kind string) (model.Invoice, error) {
+	if s.issuerErr != nil {
+		return model.Invoice{}, s.issuerErr
+	}
+	hotel, ok := s.rooms.Get(b.HotelID)
+	if !ok {
+		return model.Invoice{}, ErrHotelNotFound
+	}
+	inv := model.Invoice{
+		Kind: kind, BookingID: b.ID, UserID: b.UserID, Reference: b.Reference, IssuedAt: s.now(),
+		Issuer: s.issuer.Name, IssuerTaxID: s.issuer.TaxID,
+		Hotel: hotel.Name, HotelLocation: hotel.Location, CheckIn: b.CheckIn, CheckOut: b.CheckOut,
+		Currency: b.Currency, Lines: []model.InvoiceLine{},
+	}
+	if inv.Reference == "" {
+		inv.Reference = fmt.Sprintf("#%d", b.ID)
+	}
+	if b.LeadGuest != nil {
+		inv.BillTo = *b.LeadGuest
+	} else if u, ok := s.users.GetByID(b.UserID); ok {
+		inv.BillTo = model.GuestContact{Name: u.Name, Email: u.Email, Phone: u.Phone}
+	}
+	return inv, nil
+}
+
+// addTax adds the line for the tax included in the document's total.
+func (s *InvoiceService) addTax(inv *model.Invoice) {
+	if s.issuer.TaxPercent == nil {
+		return
+	}
+	inv.TaxCents = money.IncludedPercent(inv.TotalCents, s.issuer.TaxPercent)
+	inv.Lines = append(inv.Lines, model.InvoiceLine{
+		Kind: model.LineTax, Description: "Tax " + money.FormatRate(s.issuer.TaxPercent) + "% included", AmountCents: inv.TaxCents, Included: true,
+	})
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrUnknownKey      = errors.New("unknown signing key")
+	ErrUnsupportedKey  = errors.New("unsupported key type")
+	ErrUnexpectedAlg   = errors.New("unexpected signing algorithm")
+	ErrNoSigningKey    = errors.New("no signing key configured")
+	errNoPEMBlockFound = errors.New("no PEM block found")
+)
+
+// SigningKey is one key of a KeySet. Private is nil for keys that are only
+// kept around to verify tokens issued before a rotation.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds the key new tokens are signed with plus every key tokens are
+// still accepted from. Asymmetric keys are published through JWKS so other
+// services can verify tokens without holding the signing secret.
+type KeySet struct {
+	signing *SigningKey
+	verify  map[string]*SigningKey
+	// hmac is the legacy HS256 secret; nil once asymmetric keys are configured
+	// unless explicitly kept for a migration window.
+	hmac []byte
+}
+
+// NewKeySet returns an empty key set; add keys with AddKey.
+func NewKeySet() *KeySet {
+	return &KeySet{verify: make(map[string]*SigningKey)}
+}
+
+// NewHMACKeySet returns a key set that signs and verifies with HS256 only.
+func NewHMACKeySet(secret []byte) *KeySet {
+	return &KeySet{verify: make(map[string]*SigningKey), hmac: secret}
+}
+
+// LoadKeySet builds a key set that signs with the PEM private key in
+// signingFile and also accepts tokens from the keys in verifyFiles. HS256
+// tokens signed with secret stay valid only when acceptHS256 is set. Without
+// a signing key file it falls back to HS256 with secret.
+func LoadKeySet(signingFile string, verifyFiles []string, secret []byte, acceptHS256 bool) (*KeySet, error) {
+	if signingFile == "" {
+		return NewHMACKeySet(secret), nil
+	}
+	ks := NewKeySet()
+	if acceptHS256 {
+		ks.hmac = secret
+	}
+	k, err := loadKeyFile(signingFile)
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", signingFile, err)
+	}
+	if k.Private == nil {
+		return nil, fmt.Errorf("signing key %s: private key required", signingFile)
+	}
+	ks.signing = k
+	ks.verify[k.ID] = k
+	for _, f := range verifyFiles {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		vk, err := loadKeyFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("verification key %s: %w", f, err)
+		}
+		ks.verify[vk.ID] = vk
+	}
+	return ks, nil
+}
+
+// AddKey registers a key. When sign is true it also becomes the signing key.
+func (ks *KeySet) AddKey(k *SigningKey, sign bool) {
+	ks.verify[k.ID] = k
+	if sign {
+		ks.signing = k
+	}
+}
+
+// Sign returns a signed token for the claims, tagged with the key's kid.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.signing == nil {
+		if ks.hmac == nil {
+			return "", ErrNoSigningKey
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.hmac)
+	}
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.Private)
+}
+
+// Parse verifies a token against the key named by its kid header, checking
+// that the algorithm matches the key so a public key can never be used as an
+// HMAC secret.
+func (ks *KeySet) Parse(raw string) (*jwt.Token, error) {
+	return jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		if kid, ok := t.Header["kid"].(string); ok && kid != "" {
+			k, ok := ks.verify[kid]
+			if !ok {
+				return nil, ErrUnknownKey
+			}
+			if t.Method.Alg() != k.Method.Alg() {
+				return nil, ErrUnexpectedAlg
+			}
+			return k.Public, nil
+		}
+		if ks.hmac != nil && t.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			return ks.hmac, nil
+		}
+		return nil, ErrUnknownKey
+	})
+}
+
+// JWK is a public key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set.
+func (ks *KeySet) JWKS() map[string][]JWK {
+	keys := make([]JWK, 0, len(ks.verify))
+	for _, k := range ks.verify {
+		if j, err := publicJWK(k); err == nil {
+			keys = append(keys, j)
+		}
+	}
+	return map[string][]JWK{"keys": keys}
+}
+
+func publicJWK(k *SigningKey) (JWK, error) {
+	j := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		j.Kty = "RSA"
+		j.N = b64(pub.N.Bytes())
+		j.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		j.Kty = "OKP"
+		j.Crv = "Ed25519"
+		j.X = b64(pub)
+	default:
+		return j, ErrUnsupportedKey
+	}
+	return j, nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// NewSigningKey wraps a parsed private or public key. The kid is the RFC 7638
+// thumbprint of the public key so every service derives the same id.
+func NewSigningKey(key any) (*SigningKey, error) {
+	k := &SigningKey{}
+	switch v := key.(type) {
+	case *rsa.PrivateKey:
+		k.Private, k.Public, k.Method = v, &v.PublicKey, jwt.SigningMethodRS256
+	case *rsa.PublicKey:
+		k.Public, k.Method = v, jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		k.Private, k.Public, k.Method = v, v.Public(), jwt.SigningMethodEdDSA
+	case ed25519.PublicKey:
+		k.Public, k.Method = v, jwt.SigningMethodEdDSA
+	default:
+		return nil, ErrUnsupportedKey
+	}
+	j, err := publicJWK(k)
+	if err != nil {
+		return nil, err
+	}
+	// thumbprint members in lexicographic order, no whitespace
+	var canonical []byte
+	if j.Kty == "RSA" {
+		canonical, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N})
+	} else {
+		canonical, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X})
+	}
+	sum := sha256.Sum256(canonical)
+	k.ID = b64(sum[:])
+	return k, nil
+}
+
+func loadKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errNoPEMBlockFound
+	}
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM type %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(key)
+}
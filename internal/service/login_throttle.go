@@ -0,0 +1,227 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+var (
+	ErrLoginThrottled = errors.New("too many failed login attempts, try again later")
+	ErrLoginLocked    = errors.New("login temporarily locked after repeated failures")
+)
+
+// ThrottlePolicy controls how login failures slow down further attempts.
+// After FreeAttempts failures on an account (IPFreeAttempts for an IP, which
+// may be shared behind NAT) each attempt must wait BaseDelay, doubling per
+// failure up to MaxDelay; reaching a lockout threshold blocks the account or
+// IP for LockoutDuration. Counters are forgotten after Window without failures.
+type ThrottlePolicy struct {
+	FreeAttempts     int
+	IPFreeAttempts   int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	AccountThreshold int
+	IPThreshold      int
+	LockoutDuration  time.Duration
+	Window           time.Duration
+}
+
+// DefaultThrottlePolicy tolerates typos but stops online guessing.
+var DefaultThrottlePolicy = ThrottlePolicy{
+	FreeAttempts:     3,
+	IPFreeAttempts:   20,
+	BaseDelay:        time.Second,
+	MaxDelay:         5 * time.Minute,
+	AccountThreshold: 10,
+	IPThreshold:      50,
+	LockoutDuration:  15 * time.Minute,
+	Window:           time.Hour,
+}
+
+// LookupThrottlePolicy guards guest booking lookups. References are known to
+// more people than the guest, so misses on one only slow it down, briefly;
+// lockouts apply to client IPs alone.
+var LookupThrottlePolicy = ThrottlePolicy{
+	FreeAttempts:     5,
+	IPFreeAttempts:   10,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	AccountThreshold: 0,
+	IPThreshold:      30,
+	LockoutDuration:  15 * time.Minute,
+	Window:           time.Hour,
+}
+
+// LoginThrottle tracks failed logins per account and per client IP.
+type LoginThrottle struct {
+	repo   repository.LoginThrottleRepository
+	policy ThrottlePolicy
+	now    func() time.Time
+	// counter scopes for the subject (account) and the client IP
+	scope, ipScope string
+}
+
+// NewLoginThrottle stores counters in MySQL unless LOGIN_THROTTLE_STORE is
+// "memory". LOGIN_LOCKOUT_THRESHOLD and LOGIN_LOCKOUT_MINUTES override the
+// account lockout policy.
+func NewLoginThrottle() *LoginThrottle {
+	policy := DefaultThrottlePolicy
+	policy.AccountThreshold = config.GetInt("LOGIN_LOCKOUT_THRESHOLD", policy.AccountThreshold)
+	policy.LockoutDuration = time.Duration(config.GetInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+	return NewLoginThrottleWith(throttleRepo(), policy, time.Now)
+}
+
+func NewLoginThrottleWith(repo repository.LoginThrottleRepository, policy ThrottlePolicy, now func() time.Time) *LoginThrottle {
+	return &LoginThrottle{repo: repo, policy: policy, now: now, scope: model.ThrottleScopeAccount, ipScope: model.ThrottleScopeIP}
+}
+
+// NewLookupThrottle counts failed guest booking lookups per reference and
+// per client IP, apart from login failures.
+func NewLookupThrottle() *LoginThrottle {
+	return NewLookupThrottleWith(throttleRepo(), LookupThrottlePolicy, time.Now)
+}
+
+func NewLookupThrottleWith(repo repository.LoginThrottleRepository, policy ThrottlePolicy, now func() time.Time) *LoginThrottle {
+	return &LoginThrottle{repo: repo, policy: policy, now: now, scope: model.ThrottleScopeBooking, ipScope: model.ThrottleScopeLookupIP}
+}
+
+func throttleRepo() repository.LoginThrottleRepository {
+	if config.Get("LOGIN_THROTTLE_STORE", "mysql") == "memory" {
+		return repository.NewInMemoryLoginThrottleRepo()
+	}
+	return repository.NewMySQLLoginThrottleRepo(config.GetDB())
+}
+
+// Check reports whether a login for email from ip may be attempted now. When
+// it may not, the returned duration says how long the client should wait.
+// Unknown emails are throttled the same way so lockouts reveal nothing.
+func (t *LoginThrottle) Check(email, ip string) (time.Duration, error) {
+	now := t.now()
+	var lockWait, throttleWait time.Duration
+	for _, key := range t.keys(email, ip) {
+		a := t.current(key[0], key[1], now)
+		if a.LockedUntil.After(now) {
+			lockWait = max(lockWait, a.LockedUntil.Sub(now))
+			continue
+		}
+		if next := a.LastFailure.Add(t.delay(a)); next.After(now) {
+			throttleWait = max(throttleWait, next.Sub(now))
+		}
+	}
+	if lockWait > 0 {
+		return lockWait, ErrLoginLocked
+	}
+	if throttleWait > 0 {
+		return throttleWait, ErrLoginThrottled
+	}
+	return 0, nil
+}
+
+// Failure counts a failed attempt and starts a lockout when a threshold is
+// reached. The count is a single atomic update, so parallel guesses cannot
+// overwrite each other's failures.
+func (t *LoginThrottle) Failure(email, ip string) {
+	now := t.now()
+	for _, key := range t.keys(email, ip) {
+		a, err := t.repo.AddFailure(key[0], key[1], now, now.Add(-t.policy.Window))
+		if err != nil {
+			log.Printf("login throttle: add failure: %v", err)
+			continue
+		}
+		threshold := t.policy.AccountThreshold
+		if a.Scope == t.ipScope {
+			threshold = t.policy.IPThreshold
+		}
+		if threshold <= 0 || a.Failures < threshold {
+			continue
+		}
+		until := now.Add(t.policy.LockoutDuration)
+		locked, err := t.repo.Lock(a.Scope, a.Subject, until, now)
+		if err != nil {
+			log.Printf("login throttle: lock: %v", err)
+		}
+		if !locked {
+			continue
+		}
+		if err := t.repo.RecordLockout(model.LoginLockout{
+			Scope: a.Scope, Subject: a.Subject, Failures: a.Failures, IP: ip,
+			LockedAt: now, LockedUntil: until,
+		}); err != nil {
+			log.Printf("login throttle: record lockout: %v", err)
+		}
+		log.Printf("login throttle: %s %q locked until %s after %d failures", a.Scope, a.Subject, until.Format(time.RFC3339), a.Failures)
+	}
+}
+
+// Success clears the account's failures once the whole login has succeeded,
+// including the second factor. The IP counter is kept so one valid account
+// cannot be used to reset a guessing run against others.
+func (t *LoginThrottle) Success(email string) {
+	if err := t.repo.Reset(t.scope, normalizeEmail(email)); err != nil {
+		log.Printf("login throttle: reset: %v", err)
+	}
+}
+
+// Unlock lifts a lockout early and records which admin did it.
+func (t *LoginThrottle) Unlock(scope, subject string, adminID int) error {
+	if scope == t.scope {
+		subject = normalizeEmail(subject)
+	}
+	if err := t.repo.Reset(scope, subject); err != nil {
+		return err
+	}
+	return t.repo.MarkUnlocked(scope, subject, adminID, t.now())
+}
+
+// Lockouts returns the most recent lockout audit records.
+func (t *LoginThrottle) Lockouts(limit int) ([]model.LoginLockout, error) {
+	return t.repo.ListLockouts(limit)
+}
+
+// current loads a counter, starting over once a lockout has run out or the
+// last failure fell outside the window.
+func (t *LoginThrottle) current(scope, subject string, now time.Time) model.LoginAttempts {
+	a, ok := t.repo.Get(scope, subject)
+	expiredLock := !a.LockedUntil.IsZero() && !a.LockedUntil.After(now)
+	if !ok || expiredLock || now.Sub(a.LastFailure) > t.policy.Window {
+		return model.LoginAttempts{Scope: scope, Subject: subject}
+	}
+	return a
+}
+
+func (t *LoginThrottle) delay(a model.LoginAttempts) time.Duration {
+	free := t.policy.FreeAttempts
+	if a.Scope == t.ipScope {
+		free = t.policy.IPFreeAttempts
+	}
+	n := a.Failures - free
+	if n < 0 {
+		return 0
+	}
+	d := t.policy.BaseDelay
+	for i := 0; i < n && d < t.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > t.policy.MaxDelay {
+		d = t.policy.MaxDelay
+	}
+	return d
+}
+
+func (t *LoginThrottle) keys(email, ip string) [][2]string {
+	keys := [][2]string{{t.scope, normalizeEmail(email)}}
+	if ip != "" {
+		keys = append(keys, [2]string{t.ipScope, ip})
+	}
+	return keys
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
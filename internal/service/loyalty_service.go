@@ -0,0 +1,133 @@
+package service
+
+import (
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+const (
+	defaultLoyaltyPerPage = 20
+	maxLoyaltyPerPage     = 100
+)
+
+// LoyaltyService keeps members' points: earned on completed stays, spent on
+// bookings and given back or taken back when bookings are cancelled.
+type LoyaltyService struct {
+	repo     repository.LoyaltyRepository
+	bookings repository.BookingRepository
+	currency *CurrencyService
+	now      func() time.Time
+}
+
+func NewLoyaltyService() *LoyaltyService {
+	db := config.GetDB()
+	return NewLoyaltyServiceWith(repository.NewMySQLLoyaltyRepo(db), repository.NewMySQLBookingRepo(db), NewCurrencyService(), time.Now)
+}
+
+func NewLoyaltyServiceWith(repo repository.LoyaltyRepository, bookings repository.BookingRepository, currency *CurrencyService, now func() time.Time) *LoyaltyService {
+	return &LoyaltyService{repo: repo, bookings: bookings, currency: currency, now: now}
+}
+
+// Account returns the member's balance and tier. Tiers count the points
+// earned over the last year.
+func (s *LoyaltyService) Account(userID int) (model.LoyaltyAccount, error) {
+	balance, err := s.repo.Balance(userID)
+	if err != nil {
+		return model.LoyaltyAccount{}, err
+	}
+	tier, qualifying, err := s.tier(userID)
+	if err != nil {
+		return model.LoyaltyAccount{}, err
+	}
+	a := model.LoyaltyAccount{Balance: balance, ValueCents: balance, Currency: model.LoyaltyCurrency, Tier: tier, QualifyingPoints: qualifying}
+	if _, next := model.TierFor(qualifying); next != nil {
+		a.NextTier = next
+		a.PointsToNextTier = next.MinPoints - qualifying
+	}
+	return a, nil
+}
+
+func (s *LoyaltyService) tier(userID int) (model.LoyaltyTier, int, error) {
+	qualifying, err := s.repo.Qualifying(userID, s.now().AddDate(-1, 0, 0))
+	if err != nil {
+		return model.LoyaltyTier{}, 0, err
+	}
+	tier, _ := model.TierFor(qualifying)
+	return tier, qualifying, nil
+}
+
+// History returns one page of the member's ledger, newest first, 20 per
+// page by default.
+func (s *LoyaltyService) History(userID int, page int, perPage int) (model.Page[model.LoyaltyEntry], error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultLoyaltyPerPage
+	}
+	perPage = min(perPage, maxLoyaltyPerPage)
+	items, total, err := s.repo.History(userID, page, perPage)
+	if err != nil {
+		return model.Page[model.LoyaltyEntry]{}, err
+	}
+	return model.Page[model.LoyaltyEntry]{Items: items, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+// Redemption checks that the user can spend points and returns what they
+// are worth in the given currency. The balance is checked again when the
+// booking is stored.
+func (s *LoyaltyService) Redemption(userID int, points int, currency string) (int, error) {
+	if points < 0 {
+		return 0, &ValidationError{Problems: []string{"redeem_points must not be negative"}}
+	}
+	if points == 0 {
+		return 0, nil
+	}
+	balance, err := s.repo.Balance(userID)
+	if err != nil {
+		return 0, err
+	}
+	if points > balance {
+		return 0, repository.ErrNotEnoughPoints
+	}
+	// a point is worth one cent of the loyalty currency
+	value, _, err := s.currency.Convert(points, model.LoyaltyCurrency, currency)
+	return value, err
+}
+
+// AwardCompleted credits the points for confirmed stays checked out by day,
+// once per booking, and returns how many bookings were credited. Points are
+// earned on what was paid by card, at the member's tier when awarded.
+func (s *LoyaltyService) AwardCompleted(day time.Time) (int, error) {
+	list, err := s.bookings.ListCompleted(day)
+	if err != nil {
+		return 0, err
+	}
+	awarded := 0
+	for _, b := range list {
+		spent, _, err := s.currency.Convert(b.AmountDueCents(), b.Currency, model.LoyaltyCurrency)
+		if err != nil {
+			return awarded, err
+		}
+		tier, _, err := s.tier(b.UserID)
+		if err != nil {
+			return awarded, err
+		}
+		id := b.ID
+		_, err = s.repo.Append(model.LoyaltyEntry{
+			UserID: b.UserID, BookingID: &id, Kind: model.LoyaltyEarn, Points: model.EarnPoints(spent, tier),
+			Description: "Stay " + b.Reference, CreatedAt: s.now(),
+		})
+		if err == repository.ErrLoyaltyEntryExists {
+			continue
+		}
+		if err != nil {
+			return awarded, err
+		}
+		awarded++
+	}
+	return awarded, nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/totp"
+)
+
+var (
+	ErrMFANotEnrolled    = errors.New("two-factor authentication is not set up")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFARequired       = errors.New("two-factor authentication is required for this role")
+	ErrInvalidMFACode    = errors.New("invalid code")
+)
+
+const (
+	mfaChallengeTTL      = 5 * time.Minute
+	totpAllowedClockSkew = 1 // steps either side of now
+	recoveryCodeCount    = 10
+	// 32 symbols so a random byte maps onto it without bias; no 0/O or 1/I
+	recoveryCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// MFAService manages TOTP enrollment and the second step of login.
+type MFAService struct {
+	repo     repository.MFARepository
+	auth     *AuthService
+	issuer   string
+	required map[string]bool // roles that must use 2FA
+	now      func() time.Time
+}
+
+// NewMFAService reads MFA_REQUIRED_ROLES (comma-separated, default "admin")
+// and MFA_ISSUER (the name shown in authenticator apps).
+func NewMFAService() *MFAService {
+	roles := strings.Split(config.Get("MFA_REQUIRED_ROLES", model.RoleAdmin), ",")
+	return NewMFAServiceWith(repository.NewMySQLMFARepo(config.GetDB()), GetAuth(), config.Get("MFA_ISSUER", "AgoDrift"), roles, time.Now)
+}
+
+func NewMFAServiceWith(repo repository.MFARepository, auth *AuthService, issuer string, requiredRoles []string, now func() time.Time) *MFAService {
+	required := make(map[string]bool)
+	for _, r := range requiredRoles {
+		if r = strings.TrimSpace(r); r != "" {
+			required[r] = true
+		}
+	}
+	return &MFAService{repo: repo, auth: auth, issuer: issuer, required: required, now: now}
+}
+
+// Required reports whether policy forces 2FA for the role.
+func (s *MFAService) Required(role string) bool {
+	return s.required[role]
+}
+
+// Enabled reports whether the user has completed enrollment.
+func (s *MFAService) Enabled(userID int) bool {
+	m, ok := s.repo.Get(userID)
+	return ok && m.Enabled
+}
+
+// Challenge returns a short-lived token for the second login step.
+func (s *MFAService) Challenge(u model.User) (string, error) {
+	return s.auth.CreateChallengeToken(u, mfaChallengeTTL)
+}
+
+// Enroll generates a new secret and returns it with its provisioning URI.
+// The secret is inactive until Activate verifies a code from it.
+func (s *MFAService) Enroll(u model.User) (string, string, error) {
+	if s.Enabled(u.ID) {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.repo.SaveSecret(u.ID, secret); err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(s.issuer, u.Email, secret), nil
+}
+
+// Activate enables 2FA after checking a code and returns fresh recovery
+// codes. They are only shown once; we keep their hashes.
+func (s *MFAService) Activate(userID int, code string) ([]string, error) {
+	m, ok := s.repo.Get(userID)
+	if !ok {
+		return nil, ErrMFANotEnrolled
+	}
+	if m.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.checkTOTP(m, code); err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = newRecoveryCode()
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	if err := s.repo.Enable(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a TOTP code or, failing that, consumes a recovery code.
+func (s *MFAService) Verify(userID int, code string) error {
+	m, ok := s.repo.Get(userID)
+	if !ok || !m.Enabled {
+		return ErrMFANotEnrolled
+	}
+	if err := s.checkTOTP(m, code); err == nil {
+		return nil
+	}
+	if s.repo.UseRecoveryCode(userID, hashRecoveryCode(code)) {
+		return nil
+	}
+	return ErrInvalidMFACode
+}
+
+// Disable turns 2FA off after a valid code, unless policy requires it.
+func (s *MFAService) Disable(u model.User, code string) error {
+	if s.Required(u.Role) {
+		return ErrMFARequired
+	}
+	if err := s.Verify(u.ID, code); err != nil {
+		return err
+	}
+	return s.repo.Disable(u.ID)
+}
+
+func (s *MFAService) checkTOTP(m model.MFA, code string) error {
+	step, ok := totp.Validate(m.Secret, code, s.now(), totpAllowedClockSkew)
+	if !ok || !s.repo.AdvanceStep(m.UserID, step) {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func newRecoveryCode() string {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	out := make([]byte, 0, 11)
+	for i, c := range b {
+		if i == 5 {
+			out = append(out, '-')
+		}
+		out = append(out, recoveryCodeAlphabet[int(c)%len(recoveryCodeAlphabet)])
+	}
+	return string(out)
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown identity provider")
+	ErrInvalidState     = errors.New("invalid or expired login state")
+	ErrInvalidIDToken   = errors.New("invalid id token")
+	ErrEmailNotVerified = errors.New("identity provider did not verify the email address")
+)
+
+// oidcStateTTL bounds how long a user has to complete the provider login.
+const oidcStateTTL = 10 * time.Minute
+
+// OIDCProvider is one configured OpenID Connect identity provider. Endpoints
+// left empty are read from the issuer's discovery document.
+type OIDCProvider struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+	AuthURL      string   `json:"authorization_endpoint"`
+	TokenURL     string   `json:"token_endpoint"`
+	JWKSURL      string   `json:"jwks_uri"`
+}
+
+// OIDCService implements the authorization-code flow with PKCE and issues
+// our own tokens once the provider's ID token has been verified. Started
+// logins are stored, so the callback may reach any instance.
+type OIDCService struct {
+	providers  map[string]*OIDCProvider
+	users      repository.UserRepository
+	identities repository.IdentityRepository
+	logins     repository.OIDCLoginRepository
+	auth       *AuthService
+	mfa        *MFAService
+	client     *http.Client
+
+	mu   sync.Mutex
+	jwks map[string]map[string]any
+}
+
+// NewOIDCService reads providers from OIDC_PROVIDERS_FILE, a JSON array of
+// OIDCProvider.
+func NewOIDCService() *OIDCService {
+	providers := make([]OIDCProvider, 0)
+	if path := config.Get("OIDC_PROVIDERS_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &providers)
+		}
+		if err != nil {
+			log.Printf("oidc: ignoring providers file: %v", err)
+		}
+	}
+	db := config.GetDB()
+	return NewOIDCServiceWith(providers, repository.NewMySQLUserRepo(db), repository.NewMySQLIdentityRepo(db), repository.NewMySQLOIDCLoginRepo(db),
+		GetAuth(), NewMFAService(), http.DefaultClient)
+}
+
+func NewOIDCServiceWith(providers []OIDCProvider, users repository.UserRepository, identities repository.IdentityRepository, logins repository.OIDCLoginRepository,
+	auth *AuthService, mfa *MFAService, client *http.Client) *OIDCService {
+	s := &OIDCService{
+		providers:  make(map[string]*OIDCProvider, len(providers)),
+		users:      users,
+		identities: identities,
+		logins:     logins,
+		auth:       auth,
+		mfa:        mfa,
+		client:     client,
+		jwks:       make(map[string]map[string]any),
+	}
+	for i := range providers {
+		p := providers[i]
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
+		s.providers[p.Name] = &p
+	}
+	return s
+}
+
+// Providers returns the names of the configured providers.
+func (s *OIDCService) Providers() []string {
+	out := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AuthURL starts a login and returns the provider URL to redirect the user to.
+func (s *OIDCService) AuthURL(provider string) (string, error) {
+	configured, ok := s.providers[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+	p, err := s.discover(configured)
+	if err != nil {
+		return "", err
+	}
+	state, nonce, verifier := randomToken(), randomToken(), randomToken()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	login := model.OIDCLogin{State: state, Provider: provider, Nonce: nonce, Verifier: verifier, ExpiresAt: time.Now().Add(oidcStateTTL)}
+	if err := s.logins.Create(login); err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.AuthURL + sep + q.Encode(), nil
+}
+
+// OIDCResult is a completed provider login: our own token, or a challenge
+// for the second step when the account uses or must set up two-factor
+// authentication, as after a password login.
+type OIDCResult struct {
+	User               model.User
+	Token              string
+	MFAToken           string
+	EnrollmentRequired bool
+}
+
+// Callback completes a login: it redeems the code, verifies the ID token,
+// finds or links the local account and returns our own token for it.
+func (s *OIDCService) Callback(provider string, state string, code string, client SessionClient) (OIDCResult, error) {
+	login, ok := s.logins.Take(state, time.Now())
+	if !ok || login.Provider != provider {
+		return OIDCResult{}, ErrInvalidState
+	}
+	p, err := s.discover(s.providers[provider])
+	if err != nil {
+		return OIDCResult{}, err
+	}
+
+	rawIDToken, err := s.exchange(&p, code, login.Verifier)
+	if err != nil {
+		return OIDCResult{}, err
+	}
+	claims, err := s.verifyIDToken(&p, rawIDToken, login.Nonce)
+	if err != nil {
+		return OIDCResult{}, err
+	}
+	u, err := s.linkAccount(p.Name, claims)
+	if err != nil {
+		return OIDCResult{}, err
+	}
+	// the provider vouches for the password step only
+	enabled := s.mfa.Enabled(u.ID)
+	if enabled || s.mfa.Required(u.Role) {
+		challenge, err := s.mfa.Challenge(u)
+		if err != nil {
+			return OIDCResult{}, err
+		}
+		return OIDCResult{User: u, MFAToken: challenge, EnrollmentRequired: !enabled}, nil
+	}
+	token, err := s.auth.StartSession(u, 30*time.Minute, client)
+	if err != nil {
+		return OIDCResult{}, err
+	}
+	return OIDCResult{User: u, Token: token}, nil
+}
+
+// linkAccount resolves the local user for an external identity. Unknown
+// identities are linked to the account with the same email, or a new account
+// is created, but only when the provider says the email is verified.
+func (s *OIDCService) linkAccount(provider string, claims jwt.MapClaims) (model.User, error) {
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return model.User{}, ErrInvalidIDToken
+	}
+	if u, ok := s.identities.FindUser(provider, sub); ok {
+		return u, nil
+	}
+	email, _ := claims["email"].(string)
+	verified, _ := claims["email_verified"].(bool)
+	if v, ok := claims["email_verified"].(string); ok {
+		verified = v == "true"
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || !verified {
+		return model.User{}, ErrEmailNotVerified
+	}
+	u, ok := s.users.GetByEmail(email)
+	if !ok {
+		name, _ := claims["name"].(string)
+		// the random password can never be typed, so the account is usable
+		// only through the provider until the user sets one
+		u = s.users.Create(model.User{Name: name, Email: email, Password: randomToken(), Role: model.RoleUser, EmailVerified: true})
+		if u.ID == 0 {
+			return model.User{}, errors.New("failed to create user")
+		}
+	}
+	if err := s.identities.Link(u.ID, provider, sub, email); err != nil {
+		return model.User{}, err
+	}
+	return u, nil
+}
+
+// discover returns a copy of p with its endpoints filled in from the
+// issuer's discovery document. Logins run concurrently, so p's endpoints are
+// only read and written under s.mu.
+func (s *OIDCService) discover(p *OIDCProvider) (OIDCProvider, error) {
+	s.mu.Lock()
+	resolved := *p
+	s.mu.Unlock()
+	if resolved.AuthURL != "" && resolved.TokenURL != "" && resolved.JWKSURL != "" {
+		return resolved, nil
+	}
+	var doc struct {
+		Issuer   string `json:"issuer"`
+		AuthURL  string `json:"authorization_endpoint"`
+		TokenURL string `json:"token_endpoint"`
+		JWKSURL  string `json:"jwks_uri"`
+	}
+	if err := s.getJSON(strings.TrimSuffix(resolved.Issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return OIDCProvider{}, fmt.Errorf("oidc discovery for %s: %w", resolved.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.AuthURL == "" {
+		p.AuthURL = doc.AuthURL
+	}
+	if p.TokenURL == "" {
+		p.TokenURL = doc.TokenURL
+	}
+	if p.JWKSURL == "" {
+		p.JWKSURL = doc.JWKSURL
+	}
+	return *p, nil
+}
+
+func (s *OIDCService) exchange(p *OIDCProvider, code string, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	resp, err := s.client.PostForm(p.TokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("token exchange failed: %s %s", resp.Status, body.Error)
+	}
+	return body.IDToken, nil
+}
+
+func (s *OIDCService) verifyIDToken(p *OIDCProvider, raw string, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			// some providers (e.g. LINE web login) sign with the client secret
+			if p.ClientSecret == "" {
+				return nil, ErrUnexpectedAlg
+			}
+			return []byte(p.ClientSecret), nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		return s.providerKey(p, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if !claims.VerifyIssuer(p.Issuer, true) || !claims.VerifyAudience(p.ClientID, true) {
+		return nil, ErrInvalidIDToken
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, ErrInvalidIDToken
+	}
+	return claims, nil
+}
+
+// providerKey returns the provider's public key for kid, refetching the JWKS
+// once when the kid is unknown in case the provider rotated its keys.
+func (s *OIDCService) providerKey(p *OIDCProvider, kid string) (any, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		s.mu.Lock()
+		keys := s.jwks[p.Name]
+		s.mu.Unlock()
+		if keys != nil {
+			if k, ok := keys[kid]; ok {
+				return k, nil
+			}
+			if kid == "" && len(keys) == 1 {
+				for _, k := range keys {
+					return k, nil
+				}
+			}
+			if attempt > 0 {
+				break
+			}
+		}
+		var set struct {
+			Keys []json.RawMessage `json:"keys"`
+		}
+		if err := s.getJSON(p.JWKSURL, &set); err != nil {
+			return nil, err
+		}
+		parsed := make(map[string]any, len(set.Keys))
+		for _, rawKey := range set.Keys {
+			id, key, err := parseJWK(rawKey)
+			if err == nil {
+				parsed[id] = key
+			}
+		}
+		s.mu.Lock()
+		s.jwks[p.Name] = parsed
+		s.mu.Unlock()
+	}
+	return nil, ErrUnknownKey
+}
+
+func parseJWK(raw json.RawMessage) (string, any, error) {
+	var j struct {
+		Kty, Kid, Crv, N, E, X, Y string
+	}
+	if err := json.Unmarshal(raw, &j); err != nil {
+		return "", nil, err
+	}
+	dec := func(s string) *big.Int {
+		b, _ := base64.RawURLEncoding.DecodeString(s)
+		return new(big.Int).SetBytes(b)
+	}
+	switch j.Kty {
+	case "RSA":
+		return j.Kid, &rsa.PublicKey{N: dec(j.N), E: int(dec(j.E).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return "", nil, ErrUnsupportedKey
+		}
+		return j.Kid, &ecdsa.PublicKey{Curve: curve, X: dec(j.X), Y: dec(j.Y)}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return "", nil, ErrUnsupportedKey
+		}
+		b, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return "", nil, ErrUnsupportedKey
+		}
+		return j.Kid, ed25519.PublicKey(b), nil
+	}
+	return "", nil, ErrUnsupportedKey
+}
+
+func (s *OIDCService) getJSON(u string, v any) error {
+	resp, err := s.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/notification"
+	"agodrift/internal/payment"
+	"agodrift/internal/repository"
+)
+
+var (
+	ErrPaymentTokenRequired = errors.New("payment_token required")
+	ErrBookingNotPayable    = errors.New("booking is not awaiting payment")
+	ErrAlreadyPaid          = errors.New("booking already has a payment in progress")
+	ErrPaymentDeclined      = errors.New("payment declined")
+	ErrPaymentState         = errors.New("payment does not allow this in its current state")
+	ErrRefundAmount         = errors.New("refund amount must be between 1 and the captured amount not yet refunded")
+	ErrGatewayUnavailable   = errors.New("payment gateway unavailable")
+)
+
+// PaymentService takes payments for bookings through a payment.Gateway. A
+// booking is confirmed when the gateway authorizes its payment, either
+// right away or later through a webhook event.
+type PaymentService struct {
+	repo     repository.PaymentRepository
+	bookings repository.BookingRepository
+	gateway  payment.Gateway
+	perms    *RBACService
+	notifier *notification.Notifier
+	now      func() time.Time
+}
+
+// NewPaymentService uses the gateway configured in the environment. Without
+// one every payment fails; main refuses to start the server in that case.
+func NewPaymentService() *PaymentService {
+	db := config.GetDB()
+	gateway, err := payment.FromEnv()
+	if err != nil {
+		gateway = payment.Unavailable(err)
+	}
+	return NewPaymentServiceWith(repository.NewMySQLPaymentRepo(db), repository.NewMySQLBookingRepo(db), gateway, GetRBAC(), notification.NewNotifier(), time.Now)
+}
+
+func NewPaymentServiceWith(repo repository.PaymentRepository, bookings repository.BookingRepository, gateway payment.Gateway, perms *RBACService, notifier *notification.Notifier, now func() time.Time) *PaymentService {
+	return &PaymentService{repo: repo, bookings: bookings, gateway: gateway, perms: perms, notifier: notifier, now: now}
+}
+
+// Pay authorizes what is left of the caller's pending booking after loyalty
+// points. A declined card returns the failed payment with ErrPaymentDeclined;
+// the guest may retry with another card.
+func (s *PaymentService) Pay(userID int, bookingID int, token string) (model.Payment, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return model.Payment{}, ErrPaymentTokenRequired
+	}
+	b, err := s.bookings.GetByID(bookingID)
+	if err != nil {
+		return model.Payment{}, err
+	}
+	if b.UserID != userID {
+		return model.Payment{}, ErrForbidden
+	}
+	if b.Status != model.BookingPending {
+		return model.Payment{}, ErrBookingNotPayable
+	}
+	paid, err := s.Paid(bookingID)
+	if err != nil {
+		return model.Payment{}, err
+	}
+	if paid {
+		return model.Payment{}, ErrAlreadyPaid
+	}
+
+	now := s.now()
+	p, err := s.repo.Create(model.Payment{
+		BookingID: b.ID, UserID: userID, Gateway: s.gateway.Name(),
+		AmountCents: b.AmountDueCents(), Currency: b.Currency,
+		Status: model.PaymentPending, CreatedAt: now, UpdatedAt: now,
+	})
+	if err != nil {
+		return model.Payment{}, err
+	}
+	ref := b.Reference
+	if ref == "" {
+		ref = fmt.Sprintf("booking-%d", b.ID)
+	}
+	res, err := s.gateway.Authorize(payment.AuthorizeRequest{
+		Reference: fmt.Sprintf("%s-%d", ref, p.ID), AmountCents: p.AmountCents, Currency: p.Currency, Token: token,
+	})
+	if err != nil {
+		log.Printf("payment: authorize payment %d: %v", p.ID, err)
+		p.Status, p.FailureReason = model.PaymentFailed, "gateway_error"
+		s.save(&p)
+		return p, ErrGatewayUnavailable
+	}
+	p.GatewayRef = res.Ref
+	return s.settleAuthorization(p, res.Status, res.Reason)
+}
+
+// Paid reports whether the booking has a payment in progress, authorized or
+// captured.
+func (s *PaymentService) Paid(bookingID int) (bool, error) {
+	existing, err := s.repo.ListByBooking(bookingID)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range existing {
+		if p.Status == model.PaymentPending || p.Status == model.PaymentAuthorized || p.Status == model.PaymentCaptured {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// settleAuthorization applies the gateway's answer to a pending payment.
+func (s *PaymentService) settleAuthorization(p model.Payment, status string, reason string) (model.Payment, error) {
+	switch status {
+	case payment.StatusPending:
+		return p, s.save(&p)
+	case payment.StatusFailed:
+		p.Status, p.FailureReason = model.PaymentFailed, reason
+		if err := s.save(&p); err != nil {
+			return p, err
+		}
+		return p, ErrPaymentDeclined
+	}
+
+	p.Status = model.PaymentAuthorized
+	if err := s.save(&p); err != nil {
+		return p, err
+	}
+	confirmed, err := s.bookings.Transition(p.BookingID, model.BookingPending, model.BookingConfirmed)
+	if err != nil {
+		return p, err
+	}
+	if !confirmed {
+		// cancelled while the gateway was deciding: release the hold
+		if _, err := s.gateway.Void(p.GatewayRef); err != nil {
+			return p, err
+		}
+		p.Status = model.PaymentVoided
+		return p, s.save(&p)
+	}
+	if b, err := s.bookings.GetByID(p.BookingID); err == nil {
+		s.notifier.Notify(notification.BookingConfirmed, b)
+	}
+	return p, nil
+}
+
+// HandleWebhook applies an asynchronous gateway result. Events are applied
+// at most once: repeats find the payment already settled and are ignored.
+func (s *PaymentService) HandleWebhook(body []byte, signature string) error {
+	e, err := s.gateway.ParseWebhook(body, signature)
+	if err != nil {
+		return err
+	}
+	p, err := s.repo.GetByGatewayRef(s.gateway.Name(), e.Ref)
+	if err != nil {
+		return err
+	}
+	switch e.Type {
+	case payment.EventAuthorizationSucceeded, payment.EventAuthorizationFailed:
+		if p.Status != model.PaymentPending {
+			return nil
+		}
+		status := payment.StatusSucceeded
+		if e.Type == payment.EventAuthorizationFailed {
+			status = payment.StatusFailed
+		}
+		_, err = s.settleAuthorization(p, status, e.Reason)
+		if err == ErrPaymentDeclined {
+			err = nil
+		}
+		return err
+	case payment.EventCaptureSucceeded:
+		if p.Status != model.PaymentAuthorized {
+			return nil
+		}
+		p.Status, p.CapturedCents = model.PaymentCaptured, p.AmountCents
+		if e.AmountCents > 0 && e.AmountCents < p.AmountCents {
+			p.CapturedCents = e.AmountCents
+		}
+		return s.save(&p)
+	}
+	// other event types are not used
+	return nil
+}
+
+// ListForBooking returns a booking's payments to its guest, to staff who may
+// read any booking and to those managing payments.
+func (s *PaymentService) ListForBooking(userID int, role string, bookingID int) ([]model.Payment, error) {
+	b, err := s.bookings.GetByID(bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if b.UserID != userID && !s.perms.Has(userID, role, model.PermBookingsReadAny) && !s.perms.Has(userID, role, model.PermPaymentsManage) {
+		return nil, ErrForbidden
+	}
+	return s.repo.ListByBooking(bookingID)
+}
+
+// Capture collects the full authorized amount.
+func (s *PaymentService) Capture(id int) (model.Payment, error) {
+	p, err := s.repo.Get(id)
+	if err != nil {
+		return model.Payment{}, err
+	}
+	if p.Status != model.PaymentAuthorized {
+		return p, ErrPaymentState
+	}
+	if _, err := s.gateway.Capture(p.GatewayRef, p.AmountCents); err != nil {
+		log.Printf("payment: capture payment %d: %v", p.ID, err)
+		return p, ErrGatewayUnavailable
+	}
+	p.Status, p.CapturedCents = model.PaymentCaptured, p.AmountCents
+	return p, s.save(&p)
+}
+
+// Void releases an authorization that has not been captured.
+func (s *PaymentService) Void(id int) (model.Payment, error) {
+	p, err := s.repo.Get(id)
+	if err != nil {
+		return model.Payment{}, err
+	}
+	if p.Status != model.PaymentAuthorized {
+		return p, ErrPaymentState
+	}
+	if _, err := s.gateway.Void(p.GatewayRef); err != nil {
+		log.Printf("payment: void payment %d: %v", p.ID, err)
+		return p, ErrGatewayUnavailable
+	}
+	p.Status = model.PaymentVoided
+	return p, s.save(&p)
+}
+
+// Refund returns amountCents of a captured payment, or everything not yet
+// refunded when amountCents is 0.
+func (s *PaymentService) Refund(id int, amountCents int) (model.Payment, error) {
+	p, err := s.repo.Get(id)
+	if err != nil {
+		return model.Payment{}, err
+	}
+	if p.Status != model.PaymentCaptured {
+		return p, ErrPaymentState
+	}
+	left := p.CapturedCents - p.RefundedCents
+	if amountCents == 0 {
+		amountCents = left
+	}
+	if amountCents < 1 || amountCents > left {
+		return p, ErrRefundAmount
+	}
+	if _, err := s.gateway.Refund(p.GatewayRef, amountCents); err != nil {
+		log.Printf("payment: refund payment %d: %v", p.ID, err)
+		return p, ErrGatewayUnavailable
+	}
+	p.RefundedCents += amountCents
+	if p.RefundedCents == p.CapturedCents {
+		p.Status = model.PaymentRefunded
+	}
+	return p, s.save(&p)
+}
+
+func (s *PaymentService) save(p *model.Payment) error {
+	p.UpdatedAt = s.now()
+	return s.repo.Update(*p)
+}
+
+// SettleCancellation keeps penaltyCents of a cancelled booking's payments
+// and releases the rest: authorizations are captured up to the penalty, or
+// voided when nothing is owed, and captured payments are refunded down to
+// it. It returns the payments it changed.
+func (s *PaymentService) SettleCancellation(bookingID int, penaltyCents int) ([]model.Payment, error) {
+	list, err := s.repo.ListByBooking(bookingID)
+	if err != nil {
+		return nil, err
+	}
+	changed := make([]model.Payment, 0)
+	for _, p := range list {
+		switch p.Status {
+		case model.PaymentAuthorized:
+			if penaltyCents == 0 {
+				if _, err := s.gateway.Void(p.GatewayRef); err != nil {
+					log.Printf("payment: void payment %d: %v", p.ID, err)
+					return changed, ErrGatewayUnavailable
+				}
+				p.Status = model.PaymentVoided
+				break
+			}
+			amount := min(penaltyCents, p.AmountCents)
+			if _, err := s.gateway.Capture(p.GatewayRef, amount); err != nil {
+				log.Printf("payment: capture payment %d: %v", p.ID, err)
+				return changed, ErrGatewayUnavailable
+			}
+			p.Status, p.CapturedCents = model.PaymentCaptured, amount
+			penaltyCents -= amount
+		case model.PaymentCaptured:
+			held := p.CapturedCents - p.RefundedCents
+			kept := min(penaltyCents, held)
+			penaltyCents -= kept
+			if held == kept {
+				continue
+			}
+			if _, err := s.gateway.Refund(p.GatewayRef, held-kept); err != nil {
+				log.Printf("payment: refund payment %d: %v", p.ID, err)
+				return changed, ErrGatewayUnavailable
+			}
+			p.RefundedCents += held - kept
+			if p.RefundedCents == p.CapturedCents {
+				p.Status = model.PaymentRefunded
+			}
+		default:
+			continue
+		}
+		if err := s.save(&p); err != nil {
+			return changed, err
+		}
+		changed = append(changed, p)
+	}
+	return changed, nil
+}
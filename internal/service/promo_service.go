@@ -0,0 +1,257 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/money"
+	"agodrift/internal/repository"
+)
+
+// maxPromoCodes is how many codes one booking can combine.
+const maxPromoCodes = 3
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9-]{3,32}$`)
+
+// PromoService manages promo codes and works out which of a guest's codes
+// apply to a stay.
+type PromoService struct {
+	repo     repository.PromoRepository
+	currency *CurrencyService
+	now      func() time.Time
+}
+
+func NewPromoService() *PromoService {
+	return NewPromoServiceWith(repository.NewMySQLPromoRepo(config.GetDB()), NewCurrencyService(), time.Now)
+}
+
+func NewPromoServiceWith(repo repository.PromoRepository, currency *CurrencyService, now func() time.Time) *PromoService {
+	return &PromoService{repo: repo, currency: currency, now: now}
+}
+
+func (s *PromoService) List() ([]model.PromoCode, error) {
+	return s.repo.List()
+}
+
+func (s *PromoService) Create(p model.PromoCode) (model.PromoCode, error) {
+	p, problems := CheckPromoCode(p)
+	if len(problems) > 0 {
+		return model.PromoCode{}, &ValidationError{Problems: problems}
+	}
+	p.CreatedAt = s.now()
+	return s.repo.Create(p)
+}
+
+// Update replaces a promo code. Bookings already made keep their discount.
+func (s *PromoService) Update(id int, p model.PromoCode) (model.PromoCode, error) {
+	old, err := s.repo.Get(id)
+	if err != nil {
+		return model.PromoCode{}, err
+	}
+	p, problems := CheckPromoCode(p)
+	if len(problems) > 0 {
+		return model.PromoCode{}, &ValidationError{Problems: problems}
+	}
+	p.ID, p.CreatedAt = id, old.CreatedAt
+	if err := s.repo.Update(p); err != nil {
+		return model.PromoCode{}, err
+	}
+	return p, nil
+}
+
+// CheckPromoCode normalizes a promo code and returns the reasons it is
+// invalid.
+func CheckPromoCode(p model.PromoCode) (model.PromoCode, []string) {
+	var problems []string
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	p.Description = strings.TrimSpace(p.Description)
+	p.Currency = money.Normalize(p.Currency)
+	if !promoCodePattern.MatchString(p.Code) {
+		problems = append(problems, "code must be 3-32 letters, digits or dashes")
+	}
+	if len(p.Description) > 255 {
+		problems = append(problems, "description must be at most 255 characters")
+	}
+	switch p.Kind {
+	case model.PromoPercent:
+		if p.PercentOff < 1 || p.PercentOff > 100 {
+			problems = append(problems, "percent_off must be 1-100")
+		}
+		p.AmountOffCents = 0
+	case model.PromoFixed:
+		if p.AmountOffCents < 1 {
+			problems = append(problems, "amount_off_cents must be positive")
+		}
+		p.PercentOff = 0
+	default:
+		problems = append(problems, `kind must be "percent" or "fixed"`)
+	}
+	if (p.Kind == model.PromoFixed || p.MinSpendCents > 0) && !money.Valid(p.Currency) {
+		problems = append(problems, "currency must be an ISO 4217 code for fixed amounts and minimum spend")
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		problems = append(problems, "ends_at must be after starts_at")
+	}
+	if p.MaxUses < 0 || p.MaxUsesPerUser < 0 || p.MinNights < 0 || p.MinSpendCents < 0 {
+		problems = append(problems, "limits must not be negative")
+	}
+	if p.HotelIDs == nil {
+		p.HotelIDs = []int{}
+	}
+	if p.Destinations == nil {
+		p.Destinations = []string{}
+	}
+	for i, d := range p.Destinations {
+		p.Destinations[i] = strings.TrimSpace(d)
+	}
+	return p, problems
+}
+
+// Stay is what a set of promo codes is checked against.
+type Stay struct {
+	UserID   int // 0 for guests who are not logged in; per-user limits are then skipped
+	Hotel    model.Room
+	CheckIn  time.Time
+	CheckOut time.Time
+	Rooms    int
+}
+
+// Apply checks codes against a stay at the hotel's current price and
+// returns them ready to price the booking, with fixed amounts in the hotel's
+// currency and each code's discount filled in. A code that doesn't apply
+// fails the whole set with a ValidationError saying why.
+func (s *PromoService) Apply(codes []string, stay Stay) ([]model.AppliedPromo, error) {
+	seen := map[string]bool{}
+	var list []string
+	for _, c := range codes {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c != "" && !seen[c] {
+			seen[c] = true
+			list = append(list, c)
+		}
+	}
+	out := make([]model.AppliedPromo, 0, len(list))
+	if len(list) == 0 {
+		return out, nil
+	}
+	if len(list) > maxPromoCodes {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("at most %d promo codes can be combined", maxPromoCodes)}}
+	}
+
+	now := s.now()
+	nights := model.Nights(stay.CheckIn, stay.CheckOut)
+	subtotal := stay.Hotel.PriceCents * nights * stay.Rooms
+	var problems []string
+	for _, code := range list {
+		p, err := s.repo.GetByCode(code)
+		if err == repository.ErrPromoNotFound || (err == nil && !p.Live(now)) {
+			problems = append(problems, fmt.Sprintf("promo code %s is not valid", code))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		reason, err := s.check(p, stay, nights, subtotal, len(list))
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			problems = append(problems, fmt.Sprintf("promo code %s %s", code, reason))
+			continue
+		}
+		applied := model.AppliedPromo{PromoID: p.ID, Code: p.Code, Kind: p.Kind, PercentOff: p.PercentOff, AmountOffCents: p.AmountOffCents}
+		if p.Kind == model.PromoFixed {
+			if applied.AmountOffCents, _, err = s.currency.Convert(p.AmountOffCents, p.Currency, stay.Hotel.Currency); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, applied)
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	model.ApplyPromos(subtotal, out)
+	return out, nil
+}
+
+// Requalify checks the promo codes already on a booking against new terms
+// for its stay, failing with a ValidationError for any code the new stay no
+// longer qualifies for. Only the length and spend conditions are checked
+// again: the booking keeps its use of the code even if the code has since
+// ended or been used up.
+func (s *PromoService) Requalify(applied []model.AppliedPromo, stay Stay) error {
+	nights := model.Nights(stay.CheckIn, stay.CheckOut)
+	subtotal := stay.Hotel.PriceCents * nights * stay.Rooms
+	var problems []string
+	for _, a := range applied {
+		p, err := s.repo.Get(a.PromoID)
+		if err != nil {
+			return err
+		}
+		reason, err := s.checkTerms(p, stay.Hotel, nights, subtotal)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			problems = append(problems, fmt.Sprintf("promo code %s %s", a.Code, reason))
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// check returns why p doesn't apply to the stay, or "" if it does.
+func (s *PromoService) check(p model.PromoCode, stay Stay, nights int, subtotal int, combined int) (string, error) {
+	h := stay.Hotel
+	if len(p.HotelIDs) > 0 && !slices.Contains(p.HotelIDs, h.ID) {
+		return "does not apply to this hotel", nil
+	}
+	if len(p.Destinations) > 0 && !slices.ContainsFunc(p.Destinations, func(d string) bool { return strings.EqualFold(d, h.Destination) }) {
+		return "does not apply to this destination", nil
+	}
+	if p.ExcludeSaleRates && h.OriginalPriceCents != nil && *h.OriginalPriceCents > h.PriceCents {
+		return "does not apply to hotels already on sale", nil
+	}
+	if combined > 1 && !p.Stackable {
+		return "cannot be combined with other codes", nil
+	}
+	if reason, err := s.checkTerms(p, h, nights, subtotal); reason != "" || err != nil {
+		return reason, err
+	}
+	total, byUser, err := s.repo.Uses(p.ID, stay.UserID)
+	if err != nil {
+		return "", err
+	}
+	if p.MaxUses > 0 && total >= p.MaxUses {
+		return "has been used up", nil
+	}
+	if stay.UserID != 0 && p.MaxUsesPerUser > 0 && byUser >= p.MaxUsesPerUser {
+		return "has already been used on your account", nil
+	}
+	return "", nil
+}
+
+// checkTerms returns why the stay's length or spend doesn't qualify for p,
+// or "" if it does.
+func (s *PromoService) checkTerms(p model.PromoCode, h model.Room, nights int, subtotal int) (string, error) {
+	if nights < p.MinNights {
+		return fmt.Sprintf("requires a stay of at least %d nights", p.MinNights), nil
+	}
+	if p.MinSpendCents > 0 {
+		minSpend, _, err := s.currency.Convert(p.MinSpendCents, p.Currency, h.Currency)
+		if err != nil {
+			return "", err
+		}
+		if subtotal < minSpend {
+			return "requires a minimum spend of " + money.Format(minSpend, h.Currency), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+var (
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrBuiltInRole       = errors.New("built-in roles cannot be changed or deleted")
+)
+
+var (
+	roleNamePattern   = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+	permissionPattern = regexp.MustCompile(`^(\*|[a-z_-]+(:[a-z_*-]+)*)$`)
+)
+
+// permissionCacheTTL bounds how long a role change on another instance takes
+// to be seen. Changes made through this service clear the cache immediately.
+const permissionCacheTTL = 30 * time.Second
+
+type cachedPermissions struct {
+	perms   []string
+	expires time.Time
+}
+
+// RBACService resolves a user's permissions from their assigned roles.
+type RBACService struct {
+	repo  repository.RBACRepository
+	mu    sync.Mutex
+	cache map[string]cachedPermissions // keyed by user id and token role
+	// keyScopes returns the permissions an API key is limited to
+	keyScopes func(keyID int) ([]string, bool)
+}
+
+// DefaultRBAC is a shared singleton used by middleware and services
+var DefaultRBAC *RBACService
+
+var rbacOnce sync.Once
+
+// GetRBAC returns the shared RBACService, creating it on first use
+func GetRBAC() *RBACService {
+	rbacOnce.Do(func() {
+		if DefaultRBAC == nil {
+			DefaultRBAC = NewRBACServiceWithRepo(repository.NewMySQLRBACRepo(config.GetDB()))
+		}
+	})
+	return DefaultRBAC
+}
+
+func NewRBACServiceWithRepo(repo repository.RBACRepository) *RBACService {
+	return &RBACService{repo: repo, cache: make(map[string]cachedPermissions)}
+}
+
+// Permissions returns the union of the permissions granted by the user's
+// assigned roles and by the primary role carried in their token.
+func (s *RBACService) Permissions(userID int, primaryRole string) []string {
+	key := strconv.Itoa(userID) + ":" + primaryRole
+	s.mu.Lock()
+	if c, ok := s.cache[key]; ok && time.Now().Before(c.expires) {
+		s.mu.Unlock()
+		return c.perms
+	}
+	s.mu.Unlock()
+
+	roles, err := s.repo.UserRoles(userID)
+	if err != nil {
+		roles = nil
+	}
+	if primaryRole != "" {
+		roles = append(roles, primaryRole)
+	}
+	seen := make(map[string]bool)
+	perms := make([]string, 0)
+	for _, name := range roles {
+		role, ok := s.repo.GetRole(name)
+		if !ok {
+			continue
+		}
+		for _, p := range role.Permissions {
+			if !seen[p] {
+				seen[p] = true
+				perms = append(perms, p)
+			}
+		}
+	}
+	sort.Strings(perms)
+
+	if err == nil {
+		s.mu.Lock()
+		s.cache[key] = cachedPermissions{perms: perms, expires: time.Now().Add(permissionCacheTTL)}
+		s.mu.Unlock()
+	}
+	return perms
+}
+
+// Has reports whether the user holds perm. A role scoped to an API key (see
+// APIKeyRole) only has the permissions both the user and the key hold.
+func (s *RBACService) Has(userID int, primaryRole string, perm string) bool {
+	primaryRole, keyID := splitAPIKeyRole(primaryRole)
+	if keyID != 0 {
+		if s.keyScopes == nil {
+			return false
+		}
+		scope, ok := s.keyScopes(keyID)
+		if !ok || !coveredBy(scope, perm) {
+			return false
+		}
+	}
+	return coveredBy(s.Permissions(userID, primaryRole), perm)
+}
+
+// SetKeyScopes installs the API key scope lookup used by Has.
+func (s *RBACService) SetKeyScopes(fn func(keyID int) ([]string, bool)) {
+	s.keyScopes = fn
+}
+
+// apiKeyRoleSep separates a role from the API key that narrows it.
+const apiKeyRoleSep = "#apikey:"
+
+// APIKeyRole returns the role claim for requests authenticated with an API
+// key: the user's role narrowed to the key's permissions. Exact role
+// comparisons never match it, so role-gated routes stay closed to keys.
+func APIKeyRole(role string, keyID int) string {
+	return role + apiKeyRoleSep + strconv.Itoa(keyID)
+}
+
+func splitAPIKeyRole(role string) (string, int) {
+	base, id, ok := strings.Cut(role, apiKeyRoleSep)
+	if !ok {
+		return role, 0
+	}
+	keyID, err := strconv.Atoi(id)
+	if err != nil || keyID <= 0 {
+		// malformed: grant nothing rather than the bare role
+		return "", -1
+	}
+	return base, keyID
+}
+
+func coveredBy(granted []string, perm string) bool {
+	for _, g := range granted {
+		if PermissionCovers(g, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionCovers reports whether a granted permission satisfies required.
+func PermissionCovers(granted string, required string) bool {
+	switch {
+	case granted == model.PermAll, granted == required:
+		return true
+	case strings.HasSuffix(granted, ":*"):
+		return strings.HasPrefix(required, strings.TrimSuffix(granted, "*"))
+	case strings.HasPrefix(required, granted+":"):
+		return true
+	case strings.HasSuffix(granted, ":any") && strings.HasSuffix(required, ":own"):
+		return strings.TrimSuffix(granted, ":any") == strings.TrimSuffix(required, ":own")
+	}
+	return false
+}
+
+func (s *RBACService) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = make(map[string]cachedPermissions)
+}
+
+func (s *RBACService) ListRoles() ([]model.Role, error) {
+	return s.repo.ListRoles()
+}
+
+// SaveRole creates or replaces a custom role's description and permissions.
+// Built-in roles are fixed, so admin can never lose "*".
+func (s *RBACService) SaveRole(role model.Role) (model.Role, error) {
+	role.Name = strings.ToLower(strings.TrimSpace(role.Name))
+	if !roleNamePattern.MatchString(role.Name) {
+		return model.Role{}, ErrInvalidRole
+	}
+	if existing, ok := s.repo.GetRole(role.Name); ok && existing.BuiltIn {
+		return model.Role{}, ErrBuiltInRole
+	}
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+	for i, p := range role.Permissions {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if !permissionPattern.MatchString(p) {
+			return model.Role{}, ErrInvalidPermission
+		}
+		role.Permissions[i] = p
+	}
+	if err := s.repo.SaveRole(role); err != nil {
+		return model.Role{}, err
+	}
+	s.invalidate()
+	saved, _ := s.repo.GetRole(role.Name)
+	return saved, nil
+}
+
+func (s *RBACService) DeleteRole(name string) error {
+	role, ok := s.repo.GetRole(name)
+	if !ok {
+		return repository.ErrRoleNotFound
+	}
+	if role.BuiltIn {
+		return ErrBuiltInRole
+	}
+	if err := s.repo.DeleteRole(name); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *RBACService) UserRoles(userID int) ([]string, error) {
+	return s.repo.UserRoles(userID)
+}
+
+func (s *RBACService) AssignRole(userID int, role string) error {
+	if err := s.repo.AssignRole(userID, role); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *RBACService) RevokeRole(userID int, role string) error {
+	if err := s.repo.RevokeRole(userID, role); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
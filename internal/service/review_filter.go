@@ -0,0 +1,63 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Review filter flags.
+const (
+	FlagProfanity = "profanity"
+	FlagEmail     = "email"
+	FlagPhone     = "phone"
+	FlagURL       = "url"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d\s\-().]{7,}\d`)
+	urlPattern   = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+`)
+	wordPattern  = regexp.MustCompile(`[\p{L}']+`)
+)
+
+var defaultBannedWords = []string{"fuck", "shit", "bitch", "asshole", "bastard", "cunt", "dick"}
+
+// ReviewFilter is a simple rule-based filter that flags reviews containing
+// profanity or contact details so they are held for moderation.
+type ReviewFilter struct {
+	banned map[string]struct{}
+}
+
+// NewReviewFilter returns a filter using the given banned words, or a
+// built-in list when none are provided.
+func NewReviewFilter(words ...string) *ReviewFilter {
+	if len(words) == 0 {
+		words = defaultBannedWords
+	}
+	f := &ReviewFilter{banned: make(map[string]struct{}, len(words))}
+	for _, w := range words {
+		f.banned[strings.ToLower(strings.TrimSpace(w))] = struct{}{}
+	}
+	return f
+}
+
+// Check returns the flags raised by the text, empty when it is clean.
+func (f *ReviewFilter) Check(text string) []string {
+	flags := make([]string, 0)
+	for _, w := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if _, ok := f.banned[strings.Trim(w, "'")]; ok {
+			flags = append(flags, FlagProfanity)
+			break
+		}
+	}
+	if emailPattern.MatchString(text) {
+		flags = append(flags, FlagEmail)
+	}
+	if phonePattern.MatchString(text) {
+		flags = append(flags, FlagPhone)
+	}
+	if urlPattern.MatchString(text) {
+		flags = append(flags, FlagURL)
+	}
+	return flags
+}
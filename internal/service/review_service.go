@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+var (
+	ErrInvalidReview = errors.New("invalid review")
+	ErrForbidden     = errors.New("forbidden")
+)
+
+// ReviewService handles review submission, moderation and hotel replies.
+type ReviewService struct {
+	repo   repository.ReviewRepository
+	access hotelAccess
+	filter *ReviewFilter
+}
+
+func NewReviewService() *ReviewService {
+	db := config.GetDB()
+	return &ReviewService{
+		repo:   repository.NewMySQLReviewRepo(db),
+		access: hotelAccess{owners: repository.NewMySQLHotelOwnerRepo(db), perms: GetRBAC()},
+		filter: NewReviewFilter(),
+	}
+}
+
+func NewReviewServiceWithRepo(repo repository.ReviewRepository, owners repository.HotelOwnerRepository, perms *RBACService) *ReviewService {
+	return &ReviewService{repo: repo, access: hotelAccess{owners: owners, perms: perms}, filter: NewReviewFilter()}
+}
+
+// Submit stores a new review. Reviews that pass the filter are published
+// immediately; flagged ones wait in the moderation queue.
+func (s *ReviewService) Submit(userID int, hotelID int, rating int, title string, body string) (model.Review, error) {
+	title = strings.TrimSpace(title)
+	body = strings.TrimSpace(body)
+	if rating < 1 || rating > 5 || body == "" {
+		return model.Review{}, ErrInvalidReview
+	}
+	rv := model.Review{
+		HotelID: hotelID,
+		UserID:  userID,
+		Rating:  rating,
+		Title:   title,
+		Body:    body,
+		Status:  model.ReviewApproved,
+	}
+	if flags := s.filter.Check(title + "\n" + body); len(flags) > 0 {
+		rv.Status = model.ReviewPending
+		rv.Flags = strings.Join(flags, ",")
+	}
+	return s.repo.Create(rv)
+}
+
+// ListForHotel returns the approved reviews for a hotel.
+func (s *ReviewService) ListForHotel(hotelID int) ([]model.Review, error) {
+	return s.repo.ListByHotel(hotelID, model.ReviewApproved)
+}
+
+// Queue returns reviews in the given moderation status, pending by default.
+func (s *ReviewService) Queue(status string) ([]model.Review, error) {
+	if status == "" {
+		status = model.ReviewPending
+	}
+	return s.repo.ListByStatus(status)
+}
+
+func (s *ReviewService) Approve(id int, moderatorID int) error {
+	return s.repo.SetStatus(id, model.ReviewApproved, "", moderatorID)
+}
+
+func (s *ReviewService) Reject(id int, moderatorID int, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return ErrInvalidReview
+	}
+	return s.repo.SetStatus(id, model.ReviewRejected, reason, moderatorID)
+}
+
+// Reply posts the hotel's public reply. Only a manager or partner linked to
+// the reviewed hotel, or someone who may edit any hotel, may reply, and only
+// once per review.
+func (s *ReviewService) Reply(reviewID int, userID int, role string, body string) (model.ReviewReply, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return model.ReviewReply{}, ErrInvalidReview
+	}
+	rv, ok := s.repo.Get(reviewID)
+	if !ok {
+		return model.ReviewReply{}, repository.ErrReviewNotFound
+	}
+	if !s.access.can(userID, role, rv.HotelID, model.PermHotelsWrite, model.PermReviewsReply) {
+		return model.ReviewReply{}, ErrForbidden
+	}
+	if rv.Reply != nil {
+		return model.ReviewReply{}, repository.ErrReplyExists
+	}
+	return s.repo.CreateReply(model.ReviewReply{ReviewID: reviewID, UserID: userID, Body: body})
+}
@@ -1,21 +1,36 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
 	"agodrift/internal/config"
 	"agodrift/internal/model"
+	"agodrift/internal/money"
 	"agodrift/internal/repository"
 )
 
 type RoomService struct {
-	repo repository.RoomRepository
+	repo   repository.RoomRepository
+	owners repository.HotelOwnerRepository
+	access hotelAccess
 }
 
 func NewRoomService() *RoomService {
-	return &RoomService{repo: repository.NewMySQLRoomRepo(config.GetDB())}
+	db := config.GetDB()
+	return NewRoomServiceWithRepos(repository.NewMySQLRoomRepo(db), repository.NewMySQLHotelOwnerRepo(db), GetRBAC())
 }
 
 func NewRoomServiceWithRepo(repo repository.RoomRepository) *RoomService {
-	return &RoomService{repo: repo}
+	return NewRoomServiceWithRepos(repo, repository.NewInMemoryHotelOwnerRepo(), NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()))
+}
+
+func NewRoomServiceWithRepos(repo repository.RoomRepository, owners repository.HotelOwnerRepository, perms *RBACService) *RoomService {
+	return &RoomService{repo: repo, owners: owners, access: hotelAccess{owners: owners, perms: perms}}
 }
 
 func (s *RoomService) List() []model.Room {
@@ -29,3 +44,201 @@ func (s *RoomService) Get(id int) (model.Room, bool) {
 func (s *RoomService) Create(r model.Room) model.Room {
 	return s.repo.Create(r)
 }
+
+// HotelUpdate holds the hotel fields a partner may change. Nil fields are
+// left untouched. Rating, reviews and featured placement stay admin-curated.
+type HotelUpdate struct {
+	Name               *string `json:"name"`
+	Description        *string `json:"description"`
+	Location           *string `json:"location"`
+	Destination        *string `json:"destination"`
+	PriceCents         *int    `json:"price_cents"`
+	OriginalPriceCents *int    `json:"original_price_cents"`
+	Currency           *string `json:"currency"`
+	Amenities          *string `json:"amenities"`
+	MaxAdults          *int    `json:"max_adults"`
+	MaxChildren        *int    `json:"max_children"`
+	Status             *string `json:"status"`
+}
+
+// ValidationError carries the reasons an update was rejected. It is shared
+// by hotel and profile updates.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "validation failed: " + strings.Join(e.Problems, "; ")
+}
+
+var ErrInvalidInventory = errors.New("rooms_available must be between 0 and rooms_total")
+
+// Update applies a partial update to a hotel the caller may manage.
+func (s *RoomService) Update(userID int, role string, id int, u HotelUpdate) (model.Room, error) {
+	rm, ok := s.repo.Get(id)
+	if !ok {
+		return model.Room{}, ErrHotelNotFound
+	}
+	if !s.access.can(userID, role, id, model.PermHotelsWrite, model.PermHotelsWriteOwn) {
+		return model.Room{}, ErrForbidden
+	}
+	setString := func(dst *string, v *string) {
+		if v != nil {
+			*dst = strings.TrimSpace(*v)
+		}
+	}
+	setInt := func(dst *int, v *int) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setString(&rm.Name, u.Name)
+	setString(&rm.Description, u.Description)
+	setString(&rm.Location, u.Location)
+	setString(&rm.Destination, u.Destination)
+	setString(&rm.Amenities, u.Amenities)
+	setString(&rm.Status, u.Status)
+	setInt(&rm.PriceCents, u.PriceCents)
+	setInt(&rm.MaxAdults, u.MaxAdults)
+	setInt(&rm.MaxChildren, u.MaxChildren)
+	if u.Currency != nil {
+		rm.Currency = money.Normalize(*u.Currency)
+	}
+	if u.OriginalPriceCents != nil {
+		if *u.OriginalPriceCents <= 0 {
+			rm.OriginalPriceCents = nil
+		} else {
+			v := *u.OriginalPriceCents
+			rm.OriginalPriceCents = &v
+		}
+	}
+	if problems := ValidateHotel(rm); len(problems) > 0 {
+		return model.Room{}, &ValidationError{Problems: problems}
+	}
+	if err := s.repo.Update(rm); err != nil {
+		return model.Room{}, err
+	}
+	return rm, nil
+}
+
+// SetInventory replaces a hotel's room counts.
+func (s *RoomService) SetInventory(userID int, role string, id int, roomsTotal int, roomsAvailable int) (model.Room, error) {
+	rm, ok := s.repo.Get(id)
+	if !ok {
+		return model.Room{}, ErrHotelNotFound
+	}
+	if !s.access.can(userID, role, id, model.PermHotelsWrite, model.PermHotelsWriteOwn) {
+		return model.Room{}, ErrForbidden
+	}
+	if roomsTotal < 1 || roomsAvailable < 0 || roomsAvailable > roomsTotal {
+		return model.Room{}, ErrInvalidInventory
+	}
+	if err := s.repo.UpdateInventory(id, roomsTotal, roomsAvailable); err != nil {
+		return model.Room{}, err
+	}
+	rm.RoomsTotal = roomsTotal
+	rm.RoomsAvailable = roomsAvailable
+	return rm, nil
+}
+
+// ListOwned returns the hotels linked to a partner or manager.
+func (s *RoomService) ListOwned(userID int) ([]model.Room, error) {
+	ids, err := s.owners.HotelIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.Room, 0, len(ids))
+	for _, id := range ids {
+		if rm, ok := s.repo.Get(id); ok {
+			out = append(out, rm)
+		}
+	}
+	return out, nil
+}
+
+// AssignOwner links a partner or manager account to a hotel.
+func (s *RoomService) AssignOwner(userID int, hotelID int) error {
+	if _, ok := s.repo.Get(hotelID); !ok {
+		return ErrHotelNotFound
+	}
+	return s.owners.Assign(userID, hotelID)
+}
+
+func (s *RoomService) RemoveOwner(userID int, hotelID int) error {
+	return s.owners.Remove(userID, hotelID)
+}
+
+// Import validates hotels read from CSV or JSON and, unless dryRun is set or
+// any row is invalid, upserts them by external reference, or by id for rows
+// without one, so a re-imported export updates its hotels in place. Rows are
+// written in batches of batchSize, each batch in its own transaction.
+func (s *RoomService) Import(format string, r io.Reader, dryRun bool, batchSize int) (ImportReport, error) {
+	rooms, parseErrs, err := ParseHotels(format, r)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	report := ImportReport{DryRun: dryRun, Total: len(rooms), Errors: make([]RowError, 0)}
+
+	bad := make(map[int]bool, len(parseErrs))
+	for _, pe := range parseErrs {
+		bad[pe.Row] = true
+		report.Errors = append(report.Errors, pe)
+	}
+	seenRefs := make(map[string]int)
+	seenIDs := make(map[int]int)
+	for i, rm := range rooms {
+		row := i + 1
+		if bad[row] {
+			continue
+		}
+		rm.Currency = money.Normalize(rm.Currency)
+		rooms[i] = rm
+		errs := ValidateHotel(rm)
+		if rm.ExternalRef != "" {
+			if first, ok := seenRefs[rm.ExternalRef]; ok {
+				errs = append(errs, "duplicate external_ref, first seen on row "+strconv.Itoa(first))
+			} else {
+				seenRefs[rm.ExternalRef] = row
+			}
+		} else if rm.ID != 0 {
+			if _, ok := s.repo.Get(rm.ID); !ok {
+				errs = append(errs, "id "+strconv.Itoa(rm.ID)+" does not match a hotel")
+			} else if first, ok := seenIDs[rm.ID]; ok {
+				errs = append(errs, "duplicate id, first seen on row "+strconv.Itoa(first))
+			} else {
+				seenIDs[rm.ID] = row
+			}
+		}
+		if len(errs) > 0 {
+			report.Errors = append(report.Errors, RowError{Row: row, ExternalRef: rm.ExternalRef, Errors: errs})
+			continue
+		}
+		report.Valid++
+	}
+	sort.Slice(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+
+	if dryRun || len(report.Errors) > 0 {
+		return report, nil
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+	for start := 0; start < len(rooms); start += batchSize {
+		end := min(start+batchSize, len(rooms))
+		created, updated, err := s.repo.UpsertBatch(rooms[start:end])
+		if err != nil {
+			report.Failure = fmt.Sprintf("batch %d (rows %d-%d) rolled back: %v", report.Batches+1, start+1, end, err)
+			return report, nil
+		}
+		report.Batches++
+		report.Created += created
+		report.Updated += updated
+	}
+	report.Imported = true
+	return report, nil
+}
+
+// Export writes every hotel in the given format.
+func (s *RoomService) Export(format string, w io.Writer) error {
+	return WriteHotels(format, w, s.repo.List())
+}
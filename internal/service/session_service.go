@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionTouchInterval limits last-seen writes to one per session per minute.
+const sessionTouchInterval = time.Minute
+
+// SessionClient describes the device a login came from.
+type SessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionService tracks issued access tokens so they can be listed and
+// revoked one by one. A token is only accepted while its session is active.
+type SessionService struct {
+	repo repository.SessionRepository
+	now  func() time.Time
+}
+
+func NewSessionService(repo repository.SessionRepository, now func() time.Time) *SessionService {
+	return &SessionService{repo: repo, now: now}
+}
+
+// Start records a new session for a token with the given jti.
+func (s *SessionService) Start(u model.User, jti string, expiresAt time.Time, client SessionClient) error {
+	now := s.now()
+	ua := client.UserAgent
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+	return s.repo.Create(model.Session{
+		ID: jti, UserID: u.ID, UserAgent: ua, IP: client.IP,
+		CreatedAt: now, LastSeenAt: now, ExpiresAt: expiresAt,
+	})
+}
+
+// Check reports whether the session is active and refreshes its last-seen time.
+func (s *SessionService) Check(jti string) bool {
+	sess, ok := s.repo.Get(jti)
+	now := s.now()
+	if !ok || !sess.Active(now) {
+		return false
+	}
+	if now.Sub(sess.LastSeenAt) >= sessionTouchInterval {
+		if err := s.repo.Touch(jti, now); err != nil {
+			log.Printf("session: touch %s: %v", jti, err)
+		}
+	}
+	return true
+}
+
+// List returns the user's active sessions, marking the one making the request.
+func (s *SessionService) List(userID int, current string) ([]model.Session, error) {
+	list, err := s.repo.ListActive(userID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Current = list[i].ID == current
+	}
+	return list, nil
+}
+
+// Revoke ends one of the user's sessions.
+func (s *SessionService) Revoke(userID int, id string) error {
+	ok, err := s.repo.Revoke(userID, id, s.now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOthers ends every session of the user except current.
+func (s *SessionService) RevokeOthers(userID int, current string) (int, error) {
+	return s.repo.RevokeAll(userID, current, s.now())
+}
+
+// RevokeAll ends every session of the user.
+func (s *SessionService) RevokeAll(userID int) (int, error) {
+	return s.repo.RevokeAll(userID, "", s.now())
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"agodrift/internal/config"
+	"agodrift/internal/model"
+	"agodrift/internal/money"
+	"agodrift/internal/repository"
+)
+
+var (
+	ErrWrongPassword = errors.New("current password is incorrect")
+	ErrUnknownRole   = errors.New("unknown role")
+	ErrInvalidStatus = errors.New(`status must be "active" or "disabled"`)
+	ErrSelfAction    = errors.New("you cannot change your own role or status")
+	ErrOutranked     = errors.New("you cannot manage a user with more permissions than you")
+)
+
+const (
+	defaultUsersPerPage = 20
+	maxUsersPerPage     = 100
+)
+
+var (
+	profilePhonePattern = regexp.MustCompile(`^\+?[0-9][0-9 ()-]{5,30}$`)
+	languagePattern     = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+)
+
+// ProfileUpdate is a partial profile update; nil fields are left unchanged.
+type ProfileUpdate struct {
+	Name              *string `json:"name"`
+	Phone             *string `json:"phone"`
+	PreferredCurrency *string `json:"preferred_currency"`
+	Language          *string `json:"language"`
+}
+
+// UserService manages accounts: the caller's own profile and, for admins,
+// everyone's role and status.
+type UserService struct {
+	users repository.UserRepository
+	auth  *AuthService
+	perms *RBACService
+}
+
+func NewUserService() *UserService {
+	return NewUserServiceWith(repository.NewMySQLUserRepo(config.GetDB()), GetAuth(), GetRBAC())
+}
+
+func NewUserServiceWith(users repository.UserRepository, auth *AuthService, perms *RBACService) *UserService {
+	return &UserService{users: users, auth: auth, perms: perms}
+}
+
+// Profile returns the stored user, so name and role changes show without
+// logging in again.
+func (s *UserService) Profile(id int) (model.User, error) {
+	u, ok := s.users.GetByID(id)
+	if !ok {
+		return model.User{}, repository.ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (s *UserService) UpdateProfile(id int, p ProfileUpdate) (model.User, error) {
+	u, err := s.Profile(id)
+	if err != nil {
+		return model.User{}, err
+	}
+	var problems []string
+	if p.Name != nil {
+		u.Name = strings.TrimSpace(*p.Name)
+		if u.Name == "" || len(u.Name) > 255 {
+			problems = append(problems, "name must be 1-255 characters")
+		}
+	}
+	if p.Phone != nil {
+		u.Phone = strings.TrimSpace(*p.Phone)
+		if u.Phone != "" && !profilePhonePattern.MatchString(u.Phone) {
+			problems = append(problems, "phone is not a valid phone number")
+		}
+	}
+	if p.PreferredCurrency != nil {
+		u.PreferredCurrency = money.Normalize(*p.PreferredCurrency)
+		if u.PreferredCurrency != "" && !money.Valid(u.PreferredCurrency) {
+			problems = append(problems, "preferred_currency must be an ISO 4217 code")
+		}
+	}
+	if p.Language != nil {
+		u.Language = strings.TrimSpace(*p.Language)
+		if !languagePattern.MatchString(u.Language) {
+			problems = append(problems, `language must be a tag such as "en" or "th-TH"`)
+		}
+	}
+	if len(problems) > 0 {
+		return model.User{}, &ValidationError{Problems: problems}
+	}
+	if err := s.users.Update(u); err != nil {
+		return model.User{}, err
+	}
+	return u, nil
+}
+
+// ChangePassword replaces the password after checking the current one and
+// signs out every other session.
+func (s *UserService) ChangePassword(id int, current, next string, currentSession string) error {
+	u, err := s.Profile(id)
+	if err != nil {
+		return err
+	}
+	// plain compare, as in AuthService.Authenticate
+	if u.Password != current {
+		return ErrWrongPassword
+	}
+	if len(next) < minPasswordLen {
+		return ErrWeakPassword
+	}
+	if err := s.users.UpdatePassword(id, next); err != nil {
+		return err
+	}
+	_, err = s.auth.Sessions().RevokeOthers(id, currentSession)
+	return err
+}
+
+// Search lists users for admins, 20 per page by default.
+func (s *UserService) Search(f model.UserSearch) (model.Page[model.User], error) {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.PerPage < 1 {
+		f.PerPage = defaultUsersPerPage
+	}
+	f.PerPage = min(f.PerPage, maxUsersPerPage)
+	f.Query = strings.TrimSpace(f.Query)
+	list, total, err := s.users.Search(f)
+	if err != nil {
+		return model.Page[model.User]{}, err
+	}
+	return model.Page[model.User]{Items: list, Total: total, Page: f.Page, PerPage: f.PerPage}, nil
+}
+
+// SetRole changes a user's primary role. The JWT middleware reads the stored
+// role, so the change applies to existing sessions immediately.
+func (s *UserService) SetRole(adminID, id int, role string) (model.User, error) {
+	if adminID == id {
+		return model.User{}, ErrSelfAction
+	}
+	u, err := s.Profile(id)
+	if err != nil {
+		return model.User{}, err
+	}
+	roles, err := s.perms.ListRoles()
+	if err != nil {
+		return model.User{}, err
+	}
+	role = strings.ToLower(strings.TrimSpace(role))
+	known := false
+	for _, r := range roles {
+		known = known || r.Name == role
+	}
+	if !known {
+		return model.User{}, ErrUnknownRole
+	}
+	if err := s.users.SetRole(id, role); err != nil {
+		return model.User{}, err
+	}
+	u.Role = role
+	return u, nil
+}
+
+// SetStatus enables or disables an account. Disabling also signs the user
+// out of every session.
+func (s *UserService) SetStatus(adminID int, adminRole string, id int, status string) (model.User, error) {
+	if status != model.UserActive && status != model.UserDisabled {
+		return model.User{}, ErrInvalidStatus
+	}
+	if adminID == id {
+		return model.User{}, ErrSelfAction
+	}
+	u, err := s.manageable(adminID, adminRole, id)
+	if err != nil {
+		return model.User{}, err
+	}
+	if err := s.users.SetStatus(id, status); err != nil {
+		return model.User{}, err
+	}
+	if status == model.UserDisabled {
+		s.auth.RevokeUserTokens(id)
+	}
+	u.Status = status
+	return u, nil
+}
+
+// ForceLogout ends every session of the user and returns how many ended.
+func (s *UserService) ForceLogout(adminID int, adminRole string, id int) (int, error) {
+	if _, err := s.manageable(adminID, adminRole, id); err != nil {
+		return 0, err
+	}
+	return s.auth.Sessions().RevokeAll(id)
+}
+
+// manageable returns the user if the caller may disable or sign them out:
+// the caller must hold every permission the user has, and only admins may
+// act on admins. Otherwise users:manage holders such as support could lock
+// out the admins above them.
+func (s *UserService) manageable(adminID int, adminRole string, id int) (model.User, error) {
+	u, err := s.Profile(id)
+	if err != nil {
+		return model.User{}, err
+	}
+	if u.Role == model.RoleAdmin && !s.perms.Has(adminID, adminRole, model.PermAll) {
+		return model.User{}, ErrOutranked
+	}
+	for _, p := range s.perms.Permissions(u.ID, u.Role) {
+		if !s.perms.Has(adminID, adminRole, p) {
+			return model.User{}, ErrOutranked
+		}
+	}
+	return u, nil
+}
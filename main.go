@@ -2,12 +2,40 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"agodrift/internal/api"
+	"agodrift/internal/cli"
 	"agodrift/internal/config"
+	"agodrift/internal/payment"
+	"agodrift/internal/service"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import-hotels":
+			os.Exit(cli.ImportHotels(os.Args[2:]))
+		case "export-hotels":
+			os.Exit(cli.ExportHotels(os.Args[2:]))
+		case "import-rates":
+			os.Exit(cli.ImportRates(os.Args[2:]))
+		case "send-reminders":
+			os.Exit(cli.SendReminders(os.Args[2:]))
+		case "award-points":
+			os.Exit(cli.AwardPoints(os.Args[2:]))
+		}
+	}
+
+	// settings every payment or invoice depends on are checked before
+	// taking requests
+	if _, err := payment.FromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := service.InvoiceIssuerFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+
 	app := api.NewApp()
 	port := config.Get("PORT", "5000")
 	addr := ":" + port
@@ -1,8 +1,35 @@
 -- Hotel booking schema seed
 
 -- Drop old demo tables if they exist
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS user_sessions;
+DROP TABLE IF EXISTS one_time_tokens;
+DROP TABLE IF EXISTS login_lockouts;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
+DROP TABLE IF EXISTS oidc_logins;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS review_replies;
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS hotel_owners;
+DROP TABLE IF EXISTS payments;
+DROP TABLE IF EXISTS booking_promos;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS invoice_sequences;
+DROP TABLE IF EXISTS loyalty_ledger;
+DROP TABLE IF EXISTS booking_changes;
+DROP TABLE IF EXISTS booking_guests;
+DROP TABLE IF EXISTS booking_status_history;
 DROP TABLE IF EXISTS bookings;
+DROP TABLE IF EXISTS promo_codes;
+DROP TABLE IF EXISTS exchange_rates;
 DROP TABLE IF EXISTS hotels;
+DROP TABLE IF EXISTS cancellation_policies;
 DROP TABLE IF EXISTS users;
 
 -- Users table: stores admin and normal users
@@ -12,12 +39,27 @@ CREATE TABLE IF NOT EXISTS users (
   email VARCHAR(255) NOT NULL UNIQUE,
   password VARCHAR(255) NOT NULL,
   role VARCHAR(50) NOT NULL DEFAULT 'user',
+  email_verified TINYINT(1) NOT NULL DEFAULT 0,
+  phone VARCHAR(32) NOT NULL DEFAULT '',
+  preferred_currency CHAR(3) NOT NULL DEFAULT '', -- empty = show hotel prices as-is
+  language VARCHAR(16) NOT NULL DEFAULT 'en',
+  status VARCHAR(20) NOT NULL DEFAULT 'active', -- active / disabled
   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Cancellation policies: what guests pay when cancelling; hotels pick one
+CREATE TABLE IF NOT EXISTS cancellation_policies (
+  code VARCHAR(32) PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  description VARCHAR(500) NOT NULL DEFAULT '',
+  non_refundable TINYINT(1) NOT NULL DEFAULT 0, -- 1 = the full total is always charged
+  rules JSON NOT NULL                           -- [{hours_before, penalty_nights, penalty_percent}]
+);
+
 -- Hotels table: stores hotel inventory and data used for filters on the frontend
 CREATE TABLE IF NOT EXISTS hotels (
   id INT AUTO_INCREMENT PRIMARY KEY,
+  external_ref VARCHAR(100) NULL UNIQUE,   -- partner's own identifier, used to upsert bulk imports
   name VARCHAR(255) NOT NULL,
   description TEXT,
   location VARCHAR(255) NOT NULL,          -- e.g. "Manhattan, New York"
@@ -26,6 +68,7 @@ CREATE TABLE IF NOT EXISTS hotels (
   reviews INT NOT NULL DEFAULT 0,
   price_cents INT NOT NULL,                -- current price per night in cents
   original_price_cents INT NULL,           -- original price to show discount
+  currency CHAR(3) NOT NULL DEFAULT 'USD', -- ISO 4217 code prices are stored in
   amenities TEXT,                          -- comma-separated list of amenities
   featured TINYINT(1) NOT NULL DEFAULT 0,  -- 1 = featured, 0 = normal
   max_adults INT NOT NULL DEFAULT 1,       -- capacity configuration
@@ -33,12 +76,15 @@ CREATE TABLE IF NOT EXISTS hotels (
   rooms_total INT NOT NULL DEFAULT 1,      -- how many rooms this hotel has
   rooms_available INT NOT NULL DEFAULT 1,  -- rooms currently available for booking
   status VARCHAR(50) NOT NULL DEFAULT 'active', -- active / inactive / maintenance
-  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+  cancellation_policy VARCHAR(32) NULL,    -- NULL = the default, flexible
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT fk_hotels_cancellation_policy FOREIGN KEY (cancellation_policy) REFERENCES cancellation_policies(code)
 );
 
 -- Bookings table: link between users and hotels with stay details
 CREATE TABLE IF NOT EXISTS bookings (
   id INT AUTO_INCREMENT PRIMARY KEY,
+  reference CHAR(10) NULL UNIQUE,                -- confirmation code shown to guests, e.g. AGD-7K3QX7
   user_id INT NOT NULL,
   hotel_id INT NOT NULL,
   check_in DATE NOT NULL,
@@ -46,17 +92,398 @@ CREATE TABLE IF NOT EXISTS bookings (
   adults INT NOT NULL DEFAULT 1,
   children INT NOT NULL DEFAULT 0,
   rooms INT NOT NULL DEFAULT 1,
-  total_price_cents INT NOT NULL,
+  total_price_cents INT NOT NULL,                -- after promo codes
+  discount_cents INT NOT NULL DEFAULT 0,         -- taken off by promo codes
+  points_redeemed INT NOT NULL DEFAULT 0,        -- loyalty points spent on the booking
+  points_value_cents INT NOT NULL DEFAULT 0,     -- part of the total they paid for
+  currency CHAR(3) NOT NULL DEFAULT 'USD',       -- currency the hotel charges in
+  display_currency CHAR(3) NULL,                 -- currency the guest asked to see
+  display_total_cents INT NULL,
+  exchange_rate DECIMAL(20,10) NULL,             -- rate used for display_total_cents, for audit
   status VARCHAR(50) NOT NULL DEFAULT 'pending', -- confirmed / cancelled / pending
+  reminder_sent_at TIMESTAMP NULL,               -- upcoming-stay reminder emailed
+  lead_guest_name VARCHAR(100) NOT NULL DEFAULT '',
+  lead_guest_email VARCHAR(255) NOT NULL DEFAULT '',
+  lead_guest_phone VARCHAR(32) NOT NULL DEFAULT '',
+  special_requests VARCHAR(1000) NOT NULL DEFAULT '',
+  arrival_time VARCHAR(5) NOT NULL DEFAULT '',   -- estimated, HH:MM
+  cancellation_policy JSON NULL,                 -- copy of the hotel's policy when booked; NULL = free
   created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   CONSTRAINT fk_bookings_user FOREIGN KEY (user_id) REFERENCES users(id),
   CONSTRAINT fk_bookings_hotel FOREIGN KEY (hotel_id) REFERENCES hotels(id)
 );
 
+-- Promo codes: marketing discounts entered when booking; 0 limits = no limit
+CREATE TABLE IF NOT EXISTS promo_codes (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  code VARCHAR(32) NOT NULL UNIQUE,              -- upper case, e.g. SUMMER25
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  kind VARCHAR(10) NOT NULL,                     -- percent / fixed
+  percent_off INT NOT NULL DEFAULT 0,
+  amount_off_cents INT NOT NULL DEFAULT 0,
+  currency CHAR(3) NOT NULL DEFAULT '',          -- of amount_off_cents and min_spend_cents
+  starts_at DATETIME NULL,
+  ends_at DATETIME NULL,
+  max_uses INT NOT NULL DEFAULT 0,               -- bookings across all guests
+  max_uses_per_user INT NOT NULL DEFAULT 0,
+  min_nights INT NOT NULL DEFAULT 0,
+  min_spend_cents INT NOT NULL DEFAULT 0,        -- before discounts
+  hotel_ids JSON NOT NULL,                       -- [] = every hotel
+  destinations JSON NOT NULL,                    -- [] = every destination
+  stackable TINYINT(1) NOT NULL DEFAULT 0,       -- 1 = may be combined with other stackable codes
+  exclude_sale_rates TINYINT(1) NOT NULL DEFAULT 0,
+  active TINYINT(1) NOT NULL DEFAULT 1,
+  created_at DATETIME NOT NULL
+);
+
+-- Booking promos: codes used by a booking, in the order applied; a use
+-- counts until the booking is cancelled
+CREATE TABLE IF NOT EXISTS booking_promos (
+  booking_id INT NOT NULL,
+  promo_id INT NOT NULL,
+  position INT NOT NULL,
+  code VARCHAR(32) NOT NULL,
+  kind VARCHAR(10) NOT NULL,
+  percent_off INT NOT NULL DEFAULT 0,
+  amount_off_cents INT NOT NULL DEFAULT 0,       -- in the booking currency
+  discount_cents INT NOT NULL,
+  PRIMARY KEY (booking_id, promo_id),
+  KEY idx_booking_promos_promo (promo_id),
+  CONSTRAINT fk_booking_promos_booking FOREIGN KEY (booking_id) REFERENCES bookings(id),
+  CONSTRAINT fk_booking_promos_promo FOREIGN KEY (promo_id) REFERENCES promo_codes(id)
+);
+
+-- Loyalty ledger: append-only points entries; a balance is the sum of its
+-- rows, and each booking has at most one entry of each kind
+CREATE TABLE IF NOT EXISTS loyalty_ledger (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  booking_id INT NULL,
+  kind VARCHAR(10) NOT NULL,                     -- earn / reverse / redeem / return
+  points INT NOT NULL,                           -- negative for reverse and redeem
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  UNIQUE KEY uq_loyalty_ledger_booking_kind (booking_id, kind),
+  KEY idx_loyalty_ledger_user (user_id, created_at),
+  CONSTRAINT fk_loyalty_ledger_user FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT fk_loyalty_ledger_booking FOREIGN KEY (booking_id) REFERENCES bookings(id)
+);
+
+-- Invoice sequences: last number used per document kind; the row is locked
+-- while a document is stored so numbers have no gaps
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+  kind VARCHAR(20) PRIMARY KEY,                  -- invoice / credit_note
+  last_number INT NOT NULL DEFAULT 0
+);
+
+-- Invoices: invoices and credit notes as issued, one of each per booking
+CREATE TABLE IF NOT EXISTS invoices (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  number VARCHAR(20) NOT NULL UNIQUE,            -- e.g. INV-000042, CN-000007
+  kind VARCHAR(20) NOT NULL,
+  booking_id INT NOT NULL,
+  user_id INT NOT NULL,
+  issued_at DATETIME NOT NULL,
+  currency CHAR(3) NOT NULL,
+  total_cents INT NOT NULL,                      -- negative for credit notes
+  document JSON NOT NULL,                        -- the full document with its lines
+  UNIQUE KEY uq_invoices_booking_kind (booking_id, kind),
+  CONSTRAINT fk_invoices_booking FOREIGN KEY (booking_id) REFERENCES bookings(id)
+);
+
+-- Payments: gateway transactions for bookings; amounts in the booking currency
+CREATE TABLE IF NOT EXISTS payments (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  booking_id INT NOT NULL,
+  user_id INT NOT NULL,
+  gateway VARCHAR(32) NOT NULL,
+  gateway_ref VARCHAR(255) NOT NULL DEFAULT '',
+  amount_cents INT NOT NULL,
+  currency CHAR(3) NOT NULL,
+  captured_cents INT NOT NULL DEFAULT 0,
+  refunded_cents INT NOT NULL DEFAULT 0,
+  status VARCHAR(20) NOT NULL,                   -- pending / authorized / captured / voided / refunded / failed
+  failure_reason VARCHAR(100) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL,
+  KEY idx_payments_booking (booking_id),
+  KEY idx_payments_gateway_ref (gateway, gateway_ref),
+  CONSTRAINT fk_payments_booking FOREIGN KEY (booking_id) REFERENCES bookings(id)
+);
+
+-- Booking status history: every status a booking has had
+CREATE TABLE IF NOT EXISTS booking_status_history (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  booking_id INT NOT NULL,
+  status VARCHAR(50) NOT NULL,
+  changed_at DATETIME NOT NULL,
+  KEY idx_booking_status_history_booking (booking_id),
+  CONSTRAINT fk_booking_status_history_booking FOREIGN KEY (booking_id) REFERENCES bookings(id)
+);
+
+-- Booking guests: named guests in the order given; age is set for children
+CREATE TABLE IF NOT EXISTS booking_guests (
+  booking_id INT NOT NULL,
+  position INT NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  is_child TINYINT(1) NOT NULL DEFAULT 0,
+  age INT NULL,
+  PRIMARY KEY (booking_id, position),
+  CONSTRAINT fk_booking_guests_booking FOREIGN KEY (booking_id) REFERENCES bookings(id)
+);
+
+-- Booking changes: old and new terms of every modification, for audit
+CREATE TABLE IF NOT EXISTS booking_changes (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  booking_id INT NOT NULL,
+  changed_by INT NOT NULL,
+  old_terms JSON NOT NULL,
+  new_terms JSON NOT NULL,
+  price_delta_cents INT NOT NULL,
+  currency CHAR(3) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  KEY idx_booking_changes_booking (booking_id),
+  CONSTRAINT fk_booking_changes_booking FOREIGN KEY (booking_id) REFERENCES bookings(id)
+);
+
+-- Roles: named permission sets; built-in roles cannot be deleted
+CREATE TABLE IF NOT EXISTS roles (
+  name VARCHAR(50) PRIMARY KEY,
+  description VARCHAR(255) NOT NULL DEFAULT '',
+  built_in TINYINT(1) NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Role permissions: e.g. "hotels:write", "bookings:read:any", "*"
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role VARCHAR(50) NOT NULL,
+  permission VARCHAR(100) NOT NULL,
+  PRIMARY KEY (role, permission),
+  CONSTRAINT fk_role_permissions_role FOREIGN KEY (role) REFERENCES roles(name)
+);
+
+-- User roles: extra roles granted on top of users.role
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id INT NOT NULL,
+  role VARCHAR(50) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, role),
+  CONSTRAINT fk_user_roles_user FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT fk_user_roles_role FOREIGN KEY (role) REFERENCES roles(name)
+);
+
+-- User identities: external OpenID Connect accounts linked to users
+CREATE TABLE IF NOT EXISTS user_identities (
+  provider VARCHAR(50) NOT NULL,
+  subject VARCHAR(255) NOT NULL,                 -- the provider's "sub" claim
+  user_id INT NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (provider, subject),
+  CONSTRAINT fk_user_identities_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- OIDC logins: state, nonce and PKCE verifier of logins waiting for the provider callback
+CREATE TABLE IF NOT EXISTS oidc_logins (
+  state VARCHAR(64) PRIMARY KEY,
+  provider VARCHAR(50) NOT NULL,
+  nonce VARCHAR(64) NOT NULL,
+  verifier VARCHAR(128) NOT NULL,
+  expires_at DATETIME NOT NULL,
+  KEY idx_oidc_logins_expires (expires_at)
+);
+
+-- User MFA: TOTP secrets; enabled once the first code has been verified
+CREATE TABLE IF NOT EXISTS user_mfa (
+  user_id INT PRIMARY KEY,
+  secret VARCHAR(64) NOT NULL,                   -- base32 TOTP secret
+  enabled TINYINT(1) NOT NULL DEFAULT 0,
+  last_step BIGINT NOT NULL DEFAULT 0,           -- last accepted TOTP step, blocks code replay
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT fk_user_mfa_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- MFA recovery codes: sha256 hashes of single-use codes
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+  user_id INT NOT NULL,
+  code_hash CHAR(64) NOT NULL,
+  used_at TIMESTAMP NULL,
+  PRIMARY KEY (user_id, code_hash),
+  CONSTRAINT fk_mfa_recovery_codes_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- User sessions: one row per issued access token (jti)
+CREATE TABLE IF NOT EXISTS user_sessions (
+  id CHAR(36) PRIMARY KEY,                       -- token jti
+  user_id INT NOT NULL,
+  user_agent VARCHAR(255) NOT NULL DEFAULT '',
+  ip VARCHAR(64) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  last_seen_at DATETIME NOT NULL,
+  expires_at DATETIME NOT NULL,
+  revoked_at DATETIME NULL,
+  KEY idx_user_sessions_user (user_id, expires_at),
+  CONSTRAINT fk_user_sessions_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- API keys: hashed personal keys limited to a subset of the user's permissions
+CREATE TABLE IF NOT EXISTS api_keys (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  prefix CHAR(12) NOT NULL,                      -- agd_ + 8 hex, shown in listings
+  key_hash CHAR(64) NOT NULL,                    -- sha256 of the full key
+  permissions TEXT NOT NULL,                     -- comma-separated
+  expires_at DATETIME NULL,
+  last_used_at DATETIME NULL,
+  created_at DATETIME NOT NULL,
+  revoked_at DATETIME NULL,
+  UNIQUE KEY uq_api_keys_prefix (prefix),
+  KEY idx_api_keys_user (user_id),
+  CONSTRAINT fk_api_keys_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Idempotency keys: stored responses replayed to retries with the same key
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  user_id INT NOT NULL,
+  idem_key VARCHAR(255) NOT NULL,
+  fingerprint CHAR(64) NOT NULL,                 -- sha256 of method, path and body
+  status_code INT NOT NULL DEFAULT 0,            -- 0 while the request is running
+  content_type VARCHAR(100) NOT NULL DEFAULT '',
+  response MEDIUMBLOB NULL,
+  created_at DATETIME NOT NULL,                  -- a running request's lease on the key starts here
+  expires_at DATETIME NOT NULL,
+  PRIMARY KEY (user_id, idem_key),
+  KEY idx_idempotency_keys_expires (expires_at)
+);
+
+-- One-time tokens: jtis of password reset and email verification links
+CREATE TABLE IF NOT EXISTS one_time_tokens (
+  jti CHAR(36) PRIMARY KEY,
+  user_id INT NOT NULL,
+  purpose VARCHAR(30) NOT NULL,                  -- password_reset / verify_email
+  expires_at DATETIME NOT NULL,
+  used_at DATETIME NULL,
+  KEY idx_one_time_tokens_user (user_id, purpose),
+  CONSTRAINT fk_one_time_tokens_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Login attempts: failure counters per account (lower-cased email) and per client IP
+CREATE TABLE IF NOT EXISTS login_attempts (
+  scope VARCHAR(20) NOT NULL,                    -- account / ip, or booking / lookup_ip for guest booking lookups
+  subject VARCHAR(255) NOT NULL,
+  failures INT NOT NULL DEFAULT 0,
+  last_failure DATETIME NOT NULL,
+  locked_until DATETIME NULL,
+  PRIMARY KEY (scope, subject)
+);
+
+-- Login lockouts: audit trail of every lockout and who lifted it early
+CREATE TABLE IF NOT EXISTS login_lockouts (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  scope VARCHAR(20) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  failures INT NOT NULL,
+  ip VARCHAR(64) NOT NULL DEFAULT '',            -- client that triggered the lockout
+  locked_at DATETIME NOT NULL,
+  locked_until DATETIME NOT NULL,
+  unlocked_by INT NULL,
+  unlocked_at DATETIME NULL,
+  KEY idx_login_lockouts_subject (scope, subject)
+);
+
+-- Exchange rates: one unit of base buys rate units of quote
+CREATE TABLE IF NOT EXISTS exchange_rates (
+  base CHAR(3) NOT NULL,
+  quote CHAR(3) NOT NULL,
+  rate DECIMAL(20,10) NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (base, quote)
+);
+
+-- Hotel owners: partner and manager accounts linked to the hotels they may manage
+CREATE TABLE IF NOT EXISTS hotel_owners (
+  user_id INT NOT NULL,
+  hotel_id INT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (user_id, hotel_id),
+  CONSTRAINT fk_hotel_owners_user FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT fk_hotel_owners_hotel FOREIGN KEY (hotel_id) REFERENCES hotels(id)
+);
+
+-- Reviews table: guest reviews, held for moderation when flagged
+CREATE TABLE IF NOT EXISTS reviews (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  hotel_id INT NOT NULL,
+  user_id INT NOT NULL,
+  rating TINYINT NOT NULL,                        -- 1-5
+  title VARCHAR(255) NOT NULL DEFAULT '',
+  body TEXT NOT NULL,
+  status VARCHAR(50) NOT NULL DEFAULT 'pending',  -- pending / approved / rejected
+  flags VARCHAR(255) NOT NULL DEFAULT '',         -- filter flags, e.g. "profanity,email"
+  moderation_reason VARCHAR(500) NOT NULL DEFAULT '',
+  moderated_by INT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT fk_reviews_hotel FOREIGN KEY (hotel_id) REFERENCES hotels(id),
+  CONSTRAINT fk_reviews_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Review replies: one public reply from the hotel per review
+CREATE TABLE IF NOT EXISTS review_replies (
+  review_id INT PRIMARY KEY,
+  user_id INT NOT NULL,
+  body TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT fk_review_replies_review FOREIGN KEY (review_id) REFERENCES reviews(id),
+  CONSTRAINT fk_review_replies_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
 -- Seed users (including an admin)
 INSERT INTO users (name, email, password, role) VALUES
 ('Admin User', 'admin@agodrift.dev', 'adminpass', 'admin'),
-('Alice Traveler', 'alice@example.com', 'userpass', 'user');
+('Alice Traveler', 'alice@example.com', 'userpass', 'user'),
+('Paula Partner', 'partner@agodrift.dev', 'partnerpass', 'partner');
+
+-- Seed built-in roles (keep in sync with model.DefaultRoles)
+INSERT INTO roles (name, description, built_in) VALUES
+('admin', 'Full access', 1),
+('user', 'Traveler', 1),
+('manager', 'Hotel staff', 1),
+('partner', 'Hotel owner', 1),
+('support', 'Customer support', 1),
+('finance', 'Finance team', 1),
+('marketing', 'Marketing team', 1);
+
+INSERT INTO role_permissions (role, permission) VALUES
+('admin', '*'),
+('manager', 'reviews:reply'),
+('partner', 'hotels:write:own'),
+('partner', 'bookings:read:hotel'),
+('partner', 'reviews:reply'),
+('support', 'bookings:read:any'),
+('support', 'bookings:cancel:any'),
+('support', 'bookings:modify:any'),
+('support', 'reviews:moderate'),
+('support', 'users:manage'),
+('finance', 'bookings:read:any'),
+('finance', 'rates:write'),
+('finance', 'payments:manage'),
+('marketing', 'promos:manage');
+
+-- Built-in cancellation policies, kept in sync with model.DefaultCancellationPolicies
+INSERT INTO cancellation_policies (code, name, description, non_refundable, rules) VALUES
+('flexible', 'Flexible', 'Free cancellation until 48 hours before check-in, then the first night is charged.', 0,
+  '[{"hours_before": 48, "penalty_nights": 1}]'),
+('moderate', 'Moderate', 'Free cancellation until 7 days before check-in, then half the total is charged; the full total within 24 hours.', 0,
+  '[{"hours_before": 168, "penalty_percent": 50}, {"hours_before": 24, "penalty_percent": 100}]'),
+('non_refundable', 'Non-refundable', 'The full total is charged whenever the booking is cancelled.', 1, '[]');
+
+-- Invoice numbering starts at 1 for each kind
+INSERT INTO invoice_sequences (kind, last_number) VALUES ('invoice', 0), ('credit_note', 0);
+
+-- Sample promo code
+INSERT INTO promo_codes (code, description, kind, percent_off, max_uses_per_user, hotel_ids, destinations, active, created_at) VALUES
+('WELCOME10', '10% off your first stay', 'percent', 10, 1, '[]', '[]', 1, NOW());
 
 -- Seed hotels based on frontend demo data
 INSERT INTO hotels (
@@ -179,8 +606,17 @@ INSERT INTO hotels (
   'active'
 );
 
+-- Link the demo partner to the Amalfi Coast villa
+INSERT INTO hotel_owners (user_id, hotel_id) VALUES (3, 5);
+
+-- Seed exchange rates from the USD base currency
+INSERT INTO exchange_rates (base, quote, rate) VALUES
+('USD', 'THB', 36.5000000000),
+('USD', 'EUR', 0.9200000000);
+
 -- Seed example bookings linking users and hotels
 INSERT INTO bookings (
+  reference,
   user_id,
   hotel_id,
   check_in,
@@ -192,6 +628,7 @@ INSERT INTO bookings (
   status
 ) VALUES
 (
+  'AGD-7K3QX7',
   2,
   1,
   '2025-03-10',
@@ -203,6 +640,7 @@ INSERT INTO bookings (
   'confirmed'
 ),
 (
+  'AGD-4MB2WK',
   2,
   4,
   '2025-06-01',
@@ -214,6 +652,10 @@ INSERT INTO bookings (
   'pending'
 );
 
+-- Start the status history of the example bookings
+INSERT INTO booking_status_history (booking_id, status, changed_at)
+SELECT id, status, created_at FROM bookings;
+
 -- Useful indexes for query performance
 
 -- Users: lookup by email and role
@@ -230,3 +672,7 @@ CREATE INDEX IF NOT EXISTS idx_hotels_featured_status ON hotels (featured, statu
 CREATE INDEX IF NOT EXISTS idx_bookings_user_id ON bookings (user_id);
 CREATE INDEX IF NOT EXISTS idx_bookings_hotel_id ON bookings (hotel_id);
 CREATE INDEX IF NOT EXISTS idx_bookings_hotel_dates ON bookings (hotel_id, check_in, check_out);
+
+-- Reviews: public listing per hotel and moderation queue
+CREATE INDEX IF NOT EXISTS idx_reviews_hotel_status ON reviews (hotel_id, status);
+CREATE INDEX IF NOT EXISTS idx_reviews_status ON reviews (status, created_at);
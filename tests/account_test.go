@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+
+	"agodrift/internal/mail"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+var linkToken = regexp.MustCompile(`token=(\S+)`)
+
+func mailedToken(t *testing.T, buf *bytes.Buffer) string {
+	t.Helper()
+	m := linkToken.FindStringSubmatch(buf.String())
+	if m == nil {
+		t.Fatalf("no link in mail:\n%s", buf.String())
+	}
+	buf.Reset()
+	tok, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func newAccountService(buf *bytes.Buffer) (*service.AccountService, *service.AuthService, repository.UserRepository) {
+	users := repository.NewInMemoryUserRepo()
+	auth := service.NewAuthServiceWithKeys(users, service.NewHMACKeySet([]byte("test")))
+	svc := service.NewAccountServiceWith(users, repository.NewInMemoryOneTimeTokenRepo(), auth, mail.NewWriterMailer(buf, "test@agodrift.dev"), "https://app.test/", time.Now)
+	return svc, auth, users
+}
+
+func TestPasswordReset(t *testing.T) {
+	var buf bytes.Buffer
+	svc, auth, users := newAccountService(&buf)
+
+	if err := svc.RequestPasswordReset("nobody@example.com"); err != nil || buf.Len() != 0 {
+		t.Fatal("unknown email should be ignored silently")
+	}
+	if err := svc.RequestPasswordReset("Alice@Example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "To: alice@example.com") || !strings.Contains(buf.String(), "https://app.test/reset-password?token=") {
+		t.Fatalf("unexpected mail:\n%s", buf.String())
+	}
+	first := mailedToken(t, &buf)
+	if err := svc.RequestPasswordReset("alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	second := mailedToken(t, &buf)
+
+	if err := svc.ResetPassword(second, "short"); err != service.ErrWeakPassword {
+		t.Fatalf("want weak password error, got %v", err)
+	}
+	if err := svc.VerifyEmail(second); err != service.ErrInvalidAccountToken {
+		t.Fatal("reset token must not verify email")
+	}
+	if err := svc.ResetPassword(second, "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	if u, ok := auth.Authenticate("alice@example.com", "new-password"); !ok || u.Email != "alice@example.com" {
+		t.Fatal("new password not stored")
+	}
+	if err := svc.ResetPassword(second, "another-password"); err != service.ErrInvalidAccountToken {
+		t.Fatal("reset token must be single-use")
+	}
+	if err := svc.ResetPassword(first, "another-password"); err != service.ErrInvalidAccountToken {
+		t.Fatal("older reset tokens must die with the reset")
+	}
+
+	u, _ := users.GetByEmail("alice@example.com")
+	old := jwt.MapClaims{"uid": float64(u.ID), "jti": "x", "iat": float64(time.Now().Add(-time.Minute).Unix())}
+	if !auth.IsRevoked(old) {
+		t.Fatal("sessions issued before the reset should be revoked")
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	var buf bytes.Buffer
+	svc, _, users := newAccountService(&buf)
+
+	if err := svc.RequestEmailVerification("alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	tok := mailedToken(t, &buf)
+	if err := svc.ResetPassword(tok, "new-password"); err != service.ErrInvalidAccountToken {
+		t.Fatal("verification token must not reset passwords")
+	}
+	if err := svc.VerifyEmail(tok); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := users.GetByEmail("alice@example.com"); !u.EmailVerified {
+		t.Fatal("email not marked verified")
+	}
+	if err := svc.VerifyEmail(tok); err != service.ErrInvalidAccountToken {
+		t.Fatal("verification token must be single-use")
+	}
+	if err := svc.RequestEmailVerification("alice@example.com"); err != nil || buf.Len() != 0 {
+		t.Fatal("verified addresses should not get another mail")
+	}
+}
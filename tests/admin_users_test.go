@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestAdminUserManagement(t *testing.T) {
+	users := repository.NewInMemoryUserRepo()
+	for _, name := range []string{"Bob", "Carol", "Dave"} {
+		users.Create(model.User{Name: name, Email: name + "@example.com", Password: "pw", Role: model.RoleUser})
+	}
+	auth := service.NewAuthServiceWithKeys(users, service.NewHMACKeySet([]byte("test")))
+	svc := service.NewUserServiceWith(users, auth, service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()))
+	admin, _ := users.GetByEmail("admin@agodrift.dev")
+	alice, _ := users.GetByEmail("alice@example.com")
+
+	page, err := svc.Search(model.UserSearch{Role: model.RoleUser, PerPage: 2, Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 4 || len(page.Items) != 2 || page.Items[0].Name != "Carol" {
+		t.Fatalf("unexpected page %+v", page)
+	}
+	if page, _ := svc.Search(model.UserSearch{Query: "ALICE"}); page.Total != 1 || page.Items[0].ID != alice.ID || page.PerPage != 20 {
+		t.Fatalf("search by email failed: %+v", page)
+	}
+
+	if _, err := svc.SetRole(admin.ID, alice.ID, "wizard"); err != service.ErrUnknownRole {
+		t.Fatalf("want unknown role, got %v", err)
+	}
+	if _, err := svc.SetRole(admin.ID, admin.ID, model.RoleUser); err != service.ErrSelfAction {
+		t.Fatal("admins must not demote themselves")
+	}
+
+	token, _ := auth.StartSession(alice, time.Hour, service.SessionClient{})
+	claims := sessionClaims(t, auth, token)
+	if _, err := svc.SetRole(admin.ID, alice.ID, "Support"); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := auth.CurrentUser(claims); err != nil || u.Role != "support" {
+		t.Fatalf("role change should apply to existing tokens: %+v %v", u, err)
+	}
+
+	if _, err := svc.SetStatus(admin.ID, model.RoleAdmin, alice.ID, "banned"); err != service.ErrInvalidStatus {
+		t.Fatal("want invalid status")
+	}
+	if _, err := svc.SetStatus(admin.ID, model.RoleAdmin, alice.ID, model.UserDisabled); err != nil {
+		t.Fatal(err)
+	}
+	if !auth.IsRevoked(claims) {
+		t.Fatal("disabling should revoke sessions")
+	}
+	if _, err := auth.CurrentUser(claims); err != service.ErrAccountDisabled {
+		t.Fatal("disabled user's tokens must be refused")
+	}
+	if _, ok := auth.Authenticate("alice@example.com", "userpass"); ok {
+		t.Fatal("disabled user must not log in")
+	}
+	if _, err := auth.StartSession(alice, time.Hour, service.SessionClient{}); err != service.ErrAccountDisabled {
+		t.Fatal("disabled user must not get new sessions")
+	}
+
+	if _, err := svc.SetStatus(admin.ID, model.RoleAdmin, alice.ID, model.UserActive); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := auth.Authenticate("alice@example.com", "userpass"); !ok {
+		t.Fatal("re-enabled user should log in")
+	}
+	auth.StartSession(alice, time.Hour, service.SessionClient{})
+	auth.StartSession(alice, time.Hour, service.SessionClient{})
+	if n, err := svc.ForceLogout(admin.ID, model.RoleAdmin, alice.ID); err != nil || n != 2 {
+		t.Fatalf("want 2 sessions revoked, got %d %v", n, err)
+	}
+}
+
+func TestSupportCannotManageAdmins(t *testing.T) {
+	users := repository.NewInMemoryUserRepo()
+	auth := service.NewAuthServiceWithKeys(users, service.NewHMACKeySet([]byte("test")))
+	svc := service.NewUserServiceWith(users, auth, service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()))
+	admin, _ := users.GetByEmail("admin@agodrift.dev")
+	alice, _ := users.GetByEmail("alice@example.com")
+	support := users.Create(model.User{Name: "Sam", Email: "sam@example.com", Password: "pw", Role: "support"})
+	partner := users.Create(model.User{Name: "Pat", Email: "pat@example.com", Password: "pw", Role: model.RolePartner})
+
+	if _, err := svc.SetStatus(support.ID, "support", admin.ID, model.UserDisabled); err != service.ErrOutranked {
+		t.Fatalf("support must not disable admins, got %v", err)
+	}
+	if _, err := svc.ForceLogout(support.ID, "support", admin.ID); err != service.ErrOutranked {
+		t.Fatalf("support must not sign out admins, got %v", err)
+	}
+	if _, err := svc.SetStatus(support.ID, "support", partner.ID, model.UserDisabled); err != service.ErrOutranked {
+		t.Fatalf("support must not disable partners, whose permissions it lacks, got %v", err)
+	}
+	if _, err := svc.SetStatus(support.ID, "support", alice.ID, model.UserDisabled); err != nil {
+		t.Fatalf("support should disable customers: %v", err)
+	}
+	if _, err := svc.ForceLogout(admin.ID, model.RoleAdmin, support.ID); err != nil {
+		t.Fatalf("admins manage everyone: %v", err)
+	}
+}
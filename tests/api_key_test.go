@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"agodrift/internal/middleware"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestAPIKeys(t *testing.T) {
+	now := time.Now()
+	users := repository.NewInMemoryUserRepo()
+	rbac := service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo())
+	keys := service.NewAPIKeyServiceWith(repository.NewInMemoryAPIKeyRepo(), users, rbac, func() time.Time { return now })
+	admin, _ := users.GetByEmail("admin@agodrift.dev")
+	alice, _ := users.GetByEmail("alice@example.com")
+
+	if _, err := keys.Create(alice.ID, alice.Role, "sync", []string{model.PermRatesWrite}, nil); err == nil {
+		t.Fatal("key must not exceed the user's permissions")
+	}
+	past := now.Add(-time.Minute)
+	if _, err := keys.Create(admin.ID, admin.Role, "sync", []string{model.PermRatesWrite}, &past); err == nil {
+		t.Fatal("expiry in the past accepted")
+	}
+
+	expires := now.Add(24 * time.Hour)
+	created, err := keys.Create(admin.ID, admin.Role, "channel manager", []string{"hotels:write:own", model.PermRatesWrite}, &expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Key, created.Prefix+"_") || len(created.Prefix) != 12 || created.Hash == created.Key {
+		t.Fatalf("unexpected key %q prefix %q", created.Key, created.Prefix)
+	}
+
+	key, u, err := keys.Authenticate(created.Key)
+	if err != nil || u.ID != admin.ID {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if _, _, err := keys.Authenticate(created.Prefix + "_" + strings.Repeat("0", 64)); err != service.ErrInvalidAPIKey {
+		t.Fatal("wrong secret accepted")
+	}
+	list, _ := keys.List(admin.ID)
+	if len(list) != 1 || list[0].LastUsedAt == nil {
+		t.Fatalf("last use not recorded: %+v", list)
+	}
+
+	// the key narrows the admin's wildcard to its own permissions
+	role := service.APIKeyRole(admin.Role, key.ID)
+	if !rbac.Has(admin.ID, role, model.PermRatesWrite) || !rbac.Has(admin.ID, role, model.PermHotelsWriteOwn) {
+		t.Fatal("key permission denied")
+	}
+	if rbac.Has(admin.ID, role, model.PermRBACManage) || rbac.Has(admin.ID, role, model.PermHotelsWrite) {
+		t.Fatal("key exceeded its scope")
+	}
+
+	now = now.Add(25 * time.Hour)
+	if _, _, err := keys.Authenticate(created.Key); err != service.ErrInvalidAPIKey {
+		t.Fatal("expired key accepted")
+	}
+	if rbac.Has(admin.ID, role, model.PermRatesWrite) {
+		t.Fatal("expired key still grants permissions")
+	}
+
+	other, _ := keys.Create(admin.ID, admin.Role, "back office", []string{model.PermUsersManage}, nil)
+	if err := keys.Revoke(alice.ID, other.ID); err != repository.ErrAPIKeyNotFound {
+		t.Fatal("users must not revoke each other's keys")
+	}
+	if err := keys.Revoke(admin.ID, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := keys.Authenticate(other.Key); err != service.ErrInvalidAPIKey {
+		t.Fatal("revoked key accepted")
+	}
+}
+
+func TestAPIKeyRefusedOnUnscopedRoutes(t *testing.T) {
+	users := repository.NewInMemoryUserRepo()
+	rbac := service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo())
+	keys := service.NewAPIKeyServiceWith(repository.NewInMemoryAPIKeyRepo(), users, rbac, time.Now)
+	prevAuth, prevRBAC, prevKeys := service.DefaultAuth, service.DefaultRBAC, service.DefaultAPIKeys
+	service.DefaultAuth = service.NewAuthServiceWithKeys(users, service.NewHMACKeySet([]byte("test")))
+	service.DefaultRBAC, service.DefaultAPIKeys = rbac, keys
+	t.Cleanup(func() {
+		service.DefaultAuth, service.DefaultRBAC, service.DefaultAPIKeys = prevAuth, prevRBAC, prevKeys
+	})
+
+	// routed as in api.NewApp
+	app := fiber.New()
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) }
+	app.Post("/api/v1/bookings", middleware.JWTConfig("test"), ok)
+	app.Get("/api/v1/admin/exchange-rates", middleware.JWTConfig("test", model.PermRatesWrite), middleware.RequirePermission(model.PermRatesWrite), ok)
+	app.Get("/api/v1/partner/bookings", middleware.JWTConfig("test", model.PermBookingsReadHotel), middleware.RequirePermission(model.PermBookingsReadHotel), ok)
+
+	admin, _ := users.GetByEmail("admin@agodrift.dev")
+	readOnly, err := keys.Create(admin.ID, admin.Role, "reporting", []string{model.PermBookingsReadHotel}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/api/v1/bookings", fiber.StatusForbidden},
+		{http.MethodGet, "/api/v1/admin/exchange-rates", fiber.StatusForbidden},
+		{http.MethodGet, "/api/v1/partner/bookings", fiber.StatusNoContent},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("X-API-Key", readOnly.Key)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.path, resp.StatusCode, tc.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestNewBookingDetail(t *testing.T) {
+	in, _ := time.Parse("2006-01-02", "2026-11-01")
+	created := in.AddDate(0, -1, 0)
+	display := 45123
+	b := model.Booking{
+		ID: 9, HotelID: 4, CheckIn: in, CheckOut: in.AddDate(0, 0, 3), Rooms: 2,
+		TotalPriceCents: 45000 * 3 * 2, Currency: "USD",
+		DisplayCurrency: "THB", DisplayTotalCents: &display, ExchangeRate: "35.5",
+		Status: model.BookingCancelled, CreatedAt: created,
+	}
+	hotel := &model.HotelSummary{ID: 4, Name: "Amalfi Coast Villa"}
+
+	d := service.NewBookingDetail(b, hotel, nil)
+	if d.Nights != 3 || d.Price.NightlyPriceCents != 45000 || d.Price.Rooms != 2 || d.Price.DisplayCurrency != "THB" {
+		t.Fatalf("unexpected breakdown %+v", d.Price)
+	}
+	if d.Hotel.Name != "Amalfi Coast Villa" {
+		t.Fatalf("hotel not embedded: %+v", d.Hotel)
+	}
+	// bookings from before status history get their current status
+	if len(d.StatusHistory) != 1 || d.StatusHistory[0].Status != model.BookingCancelled || !d.StatusHistory[0].ChangedAt.Equal(created) {
+		t.Fatalf("unexpected history %+v", d.StatusHistory)
+	}
+
+	history := []model.BookingStatusChange{
+		{Status: model.BookingPending, ChangedAt: created},
+		{Status: model.BookingCancelled, ChangedAt: created.Add(time.Hour)},
+	}
+	if d := service.NewBookingDetail(b, nil, history); len(d.StatusHistory) != 2 || d.Hotel != nil {
+		t.Fatalf("history not kept: %+v", d.StatusHistory)
+	}
+}
+
+// batchOnlyRooms fails the test when a hotel is looked up on its own.
+type batchOnlyRooms struct {
+	repository.RoomRepository
+	t       *testing.T
+	batches int
+}
+
+func (r *batchOnlyRooms) Get(id int) (model.Room, bool) {
+	r.t.Errorf("hotel %d looked up on its own", id)
+	return r.RoomRepository.Get(id)
+}
+
+func (r *batchOnlyRooms) GetMany(ids []int) (map[int]model.Room, error) {
+	r.batches++
+	return r.RoomRepository.GetMany(ids)
+}
+
+func TestListMineLoadsHotelsOnce(t *testing.T) {
+	in := time.Now().AddDate(0, 1, 0)
+	bookings := newStubBookings(
+		model.Booking{ID: 1, UserID: 2, HotelID: 1, CheckIn: in, CheckOut: in.AddDate(0, 0, 1), Rooms: 1, Status: model.BookingConfirmed},
+		model.Booking{ID: 2, UserID: 2, HotelID: 1, CheckIn: in, CheckOut: in.AddDate(0, 0, 2), Rooms: 1, Status: model.BookingCancelled},
+		model.Booking{ID: 3, UserID: 2, HotelID: 99, CheckIn: in, CheckOut: in.AddDate(0, 0, 1), Rooms: 1, Status: model.BookingPending},
+	)
+	rooms := &batchOnlyRooms{RoomRepository: repository.NewInMemoryRoomRepo(), t: t}
+	s := service.NewBookingServiceWith(bookings, rooms, repository.NewInMemoryUserRepo(), repository.NewInMemoryHotelOwnerRepo(),
+		service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()), nil, repository.NewInMemoryCancellationPolicyRepo(), nil, nil, nil, nil, nil)
+
+	page, err := s.ListMine(model.BookingSearch{UserID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rooms.batches != 1 || len(page.Items) != 3 {
+		t.Fatalf("%d hotel queries for %d bookings", rooms.batches, len(page.Items))
+	}
+	if page.Items[0].Hotel == nil || page.Items[0].Hotel.Name != "Demo Hotel" || page.Items[1].Hotel == nil || page.Items[2].Hotel != nil {
+		t.Fatalf("unexpected hotels %+v %+v %+v", page.Items[0].Hotel, page.Items[1].Hotel, page.Items[2].Hotel)
+	}
+	if page.Items[0].Cancellation == nil || page.Items[1].Cancellation != nil {
+		t.Fatalf("cancellation charge only for live bookings")
+	}
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"agodrift/internal/model"
+	"agodrift/internal/service"
+)
+
+func TestCheckGuestDetails(t *testing.T) {
+	age := 7
+	d, err := service.CheckGuestDetails(model.GuestDetails{
+		LeadGuest: &model.GuestContact{Name: " Alice Traveler ", Email: "alice@example.com", Phone: "+66 81 234 5678"},
+		Guests: []model.BookingGuest{
+			{Name: "Alice Traveler"},
+			{Name: "Tom", Child: true, Age: &age},
+		},
+		SpecialRequests: " High floor, late check-in ",
+		ArrivalTime:     "22:30",
+	}, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.LeadGuest.Name != "Alice Traveler" || d.SpecialRequests != "High floor, late check-in" || len(d.Guests) != 2 {
+		t.Fatalf("not normalized: %+v", d)
+	}
+
+	// details are optional
+	d, err = service.CheckGuestDetails(model.GuestDetails{}, 1, 0)
+	if err != nil || d.Guests == nil {
+		t.Fatalf("empty details: %+v %v", d, err)
+	}
+
+	old := 18
+	_, err = service.CheckGuestDetails(model.GuestDetails{
+		LeadGuest: &model.GuestContact{Name: "Bob", Email: "not an email"},
+		Guests: []model.BookingGuest{
+			{Name: "Bob"}, {Name: "Carol"},
+			{Name: "Dan", Child: true},
+			{Name: "Eve", Child: true, Age: &old},
+		},
+		ArrivalTime: "25:00",
+	}, 1, 1)
+	var ve *service.ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("want validation error, got %v", err)
+	}
+	// email, two child ages, too many adults, too many children, arrival time
+	if len(ve.Problems) != 6 {
+		t.Fatalf("unexpected problems %q", ve.Problems)
+	}
+}
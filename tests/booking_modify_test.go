@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/payment"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestBookingUpdateApply(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	today := day("2026-10-19")
+	b := model.Booking{CheckIn: day("2026-11-01"), CheckOut: day("2026-11-03"), Adults: 2, Rooms: 1, TotalPriceCents: 40000}
+
+	out := day("2026-11-05")
+	rooms := 2
+	terms, err := service.BookingUpdate{CheckOut: &out, Rooms: &rooms}.Apply(b, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !terms.CheckIn.Equal(b.CheckIn) || !terms.CheckOut.Equal(out) || terms.Rooms != 2 || terms.Adults != 2 {
+		t.Fatalf("unexpected terms %+v", terms)
+	}
+
+	past := day("2026-10-01")
+	zero := 0
+	_, err = service.BookingUpdate{CheckIn: &past, Adults: &zero}.Apply(b, today)
+	var ve *service.ValidationError
+	if !errors.As(err, &ve) || len(ve.Problems) != 2 {
+		t.Fatalf("want past check_in and adults problems, got %v", err)
+	}
+
+	in := day("2026-11-04")
+	if _, err := (service.BookingUpdate{CheckIn: &in}).Apply(b, today); !errors.As(err, &ve) {
+		t.Fatal("check_in after check_out accepted")
+	}
+	same := b.CheckIn
+	if _, err := (service.BookingUpdate{CheckIn: &same}).Apply(b, today); !errors.As(err, &ve) {
+		t.Fatal("empty change accepted")
+	}
+
+	// named guests are not dropped silently when the party shrinks
+	b.Guests = []model.BookingGuest{{Name: "Ann"}, {Name: "Ben"}}
+	one := 1
+	_, err = service.BookingUpdate{Adults: &one}.Apply(b, today)
+	if !errors.As(err, &ve) || len(ve.Problems) != 1 || !strings.Contains(ve.Problems[0], "2 adults") {
+		t.Fatalf("want too many named adults, got %v", err)
+	}
+}
+
+func TestModifyPointsConfirmedBooking(t *testing.T) {
+	in := time.Now().AddDate(0, 1, 0).Truncate(24 * time.Hour)
+	// 2 nights at 150.00, paid in full with points and confirmed at booking
+	b := model.Booking{ID: 1, UserID: 2, HotelID: 1, CheckIn: in, CheckOut: in.AddDate(0, 0, 2), Adults: 2, Rooms: 1,
+		TotalPriceCents: 30000, PointsRedeemed: 30000, PointsValueCents: 30000, Currency: "USD", Status: model.BookingConfirmed}
+	bookings := newStubBookings(b)
+	perms := service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo())
+	payments := service.NewPaymentServiceWith(repository.NewInMemoryPaymentRepo(), bookings, payment.NewFakeGateway("whsec"), perms, nil, time.Now)
+	s := service.NewBookingServiceWith(bookings, repository.NewInMemoryRoomRepo(), repository.NewInMemoryUserRepo(), repository.NewInMemoryHotelOwnerRepo(),
+		perms, nil, repository.NewInMemoryCancellationPolicyRepo(), nil, nil, payments, nil, nil)
+
+	// a longer stay would be confirmed without being paid for
+	out := in.AddDate(0, 0, 3)
+	if _, _, err := s.Modify(2, model.RoleUser, 1, service.BookingUpdate{CheckOut: &out}); err != service.ErrBookingPaid {
+		t.Fatalf("longer stay: %v", err)
+	}
+	if got, _ := bookings.GetByID(1); !got.CheckOut.Equal(b.CheckOut) || got.TotalPriceCents != 30000 {
+		t.Fatalf("booking changed: %+v", got)
+	}
+
+	// changes the points still cover go through
+	adults := 1
+	got, change, err := s.Modify(2, model.RoleUser, 1, service.BookingUpdate{Adults: &adults})
+	if err != nil || got.Adults != 1 || got.AmountDueCents() != 0 || change.PriceDeltaCents != 0 {
+		t.Fatalf("same price change: %+v %+v %v", got, change, err)
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"sort"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+)
+
+// stubBookings keeps bookings in a map for services that read or update a
+// few bookings. The embedded interface is nil, so only the methods below
+// may be called.
+type stubBookings struct {
+	repository.BookingRepository
+	byID map[int]model.Booking
+}
+
+func newStubBookings(list ...model.Booking) *stubBookings {
+	s := &stubBookings{byID: map[int]model.Booking{}}
+	for _, b := range list {
+		s.byID[b.ID] = b
+	}
+	return s
+}
+
+func (s *stubBookings) GetByID(id int) (model.Booking, error) {
+	b, ok := s.byID[id]
+	if !ok {
+		return model.Booking{}, repository.ErrBookingNotFound
+	}
+	return b, nil
+}
+
+func (s *stubBookings) Transition(id int, from, to string) (bool, error) {
+	b, ok := s.byID[id]
+	if !ok || b.Status != from {
+		return false, nil
+	}
+	b.Status = to
+	s.byID[id] = b
+	return true, nil
+}
+
+func (s *stubBookings) Cancel(id int, today time.Time, returnPoints int) (model.Booking, error) {
+	b, ok := s.byID[id]
+	if !ok {
+		return model.Booking{}, repository.ErrBookingNotFound
+	}
+	if b.Status == model.BookingCancelled {
+		return model.Booking{}, repository.ErrBookingCancelled
+	}
+	b.Status = model.BookingCancelled
+	s.byID[id] = b
+	return b, nil
+}
+
+// ListCompleted returns the bookings checked out by day, by id.
+func (s *stubBookings) ListCompleted(day time.Time) ([]model.Booking, error) {
+	out := make([]model.Booking, 0)
+	for _, b := range s.byID {
+		if !b.CheckOut.After(day) {
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// StatusHistory returns no history, so details fall back to the current status.
+func (s *stubBookings) StatusHistory(ids []int) (map[int][]model.BookingStatusChange, error) {
+	return map[int][]model.BookingStatusChange{}, nil
+}
+
+// Search returns every booking as one page, by id.
+func (s *stubBookings) Search(f model.BookingSearch) ([]model.Booking, int, error) {
+	out := make([]model.Booking, 0, len(s.byID))
+	for _, b := range s.byID {
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, len(out), nil
+}
+
+// Modify reprices the booking at its current nightly rate per room and, like
+// the MySQL repository, refuses to leave a confirmed booking with an amount
+// due.
+func (s *stubBookings) Modify(id int, changedBy int, terms model.BookingTerms, exchangeRate string) (model.Booking, model.BookingChange, error) {
+	b, ok := s.byID[id]
+	if !ok {
+		return model.Booking{}, model.BookingChange{}, repository.ErrBookingNotFound
+	}
+	old := b.Terms()
+	nightly := b.TotalPriceCents / (model.Nights(b.CheckIn, b.CheckOut) * b.Rooms)
+	b.CheckIn, b.CheckOut = terms.CheckIn, terms.CheckOut
+	b.Adults, b.Children, b.Rooms = terms.Adults, terms.Children, terms.Rooms
+	b.TotalPriceCents = nightly * model.Nights(b.CheckIn, b.CheckOut) * b.Rooms
+	if b.PointsValueCents > b.TotalPriceCents {
+		return model.Booking{}, model.BookingChange{}, repository.ErrPointsExceedTotal
+	}
+	if b.Status == model.BookingConfirmed && b.AmountDueCents() > 0 {
+		return model.Booking{}, model.BookingChange{}, repository.ErrConfirmedAmountDue
+	}
+	s.byID[id] = b
+	return b, model.BookingChange{BookingID: id, ChangedBy: changedBy, Old: old, New: b.Terms(), PriceDeltaCents: b.TotalPriceCents - old.TotalPriceCents, Currency: b.Currency}, nil
+}
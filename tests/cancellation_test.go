@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/payment"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func defaultPolicy(t *testing.T, code string) model.CancellationPolicy {
+	t.Helper()
+	for _, p := range model.DefaultCancellationPolicies {
+		if p.Code == code {
+			return p
+		}
+	}
+	t.Fatalf("no default policy %q", code)
+	return model.CancellationPolicy{}
+}
+
+func TestCancellationCharge(t *testing.T) {
+	checkIn := time.Date(2026, 11, 10, 0, 0, 0, 0, time.UTC)
+	// 4 nights, 2 rooms at 10000 a night
+	b := model.Booking{CheckIn: checkIn, CheckOut: checkIn.AddDate(0, 0, 4), Rooms: 2, TotalPriceCents: 80000, Currency: "USD"}
+	before := func(hours int) time.Time { return checkIn.Add(-time.Duration(hours) * time.Hour) }
+
+	cases := []struct {
+		policy  string
+		at      time.Time
+		penalty int
+	}{
+		{"flexible", before(49), 0},
+		{"flexible", before(48), 0},
+		{"flexible", before(47), 20000}, // one night of both rooms
+		{"flexible", checkIn.Add(12 * time.Hour), 20000},
+		{"moderate", before(200), 0},
+		{"moderate", before(100), 40000},
+		{"moderate", before(2), 80000},
+		{"non_refundable", before(1000), 80000},
+	}
+	for _, tc := range cases {
+		c := defaultPolicy(t, tc.policy).Charge(b, tc.at)
+		if c.PenaltyCents != tc.penalty || c.RefundCents != 80000-tc.penalty || c.Currency != "USD" || c.Policy != tc.policy {
+			t.Errorf("%s at %s: got %+v, want penalty %d", tc.policy, tc.at, c, tc.penalty)
+		}
+	}
+
+	if until := defaultPolicy(t, "flexible").FreeUntil(checkIn); until == nil || !until.Equal(before(48)) {
+		t.Fatalf("flexible free until %v", until)
+	}
+	if until := defaultPolicy(t, "non_refundable").FreeUntil(checkIn); until != nil {
+		t.Fatalf("non-refundable is free until %v", until)
+	}
+
+	// nights never exceed the stay, and bookings without a policy cancel free
+	short := model.Booking{CheckIn: checkIn, CheckOut: checkIn.AddDate(0, 0, 1), Rooms: 1, TotalPriceCents: 10000}
+	p := model.CancellationPolicy{Code: "strict", Rules: []model.CancellationRule{{HoursBefore: 72, PenaltyNights: 3}}}
+	if c := p.Charge(short, before(1)); c.PenaltyCents != 10000 || c.RefundCents != 0 {
+		t.Fatalf("penalty exceeds the total: %+v", c)
+	}
+	if c := short.CancellationCharge(before(1)); c.PenaltyCents != 0 || c.RefundCents != 10000 {
+		t.Fatalf("booking without policy charged: %+v", c)
+	}
+}
+
+func TestCheckCancellationPolicy(t *testing.T) {
+	p, problems := service.CheckCancellationPolicy(model.CancellationPolicy{
+		Code: " Strict ", Name: "Strict",
+		Rules: []model.CancellationRule{{HoursBefore: 24, PenaltyPercent: 100}, {HoursBefore: 336, PenaltyNights: 1}},
+	})
+	if len(problems) > 0 {
+		t.Fatalf("valid policy rejected: %v", problems)
+	}
+	if p.Code != "strict" || p.Rules[0].HoursBefore != 336 {
+		t.Fatalf("policy not normalized: %+v", p)
+	}
+
+	_, problems = service.CheckCancellationPolicy(model.CancellationPolicy{
+		Code: "x", NonRefundable: true,
+		Rules: []model.CancellationRule{{HoursBefore: -1, PenaltyPercent: 150}, {HoursBefore: 24}},
+	})
+	// code, name, non-refundable with rules, negative hours, percent, empty penalty
+	if len(problems) != 6 {
+		t.Fatalf("want 6 problems, got %v", problems)
+	}
+}
+
+func TestSetHotelCancellationPolicy(t *testing.T) {
+	owners := repository.NewInMemoryHotelOwnerRepo()
+	policies := repository.NewInMemoryCancellationPolicyRepo()
+	s := service.NewCancellationServiceWith(policies, owners, service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()))
+	owners.Assign(42, 1)
+
+	if p, _ := policies.ForHotel(1); p.Code != model.DefaultCancellationPolicy {
+		t.Fatalf("default policy %q", p.Code)
+	}
+	if _, err := s.SetHotelPolicy(42, model.RolePartner, 2, "moderate"); err != service.ErrForbidden {
+		t.Fatalf("partner set another hotel's policy: %v", err)
+	}
+	if _, err := s.SetHotelPolicy(42, model.RolePartner, 1, "lenient"); err != repository.ErrPolicyNotFound {
+		t.Fatalf("unknown policy: %v", err)
+	}
+	if _, err := s.SetHotelPolicy(42, model.RolePartner, 1, "Moderate"); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := policies.ForHotel(1); p.Code != "moderate" {
+		t.Fatalf("hotel policy %q", p.Code)
+	}
+
+	var ve *service.ValidationError
+	if _, err := s.Put(model.CancellationPolicy{Code: "bad"}); !errors.As(err, &ve) {
+		t.Fatalf("invalid policy saved: %v", err)
+	}
+}
+
+func TestSettleCancellation(t *testing.T) {
+	booking := model.Booking{ID: 1, UserID: 7, Status: model.BookingPending, TotalPriceCents: 40000, Currency: "USD"}
+	perms := service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo())
+
+	// authorized, nothing owed: the hold is released
+	svc := service.NewPaymentServiceWith(repository.NewInMemoryPaymentRepo(), newStubBookings(booking), payment.NewFakeGateway("whsec"), perms, nil, time.Now)
+	p, _ := svc.Pay(7, 1, "tok_visa")
+	changed, err := svc.SettleCancellation(1, 0)
+	if err != nil || len(changed) != 1 || changed[0].Status != model.PaymentVoided {
+		t.Fatalf("void: %+v %v", changed, err)
+	}
+
+	// authorized with a penalty: only the penalty is captured
+	svc = service.NewPaymentServiceWith(repository.NewInMemoryPaymentRepo(), newStubBookings(booking), payment.NewFakeGateway("whsec"), perms, nil, time.Now)
+	svc.Pay(7, 1, "tok_visa")
+	changed, err = svc.SettleCancellation(1, 10000)
+	if err != nil || len(changed) != 1 || changed[0].Status != model.PaymentCaptured || changed[0].CapturedCents != 10000 {
+		t.Fatalf("partial capture: %+v %v", changed, err)
+	}
+
+	// captured: refunded down to the penalty
+	svc = service.NewPaymentServiceWith(repository.NewInMemoryPaymentRepo(), newStubBookings(booking), payment.NewFakeGateway("whsec"), perms, nil, time.Now)
+	p, _ = svc.Pay(7, 1, "tok_visa")
+	svc.Capture(p.ID)
+	changed, err = svc.SettleCancellation(1, 10000)
+	if err != nil || len(changed) != 1 || changed[0].RefundedCents != 30000 || changed[0].Status != model.PaymentCaptured {
+		t.Fatalf("partial refund: %+v %v", changed, err)
+	}
+	// settling again changes nothing
+	if changed, err := svc.SettleCancellation(1, 10000); err != nil || len(changed) != 0 {
+		t.Fatalf("second settlement: %+v %v", changed, err)
+	}
+}
+
+func TestCancelIssuesCreditNote(t *testing.T) {
+	b := invoiceBooking(1, model.BookingConfirmed)
+	b.CheckIn = time.Now().AddDate(0, 1, 0).Truncate(24 * time.Hour)
+	b.CheckOut = b.CheckIn.AddDate(0, 0, 3)
+	bookings := newStubBookings(b)
+	rooms := repository.NewInMemoryRoomRepo()
+	users := repository.NewInMemoryUserRepo()
+	owners := repository.NewInMemoryHotelOwnerRepo()
+	perms := service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo())
+	payments := service.NewPaymentServiceWith(repository.NewInMemoryPaymentRepo(), bookings, payment.NewFakeGateway("whsec"), perms, nil, time.Now)
+	invoices := service.NewInvoiceServiceWith(repository.NewInMemoryInvoiceRepo(), bookings, rooms, users, owners, perms, invoiceIssuer, time.Now)
+	s := service.NewBookingServiceWith(bookings, rooms, users, owners, perms, nil, repository.NewInMemoryCancellationPolicyRepo(),
+		nil, nil, payments, invoices, nil)
+
+	_, charge, err := s.Cancel(2, model.RoleUser, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charge.RefundCents == 0 {
+		t.Fatalf("expected a refund, got %+v", charge)
+	}
+	cn, err := invoices.CreditNote(2, model.RoleUser, 1)
+	if err != nil {
+		t.Fatalf("no credit note after cancelling: %v", err)
+	}
+	if cn.InvoiceNumber != "INV-000001" || cn.TotalCents != -charge.RefundCents {
+		t.Fatalf("unexpected credit note %+v for %+v", cn, charge)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"testing"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestCurrencyConversion(t *testing.T) {
+	s := service.NewCurrencyServiceWithRepo(repository.NewInMemoryExchangeRateRepo(), "USD")
+	if err := s.SetRates([]model.ExchangeRate{
+		{Base: "usd", Quote: "thb", Rate: "36.5"},
+		{Base: "USD", Quote: "EUR", Rate: "0.92"},
+		{Base: "USD", Quote: "JPY", Rate: "150.125"},
+	}); err != nil {
+		t.Fatalf("set rates: %v", err)
+	}
+	cases := []struct {
+		amount   int
+		from, to string
+		want     int
+	}{
+		{18900, "USD", "THB", 689850}, // direct
+		{689850, "THB", "USD", 18900}, // inverse
+		{1, "USD", "EUR", 1},          // 0.92 rounds half away from zero
+		{18900, "USD", "JPY", 28374},  // 189 * 150.125 = 28373.625
+		{100000, "THB", "EUR", 2521},  // cross rate via USD: 1000 / 36.5 * 0.92
+		{-18900, "USD", "THB", -689850},
+	}
+	for _, tc := range cases {
+		got, _, err := s.Convert(tc.amount, tc.from, tc.to)
+		if err != nil {
+			t.Fatalf("%s->%s: %v", tc.from, tc.to, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%d %s->%s: expected %d, got %d", tc.amount, tc.from, tc.to, tc.want, got)
+		}
+	}
+	if _, _, err := s.Convert(100, "USD", "GBP"); err != service.ErrNoExchangeRate {
+		t.Fatalf("expected ErrNoExchangeRate, got %v", err)
+	}
+}
+
+// countingRates counts single-pair lookups on top of the in-memory repo.
+type countingRates struct {
+	repository.ExchangeRateRepository
+	gets int
+}
+
+func (r *countingRates) Get(base string, quote string) (model.ExchangeRate, bool) {
+	r.gets++
+	return r.ExchangeRateRepository.Get(base, quote)
+}
+
+func TestConvertRoomsLoadsRatesOnce(t *testing.T) {
+	repo := &countingRates{ExchangeRateRepository: repository.NewInMemoryExchangeRateRepo()}
+	s := service.NewCurrencyServiceWithRepo(repo, "USD")
+	if err := s.SetRates([]model.ExchangeRate{{Base: "USD", Quote: "THB", Rate: "36.5"}, {Base: "USD", Quote: "EUR", Rate: "0.92"}}); err != nil {
+		t.Fatalf("set rates: %v", err)
+	}
+	rooms := []model.Room{
+		{PriceCents: 365000, Currency: "THB"},
+		{PriceCents: 10000, Currency: "EUR"},
+		{PriceCents: 10000, Currency: "USD"},
+	}
+	if err := s.ConvertRooms(rooms, "usd"); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if repo.gets != 0 {
+		t.Fatalf("expected rates from one list query, got %d lookups", repo.gets)
+	}
+	if rooms[0].PriceCents != 10000 || rooms[1].PriceCents != 10870 || rooms[2].PriceCents != 10000 || rooms[1].Currency != "USD" {
+		t.Fatalf("unexpected prices %+v", rooms)
+	}
+	if err := s.ConvertRooms(rooms, "XYZ"); err != service.ErrNoExchangeRate {
+		t.Fatalf("want no exchange rate, got %v", err)
+	}
+}
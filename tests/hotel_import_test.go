@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+const hotelsCSV = `external_ref,name,location,destination,price_cents,amenities,rooms_total
+P-1,Sea Breeze,"Patong, Phuket",Phuket,250000,"Pool,Wi-Fi",20
+P-2,Old Town Inn,"Chiang Mai",Chiang Mai,120000,Breakfast,8
+`
+
+func TestImportHotelsDryRunAndUpsert(t *testing.T) {
+	s := service.NewRoomServiceWithRepo(repository.NewInMemoryRoomRepo())
+	before := len(s.List())
+
+	report, err := s.Import(service.FormatCSV, strings.NewReader(hotelsCSV), true, 0)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if report.Valid != 2 || len(report.Errors) != 0 || report.Imported {
+		t.Fatalf("unexpected dry run report: %+v", report)
+	}
+	if len(s.List()) != before {
+		t.Fatalf("dry run must not write")
+	}
+
+	report, _ = s.Import(service.FormatCSV, strings.NewReader(hotelsCSV), false, 1)
+	if report.Created != 2 || report.Batches != 2 || !report.Imported {
+		t.Fatalf("unexpected import report: %+v", report)
+	}
+
+	// re-importing the export updates rows instead of duplicating them
+	var buf bytes.Buffer
+	if err := s.Export(service.FormatJSON, &buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	report, _ = s.Import(service.FormatJSON, &buf, false, 0)
+	if report.Updated != before+2 || report.Created != 0 {
+		t.Fatalf("unexpected round-trip report: %+v", report)
+	}
+	if len(s.List()) != before+2 {
+		t.Fatalf("expected %d hotels, got %d", before+2, len(s.List()))
+	}
+
+	// the CSV export carries the id too
+	buf.Reset()
+	if err := s.Export(service.FormatCSV, &buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	report, _ = s.Import(service.FormatCSV, &buf, false, 0)
+	if report.Updated != before+2 || report.Created != 0 || len(s.List()) != before+2 {
+		t.Fatalf("unexpected CSV round-trip report: %+v", report)
+	}
+}
+
+func TestImportHotelsRejectsUnknownID(t *testing.T) {
+	s := service.NewRoomServiceWithRepo(repository.NewInMemoryRoomRepo())
+	in := "id,name,location,destination,price_cents\n99999,Ghost,X,Y,100\n"
+	report, err := s.Import(service.FormatCSV, strings.NewReader(in), false, 0)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if report.Imported || len(report.Errors) != 1 || !strings.Contains(report.Errors[0].Errors[0], "does not match a hotel") {
+		t.Fatalf("expected an unknown id error, got %+v", report)
+	}
+}
+
+func TestImportHotelsReportsRowErrors(t *testing.T) {
+	s := service.NewRoomServiceWithRepo(repository.NewInMemoryRoomRepo())
+	in := "external_ref,name,location,destination,price_cents\nA,Ok,X,Y,100\nB,,X,Y,abc\nA,Dup,X,Y,100\n"
+	report, err := s.Import(service.FormatCSV, strings.NewReader(in), false, 0)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if report.Imported || len(report.Errors) != 2 {
+		t.Fatalf("expected 2 row errors and no import, got %+v", report)
+	}
+	if report.Errors[0].Row != 2 || report.Errors[1].Row != 3 {
+		t.Fatalf("unexpected rows: %+v", report.Errors)
+	}
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	now := time.Now()
+	s := service.NewIdempotencyServiceWith(repository.NewInMemoryIdempotencyRepo(), time.Hour, func() time.Time { return now })
+	body := []byte(`{"hotel_id":1,"check_in":"2026-11-01","check_out":"2026-11-03"}`)
+	fp := service.Fingerprint("POST", "/api/v1/bookings", body)
+
+	rec, err := s.Begin(2, "retry-1", fp)
+	if err != nil || rec != nil {
+		t.Fatalf("first request must run: %v %v", rec, err)
+	}
+	if _, err := s.Begin(2, "retry-1", fp); err != service.ErrIdempotencyInProgress {
+		t.Fatalf("concurrent retry: %v", err)
+	}
+	if err := s.Finish(2, "retry-1", 201, "application/json", []byte(`{"id":7}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = s.Begin(2, "retry-1", fp)
+	if err != nil || rec == nil || rec.Status != 201 || string(rec.Body) != `{"id":7}` {
+		t.Fatalf("retry not replayed: %+v %v", rec, err)
+	}
+	other := service.Fingerprint("POST", "/api/v1/bookings", []byte(`{"hotel_id":2}`))
+	if _, err := s.Begin(2, "retry-1", other); err != service.ErrIdempotencyMismatch {
+		t.Fatalf("reused key with another body: %v", err)
+	}
+	// keys are per user
+	if rec, err := s.Begin(3, "retry-1", other); err != nil || rec != nil {
+		t.Fatalf("other user's key collided: %v %v", rec, err)
+	}
+	if _, err := s.Begin(2, "", fp); err != service.ErrInvalidIdempotencyKey {
+		t.Fatal("empty key accepted")
+	}
+
+	// a failed request can be retried
+	s.Begin(2, "retry-2", fp)
+	s.Abort(2, "retry-2")
+	if rec, err := s.Begin(2, "retry-2", fp); err != nil || rec != nil {
+		t.Fatalf("aborted key not released: %v %v", rec, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if rec, err := s.Begin(2, "retry-1", other); err != nil || rec != nil {
+		t.Fatalf("expired key not reusable: %v %v", rec, err)
+	}
+}
+
+func TestIdempotencyTakesOverAbandonedKey(t *testing.T) {
+	now := time.Now()
+	s := service.NewIdempotencyServiceWith(repository.NewInMemoryIdempotencyRepo(), 24*time.Hour, func() time.Time { return now })
+	fp := service.Fingerprint("POST", "/api/v1/bookings", []byte(`{"hotel_id":1}`))
+
+	// the instance running the request dies before Finish or Abort
+	if rec, err := s.Begin(2, "crash-1", fp); err != nil || rec != nil {
+		t.Fatalf("first request must run: %v %v", rec, err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := s.Begin(2, "crash-1", fp); err != service.ErrIdempotencyInProgress {
+		t.Fatalf("retry within the lease: %v", err)
+	}
+	now = now.Add(10 * time.Minute)
+	if rec, err := s.Begin(2, "crash-1", fp); err != nil || rec != nil {
+		t.Fatalf("retry after the lease should take over: %v %v", rec, err)
+	}
+	if _, err := s.Begin(2, "crash-1", fp); err != service.ErrIdempotencyInProgress {
+		t.Fatalf("the new holder's lease should apply: %v", err)
+	}
+
+	// completed responses are replayed however old they are
+	s.Finish(2, "crash-1", 201, "application/json", []byte(`{"id":9}`))
+	now = now.Add(time.Hour)
+	if rec, err := s.Begin(2, "crash-1", fp); err != nil || rec == nil || rec.Status != 201 {
+		t.Fatalf("completed key not replayed: %+v %v", rec, err)
+	}
+}
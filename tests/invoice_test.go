@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"bytes"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"agodrift/internal/invoice"
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+// invoiceIssuer includes 7% tax in prices.
+var invoiceIssuer = service.InvoiceIssuer{Name: "AgoDrift Co., Ltd.", TaxID: "0105500000000", TaxPercent: big.NewRat(7, 1)}
+
+// invoiceBooking is Alice's (user 2) stay at the in-memory Demo Hotel (id 1,
+// USD 150.00 a night).
+func invoiceBooking(id int, status string) model.Booking {
+	checkIn := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	// 3 nights x 2 rooms = 900.00, less 90.00 off, 10.00 of it paid with points
+	return model.Booking{
+		ID: id, UserID: 2, HotelID: 1, Reference: "AGD-7K3QX7", CheckIn: checkIn, CheckOut: checkIn.AddDate(0, 0, 3), Rooms: 2,
+		TotalPriceCents: 81000, DiscountCents: 9000, PointsValueCents: 1000, Currency: "USD", Status: status,
+		Promos: []model.AppliedPromo{{Code: "TEN", Kind: model.PromoPercent, PercentOff: 10, DiscountCents: 9000}},
+	}
+}
+
+func TestIssueInvoice(t *testing.T) {
+	s := service.NewInvoiceServiceWith(repository.NewInMemoryInvoiceRepo(), newStubBookings(invoiceBooking(1, model.BookingConfirmed), invoiceBooking(2, model.BookingPending), invoiceBooking(3, model.BookingConfirmed)), repository.NewInMemoryRoomRepo(),
+		repository.NewInMemoryUserRepo(), repository.NewInMemoryHotelOwnerRepo(), service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()), invoiceIssuer, time.Now)
+
+	if _, err := s.Invoice(99, model.RoleUser, 1); err != service.ErrForbidden {
+		t.Fatalf("stranger read invoice: %v", err)
+	}
+	if _, err := s.Invoice(2, model.RoleUser, 2); err != service.ErrNotInvoiceable {
+		t.Fatalf("pending booking invoiced: %v", err)
+	}
+	inv, err := s.Invoice(2, model.RoleUser, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Number != "INV-000001" || inv.TotalCents != 81000 || inv.TaxCents != 5299 || inv.PointsCents != 1000 || inv.BillTo.Name != "Alice Traveler" || inv.Hotel != "Demo Hotel" {
+		t.Fatalf("unexpected invoice %+v", inv)
+	}
+	want := []model.InvoiceLine{
+		{Kind: model.LineRoom, Description: "Room nights (3 nights x 2 rooms)", Quantity: 6, UnitCents: 15000, AmountCents: 90000},
+		{Kind: model.LineDiscount, Description: "Promo code TEN", AmountCents: -9000},
+		{Kind: model.LineTax, Description: "Tax 7% included", AmountCents: 5299, Included: true},
+	}
+	if len(inv.Lines) != len(want) {
+		t.Fatalf("lines %+v", inv.Lines)
+	}
+	for i := range want {
+		if inv.Lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, inv.Lines[i], want[i])
+		}
+	}
+
+	// issued once, numbered in sequence
+	if again, err := s.Invoice(2, model.RoleUser, 1); err != nil || again.Number != "INV-000001" {
+		t.Fatalf("reissued: %+v %v", again, err)
+	}
+	if next, err := s.Invoice(1, model.RoleAdmin, 3); err != nil || next.Number != "INV-000002" {
+		t.Fatalf("second invoice: %+v %v", next, err)
+	}
+}
+
+func TestInvoiceUnitPriceOnlyWhenExact(t *testing.T) {
+	b := invoiceBooking(1, model.BookingConfirmed)
+	b.TotalPriceCents, b.DiscountCents, b.PointsValueCents, b.Promos = 90001, 0, 0, nil
+	s := service.NewInvoiceServiceWith(repository.NewInMemoryInvoiceRepo(), newStubBookings(b), repository.NewInMemoryRoomRepo(),
+		repository.NewInMemoryUserRepo(), repository.NewInMemoryHotelOwnerRepo(), service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()), invoiceIssuer, time.Now)
+	inv, err := s.Invoice(2, model.RoleUser, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room := inv.Lines[0]; room.Quantity != 6 || room.UnitCents != 0 || room.AmountCents != 90001 {
+		t.Fatalf("room line %+v", room)
+	}
+}
+
+func TestIssueCreditNote(t *testing.T) {
+	b := invoiceBooking(1, model.BookingConfirmed)
+	s := service.NewInvoiceServiceWith(repository.NewInMemoryInvoiceRepo(), newStubBookings(b, invoiceBooking(2, model.BookingConfirmed)), repository.NewInMemoryRoomRepo(),
+		repository.NewInMemoryUserRepo(), repository.NewInMemoryHotelOwnerRepo(), service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()), invoiceIssuer, time.Now)
+
+	if _, err := s.CreditNote(2, model.RoleUser, 1); err != repository.ErrInvoiceNotFound {
+		t.Fatalf("credit note before cancelling: %v", err)
+	}
+	cn, err := s.IssueCreditNote(b, 40500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn.Number != "CN-000001" || cn.InvoiceNumber != "INV-000001" || cn.TotalCents != -40500 || cn.TaxCents != -2650 {
+		t.Fatalf("unexpected credit note %+v", cn)
+	}
+	if got, err := s.CreditNote(2, model.RoleUser, 1); err != nil || got.Number != cn.Number {
+		t.Fatalf("credit note lookup: %+v %v", got, err)
+	}
+	if again, err := s.IssueCreditNote(b, 40500); err != nil || again.Number != "CN-000001" {
+		t.Fatalf("credited twice: %+v %v", again, err)
+	}
+}
+
+func TestCreditNoteReconcilesChangedBooking(t *testing.T) {
+	b := invoiceBooking(1, model.BookingConfirmed)
+	s := service.NewInvoiceServiceWith(repository.NewInMemoryInvoiceRepo(), newStubBookings(b, invoiceBooking(2, model.BookingCancelled)), repository.NewInMemoryRoomRepo(),
+		repository.NewInMemoryUserRepo(), repository.NewInMemoryHotelOwnerRepo(), service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()), invoiceIssuer, time.Now)
+	if _, err := s.Invoice(2, model.RoleUser, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// one night shorter after invoicing (540.00), cancelled keeping one night
+	// (150.00): the 810.00 invoice is credited down to the 150.00 kept
+	b.CheckOut = b.CheckOut.AddDate(0, 0, -1)
+	b.TotalPriceCents = 54000
+	cn, err := s.IssueCreditNote(b, 39000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn.TotalCents != -66000 {
+		t.Fatalf("credited %d, want -66000", cn.TotalCents)
+	}
+
+	// a non-refundable booking is invoiced when cancelled, and nothing is
+	// credited; cancelled bookings are not invoiced on request
+	nonRefundable := invoiceBooking(2, model.BookingConfirmed)
+	if cn, err := s.IssueCreditNote(nonRefundable, 0); err != nil || cn.Number != "" {
+		t.Fatalf("credit note without refund: %+v %v", cn, err)
+	}
+	if inv, err := s.Invoice(2, model.RoleUser, 2); err != nil || inv.TotalCents != 81000 {
+		t.Fatalf("penalty not invoiced: %+v %v", inv, err)
+	}
+}
+
+func TestRenderInvoice(t *testing.T) {
+	s := service.NewInvoiceServiceWith(repository.NewInMemoryInvoiceRepo(), newStubBookings(invoiceBooking(1, model.BookingConfirmed)), repository.NewInMemoryRoomRepo(),
+		repository.NewInMemoryUserRepo(), repository.NewInMemoryHotelOwnerRepo(), service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()), invoiceIssuer, time.Now)
+	inv, err := s.Invoice(2, model.RoleUser, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.BillTo.Name = "Zoë (Travel) Ltd"
+
+	html, err := invoice.HTML(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Invoice INV-000001", "USD 810.00", "USD -90.00", "(USD 52.99)", "Zoë (Travel) Ltd"} {
+		if !bytes.Contains(html, []byte(want)) {
+			t.Errorf("HTML missing %q", want)
+		}
+	}
+
+	pdf := invoice.PDF(inv)
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF: %q...", pdf[:20])
+	}
+	for _, want := range []string{"(Invoice INV-000001)", `(Zo\353 \(Travel\) Ltd)`, "(USD 810.00)"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("PDF missing %q", want)
+		}
+	}
+	// startxref must point at the cross-reference table
+	tail := string(pdf[bytes.LastIndex(pdf, []byte("startxref\n"))+len("startxref\n"):])
+	off, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(tail), "%%EOF")))
+	if err != nil || !bytes.HasPrefix(pdf[off:], []byte("xref\n")) {
+		t.Fatalf("bad startxref %q: %v", tail, err)
+	}
+}
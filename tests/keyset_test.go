@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+
+	"agodrift/internal/service"
+)
+
+func TestKeySetRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := service.NewSigningKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := service.NewSigningKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks := service.NewKeySet()
+	ks.AddKey(oldKey, true)
+	claims := jwt.MapClaims{"sub": "a@example.com", "exp": time.Now().Add(time.Minute).Unix()}
+	oldToken, err := ks.Sign(claims)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	// rotate: new tokens use EdDSA, tokens from the RSA key still verify
+	ks.AddKey(newKey, true)
+	newToken, _ := ks.Sign(claims)
+	for _, raw := range []string{oldToken, newToken} {
+		if _, err := ks.Parse(raw); err != nil {
+			t.Fatalf("parse after rotation: %v", err)
+		}
+	}
+	parsed, _ := ks.Parse(newToken)
+	if parsed.Header["kid"] != newKey.ID || parsed.Method.Alg() != "EdDSA" {
+		t.Fatalf("unexpected header: %v", parsed.Header)
+	}
+	if got := len(ks.JWKS()["keys"]); got != 2 {
+		t.Fatalf("expected 2 published keys, got %d", got)
+	}
+
+	// a key that is not in the set is rejected
+	other := service.NewKeySet()
+	_, otherEd, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _ := service.NewSigningKey(otherEd)
+	other.AddKey(otherKey, true)
+	foreign, _ := other.Sign(claims)
+	if _, err := ks.Parse(foreign); err == nil {
+		t.Fatalf("expected token from unknown key to be rejected")
+	}
+
+	// HS256 tokens are not accepted once asymmetric keys are in use
+	hs, _ := service.NewHMACKeySet([]byte("changeme")).Sign(claims)
+	if _, err := ks.Parse(hs); err == nil {
+		t.Fatalf("expected HS256 token to be rejected")
+	}
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestLoginThrottleBackoffAndLockout(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	repo := repository.NewInMemoryLoginThrottleRepo()
+	policy := service.ThrottlePolicy{
+		FreeAttempts:     2,
+		IPFreeAttempts:   50,
+		BaseDelay:        time.Second,
+		MaxDelay:         4 * time.Second,
+		AccountThreshold: 5,
+		IPThreshold:      100,
+		LockoutDuration:  10 * time.Minute,
+		Window:           time.Hour,
+	}
+	th := service.NewLoginThrottleWith(repo, policy, func() time.Time { return now })
+
+	fail := func() {
+		if _, err := th.Check("Alice@Example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt blocked early: %v", err)
+		}
+		th.Failure("Alice@Example.com", "10.0.0.1")
+	}
+	fail()
+	fail()
+	// third attempt must wait the base delay
+	if wait, err := th.Check("alice@example.com", "10.0.0.2"); err != service.ErrLoginThrottled || wait != time.Second {
+		t.Fatalf("want 1s backoff, got %v %v", wait, err)
+	}
+	now = now.Add(time.Second)
+	fail()
+	if wait, _ := th.Check("alice@example.com", "10.0.0.2"); wait != 2*time.Second {
+		t.Fatalf("backoff should double, got %v", wait)
+	}
+	now = now.Add(2 * time.Second)
+	fail()
+	now = now.Add(4 * time.Second)
+	fail() // fifth failure locks the account
+
+	wait, err := th.Check("alice@example.com", "10.9.9.9")
+	if err != service.ErrLoginLocked || wait != 10*time.Minute {
+		t.Fatalf("want lockout, got %v %v", wait, err)
+	}
+	// other accounts from the same IP are unaffected below the IP threshold
+	if _, err := th.Check("bob@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("other account blocked: %v", err)
+	}
+
+	lockouts, _ := th.Lockouts(10)
+	if len(lockouts) != 1 || lockouts[0].Subject != "alice@example.com" || lockouts[0].IP != "10.0.0.1" {
+		t.Fatalf("unexpected audit: %+v", lockouts)
+	}
+
+	if err := th.Unlock("account", "ALICE@example.com", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := th.Check("alice@example.com", "10.9.9.9"); err != nil {
+		t.Fatalf("still locked after unlock: %v", err)
+	}
+	lockouts, _ = th.Lockouts(10)
+	if lockouts[0].UnlockedBy == nil || *lockouts[0].UnlockedBy != 1 {
+		t.Fatal("unlock not recorded in audit")
+	}
+}
+
+func TestLoginThrottleIPLockoutAndSuccess(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	policy := service.DefaultThrottlePolicy
+	policy.FreeAttempts = 100
+	policy.IPFreeAttempts = 100
+	policy.IPThreshold = 3
+	th := service.NewLoginThrottleWith(repository.NewInMemoryLoginThrottleRepo(), policy, func() time.Time { return now })
+
+	th.Failure("a@example.com", "10.0.0.1")
+	th.Success("a@example.com")
+	th.Failure("b@example.com", "10.0.0.1")
+	th.Failure("c@example.com", "10.0.0.1")
+	if _, err := th.Check("d@example.com", "10.0.0.1"); err != service.ErrLoginLocked {
+		t.Fatalf("spraying accounts from one IP should lock the IP, got %v", err)
+	}
+	if _, err := th.Check("d@example.com", "10.0.0.2"); err != nil {
+		t.Fatalf("other IP blocked: %v", err)
+	}
+	now = now.Add(policy.LockoutDuration)
+	if _, err := th.Check("d@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("lockout should expire: %v", err)
+	}
+}
+
+func TestLoginThrottleCountsParallelFailures(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	policy := service.DefaultThrottlePolicy
+	policy.AccountThreshold = 20
+	repo := repository.NewInMemoryLoginThrottleRepo()
+	th := service.NewLoginThrottleWith(repo, policy, func() time.Time { return now })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			th.Failure("a@example.com", "")
+		}()
+	}
+	wg.Wait()
+	if a, _ := repo.Get(model.ThrottleScopeAccount, "a@example.com"); a.Failures != 50 {
+		t.Fatalf("want every failure counted, got %d", a.Failures)
+	}
+	if list, _ := th.Lockouts(10); len(list) != 1 {
+		t.Fatalf("want a single lockout, got %d", len(list))
+	}
+}
+
+func TestLookupThrottleKeepsApartFromLogins(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	repo := repository.NewInMemoryLoginThrottleRepo()
+	logins := service.NewLoginThrottleWith(repo, service.DefaultThrottlePolicy, func() time.Time { return now })
+	lookups := service.NewLookupThrottleWith(repo, service.LookupThrottlePolicy, func() time.Time { return now })
+
+	for i := 0; i < service.LookupThrottlePolicy.IPThreshold; i++ {
+		lookups.Failure("ABC123", "10.0.0.1")
+		now = now.Add(2 * time.Minute)
+	}
+	if _, err := lookups.Check("XYZ789", "10.0.0.1"); err != service.ErrLoginLocked {
+		t.Fatalf("guessing references should lock the IP for lookups, got %v", err)
+	}
+	if _, err := logins.Check("a@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("lookup misses must not block logins: %v", err)
+	}
+	// misses on a reference only slow it down, so they can't lock its guest out
+	if _, err := lookups.Check("ABC123", "10.0.0.2"); err != nil {
+		t.Fatalf("reference should not be locked: %v", err)
+	}
+	list, _ := logins.Lockouts(10)
+	if len(list) != 1 || list[0].Scope != model.ThrottleScopeLookupIP {
+		t.Fatalf("want one lookup IP lockout, got %+v", list)
+	}
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestLoyaltyTiers(t *testing.T) {
+	cases := []struct {
+		qualifying int
+		tier, next string
+	}{
+		{0, "member", "silver"},
+		{1999, "member", "silver"},
+		{2000, "silver", "gold"},
+		{7500, "gold", ""},
+	}
+	for _, c := range cases {
+		tier, next := model.TierFor(c.qualifying)
+		nextName := ""
+		if next != nil {
+			nextName = next.Name
+		}
+		if tier.Name != c.tier || nextName != c.next {
+			t.Errorf("%d: got %s/%s, want %s/%s", c.qualifying, tier.Name, nextName, c.tier, c.next)
+		}
+	}
+
+	gold, _ := model.TierFor(5000)
+	if got := model.EarnPoints(12345, model.LoyaltyTiers[0]); got != 123 {
+		t.Fatalf("member earned %d, want 123", got)
+	}
+	if got := model.EarnPoints(12345, gold); got != 184 {
+		t.Fatalf("gold earned %d, want 184", got)
+	}
+}
+
+func TestPointsReturnedOnCancel(t *testing.T) {
+	// 400.00 total, 100.00 of it paid with 10000 points
+	b := model.Booking{TotalPriceCents: 40000, PointsRedeemed: 10000, PointsValueCents: 10000, Currency: "USD"}
+	if b.AmountDueCents() != 30000 {
+		t.Fatalf("amount due = %d", b.AmountDueCents())
+	}
+	cases := []struct{ penalty, returned int }{
+		{0, 10000},
+		{30000, 10000}, // the card covers the whole penalty
+		{35000, 5000},
+		{40000, 0},
+	}
+	for _, c := range cases {
+		if got := b.PointsReturned(c.penalty); got != c.returned {
+			t.Errorf("penalty %d: returned %d, want %d", c.penalty, got, c.returned)
+		}
+	}
+
+	// without a policy cancelling is free and every point comes back
+	if got := b.CancellationCharge(time.Now()).PointsReturned; got != 10000 {
+		t.Fatalf("free cancellation returned %d points", got)
+	}
+}
+
+func TestAwardCompletedStays(t *testing.T) {
+	now := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	checkOut := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	bookings := newStubBookings(
+		// 365000 satang = 100.00 USD, 36500 of it paid with points
+		model.Booking{ID: 1, UserID: 7, Reference: "AGD-7K3QX7", CheckOut: checkOut, TotalPriceCents: 365000, PointsRedeemed: 1000, PointsValueCents: 36500, Currency: "THB", Status: model.BookingConfirmed},
+		model.Booking{ID: 2, UserID: 8, CheckOut: checkOut, TotalPriceCents: 20000, Currency: "USD", Status: model.BookingConfirmed},
+		model.Booking{ID: 3, UserID: 7, CheckOut: now.AddDate(0, 0, 3), TotalPriceCents: 50000, Currency: "USD", Status: model.BookingConfirmed},
+	)
+	currency := service.NewCurrencyServiceWithRepo(repository.NewInMemoryExchangeRateRepo(), "USD")
+	if err := currency.SetRates([]model.ExchangeRate{{Base: "USD", Quote: "THB", Rate: "36.5"}}); err != nil {
+		t.Fatalf("set rates: %v", err)
+	}
+	repo := repository.NewInMemoryLoyaltyRepo()
+	svc := service.NewLoyaltyServiceWith(repo, bookings, currency, func() time.Time { return now })
+	// user 8 reached silver a month ago
+	if _, err := repo.Append(model.LoyaltyEntry{UserID: 8, Kind: model.LoyaltyEarn, Points: 2500, CreatedAt: now.AddDate(0, -1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := svc.AwardCompleted(now)
+	if err != nil || n != 2 {
+		t.Fatalf("awarded %d, %v", n, err)
+	}
+	if n, err := svc.AwardCompleted(now); err != nil || n != 0 {
+		t.Fatalf("awarded again: %d, %v", n, err)
+	}
+
+	a, err := svc.Account(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Balance != 90 || a.Tier.Name != "member" || a.NextTier == nil || a.PointsToNextTier != 1910 {
+		t.Fatalf("user 7: %+v", a)
+	}
+	a, err = svc.Account(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 200.00 at silver's 25% bonus
+	if a.Balance != 2750 || a.Tier.Name != "silver" || a.ValueCents != 2750 {
+		t.Fatalf("user 8: %+v", a)
+	}
+
+	page, err := svc.History(8, 1, 1)
+	if err != nil || page.Total != 2 || len(page.Items) != 1 || page.Items[0].Points != 250 || *page.Items[0].BookingID != 2 {
+		t.Fatalf("history: %+v %v", page, err)
+	}
+}
+
+func TestLoyaltyRedemption(t *testing.T) {
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	currency := service.NewCurrencyServiceWithRepo(repository.NewInMemoryExchangeRateRepo(), "USD")
+	if err := currency.SetRates([]model.ExchangeRate{{Base: "USD", Quote: "THB", Rate: "36.5"}}); err != nil {
+		t.Fatalf("set rates: %v", err)
+	}
+	repo := repository.NewInMemoryLoyaltyRepo()
+	svc := service.NewLoyaltyServiceWith(repo, newStubBookings(), currency, func() time.Time { return now })
+	if _, err := repo.Append(model.LoyaltyEntry{UserID: 7, Kind: model.LoyaltyEarn, Points: 500, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := svc.Redemption(7, 400, "THB"); err != nil || v != 14600 {
+		t.Fatalf("redeem 400 points: %d %v", v, err)
+	}
+	if _, err := svc.Redemption(7, 600, "USD"); err != repository.ErrNotEnoughPoints {
+		t.Fatalf("overspend: %v", err)
+	}
+	var ve *service.ValidationError
+	if _, err := svc.Redemption(7, -1, "USD"); !errors.As(err, &ve) {
+		t.Fatalf("negative points: %v", err)
+	}
+	if v, err := svc.Redemption(7, 0, "USD"); err != nil || v != 0 {
+		t.Fatalf("no points: %d %v", v, err)
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agodrift/internal/mail"
+	"agodrift/internal/model"
+	"agodrift/internal/money"
+	"agodrift/internal/notification"
+	"agodrift/internal/repository"
+)
+
+type recordingMailer struct {
+	mu       sync.Mutex
+	failures int // fail this many sends first
+	attempts int
+	sent     []mail.Message
+}
+
+func (m *recordingMailer) Send(msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts++
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("smtp unavailable")
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestBookingNotificationTemplates(t *testing.T) {
+	users := repository.NewInMemoryUserRepo()
+	rooms := repository.NewInMemoryRoomRepo()
+	hotel := rooms.Create(model.Room{Name: "Tom & Jerry's <Inn>", Location: "Bangkok", PriceCents: 350000, Currency: "THB"})
+	guest, _ := users.GetByEmail("alice@example.com")
+	n := notification.NewNotifierWith(users, rooms, &recordingMailer{}, "https://app.test")
+
+	display := 9712
+	b := model.Booking{
+		ID: 42, UserID: guest.ID, HotelID: hotel.ID,
+		CheckIn: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), CheckOut: time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC),
+		Adults: 2, Rooms: 1, TotalPriceCents: 700000, Currency: "THB",
+		DisplayCurrency: "USD", DisplayTotalCents: &display,
+	}
+	for _, event := range []string{notification.BookingCreated, notification.BookingConfirmed, notification.BookingModified, notification.BookingCancelled, notification.StayReminder} {
+		msg, err := n.Render(event, b)
+		if err != nil {
+			t.Fatalf("%s: %v", event, err)
+		}
+		if msg.To != "alice@example.com" || msg.Subject == "" {
+			t.Fatalf("%s: bad envelope %+v", event, msg)
+		}
+		for _, want := range []string{"Alice Traveler", "THB 7,000.00", "about USD 97.12", "2 nights", "Sun, 1 Nov 2026"} {
+			if !strings.Contains(msg.Text, want) || !strings.Contains(msg.HTML, want) {
+				t.Errorf("%s: missing %q", event, want)
+			}
+		}
+		if !strings.Contains(msg.Text, "Tom & Jerry's <Inn>") {
+			t.Errorf("%s: text body should not be escaped", event)
+		}
+		if strings.Contains(msg.HTML, "<Inn>") || !strings.Contains(msg.HTML, "&lt;Inn&gt;") {
+			t.Errorf("%s: html body must escape hotel name", event)
+		}
+	}
+	if got := money.Format(-123456, "jpy"); got != "JPY -123,456" {
+		t.Errorf("format: %s", got)
+	}
+}
+
+func TestMailQueueRetries(t *testing.T) {
+	m := &recordingMailer{failures: 2}
+	q := mail.NewQueue(m, 1, 10, 3, time.Millisecond)
+	if err := q.Send(mail.Message{To: "a@example.com", Subject: "hi", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+	if m.attempts != 3 || len(m.sent) != 1 {
+		t.Fatalf("want delivery on third attempt, got %d attempts %d sent", m.attempts, len(m.sent))
+	}
+
+	m = &recordingMailer{failures: 10}
+	q = mail.NewQueue(m, 1, 10, 2, time.Millisecond)
+	_ = q.Send(mail.Message{To: "a@example.com", Subject: "hi"})
+	q.Close()
+	if m.attempts != 2 || len(m.sent) != 0 {
+		t.Fatalf("want give up after 2 attempts, got %d", m.attempts)
+	}
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+// mockOIDC is a minimal OpenID Connect provider that auto-approves logins
+// for a fixed identity.
+type mockOIDC struct {
+	srv           *httptest.Server
+	key           *rsa.PrivateKey
+	sub, email    string
+	emailVerified bool
+
+	mu    sync.Mutex
+	codes map[string][2]string // code -> {code_challenge, nonce}
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDC{key: key, codes: make(map[string][2]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.srv.URL,
+			"authorization_endpoint": m.srv.URL + "/authorize",
+			"token_endpoint":         m.srv.URL + "/token",
+			"jwks_uri":               m.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code_challenge_method") != "S256" {
+			http.Error(w, "pkce required", http.StatusBadRequest)
+			return
+		}
+		m.mu.Lock()
+		m.codes["code-1"] = [2]string{q.Get("code_challenge"), q.Get("nonce")}
+		m.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code=code-1&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		m.mu.Lock()
+		entry, ok := m.codes[r.Form.Get("code")]
+		delete(m.codes, r.Form.Get("code"))
+		m.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != entry[0] {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": m.srv.URL, "aud": "agodrift", "sub": m.sub, "email": m.email,
+			"email_verified": m.emailVerified, "nonce": entry[1], "exp": time.Now().Add(time.Minute).Unix(),
+		})
+		tok.Header["kid"] = "k1"
+		signed, _ := tok.SignedString(m.key)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+// login runs the browser side of the flow and returns the callback params.
+func (m *mockOIDC) login(t *testing.T, s *service.OIDCService) (string, string) {
+	authURL, err := s.AuthURL("mock")
+	if err != nil {
+		t.Fatalf("auth url: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	loc, _ := url.Parse(resp.Header.Get("Location"))
+	return loc.Query().Get("state"), loc.Query().Get("code")
+}
+
+func TestOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	m := newMockOIDC(t)
+	users := repository.NewInMemoryUserRepo()
+	auth := service.NewAuthServiceWithKeys(users, service.NewHMACKeySet([]byte("testsecret")))
+	mfa := service.NewMFAServiceWith(repository.NewInMemoryMFARepo(), auth, "AgoDrift", []string{model.RoleAdmin}, time.Now)
+	s := service.NewOIDCServiceWith([]service.OIDCProvider{{
+		Name: "mock", Issuer: m.srv.URL, ClientID: "agodrift", RedirectURL: "http://localhost/callback",
+	}}, users, repository.NewInMemoryIdentityRepo(users), repository.NewInMemoryOIDCLoginRepo(), auth, mfa, http.DefaultClient)
+
+	// an existing account is linked by its verified email
+	m.sub, m.email, m.emailVerified = "g-123", "alice@example.com", true
+	state, code := m.login(t, s)
+	res, err := s.Callback("mock", state, code, service.SessionClient{})
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	u := res.User
+	if res.Token == "" || res.MFAToken != "" || u.Email != "alice@example.com" || u.Role != model.RoleUser {
+		t.Fatalf("unexpected login result: %+v", res)
+	}
+
+	// the state is single use
+	if _, err := s.Callback("mock", state, code, service.SessionClient{}); err != service.ErrInvalidState {
+		t.Fatalf("expected ErrInvalidState on replay, got %v", err)
+	}
+
+	// once linked, the identity keeps resolving even if the email is unverified
+	m.emailVerified = false
+	state, code = m.login(t, s)
+	if res2, err := s.Callback("mock", state, code, service.SessionClient{}); err != nil || res2.User.ID != u.ID {
+		t.Fatalf("expected linked user %d, got %+v, %v", u.ID, res2.User, err)
+	}
+
+	// an unknown identity with an unverified email is refused
+	m.sub, m.email = "g-456", "mallory@example.com"
+	state, code = m.login(t, s)
+	if _, err := s.Callback("mock", state, code, service.SessionClient{}); err != service.ErrEmailNotVerified {
+		t.Fatalf("expected ErrEmailNotVerified, got %v", err)
+	}
+
+	// roles that must use 2FA get the second step, not a session
+	m.sub, m.email, m.emailVerified = "g-789", "admin@agodrift.dev", true
+	state, code = m.login(t, s)
+	res, err = s.Callback("mock", state, code, service.SessionClient{})
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if res.Token != "" || res.MFAToken == "" || !res.EnrollmentRequired {
+		t.Fatalf("admin skipped 2FA: %+v", res)
+	}
+	if challenged, _, _, err := auth.ParseChallengeToken(res.MFAToken); err != nil || challenged.ID != res.User.ID {
+		t.Fatalf("bad challenge for %d: %+v %v", res.User.ID, challenged, err)
+	}
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"testing"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestPartnerCanOnlyManageOwnHotels(t *testing.T) {
+	rooms := repository.NewInMemoryRoomRepo()
+	owned := rooms.Create(model.Room{Name: "Owned", Location: "X", Destination: "X", PriceCents: 1000, RoomsTotal: 5})
+	other := rooms.Create(model.Room{Name: "Other", Location: "Y", Destination: "Y", PriceCents: 1000, RoomsTotal: 5})
+	s := service.NewRoomServiceWithRepos(rooms, repository.NewInMemoryHotelOwnerRepo(), service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()))
+	const partnerID = 42
+	if err := s.AssignOwner(partnerID, owned.ID); err != nil {
+		t.Fatalf("assign owner: %v", err)
+	}
+
+	price := 1500
+	updated, err := s.Update(partnerID, model.RolePartner, owned.ID, service.HotelUpdate{PriceCents: &price})
+	if err != nil {
+		t.Fatalf("partner should update own hotel: %v", err)
+	}
+	if updated.PriceCents != 1500 {
+		t.Fatalf("expected price 1500, got %d", updated.PriceCents)
+	}
+	if _, err := s.Update(partnerID, model.RolePartner, other.ID, service.HotelUpdate{PriceCents: &price}); err != service.ErrForbidden {
+		t.Fatalf("expected ErrForbidden for another hotel, got %v", err)
+	}
+	if _, err := s.SetInventory(partnerID, model.RolePartner, other.ID, 5, 5); err != service.ErrForbidden {
+		t.Fatalf("expected ErrForbidden for inventory, got %v", err)
+	}
+	if _, err := s.SetInventory(partnerID, model.RolePartner, owned.ID, 5, 6); err != service.ErrInvalidInventory {
+		t.Fatalf("expected ErrInvalidInventory, got %v", err)
+	}
+	if _, err := s.Update(partnerID, model.RoleUser, owned.ID, service.HotelUpdate{PriceCents: &price}); err != service.ErrForbidden {
+		t.Fatalf("expected ErrForbidden without the partner permission, got %v", err)
+	}
+	if _, err := s.Update(1, model.RoleAdmin, other.ID, service.HotelUpdate{PriceCents: &price}); err != nil {
+		t.Fatalf("admin should update any hotel: %v", err)
+	}
+	list, _ := s.ListOwned(partnerID)
+	if len(list) != 1 || list[0].ID != owned.ID {
+		t.Fatalf("expected only the owned hotel, got %+v", list)
+	}
+}
+
+func TestHotelUpdateKeepsInventoryAndRating(t *testing.T) {
+	rooms := repository.NewInMemoryRoomRepo()
+	rm := rooms.Create(model.Room{Name: "Stale", Location: "X", Destination: "X", PriceCents: 1000, RoomsTotal: 5})
+	stale, _ := rooms.Get(rm.ID)
+	// a booking and a review land after the copy was read
+	if err := rooms.UpdateInventory(rm.ID, 5, 3); err != nil {
+		t.Fatalf("inventory: %v", err)
+	}
+	stale.PriceCents = 1200
+	stale.RoomsAvailable, stale.Rating, stale.Reviews = 5, 1, 99
+	if err := rooms.Update(stale); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, _ := rooms.Get(rm.ID)
+	if got.PriceCents != 1200 || got.RoomsAvailable != 3 || got.Rating != rm.Rating || got.Reviews != rm.Reviews {
+		t.Fatalf("expected only the price to change, got %+v", got)
+	}
+}
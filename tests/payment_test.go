@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/payment"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestPaymentConfirmsBooking(t *testing.T) {
+	bookings := newStubBookings(model.Booking{ID: 1, UserID: 7, Reference: "AGD-7K3QX7", Status: model.BookingPending, TotalPriceCents: 40000, Currency: "USD"})
+	svc := service.NewPaymentServiceWith(repository.NewInMemoryPaymentRepo(), bookings, payment.NewFakeGateway("whsec"),
+		service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()), nil, time.Now)
+
+	if _, err := svc.Pay(8, 1, "tok_visa"); err != service.ErrForbidden {
+		t.Fatalf("stranger paid: %v", err)
+	}
+	p, err := svc.Pay(7, 1, "tok_visa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != model.PaymentAuthorized || p.AmountCents != 40000 || p.GatewayRef == "" {
+		t.Fatalf("unexpected payment %+v", p)
+	}
+	if bookings.byID[1].Status != model.BookingConfirmed {
+		t.Fatalf("booking not confirmed: %s", bookings.byID[1].Status)
+	}
+	if _, err := svc.Pay(7, 1, "tok_visa"); err != service.ErrBookingNotPayable {
+		t.Fatalf("paid twice: %v", err)
+	}
+
+	p, err = svc.Capture(p.ID)
+	if err != nil || p.Status != model.PaymentCaptured || p.CapturedCents != 40000 {
+		t.Fatalf("capture: %+v %v", p, err)
+	}
+	if _, err := svc.Refund(p.ID, 50000); err != service.ErrRefundAmount {
+		t.Fatalf("over-refund accepted: %v", err)
+	}
+	p, err = svc.Refund(p.ID, 15000)
+	if err != nil || p.Status != model.PaymentCaptured || p.RefundedCents != 15000 {
+		t.Fatalf("partial refund: %+v %v", p, err)
+	}
+	p, err = svc.Refund(p.ID, 0)
+	if err != nil || p.Status != model.PaymentRefunded || p.RefundedCents != 40000 {
+		t.Fatalf("full refund: %+v %v", p, err)
+	}
+	if _, err := svc.Void(p.ID); err != service.ErrPaymentState {
+		t.Fatalf("void after capture: %v", err)
+	}
+}
+
+func TestPaymentDeclineAllowsRetry(t *testing.T) {
+	bookings := newStubBookings(model.Booking{ID: 1, UserID: 7, Reference: "AGD-7K3QX7", Status: model.BookingPending, TotalPriceCents: 40000, Currency: "USD"})
+	svc := service.NewPaymentServiceWith(repository.NewInMemoryPaymentRepo(), bookings, payment.NewFakeGateway("whsec"),
+		service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()), nil, time.Now)
+
+	p, err := svc.Pay(7, 1, payment.TokenDeclined)
+	if !errors.Is(err, service.ErrPaymentDeclined) || p.Status != model.PaymentFailed || p.FailureReason != "card_declined" {
+		t.Fatalf("decline: %+v %v", p, err)
+	}
+	if bookings.byID[1].Status != model.BookingPending {
+		t.Fatal("declined payment confirmed the booking")
+	}
+	if _, err := svc.Pay(7, 1, "tok_visa"); err != nil {
+		t.Fatalf("retry after decline: %v", err)
+	}
+	list, err := svc.ListForBooking(7, "customer", 1)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("want 2 payments, got %d %v", len(list), err)
+	}
+}
+
+func TestPaymentWebhookSettlesPending(t *testing.T) {
+	bookings := newStubBookings(model.Booking{ID: 1, UserID: 7, Reference: "AGD-7K3QX7", Status: model.BookingPending, TotalPriceCents: 40000, Currency: "USD"})
+	svc := service.NewPaymentServiceWith(repository.NewInMemoryPaymentRepo(), bookings, payment.NewFakeGateway("whsec"),
+		service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo()), nil, time.Now)
+
+	p, err := svc.Pay(7, 1, payment.TokenPending)
+	if err != nil || p.Status != model.PaymentPending {
+		t.Fatalf("pending: %+v %v", p, err)
+	}
+	if _, err := svc.Pay(7, 1, "tok_visa"); err != service.ErrAlreadyPaid {
+		t.Fatalf("second payment while pending: %v", err)
+	}
+
+	body, _ := json.Marshal(payment.Event{Type: payment.EventAuthorizationSucceeded, Ref: p.GatewayRef})
+	if err := svc.HandleWebhook(body, "bad"); err != payment.ErrInvalidSignature {
+		t.Fatalf("unsigned webhook accepted: %v", err)
+	}
+	if bookings.byID[1].Status != model.BookingPending {
+		t.Fatal("unsigned webhook confirmed the booking")
+	}
+	sig := payment.Sign("whsec", body)
+	if err := svc.HandleWebhook(body, sig); err != nil {
+		t.Fatal(err)
+	}
+	if bookings.byID[1].Status != model.BookingConfirmed {
+		t.Fatal("webhook did not confirm the booking")
+	}
+	// redelivery is a no-op
+	if err := svc.HandleWebhook(body, sig); err != nil {
+		t.Fatal(err)
+	}
+
+	unknown, _ := json.Marshal(payment.Event{Type: payment.EventAuthorizationSucceeded, Ref: "fake_nope"})
+	if err := svc.HandleWebhook(unknown, payment.Sign("whsec", unknown)); err != repository.ErrPaymentNotFound {
+		t.Fatalf("unknown ref: %v", err)
+	}
+}
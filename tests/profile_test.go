@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestProfileUpdate(t *testing.T) {
+	users := repository.NewInMemoryUserRepo()
+	svc := service.NewUserServiceWith(users, service.NewAuthServiceWithKeys(users, service.NewHMACKeySet([]byte("test"))), nil)
+	alice, _ := users.GetByEmail("alice@example.com")
+
+	name, phone, cur, lang := "  Alice T.  ", "+66 81 234 5678", "thb", "th-TH"
+	u, err := svc.UpdateProfile(alice.ID, service.ProfileUpdate{Name: &name, Phone: &phone, PreferredCurrency: &cur, Language: &lang})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "Alice T." || u.PreferredCurrency != "THB" || u.Language != "th-TH" {
+		t.Fatalf("unexpected profile %+v", u)
+	}
+	stored, _ := svc.Profile(alice.ID)
+	if stored.Phone != "+66 81 234 5678" || stored.Role != model.RoleUser || stored.Email != alice.Email {
+		t.Fatalf("profile not stored: %+v", stored)
+	}
+
+	bad, badCur, badLang := "", "baht", "thai"
+	_, err = svc.UpdateProfile(alice.ID, service.ProfileUpdate{Name: &bad, PreferredCurrency: &badCur, Language: &badLang})
+	var ve *service.ValidationError
+	if !errors.As(err, &ve) || len(ve.Problems) != 3 {
+		t.Fatalf("want 3 problems, got %v", err)
+	}
+	if stored, _ := svc.Profile(alice.ID); stored.Name != "Alice T." {
+		t.Fatal("rejected update must not be saved")
+	}
+	if _, err := svc.Profile(999); err != repository.ErrUserNotFound {
+		t.Fatalf("want not found, got %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	users := repository.NewInMemoryUserRepo()
+	auth := service.NewAuthServiceWithKeys(users, service.NewHMACKeySet([]byte("test")))
+	svc := service.NewUserServiceWith(users, auth, nil)
+	alice, _ := users.GetByEmail("alice@example.com")
+
+	here, _ := auth.StartSession(alice, time.Hour, service.SessionClient{})
+	there, _ := auth.StartSession(alice, time.Hour, service.SessionClient{})
+	hereClaims := sessionClaims(t, auth, here)
+	thereClaims := sessionClaims(t, auth, there)
+
+	if err := svc.ChangePassword(alice.ID, "wrong", "new-password", hereClaims["jti"].(string)); err != service.ErrWrongPassword {
+		t.Fatalf("want wrong password, got %v", err)
+	}
+	if err := svc.ChangePassword(alice.ID, "userpass", "short", hereClaims["jti"].(string)); err != service.ErrWeakPassword {
+		t.Fatalf("want weak password, got %v", err)
+	}
+	if err := svc.ChangePassword(alice.ID, "userpass", "new-password", hereClaims["jti"].(string)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := auth.Authenticate("alice@example.com", "new-password"); !ok {
+		t.Fatal("new password not stored")
+	}
+	if auth.IsRevoked(hereClaims) || !auth.IsRevoked(thereClaims) {
+		t.Fatal("only the other session should be revoked")
+	}
+}
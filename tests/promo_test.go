@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestApplyPromos(t *testing.T) {
+	promos := []model.AppliedPromo{
+		{Code: "TENOFF", Kind: model.PromoPercent, PercentOff: 10},
+		{Code: "FIFTY", Kind: model.PromoFixed, AmountOffCents: 5000},
+	}
+	if got := model.ApplyPromos(40000, promos); got != 9000 {
+		t.Fatalf("discount = %d, want 9000", got)
+	}
+	if promos[0].DiscountCents != 4000 || promos[1].DiscountCents != 5000 {
+		t.Fatalf("unexpected split: %+v", promos)
+	}
+
+	// together the codes never take off more than the subtotal
+	promos[1].AmountOffCents = 50000
+	if got := model.ApplyPromos(40000, promos); got != 40000 || promos[1].DiscountCents != 36000 {
+		t.Fatalf("discount = %d, split %+v", got, promos)
+	}
+}
+
+func TestCheckPromoCode(t *testing.T) {
+	p, problems := service.CheckPromoCode(model.PromoCode{Code: " summer-25 ", Kind: model.PromoPercent, PercentOff: 25, AmountOffCents: 100})
+	if len(problems) > 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	if p.Code != "SUMMER-25" || p.AmountOffCents != 0 || p.HotelIDs == nil || p.Destinations == nil {
+		t.Fatalf("not normalized: %+v", p)
+	}
+
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []model.PromoCode{
+		{Code: "X", Kind: model.PromoPercent, PercentOff: 10},
+		{Code: "BIG", Kind: model.PromoPercent, PercentOff: 101},
+		{Code: "FLAT", Kind: model.PromoFixed, AmountOffCents: 1000},
+		{Code: "FLAT", Kind: model.PromoFixed, Currency: "USD"},
+		{Code: "ODD", Kind: "bogo"},
+		{Code: "BACKWARDS", Kind: model.PromoPercent, PercentOff: 10, StartsAt: &start, EndsAt: &start},
+		{Code: "NEG", Kind: model.PromoPercent, PercentOff: 10, MaxUses: -1},
+		{Code: "SPEND", Kind: model.PromoPercent, PercentOff: 10, MinSpendCents: 10000},
+	}
+	for _, c := range cases {
+		if _, problems := service.CheckPromoCode(c); len(problems) == 0 {
+			t.Errorf("%+v: expected problems", c)
+		}
+	}
+}
+
+func TestPromoApply(t *testing.T) {
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	ended := now.Add(-time.Hour)
+	currency := service.NewCurrencyServiceWithRepo(repository.NewInMemoryExchangeRateRepo(), "USD")
+	if err := currency.SetRates([]model.ExchangeRate{{Base: "USD", Quote: "THB", Rate: "36.5"}}); err != nil {
+		t.Fatalf("set rates: %v", err)
+	}
+	repo := repository.NewInMemoryPromoRepo()
+	promos := service.NewPromoServiceWith(repo, currency, func() time.Time { return now })
+	codes := []model.PromoCode{
+		{Code: "TEN", Kind: model.PromoPercent, PercentOff: 10, Stackable: true},
+		{Code: "TWENTYUSD", Kind: model.PromoFixed, AmountOffCents: 2000, Currency: "USD", Stackable: true},
+		{Code: "SOLO", Kind: model.PromoPercent, PercentOff: 15},
+		{Code: "OLD", Kind: model.PromoPercent, PercentOff: 50, EndsAt: &ended},
+		{Code: "PHUKET", Kind: model.PromoPercent, PercentOff: 5, Destinations: []string{"Phuket"}},
+		{Code: "HOTEL9", Kind: model.PromoPercent, PercentOff: 5, HotelIDs: []int{9}},
+		{Code: "LONG", Kind: model.PromoPercent, PercentOff: 5, MinNights: 5},
+		{Code: "BIGSPEND", Kind: model.PromoPercent, PercentOff: 5, MinSpendCents: 50000, Currency: "USD"},
+		{Code: "ONCE", Kind: model.PromoPercent, PercentOff: 5, MaxUsesPerUser: 1},
+		{Code: "LAST", Kind: model.PromoPercent, PercentOff: 5, MaxUses: 2},
+	}
+	ids := map[string]int{}
+	for _, c := range codes {
+		c.Active = true
+		p, err := promos.Create(c)
+		if err != nil {
+			t.Fatalf("create %s: %v", c.Code, err)
+		}
+		ids[p.Code] = p.ID
+	}
+	// a THB hotel in Bangkok at 3000 baht a night
+	hotel := model.Room{ID: 5, Destination: "Bangkok", PriceCents: 300000, Currency: "THB"}
+	checkIn := time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC)
+	// 2 nights, 1 room: 600000 satang
+	stay := service.Stay{UserID: 7, Hotel: hotel, CheckIn: checkIn, CheckOut: checkIn.AddDate(0, 0, 2), Rooms: 1}
+
+	got, err := promos.Apply([]string{"ten", "TwentyUSD", "TEN"}, stay)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(got) != 2 || got[0].DiscountCents != 60000 || got[1].AmountOffCents != 73000 || got[1].DiscountCents != 73000 {
+		t.Fatalf("unexpected promos: %+v", got)
+	}
+	if got, err := promos.Apply(nil, stay); err != nil || len(got) != 0 {
+		t.Fatalf("no codes: %v %v", got, err)
+	}
+
+	rejected := []struct {
+		codes  []string
+		reason string
+	}{
+		{[]string{"NOPE"}, "not valid"},
+		{[]string{"OLD"}, "not valid"},
+		{[]string{"SOLO", "TEN"}, "cannot be combined"},
+		{[]string{"PHUKET"}, "destination"},
+		{[]string{"HOTEL9"}, "hotel"},
+		{[]string{"LONG"}, "at least 5 nights"},
+		{[]string{"BIGSPEND"}, "minimum spend"},
+	}
+	for _, c := range rejected {
+		_, err := promos.Apply(c.codes, stay)
+		var ve *service.ValidationError
+		if !errors.As(err, &ve) || !strings.Contains(strings.Join(ve.Problems, "; "), c.reason) {
+			t.Errorf("%v: expected %q, got %v", c.codes, c.reason, err)
+		}
+	}
+
+	// per-user limits count only the guest's own bookings
+	repo.RecordUse(ids["ONCE"], 7)
+	if _, err := promos.Apply([]string{"ONCE"}, stay); err == nil {
+		t.Fatalf("expected ONCE to be used up for user 7")
+	}
+	other := stay
+	other.UserID = 8
+	if _, err := promos.Apply([]string{"ONCE"}, other); err != nil {
+		t.Fatalf("user 8: %v", err)
+	}
+
+	repo.RecordUse(ids["LAST"], 1)
+	if _, err := promos.Apply([]string{"LAST"}, stay); err != nil {
+		t.Fatalf("one use left: %v", err)
+	}
+	repo.RecordUse(ids["LAST"], 2)
+	if _, err := promos.Apply([]string{"LAST"}, other); err == nil {
+		t.Fatalf("expected LAST to be used up")
+	}
+}
+
+func TestPromoExcludesSaleRates(t *testing.T) {
+	now := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	currency := service.NewCurrencyServiceWithRepo(repository.NewInMemoryExchangeRateRepo(), "USD")
+	if err := currency.SetRates([]model.ExchangeRate{{Base: "USD", Quote: "THB", Rate: "36.5"}}); err != nil {
+		t.Fatalf("set rates: %v", err)
+	}
+	repo := repository.NewInMemoryPromoRepo()
+	promos := service.NewPromoServiceWith(repo, currency, func() time.Time { return now })
+	if _, err := promos.Create(model.PromoCode{Code: "FULLPRICE", Kind: model.PromoPercent, PercentOff: 10, ExcludeSaleRates: true, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	original := 400000
+	hotel := model.Room{ID: 5, Destination: "Bangkok", PriceCents: 300000, Currency: "THB", OriginalPriceCents: &original}
+	checkIn := now.AddDate(0, 1, 0)
+	stay := service.Stay{Hotel: hotel, CheckIn: checkIn, CheckOut: checkIn.AddDate(0, 0, 1), Rooms: 1}
+	if _, err := promos.Apply([]string{"FULLPRICE"}, stay); err == nil {
+		t.Fatalf("expected sale hotel to be excluded")
+	}
+	stay.Hotel.OriginalPriceCents = nil
+	if _, err := promos.Apply([]string{"FULLPRICE"}, stay); err != nil {
+		t.Fatalf("full price hotel: %v", err)
+	}
+}
+
+func TestPromoRequalify(t *testing.T) {
+	now := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	currency := service.NewCurrencyServiceWithRepo(repository.NewInMemoryExchangeRateRepo(), "USD")
+	if err := currency.SetRates([]model.ExchangeRate{{Base: "USD", Quote: "THB", Rate: "36.5"}}); err != nil {
+		t.Fatalf("set rates: %v", err)
+	}
+	repo := repository.NewInMemoryPromoRepo()
+	promos := service.NewPromoServiceWith(repo, currency, func() time.Time { return now })
+	if _, err := promos.Create(model.PromoCode{Code: "LONG", Kind: model.PromoPercent, PercentOff: 5, MinNights: 3, Stackable: true, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	once, err := promos.Create(model.PromoCode{Code: "ONCE", Kind: model.PromoPercent, PercentOff: 5, MaxUses: 1, Stackable: true, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hotel := model.Room{ID: 5, Destination: "Bangkok", PriceCents: 300000, Currency: "THB"}
+	checkIn := now.AddDate(0, 1, 0)
+	stay := service.Stay{UserID: 7, Hotel: hotel, CheckIn: checkIn, CheckOut: checkIn.AddDate(0, 0, 3), Rooms: 1}
+	applied, err := promos.Apply([]string{"LONG", "ONCE"}, stay)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	// the booking's own use doesn't count against it
+	repo.RecordUse(once.ID, 7)
+	if err := promos.Requalify(applied, stay); err != nil {
+		t.Fatalf("unchanged stay should still qualify: %v", err)
+	}
+	stay.CheckOut = checkIn.AddDate(0, 0, 2)
+	err = promos.Requalify(applied, stay)
+	var ve *service.ValidationError
+	if !errors.As(err, &ve) || len(ve.Problems) != 1 || !strings.Contains(ve.Problems[0], "LONG") {
+		t.Fatalf("expected LONG to no longer qualify, got %v", err)
+	}
+}
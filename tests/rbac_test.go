@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"testing"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func TestPermissionCovers(t *testing.T) {
+	cases := []struct {
+		granted, required string
+		want              bool
+	}{
+		{"*", "hotels:write", true},
+		{"hotels:write", "hotels:write", true},
+		{"hotels:write", "hotels:write:own", true},
+		{"hotels:write:own", "hotels:write", false},
+		{"bookings:*", "bookings:read:any", true},
+		{"bookings:read:any", "bookings:read:own", true},
+		{"bookings:read:own", "bookings:read:any", false},
+		{"hotels:write", "hotels:writer", false},
+	}
+	for _, tc := range cases {
+		if got := service.PermissionCovers(tc.granted, tc.required); got != tc.want {
+			t.Fatalf("PermissionCovers(%q, %q) = %v, want %v", tc.granted, tc.required, got, tc.want)
+		}
+	}
+}
+
+func TestRBACMultipleRoles(t *testing.T) {
+	s := service.NewRBACServiceWithRepo(repository.NewInMemoryRBACRepo())
+	const uid = 7
+	if s.Has(uid, model.RoleUser, model.PermBookingsReadAny) {
+		t.Fatalf("plain user must not read any booking")
+	}
+	if err := s.AssignRole(uid, "support"); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if !s.Has(uid, model.RoleUser, model.PermBookingsReadAny) || !s.Has(uid, model.RoleUser, model.PermReviewsModerate) {
+		t.Fatalf("support role permissions not granted")
+	}
+	if _, err := s.SaveRole(model.Role{Name: "auditor", Permissions: []string{"rates:write"}}); err != nil {
+		t.Fatalf("save role: %v", err)
+	}
+	if err := s.AssignRole(uid, "auditor"); err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if !s.Has(uid, model.RoleUser, model.PermRatesWrite) {
+		t.Fatalf("custom role permission not granted")
+	}
+	if err := s.RevokeRole(uid, "support"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if s.Has(uid, model.RoleUser, model.PermBookingsReadAny) {
+		t.Fatalf("revoked role still grants permission")
+	}
+	if err := s.DeleteRole("support"); err != service.ErrBuiltInRole {
+		t.Fatalf("expected ErrBuiltInRole, got %v", err)
+	}
+	if _, err := s.SaveRole(model.Role{Name: "Admin", Permissions: []string{model.PermRatesWrite}}); err != service.ErrBuiltInRole {
+		t.Fatalf("expected built-in admin to be refused, got %v", err)
+	}
+	if !s.Has(1, model.RoleAdmin, model.PermAll) {
+		t.Fatalf("admin lost its permissions")
+	}
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"testing"
+
+	"agodrift/internal/refcode"
+	"agodrift/internal/service"
+)
+
+func TestReferenceCodes(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		code, err := refcode.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 10 || !refcode.Valid(code) {
+			t.Fatalf("generated invalid code %q", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) < 990 {
+		t.Fatalf("codes repeat too often: %d distinct of 1000", len(seen))
+	}
+
+	if !refcode.Valid("AGD-7K3QX7") {
+		t.Fatal("known good code rejected")
+	}
+	// every single-character typo is caught
+	for i := len(refcode.Prefix); i < 10; i++ {
+		for _, c := range "0123456789ABCDEFGHJKMNPQRSTVWXYZ" {
+			code := []byte("AGD-7K3QX7")
+			if code[i] == byte(c) {
+				continue
+			}
+			code[i] = byte(c)
+			if refcode.Valid(string(code)) {
+				t.Fatalf("typo %q accepted", code)
+			}
+		}
+	}
+	if refcode.Valid("AGD-7K3XQ7") {
+		t.Fatal("swapped characters accepted")
+	}
+
+	for _, in := range []string{"agd-7k3qx7", "7K3QX7", "AGD 7K3 QX7", "agd7k3qx7"} {
+		if got := refcode.Normalize(in); got != "AGD-7K3QX7" {
+			t.Fatalf("Normalize(%q) = %q", in, got)
+		}
+	}
+	if got := refcode.Normalize("AGD-4MB2WK"); got != "AGD-4MB2WK" || !refcode.Valid(got) {
+		t.Fatalf("seed code %q", got)
+	}
+}
+
+func TestMatchesLastName(t *testing.T) {
+	cases := []struct {
+		full, last string
+		want       bool
+	}{
+		{"Alice Traveler", "traveler", true},
+		{"Anna van der Berg", "Berg", true},
+		{"Anna van der Berg", "van  der berg", true},
+		{"Alice Traveler", "Alice", false},
+		{"Alice Traveler", "eler", false},
+		{"Alice Traveler", " ", false},
+	}
+	for _, tc := range cases {
+		if got := service.MatchesLastName(tc.full, tc.last); got != tc.want {
+			t.Fatalf("MatchesLastName(%q, %q) = %v", tc.full, tc.last, got)
+		}
+	}
+}
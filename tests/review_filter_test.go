@@ -0,0 +1,32 @@
+package tests
+
+import (
+	"testing"
+
+	"agodrift/internal/service"
+)
+
+func TestReviewFilterFlags(t *testing.T) {
+	f := service.NewReviewFilter()
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"Lovely stay, great breakfast and friendly staff.", nil},
+		{"The room was shit.", []string{service.FlagProfanity}},
+		{"Mail me at guest@example.com for photos", []string{service.FlagEmail}},
+		{"Call +66 81 234 5678 for a better rate", []string{service.FlagPhone}},
+		{"Book direct at www.cheaprooms.example", []string{service.FlagURL}},
+	}
+	for _, tc := range cases {
+		got := f.Check(tc.text)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%q: expected flags %v, got %v", tc.text, tc.want, got)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%q: expected flags %v, got %v", tc.text, tc.want, got)
+			}
+		}
+	}
+}
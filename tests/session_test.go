@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+)
+
+func sessionClaims(t *testing.T, auth *service.AuthService, token string) jwt.MapClaims {
+	t.Helper()
+	tok, err := auth.ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok.Claims.(jwt.MapClaims)
+}
+
+func TestSessions(t *testing.T) {
+	now := time.Now()
+	sessions := service.NewSessionService(repository.NewInMemorySessionRepo(), func() time.Time { return now })
+	auth := service.NewAuthServiceWith(repository.NewInMemoryUserRepo(), service.NewHMACKeySet([]byte("test")), sessions)
+	u := model.User{ID: 2, Email: "alice@example.com", Role: model.RoleUser}
+
+	phone, _ := auth.StartSession(u, time.Hour, service.SessionClient{UserAgent: "iPhone", IP: "10.0.0.1"})
+	now = now.Add(time.Second)
+	laptop, _ := auth.StartSession(u, time.Hour, service.SessionClient{UserAgent: "Firefox", IP: "10.0.0.2"})
+	now = now.Add(time.Second)
+	tablet, _ := auth.StartSession(u, time.Hour, service.SessionClient{UserAgent: "iPad", IP: "10.0.0.3"})
+	other, _ := auth.StartSession(model.User{ID: 3}, time.Hour, service.SessionClient{})
+
+	phoneClaims := sessionClaims(t, auth, phone)
+	laptopClaims := sessionClaims(t, auth, laptop)
+	tabletClaims := sessionClaims(t, auth, tablet)
+	current := laptopClaims["jti"].(string)
+
+	list, err := sessions.List(u.ID, current)
+	if err != nil || len(list) != 3 {
+		t.Fatalf("want 3 sessions, got %d %v", len(list), err)
+	}
+	if list[0].UserAgent != "iPad" || !list[1].Current || list[1].IP != "10.0.0.2" {
+		t.Fatalf("unexpected listing %+v", list)
+	}
+
+	// last seen is refreshed at most once a minute
+	now = now.Add(2 * time.Minute)
+	if auth.IsRevoked(laptopClaims) {
+		t.Fatal("active session rejected")
+	}
+	list, _ = sessions.List(u.ID, current)
+	if !list[1].LastSeenAt.Equal(now) {
+		t.Fatalf("last seen not updated: %v", list[1].LastSeenAt)
+	}
+
+	if err := sessions.Revoke(3, tabletClaims["jti"].(string)); err != service.ErrSessionNotFound {
+		t.Fatal("users must not revoke each other's sessions")
+	}
+	if err := sessions.Revoke(u.ID, tabletClaims["jti"].(string)); err != nil {
+		t.Fatal(err)
+	}
+	if !auth.IsRevoked(tabletClaims) {
+		t.Fatal("revoked session still accepted")
+	}
+	if n, err := sessions.RevokeOthers(u.ID, current); err != nil || n != 1 {
+		t.Fatalf("want 1 other session revoked, got %d %v", n, err)
+	}
+	if !auth.IsRevoked(phoneClaims) || auth.IsRevoked(laptopClaims) {
+		t.Fatal("revoke-others should keep only the current session")
+	}
+	if auth.IsRevoked(sessionClaims(t, auth, other)) {
+		t.Fatal("other users' sessions must be untouched")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if !auth.IsRevoked(laptopClaims) {
+		t.Fatal("expired session accepted")
+	}
+	if !auth.IsRevoked(jwt.MapClaims{"jti": "unknown"}) {
+		t.Fatal("tokens without a session must be rejected")
+	}
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"agodrift/internal/model"
+	"agodrift/internal/repository"
+	"agodrift/internal/service"
+	"agodrift/internal/totp"
+)
+
+// RFC 6238 appendix B, SHA1, truncated to 6 digits
+func TestTOTPVectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, c := range cases {
+		got, err := totp.CodeAt(secret, totp.Step(time.Unix(c.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.code {
+			t.Errorf("t=%d: got %s want %s", c.unix, got, c.code)
+		}
+	}
+	if _, ok := totp.Validate(secret, "287082", time.Unix(59+30, 0), 1); !ok {
+		t.Error("code from previous step should be accepted within skew")
+	}
+	if _, ok := totp.Validate(secret, "287082", time.Unix(59+90, 0), 1); ok {
+		t.Error("code outside skew should be rejected")
+	}
+}
+
+func TestMFAFlow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+	auth := service.NewAuthServiceWithKeys(repository.NewInMemoryUserRepo(), service.NewHMACKeySet([]byte("test")))
+	svc := service.NewMFAServiceWith(repository.NewInMemoryMFARepo(), auth, "AgoDrift", []string{model.RoleAdmin}, clock)
+	u := model.User{ID: 7, Email: "a@example.com", Role: model.RoleUser}
+
+	if !svc.Required(model.RoleAdmin) || svc.Required(model.RoleUser) {
+		t.Fatal("only admin should require 2FA")
+	}
+	secret, uri, err := svc.Enroll(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri == "" {
+		t.Fatal("missing provisioning uri")
+	}
+	code := func() string {
+		c, err := totp.CodeAt(secret, totp.Step(now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	if _, err := svc.Activate(u.ID, "000000"); err != service.ErrInvalidMFACode {
+		t.Fatalf("wrong code should fail activation, got %v", err)
+	}
+	recovery, err := svc.Activate(u.ID, code())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovery) != 10 || !svc.Enabled(u.ID) {
+		t.Fatalf("expected enabled with 10 recovery codes, got %d", len(recovery))
+	}
+
+	// the activation code cannot be replayed
+	if err := svc.Verify(u.ID, code()); err != service.ErrInvalidMFACode {
+		t.Fatalf("replayed code accepted: %v", err)
+	}
+	now = now.Add(30 * time.Second)
+	if err := svc.Verify(u.ID, code()); err != nil {
+		t.Fatalf("fresh code rejected: %v", err)
+	}
+
+	if err := svc.Verify(u.ID, recovery[0]); err != nil {
+		t.Fatalf("recovery code rejected: %v", err)
+	}
+	if err := svc.Verify(u.ID, recovery[0]); err != service.ErrInvalidMFACode {
+		t.Fatal("recovery code should be single-use")
+	}
+
+	challenge, err := svc.Challenge(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.ParseToken(challenge); err != nil {
+		t.Fatal(err)
+	}
+	got, _, _, err := auth.ParseChallengeToken(challenge)
+	if err != nil || got.ID != u.ID {
+		t.Fatalf("challenge token: %v %+v", err, got)
+	}
+	access, _ := auth.CreateToken(u, time.Minute)
+	if _, _, _, err := auth.ParseChallengeToken(access); err == nil {
+		t.Fatal("access token accepted as challenge")
+	}
+
+	now = now.Add(30 * time.Second)
+	if err := svc.Disable(u, code()); err != nil {
+		t.Fatal(err)
+	}
+	if svc.Enabled(u.ID) {
+		t.Fatal("still enabled after disable")
+	}
+	if err := svc.Disable(model.User{ID: 1, Role: model.RoleAdmin}, "x"); err != service.ErrMFARequired {
+		t.Fatal("admin must not be able to disable 2FA")
+	}
+}